@@ -0,0 +1,64 @@
+package gogroup
+
+import (
+	"path"
+	"regexp"
+)
+
+// An AliasRule requires imports whose path matches Pattern to be imported
+// under the name Alias.
+type AliasRule struct {
+	Pattern *regexp.Regexp
+	Alias   string
+}
+
+// Aliases requires imports to be under particular names. Each import is
+// checked against the first rule whose pattern matches its path, and it's a
+// violation for it to be under any other name. It's also a violation for any
+// import to be under one of the forbidden names.
+//
+// An import without a name is taken to be under the last element of its path,
+// which is usually, but not always, the name of the package. Blank and dot
+// imports are exempt from the rules, though not from the forbidden names.
+func Aliases(rules []AliasRule, forbidden []string) Option {
+	return func(p *Processor) {
+		p.aliasRules = rules
+		p.forbiddenAliases = forbidden
+	}
+}
+
+// FixAliases determines whether repairs rename imports that aren't under the
+// name their alias rule requires. Only the import itself is renamed, not the
+// uses of the old name in the rest of the file, so the file won't compile
+// until they're renamed too. Imports under forbidden names are left alone.
+func FixAliases(enabled bool) Option {
+	return func(p *Processor) {
+		p.fixAliases = enabled
+	}
+}
+
+// Check the name of an import against the alias rules, noting the alias it's
+// missing, if any, and whether its name is forbidden.
+func (p *Processor) checkAlias(g *groupedImport) {
+	name := importName(g.spec)
+	for _, forbidden := range p.forbiddenAliases {
+		if name == forbidden {
+			g.forbiddenAlias = true
+		}
+	}
+
+	if name == "_" || name == "." {
+		return
+	}
+	if name == "" {
+		name = path.Base(g.path)
+	}
+	for _, rule := range p.aliasRules {
+		if rule.Pattern.MatchString(g.path) {
+			if name != rule.Alias {
+				g.wantAlias = rule.Alias
+			}
+			return
+		}
+	}
+}
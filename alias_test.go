@@ -0,0 +1,83 @@
+package gogroup
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var aliasRules = []AliasRule{
+	{regexp.MustCompile(`^k8s\.io/apimachinery/pkg/api/errors$`), "k8serrors"},
+	{regexp.MustCompile(`/proto/`), "pb"},
+	{regexp.MustCompile(`^github\.com/pkg/errors$`), "errors"},
+}
+
+func TestAliases(t *testing.T) {
+	t.Parallel()
+
+	text := `package main
+
+import (
+	ctx "context"
+	"os"
+
+	_ "github.com/corp/proto/init"
+	"github.com/corp/proto/users"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+`
+	proc := NewProcessor(grouperGoimports{}, Aliases(aliasRules, []string{"ctx"}))
+	errs, err := proc.ValidateAll("", strings.NewReader(text))
+	assert.Nil(t, err)
+	assert.Equal(t, []*ValidationError{{
+		Kind:       KindForbiddenAlias,
+		Line:       4,
+		ImportPath: "context",
+		Message:    "Import under a forbidden alias (ctx)",
+		Rule:       "statement-forbidden-alias",
+	}, {
+		Kind:       KindWrongAlias,
+		Line:       8,
+		ImportPath: "github.com/corp/proto/users",
+		Message:    "Import not under its required alias (expected pb)",
+		Rule:       "statement-alias",
+	}, {
+		Kind:       KindWrongAlias,
+		Line:       10,
+		ImportPath: "k8s.io/apimachinery/pkg/api/errors",
+		Message:    "Import not under its required alias (expected k8serrors)",
+		Rule:       "statement-alias",
+	}}, withoutPos(errs))
+
+	// Repairs only rename imports on request.
+	r, err := proc.Repair("", strings.NewReader(text))
+	assert.Nil(t, err)
+	assert.Equal(t, text, readAll(t, r))
+
+	fixer := NewProcessor(grouperGoimports{}, Aliases(aliasRules, []string{"ctx"}), FixAliases(true))
+	r, err = fixer.Repair("", strings.NewReader(text))
+	assert.Nil(t, err)
+	fixed := readAll(t, r)
+	assert.Equal(t, `package main
+
+import (
+	ctx "context"
+	"os"
+
+	_ "github.com/corp/proto/init"
+	pb "github.com/corp/proto/users"
+	"github.com/pkg/errors"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+)
+`, fixed)
+
+	// Forbidden names can't be fixed.
+	errs, err = fixer.ValidateAll("", strings.NewReader(fixed))
+	assert.Nil(t, err)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "statement-forbidden-alias", errs[0].Rule)
+	}
+}
@@ -0,0 +1,87 @@
+package gogroup
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// NewAnalyzer creates an analyzer that reports incorrect import grouping,
+// for use with go vet and other analysis drivers.
+//
+// The analyzer is named "gogroup". Diagnostics include a suggested fix that
+// repairs the import grouping.
+func NewAnalyzer(grouper Grouper) *analysis.Analyzer {
+	p := NewProcessor(grouper)
+	return &analysis.Analyzer{
+		Name: "gogroup",
+		Doc:  "check that import statements are correctly grouped and sorted",
+		Run:  p.analyze,
+	}
+}
+
+// Run the analyzer on a package.
+func (p *Processor) analyze(pass *analysis.Pass) (interface{}, error) {
+	for _, f := range pass.Files {
+		file := pass.Fset.File(f.Pos())
+		if file == nil {
+			continue
+		}
+
+		// Analyzers don't get access to the source, so read it ourselves.
+		src, err := ioutil.ReadFile(file.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		validErr, err := p.Validate(file.Name(), bytes.NewReader(src))
+		if err != nil {
+			return nil, err
+		}
+		if validErr == nil {
+			continue
+		}
+
+		diag := analysis.Diagnostic{
+			Pos:      file.LineStart(validErr.Line),
+			Category: validErr.ID(),
+			Message: fmt.Sprintf("%s: %s", validErr.Message,
+				strconv.Quote(validErr.ImportPath)),
+		}
+
+		block, err := p.RepairBlock(file.Name(), bytes.NewReader(src))
+		if err != nil {
+			return nil, err
+		}
+		if block != nil {
+			text := strings.Join(block.Fixed, "\n")
+			if block.EndLine < file.LineCount() || bytes.HasSuffix(src, []byte("\n")) {
+				text += "\n"
+			}
+			diag.SuggestedFixes = []analysis.SuggestedFix{{
+				Message: "Fix import grouping",
+				TextEdits: []analysis.TextEdit{{
+					Pos:     file.LineStart(block.StartLine),
+					End:     lineEnd(file, block.EndLine),
+					NewText: []byte(text),
+				}},
+			}}
+		}
+
+		pass.Report(diag)
+	}
+	return nil, nil
+}
+
+// Find the position just after the end of a line, including its line ending.
+func lineEnd(file *token.File, line int) token.Pos {
+	if line < file.LineCount() {
+		return file.LineStart(line + 1)
+	}
+	return token.Pos(file.Base() + file.Size())
+}
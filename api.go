@@ -10,9 +10,28 @@
 // - Import statements within the same group have no empty lines between them.
 // - Between two groups is an empty line.
 // - Within a group, statements are sorted by path.
+//
+// The simplest way to fix a file is with Source, which groups standard library
+// imports before all others, like goimports:
+//
+//	fixed, err := gogroup.Source(src)
+//
+// For other orders, pass a Grouper with WithGrouper, or create a Processor for
+// more control.
 package gogroup
 
-import "io"
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"math"
+	"regexp"
+	"strings"
+)
 
 // A Grouper determines groupings of import statements.
 type Grouper interface {
@@ -25,27 +44,432 @@ type Grouper interface {
 	// be in a group after another import statement, its group number should be higher.
 	// Otherwise, group numbers are arbitrary. Gaps between group numbers are explicitly
 	// allowed.
+	//
+	// The output may also be GroupIgnore, to leave an import out of grouping.
 	Group(pkgPath string) (group int)
 }
 
+// A StrictGrouper is a Grouper that may assign an import to no group at all.
+// Each such import is a violation, since it's unclear where it belongs.
+// Otherwise it's skipped like an import with a keep directive, and repairs
+// leave it where it is.
+type StrictGrouper interface {
+	Grouper
+
+	// LookupGroup determines the import group that an import statement should
+	// be in, like Group, along with whether it belongs in any group.
+	LookupGroup(pkgPath string) (group int, ok bool)
+}
+
+// GroupIgnore is a group number a Grouper can yield for an import that may go
+// anywhere. Such an import is treated like one with a keep directive, even if
+// directives are ignored: validation skips it, and repairs leave it where it
+// is. Between two groups, it serves to separate them, with or without empty
+// lines around it. Within a group, its lines are skipped as if they weren't
+// there.
+const GroupIgnore = math.MinInt32
+
+// A GroupNamer is a Grouper that can also name its groups, so that messages
+// about incorrect grouping can refer to groups by name.
+type GroupNamer interface {
+	Grouper
+
+	// GroupName yields the name of a group number, or the empty string if the
+	// group has no name.
+	GroupName(group int) string
+}
+
+// An Explainer is a Grouper that can also say which of its rules assigned an
+// import its group, for people wondering why.
+type Explainer interface {
+	Grouper
+
+	// Explain describes the rule that assigned an import path its group, or
+	// yields the empty string if it matched none.
+	Explain(pkgPath string) string
+}
+
 // Processor processes files according to import grouping rules.
+//
+// A file containing the comment "//group-imports:ignore" before its import
+// declarations is ignored: it's always considered valid, and is never
+// rewritten. An import with a trailing "//group-imports:keep" comment is kept
+// in place: validation skips it as if its lines weren't there, and repairs
+// leave it unchanged while grouping the other imports around it.
+//
+// As with golangci-lint, an import with a "//nolint:gogroup" comment at the
+// end of its line or on the line above, or one listing gogroup among other
+// linters such as "//nolint:lll,gogroup", has its violations suppressed: they
+// aren't reported, but are counted apart, in FileResult.Suppressed. Repairs
+// leave such an import in place, like a kept one, so they don't undo what the
+// comment allows. See IgnoreDirectives to disable these directives.
 type Processor struct {
 	grouper Grouper
+
+	minimalPatch      bool
+	ignoreDirectives  bool
+	sortMode          SortMode
+	blockStyle        BlockStyle
+	lenient           bool
+	headers           map[int]string
+	stripComments     *regexp.Regexp
+	duplicates        bool
+	aliasRules        []AliasRule
+	forbiddenAliases  []string
+	fixAliases        bool
+	allowRelative     bool
+	denyRules         []DenyRule
+	layerRules        []LayerRule
+	commentSeparators bool
+	keepIndentation   bool
+	cacheKey          string
+	rules             []Rule
+	disabled          map[string]bool
+	fixOnly, noFix    map[string]bool
+	severities        map[string]Severity
+	timings           *Timings
+	logger            *slog.Logger
+}
+
+// An Option configures optional behavior of a Processor.
+type Option func(*Processor)
+
+// MinimalPatch determines whether Reformat must leave everything outside of
+// the import declarations byte-for-byte unchanged. Repair always does so.
+//
+// With this option, Reformat still uses goimports to add and remove imports,
+// but discards any formatting changes it makes elsewhere in the file.
+func MinimalPatch(enabled bool) Option {
+	return func(p *Processor) {
+		p.minimalPatch = enabled
+	}
+}
+
+// KeepIndentation determines whether repairs keep the indentation of import
+// lines as it is, rather than indenting them with a single tab as gofmt does.
+// Lines that repairs change otherwise, such as those of renamed imports, are
+// still indented with a tab.
+//
+// Repairs only rewrite the import declarations of files with violations, so
+// files that are only indented unusually are valid either way.
+func KeepIndentation(enabled bool) Option {
+	return func(p *Processor) {
+		p.keepIndentation = enabled
+	}
+}
+
+// IgnoreDirectives determines whether directive comments in source files,
+// such as "//group-imports:ignore" or "//nolint:gogroup", are themselves
+// ignored. This is useful to enforce import grouping everywhere.
+func IgnoreDirectives(ignore bool) Option {
+	return func(p *Processor) {
+		p.ignoreDirectives = ignore
+	}
+}
+
+// SortMode determines how imports are ordered within a group.
+type SortMode int
+
+const (
+	// SortAlpha orders imports alphabetically by path, like gofmt.
+	SortAlpha SortMode = iota
+	// SortDepthThenAlpha orders imports with fewer path elements first, and
+	// alphabetically by path among those with the same number.
+	SortDepthThenAlpha
+	// SortNatural orders imports alphabetically by path, except that runs of
+	// digits compare as numbers, so that "gopkg.in/redis.v3" comes before
+	// "gopkg.in/redis.v10".
+	SortNatural
+)
+
+var sortModeNames = map[SortMode]string{
+	SortAlpha:          "alpha",
+	SortDepthThenAlpha: "depth-then-alpha",
+	SortNatural:        "natural",
+}
+
+func (m SortMode) String() string {
+	return sortModeNames[m]
+}
+
+// SortModeNames yields the names of the sort modes, in order.
+func SortModeNames() []string {
+	return []string{SortAlpha.String(), SortDepthThenAlpha.String(), SortNatural.String()}
+}
+
+// ParseSortMode yields the sort mode with the given name.
+func ParseSortMode(name string) (SortMode, error) {
+	for mode, known := range sortModeNames {
+		if name == known {
+			return mode, nil
+		}
+	}
+	return SortAlpha, fmt.Errorf("Unknown sort mode '%s', expected one of: %s", name,
+		strings.Join(SortModeNames(), ", "))
+}
+
+// The key to compare an import path by, within its group.
+func (m SortMode) key(path string) string {
+	switch m {
+	case SortDepthThenAlpha:
+		return fmt.Sprintf("%08d/%s", strings.Count(path, "/"), path)
+	case SortNatural:
+		return naturalKey(path)
+	}
+	return path
+}
+
+// Yield a key for natural ordering, where each run of digits is replaced by
+// its length and then its value without leading zeros. Longer numbers are
+// larger, so the length decides first. Paths that differ only in leading
+// zeros are ordered by the path itself.
+func naturalKey(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); {
+		if path[i] < '0' || path[i] > '9' {
+			b.WriteByte(path[i])
+			i++
+			continue
+		}
+		j := i
+		for j < len(path) && path[j] >= '0' && path[j] <= '9' {
+			j++
+		}
+		digits := strings.TrimLeft(path[i:j], "0")
+		if digits == "" {
+			digits = "0"
+		}
+		fmt.Fprintf(&b, "%08d%s", len(digits), digits)
+		i = j
+	}
+	return b.String() + "\x00" + path
+}
+
+// Sort determines how imports are ordered within a group, both when
+// validating and when repairing. The default is SortAlpha.
+func Sort(mode SortMode) Option {
+	return func(p *Processor) {
+		p.sortMode = mode
+	}
+}
+
+// Lenient determines whether validation allows imports of different groups to
+// be adjacent, without an empty line between them, as long as the groups are
+// in the right order. An empty line is still allowed, but more than one is
+// not. Repairs still separate groups with empty lines.
+func Lenient(enabled bool) Option {
+	return func(p *Processor) {
+		p.lenient = enabled
+	}
+}
+
+// CommentSeparators determines whether a line holding only a comment, such as
+// "// --- third party ---", may separate groups instead of an empty line.
+// Such comments are those right before the first import of a group. Repairs
+// keep them between the same groups, rather than replacing them with empty
+// lines, while groups without one are still separated by an empty line.
+func CommentSeparators(enabled bool) Option {
+	return func(p *Processor) {
+		p.commentSeparators = enabled
+	}
+}
+
+// GroupHeaders requires each group with an entry in headers to start with
+// that header comment, such as "// Standard library". Validation checks that
+// the first line of each such group is its header, and repairs insert or
+// correct headers.
+//
+// A header is the first line of the comments before the first import of a
+// group, or with CommentSeparators, may follow the separator comments. Any
+// other comments there are the import's own, and stay between the header and
+// the import. A header anywhere else is a violation.
+func GroupHeaders(headers map[int]string) Option {
+	return func(p *Processor) {
+		p.headers = headers
+	}
+}
+
+// StripComments removes comments matching pattern from the import
+// declarations, such as banners between groups. The pattern is matched
+// against the whole text of each comment, including the // or /*. Each such
+// comment is a violation, and repairs remove it before regrouping.
+//
+// An import's own doc comment is never stripped. Of the comments right before
+// an import, only those before the first that doesn't match are stripped, so
+// a banner above an import's doc comment goes while the doc stays. Line
+// comments at the end of an import are never stripped, nor are group headers.
+func StripComments(pattern *regexp.Regexp) Option {
+	return func(p *Processor) {
+		p.stripComments = pattern
+	}
+}
+
+// Duplicates determines whether importing the same path more than once in a
+// file is a violation, even under different names. Repairs remove an import
+// that repeats an earlier one exactly, with the same name and no comments of
+// its own, but leave imports under different names for a human to resolve.
+func Duplicates(enabled bool) Option {
+	return func(p *Processor) {
+		p.duplicates = enabled
+	}
+}
+
+// AllowRelativeImports determines whether relative import paths, such as
+// "./util", are allowed. By default, each is a violation, since they don't
+// work with modules. A disallowed relative import is otherwise kept in place,
+// so that it causes no other violations. If allowed, it's grouped like any
+// other import.
+func AllowRelativeImports(allow bool) Option {
+	return func(p *Processor) {
+		p.allowRelative = allow
+	}
+}
+
+// BlockStyle determines whether imports must be in a parenthesized import
+// declaration.
+type BlockStyle int
+
+const (
+	// BlockAny allows any style of import declaration.
+	BlockAny BlockStyle = iota
+	// BlockParenthesized requires imports to be in a parenthesized
+	// declaration, even if there's just one.
+	BlockParenthesized
+	// BlockPlain requires a lone import to be in a plain declaration, such as
+	// `import "os"`, rather than a parenthesized one.
+	BlockPlain
+)
+
+var blockStyleNames = map[BlockStyle]string{
+	BlockAny:           "any",
+	BlockParenthesized: "parenthesized",
+	BlockPlain:         "plain",
+}
+
+func (s BlockStyle) String() string {
+	return blockStyleNames[s]
+}
+
+// ParseBlockStyle yields the block style with the given name.
+func ParseBlockStyle(name string) (BlockStyle, error) {
+	for style, known := range blockStyleNames {
+		if name == known {
+			return style, nil
+		}
+	}
+	return BlockAny, fmt.Errorf("Unknown block style '%s', expected one of: any, parenthesized, plain", name)
+}
+
+// Blocks determines the required style of import declarations, both when
+// validating and when repairing. Files without imports, or that import "C",
+// always satisfy any style. The default is BlockAny.
+func Blocks(style BlockStyle) Option {
+	return func(p *Processor) {
+		p.blockStyle = style
+	}
+}
+
+// WithGrouper sets the Grouper used to group imports, overriding the one
+// passed to NewProcessor. It's mostly useful with Source.
+func WithGrouper(grouper Grouper) Option {
+	return func(p *Processor) {
+		p.grouper = grouper
+	}
+}
+
+// CacheKey distinguishes the files a processor finds valid in a Cache from
+// those other processors sharing the Cache find valid. It's needed when
+// processors are configured differently, such as with the configurations of
+// different directories, but the Cache is opened with a single configuration.
+func CacheKey(key string) Option {
+	return func(p *Processor) {
+		p.cacheKey = key
+	}
 }
 
 // NewProcessor creates a new Processor with a given group definition.
-func NewProcessor(grouper Grouper) *Processor {
-	return &Processor{grouper}
+func NewProcessor(grouper Grouper, opts ...Option) *Processor {
+	p := &Processor{grouper: grouper}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
 }
 
 // ValidationError is an error about incorrect import grouping.
 type ValidationError struct {
-	// Line is the line of the file at which the error occurred.
+	// Kind identifies what's wrong. Code should check it, or use errors.Is
+	// with the sentinel of a kind, rather than matching Message.
+	Kind Kind
+	// Line is the one-based line of the file at which the error occurred.
+	// For an import with doc comments, it's the line of the first comment.
+	// It was zero-based in earlier versions, so callers that added one to it
+	// must no longer do so.
 	Line int
+	// Pos is the position of what's wrong: of the import itself, of the
+	// first superfluous empty line, or of the comment to strip. It's the
+	// physical position in the file, disregarding //line directives, and it's
+	// the zero Position if unknown.
+	Pos token.Position
+	// End is the position just after what's wrong: after the import, which
+	// doesn't include its comments, before the line after the superfluous
+	// empty lines, or after the comment to strip. Like Pos, it's physical,
+	// and its Offset is a byte offset as in TextEdit, so violations and the
+	// edits that fix them can be correlated. It's the zero Position if
+	// unknown.
+	End token.Position
 	// ImportPath is the path being imported.
 	ImportPath string
-	// Message is a description of why this was an error.
+	// Message is a description of why this was an error, for display only.
 	Message string
+	// Rule is a short identifier for the kind of error, such as
+	// "statement-order". It's the same as Kind.Rule(), or for a violation of
+	// a Rule added to the Processor, the ID of the rule unless it sets one.
+	Rule string
+	// Severity is how serious the violation is, as configured by Severities
+	// for its rule.
+	Severity Severity
+
+	// ExpectedGroup is the name of the group the import belongs in, and
+	// FoundGroup is the name of the group it was found in. They are only set
+	// for imports in an incorrect group, when the Grouper is a GroupNamer
+	// that names both groups.
+	ExpectedGroup string
+	FoundGroup    string
+
+	// The ID of the added Rule that found the violation, if any.
+	ruleID string
+}
+
+// ErrNoPackageClause is the error wrapped by a ParseError when a file isn't
+// Go source at all, because it has no package clause. This includes empty
+// files, and files containing only comments.
+var ErrNoPackageClause = errors.New("file contains no package clause")
+
+// ParseError is an error due to a source file not being valid Go. Other
+// errors, such as failures to read a file, are not wrapped.
+type ParseError struct {
+	// FileName is the name of the file that failed to parse.
+	FileName string
+	// Line and Column are the one-based position of the error, or zero if the
+	// error has no position.
+	Line, Column int
+	// Err is the underlying error. It's ErrNoPackageClause if there's no
+	// package clause, or otherwise the first error from the parser.
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	if e.Line > 0 {
+		// Parser errors already include the position.
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %s", e.FileName, e.Err.Error())
+}
+
+// Unwrap yields the underlying error.
+func (e *ParseError) Unwrap() error {
+	return e.Err
 }
 
 // Validate determines whether the existing import grouping of a source file is
@@ -61,6 +485,12 @@ func (p *Processor) Validate(fileName string, r io.Reader) (validErr *Validation
 	return p.validate(fileName, r)
 }
 
+// ValidateAll is like Validate, but yields every violation in the file rather
+// than just the first one. If the grouping is correct, the result is empty.
+func (p *Processor) ValidateAll(fileName string, r io.Reader) ([]*ValidationError, error) {
+	return p.validateAll(fileName, r)
+}
+
 // Repair repairs the import grouping of a source file.
 //
 // If no repairs are necessary, a nil io.Reader will be returned. If repairs
@@ -73,6 +503,143 @@ func (p *Processor) Repair(fileName string, r io.Reader) (io.Reader, error) {
 	return p.repair(fileName, r)
 }
 
+// ValidateWith is like Validate, but groups imports with the given Grouper
+// for this call only, as if the processor had been created with it. The
+// processor itself is unchanged, so calls with different groupers may run
+// concurrently. A nil grouper means the processor's own.
+//
+// Headers given with GroupHeaders are for the groups of the processor's own
+// grouper, so they aren't required with another grouper.
+func (p *Processor) ValidateWith(g Grouper, fileName string, r io.Reader) (*ValidationError, error) {
+	return p.withGrouper(g).validate(fileName, r)
+}
+
+// ValidateAllWith is like ValidateAll, but with a Grouper for this call only,
+// as ValidateWith takes.
+func (p *Processor) ValidateAllWith(g Grouper, fileName string, r io.Reader) ([]*ValidationError, error) {
+	return p.withGrouper(g).validateAll(fileName, r)
+}
+
+// RepairWith is like Repair, but with a Grouper for this call only, as
+// ValidateWith takes.
+func (p *Processor) RepairWith(g Grouper, fileName string, r io.Reader) (io.Reader, error) {
+	return p.withGrouper(g).repair(fileName, r)
+}
+
+// Yield a processor like this one, but with a different grouper, or this one
+// if the grouper is nil. The copy is shallow, and has no headers, since they
+// are by the group numbers of the original grouper.
+func (p *Processor) withGrouper(g Grouper) *Processor {
+	if g == nil {
+		return p
+	}
+	sub := *p
+	sub.grouper = g
+	sub.headers = nil
+	return &sub
+}
+
+// Source repairs the import grouping of Go source, and returns the fixed
+// source. If no repairs are necessary, src is returned unchanged.
+//
+// By default, imports are grouped as goimports groups them without local
+// prefixes, as by NewGoimportsGrouper. Use WithGrouper to choose another
+// grouping.
+func Source(src []byte, opts ...Option) ([]byte, error) {
+	p := NewProcessor(NewGoimportsGrouper(), opts...)
+	r, err := p.Repair("", bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		return src, nil
+	}
+	return ioutil.ReadAll(r)
+}
+
+// ImportBlock is the range of lines in a file that contain import statements,
+// along with the content those lines should have.
+type ImportBlock struct {
+	// StartLine and EndLine are the first and last lines of the block. Line
+	// numbers are one-based, and EndLine is inclusive.
+	StartLine, EndLine int
+	// Fixed are the lines that should replace the block, without line endings.
+	Fixed []string
+}
+
+// RepairBlock determines how to repair the import grouping of a source file,
+// without applying the repair.
+//
+// If no repairs are necessary, RepairBlock returns nil. Otherwise, replacing
+// the lines of the returned block with its Fixed lines yields the same content
+// as Repair would.
+//
+// The fileName parameter is needed for error reporting only. You may leave it
+// blank.
+func (p *Processor) RepairBlock(fileName string, r io.Reader) (*ImportBlock, error) {
+	return p.repairBlock(fileName, r)
+}
+
+// Edits determines how to repair the import grouping of a source file, as
+// edits to its content, without applying them. Each edit replaces whole lines,
+// and lines that don't change aren't included, so editors can apply them
+// without disturbing the rest of the file. The edits are in order, and don't
+// overlap. Applying them all to src yields the same content as Repair would.
+//
+// If no repairs are necessary, the result is empty.
+//
+// The fileName parameter is used in the positions of edits, and for error
+// reporting. You may leave it blank.
+func (p *Processor) Edits(fileName string, src []byte) ([]TextEdit, error) {
+	return p.edits(fileName, src)
+}
+
+// ImportInfo describes an import, as Layout arranges it.
+type ImportInfo struct {
+	// Path is the path being imported.
+	Path string
+	// Name is the name the import is under, such as an alias, "." or "_", or
+	// the empty string if it has none. It's the name repairs give it, which
+	// is its required alias if aliases are fixed.
+	Name string
+	// Group is the group the Grouper assigned the import to.
+	Group int
+	// Doc is the text of the comments right before the import, one per
+	// comment, such as "// Comment". Group headers, separator comments and
+	// comments to strip aren't included.
+	Doc []string
+	// Comment is the text of the comment at the end of the import's line, if
+	// any.
+	Comment string
+	// Kept is true if the import is kept in place, by a keep directive or
+	// because the Grouper ignores it.
+	Kept bool
+}
+
+// Layout determines how repairing a source file arranges its imports, without
+// making any changes. It yields the imports in groups, in the order repairs
+// put both groups and the imports within them. Redundant duplicate imports,
+// which repairs remove, are left out, and imports kept in place are in the
+// group they stay within.
+//
+// The layout is the same whether or not the file needs repairs. Ignored files
+// have no imports.
+//
+// The fileName parameter is needed for error reporting only. You may leave it
+// blank.
+func (p *Processor) Layout(fileName string, r io.Reader) ([][]ImportInfo, error) {
+	return p.layout(fileName, r)
+}
+
+// Ignored determines whether a source file is ignored, because it contains an
+// ignore directive.
+//
+// The fileName parameter is needed for error reporting only. You may leave it
+// blank.
+func (p *Processor) Ignored(fileName string, r io.Reader) (bool, error) {
+	return p.ignored(fileName, r)
+}
+
 // Reformat both formats the file with goimports, and repairs any import groupings.
 //
 // The fileName is necessary for determining missing imports.
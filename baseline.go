@@ -0,0 +1,175 @@
+package gogroup
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// BaselineVersion is the version of the baseline format that WriteBaseline
+// writes, and ReadBaseline accepts.
+const BaselineVersion = 1
+
+// A Baseline records the violations that a tree already has, so that they
+// can be tolerated while new ones are still reported, such as when adopting
+// an order in a large repository.
+//
+// Violations are identified by their file, rule and import path, rather than
+// by line, so that they're still recognized after unrelated changes move
+// them. Written as JSON, a baseline looks like:
+//
+//	{
+//	  "version": 1,
+//	  "violations": [
+//	    {"file": "pkg/a.go", "rule": "GI004", "import": "os", "count": 1}
+//	  ]
+//	}
+//
+// Entries are sorted by file, rule and import path, so that baselines can be
+// compared and reviewed like any other file.
+type Baseline struct {
+	// Version is BaselineVersion.
+	Version int `json:"version"`
+	// Entries are the tolerated violations.
+	Entries []BaselineEntry `json:"violations"`
+}
+
+// A BaselineEntry records the violations of a rule at an import of a file.
+type BaselineEntry struct {
+	// File is the slash-separated path of the file, relative to the working
+	// directory if it's within it.
+	File string `json:"file"`
+	// Rule is the ID of the rule the violations break, such as "GI004", or
+	// the name of the rule if it has no ID.
+	Rule string `json:"rule"`
+	// Import is the path of the import.
+	Import string `json:"import"`
+	// Count is how many such violations there are.
+	Count int `json:"count"`
+}
+
+func (e BaselineEntry) String() string {
+	return fmt.Sprintf("%s: %s at %q", e.File, e.Rule, e.Import)
+}
+
+// The key of a baseline entry.
+type baselineKey struct {
+	file, rule, imp string
+}
+
+// Yield the path of a file as a baseline records it.
+func baselinePath(path string) string {
+	return relativePath("", path)
+}
+
+// Yield the key of a violation in a file.
+func violationKey(path string, v *ValidationError) baselineKey {
+	rule := v.ID()
+	if rule == "" {
+		rule = v.Rule
+	}
+	return baselineKey{baselinePath(path), rule, v.ImportPath}
+}
+
+// NewBaseline records the violations of a report in a baseline.
+func NewBaseline(r *Report) *Baseline {
+	counts := map[baselineKey]int{}
+	for _, f := range r.Files {
+		for _, v := range f.Violations {
+			counts[violationKey(f.Path, v)]++
+		}
+	}
+	b := &Baseline{Version: BaselineVersion, Entries: []BaselineEntry{}}
+	for key, n := range counts {
+		b.Entries = append(b.Entries, BaselineEntry{File: key.file, Rule: key.rule, Import: key.imp, Count: n})
+	}
+	sort.Slice(b.Entries, func(i, j int) bool {
+		x, y := b.Entries[i], b.Entries[j]
+		if x.File != y.File {
+			return x.File < y.File
+		}
+		if x.Rule != y.Rule {
+			return x.Rule < y.Rule
+		}
+		return x.Import < y.Import
+	})
+	return b
+}
+
+// ReadBaseline reads a baseline written by WriteBaseline.
+func ReadBaseline(r io.Reader) (*Baseline, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	b := &Baseline{}
+	if err := dec.Decode(b); err != nil {
+		return nil, fmt.Errorf("Invalid baseline: %s", err.Error())
+	}
+	if b.Version != BaselineVersion {
+		return nil, fmt.Errorf("Unsupported baseline version %d, expected %d", b.Version, BaselineVersion)
+	}
+	return b, nil
+}
+
+// WriteBaseline writes a baseline to w as JSON.
+func (b *Baseline) WriteBaseline(w io.Writer) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// Apply moves the violations of a report that the baseline records from the
+// Violations of each file to its Baselined, so that only new violations are
+// left. Each entry tolerates up to its Count violations. Each file's first
+// violation is updated to match, and files left without violations have no
+// Fix.
+//
+// It yields the stale entries for files in the report: those with fewer
+// violations than they record, with the Count of violations that no longer
+// occur. Entries for other files are left alone, since they weren't checked.
+func (b *Baseline) Apply(r *Report) []BaselineEntry {
+	left := map[baselineKey]int{}
+	for _, e := range b.Entries {
+		left[baselineKey{e.File, e.Rule, e.Import}] += e.Count
+	}
+	checked := map[string]bool{}
+	for _, f := range r.Files {
+		checked[baselinePath(f.Path)] = true
+		kept := []*ValidationError{}
+		for _, v := range f.Violations {
+			key := violationKey(f.Path, v)
+			if left[key] > 0 {
+				left[key]--
+				f.Baselined = append(f.Baselined, v)
+			} else {
+				kept = append(kept, v)
+			}
+		}
+		if len(kept) == len(f.Violations) {
+			continue
+		}
+		f.Violations, f.Violation = kept, nil
+		if len(kept) > 0 {
+			f.Violation = kept[0]
+		} else {
+			f.Fix = nil
+		}
+	}
+
+	stale := []BaselineEntry{}
+	for _, e := range b.Entries {
+		key := baselineKey{e.File, e.Rule, e.Import}
+		if checked[e.File] && left[key] > 0 {
+			n := left[key]
+			if n > e.Count {
+				n = e.Count
+			}
+			left[key] -= n
+			stale = append(stale, BaselineEntry{File: e.File, Rule: e.Rule, Import: e.Import, Count: n})
+		}
+	}
+	return stale
+}
@@ -0,0 +1,80 @@
+package gogroup
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBaseline(t *testing.T) {
+	t.Parallel()
+
+	violation := func(kind Kind, path string) *ValidationError {
+		return &ValidationError{Kind: kind, Rule: kind.Rule(), ImportPath: path, Message: "Bad"}
+	}
+	a := []*ValidationError{violation(KindWrongGroup, "os"), violation(KindWrongGroup, "os"),
+		violation(KindStatementOrder, "fmt")}
+	b := []*ValidationError{violation(KindDuplicate, "io")}
+	report := &Report{Files: []*FileResult{
+		{Path: "pkg/a.go", Violation: a[0], Violations: a, Fix: &ImportBlock{}},
+		{Path: "pkg/b.go", Violation: b[0], Violations: b, Fix: &ImportBlock{}},
+		{Path: "pkg/c.go"},
+	}}
+	baseline := NewBaseline(report)
+	assert.Equal(t, []BaselineEntry{
+		{File: "pkg/a.go", Rule: "GI001", Import: "fmt", Count: 1},
+		{File: "pkg/a.go", Rule: "GI004", Import: "os", Count: 2},
+		{File: "pkg/b.go", Rule: "GI014", Import: "io", Count: 1},
+	}, baseline.Entries)
+
+	// Baselines round-trip, and are stable.
+	var buf bytes.Buffer
+	assert.Nil(t, baseline.WriteBaseline(&buf))
+	assert.True(t, strings.HasPrefix(buf.String(), "{\n  \"version\": 1,\n  \"violations\": [\n"))
+	read, err := ReadBaseline(bytes.NewReader(buf.Bytes()))
+	assert.Nil(t, err)
+	assert.Equal(t, baseline, read)
+	_, err = ReadBaseline(strings.NewReader(`{"version": 2, "violations": []}`))
+	assert.EqualError(t, err, "Unsupported baseline version 2, expected 1")
+	_, err = ReadBaseline(strings.NewReader(`{"version": 1, "entries": []}`))
+	assert.NotNil(t, err)
+
+	// Only violations beyond those recorded are left, and entries recording
+	// more than are found are stale.
+	a = append(a, violation(KindDenied, "unsafe"))
+	report = &Report{Files: []*FileResult{
+		{Path: "pkg/a.go", Violation: a[2], Violations: a[2:], Fix: &ImportBlock{}},
+		{Path: "pkg/c.go"},
+	}}
+	stale := baseline.Apply(report)
+	assert.Equal(t, []BaselineEntry{{File: "pkg/a.go", Rule: "GI004", Import: "os", Count: 2}}, stale)
+	res := report.Files[0]
+	assert.Equal(t, a[3:], res.Violations)
+	assert.Equal(t, a[3], res.Violation)
+	assert.Equal(t, a[2:3], res.Baselined)
+	assert.NotNil(t, res.Fix)
+	assert.Equal(t, 1, report.Violations())
+	assert.Equal(t, 1, report.Baselined())
+
+	// Files whose violations are all recorded are left without any.
+	report = &Report{Files: []*FileResult{{Path: "pkg/b.go", Violation: b[0], Violations: b, Fix: &ImportBlock{}}}}
+	assert.Equal(t, []BaselineEntry{}, baseline.Apply(report))
+	assert.Nil(t, report.Files[0].Violation)
+	assert.Nil(t, report.Files[0].Fix)
+	assert.Empty(t, report.Files[0].Violations)
+}
+
+func TestBaselinePath(t *testing.T) {
+	t.Parallel()
+
+	wd, err := os.Getwd()
+	assert.Nil(t, err)
+	assert.Equal(t, "pkg/a.go", baselinePath(filepath.Join(wd, "pkg", "a.go")))
+	assert.Equal(t, "pkg/a.go", baselinePath("./pkg//a.go"))
+	outside := filepath.Join(filepath.Dir(wd), "other", "a.go")
+	assert.Equal(t, filepath.ToSlash(outside), baselinePath(outside))
+}
@@ -0,0 +1,101 @@
+package gogroup
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// The version of the cache format. Changing it invalidates existing entries.
+const cacheVersion = "v1"
+
+// The content of a cache entry for a valid file.
+var cacheValid = []byte("valid\n")
+
+// Cache remembers which files have correct import grouping, so that they can
+// be skipped by later runs of ProcessFiles.
+//
+// Entries are keyed by a hash of the content of a file, and of a description
+// of the configuration. Where the configuration treats files differently by
+// their path, such as with layering rules, the key also covers which rules
+// apply to the file. Only correct files are remembered, so files with
+// violations are always processed again, and their violations reported in
+// full. Missing or corrupt entries just mean the file is processed, so the
+// cache never changes the outcome.
+type Cache struct {
+	// The directory holding entries for this version of the cache.
+	dir string
+
+	// The configuration, which is part of every key.
+	config string
+}
+
+// DefaultCacheDir yields the default cache directory, within the user's cache
+// directory.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "group-imports"), nil
+}
+
+// OpenCache opens a cache in a directory, creating it if necessary.
+//
+// The config must describe everything that affects whether a file is valid,
+// other than its content, such as the Grouper configuration and the Processor
+// options. Entries for other configurations are ignored.
+func OpenCache(dir string, config string) (*Cache, error) {
+	dir = filepath.Join(dir, cacheVersion)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Cache{dir: dir, config: config}, nil
+}
+
+// Find the path of the entry for some file content, within a scope of files
+// that the configuration treats alike.
+func (c *Cache) entryPath(src []byte, scope string) string {
+	h := sha256.New()
+	h.Write([]byte(c.config))
+	h.Write([]byte{0})
+	if scope != "" {
+		h.Write([]byte(scope))
+		h.Write([]byte{0})
+	}
+	h.Write(src)
+	key := hex.EncodeToString(h.Sum(nil))
+	return filepath.Join(c.dir, key[:2], key)
+}
+
+// Determine whether file content is known to be valid.
+func (c *Cache) valid(src []byte, scope string) bool {
+	data, err := ioutil.ReadFile(c.entryPath(src, scope))
+	return err == nil && bytes.Equal(data, cacheValid)
+}
+
+// Remember that file content is valid. Failures are ignored, since they just
+// mean the file is processed again next time.
+func (c *Cache) markValid(src []byte, scope string) {
+	path := c.entryPath(src, scope)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(cacheValid)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+}
@@ -0,0 +1,91 @@
+package gogroup
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	valid := "package a\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n"
+	invalid := "package a\n\nimport (\n\t\"os\"\n\t\"fmt\"\n)\n"
+	paths := []string{filepath.Join(dir, "a.go"), filepath.Join(dir, "b.go"), filepath.Join(dir, "c.go")}
+	assert.Nil(t, ioutil.WriteFile(paths[0], []byte(valid), 0644))
+	assert.Nil(t, ioutil.WriteFile(paths[1], []byte(valid+"// b\n"), 0644))
+	assert.Nil(t, ioutil.WriteFile(paths[2], []byte(invalid), 0644))
+
+	cache, err := OpenCache(filepath.Join(dir, "cache"), "config")
+	assert.Nil(t, err)
+	proc := NewProcessor(grouperGoimports{})
+	process := func(cache *Cache) []bool {
+		report, err := ProcessFiles(context.Background(), paths, proc, RunOptions{Cache: cache})
+		assert.Nil(t, err)
+		cached := []bool{}
+		for _, res := range report.Files {
+			cached = append(cached, res.Cached)
+		}
+		return cached
+	}
+
+	// Only valid files are cached.
+	assert.Equal(t, []bool{false, false, false}, process(cache))
+	assert.Equal(t, []bool{true, true, false}, process(cache))
+
+	// Changed files are checked again.
+	assert.Nil(t, ioutil.WriteFile(paths[0], []byte(invalid+"// a\n"), 0644))
+	assert.Equal(t, []bool{false, true, false}, process(cache))
+
+	// So are all files when the configuration changes.
+	other, err := OpenCache(filepath.Join(dir, "cache"), "other config")
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{false, false, false}, process(other))
+
+	// Corrupt entries are ignored.
+	src, err := ioutil.ReadFile(paths[1])
+	assert.Nil(t, err)
+	assert.Nil(t, ioutil.WriteFile(cache.entryPath(src, ""), []byte("garbage"), 0644))
+	assert.Equal(t, []bool{false, false, false}, process(cache))
+	assert.Equal(t, []bool{false, true, false}, process(cache))
+
+	// Without a cache, everything is checked.
+	assert.Equal(t, []bool{false, false, false}, process(nil))
+}
+
+func TestCacheLayers(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// The same content is valid in one directory, but not in another.
+	src := []byte("package a\n\nimport \"os\"\n")
+	paths := []string{filepath.Join(dir, "ok", "a.go"), filepath.Join(dir, "pure", "a.go")}
+	for _, path := range paths {
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, ioutil.WriteFile(path, src, 0644))
+	}
+
+	cache, err := OpenCache(filepath.Join(dir, "cache"), "config")
+	assert.Nil(t, err)
+	proc := NewProcessor(grouperGoimports{}, Layers([]LayerRule{
+		{Dir: filepath.Join(dir, "pure"), Deny: []string{"os"}},
+	}))
+	for i := 0; i < 2; i++ {
+		report, err := ProcessFiles(context.Background(), paths, proc, RunOptions{Cache: cache})
+		assert.Nil(t, err)
+		assert.Equal(t, i > 0, report.Files[0].Cached)
+		assert.False(t, report.Files[1].Cached)
+		assert.Len(t, report.Files[1].Violations, 1)
+	}
+}
@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/vasi-stripe/gogroup"
+)
+
+// Read the baseline given by -baseline.
+func readBaselineFile(path string) (*gogroup.Baseline, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	b, err := gogroup.ReadBaseline(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err.Error())
+	}
+	return b, nil
+}
+
+// Tolerate the violations of a report that the baseline records, and warn
+// about the entries of the baseline that are stale, so it can be shrunk.
+func (c *command) applyBaseline(b *gogroup.Baseline, report *gogroup.Report) {
+	for _, e := range b.Apply(report) {
+		fmt.Fprintf(c.stderr, "Warning: stale baseline entry, %d fewer violations: %s\n", e.Count, e.String())
+	}
+}
+
+// Write the violations of a report as a baseline, for -write-baseline.
+func (c *command) writeBaseline(path string, report *gogroup.Report) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	b := gogroup.NewBaseline(report)
+	if err = b.WriteBaseline(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stderr, "Wrote %d violations to the baseline %s\n", report.Violations(), path)
+	return nil
+}
@@ -0,0 +1,207 @@
+// Package cli implements the group-imports command, so that it can be embedded
+// in other programs.
+package cli
+
+import (
+	"context"
+	"fmt"
+	"go/build"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/vasi-stripe/gogroup"
+)
+
+const (
+	statusError       = 1
+	statusHelp        = 2
+	statusInvalidFile = 3
+	statusParseError  = 4
+)
+
+// Create the build context that files found in directories must match, or
+// nil if files shouldn't be filtered. Setting any of goos, goarch or tags
+// enables filtering.
+func newBuildContext(enabled bool, goos, goarch, tags string) *build.Context {
+	if !enabled && goos == "" && goarch == "" && tags == "" {
+		return nil
+	}
+
+	ctx := build.Default
+	if goos != "" {
+		ctx.GOOS = goos
+	}
+	if goarch != "" {
+		ctx.GOARCH = goarch
+	}
+	if tags != "" {
+		// Like the go command, accept tags separated by commas or spaces.
+		ctx.BuildTags = strings.FieldsFunc(tags, func(r rune) bool {
+			return r == ',' || r == ' '
+		})
+	}
+	return &ctx
+}
+
+// Create a context that's cancelled on SIGINT or SIGTERM, so that rewrites in
+// progress can clean up their temporary files. A second signal terminates the
+// process as usual. Call the returned function to stop handling signals.
+func signalContext() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-sigs:
+		case <-ctx.Done():
+		}
+		signal.Stop(sigs)
+		cancel()
+	}()
+	return ctx, cancel
+}
+
+// Open the cache, in the default directory if dir is empty. If the cache
+// can't be opened, warn and yield nil, so files are processed in full.
+func openCache(w io.Writer, dir string, config string) *gogroup.Cache {
+	var err error
+	if dir == "" {
+		dir, err = gogroup.DefaultCacheDir()
+	}
+	var cache *gogroup.Cache
+	if err == nil {
+		cache, err = gogroup.OpenCache(dir, config)
+	}
+	if err != nil {
+		fmt.Fprintf(w, "Warning: not using the cache: %s\n", err.Error())
+	}
+	return cache
+}
+
+// Classify an error processing a file.
+func errorStatus(err error) int {
+	if _, ok := err.(*gogroup.ParseError); ok {
+		return statusParseError
+	}
+	return statusError
+}
+
+// Pick the exit status that takes precedence. Other errors come first, since
+// they may be transient, then files that don't parse, then violations.
+func worseStatus(a, b int) int {
+	rank := map[int]int{0: 0, statusInvalidFile: 1, statusParseError: 2, statusError: 3}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+// A single invocation of the command, with its standard streams.
+type command struct {
+	stdin          io.Reader
+	stdout, stderr io.Writer
+}
+
+// Report an error, yielding the status to exit with.
+func (c *command) fail(status int, err error) int {
+	fmt.Fprintln(c.stderr, err.Error())
+	return status
+}
+
+// Process files, report the results, and yield an appropriate exit status.
+// Files that were skipped without processing are included in the report.
+func (c *command) run(proc *gogroup.Processor, opts gogroup.RunOptions, out *output, files []string,
+	skipped []*gogroup.FileResult) int {
+	ctx, stop := signalContext()
+	defer stop()
+	report, err := gogroup.ProcessFiles(ctx, files, proc, opts)
+	if err != nil {
+		return c.fail(statusError, err)
+	}
+	defer out.timings.Start(gogroup.PhaseOutput)()
+	report.Merge(&gogroup.Report{Files: skipped})
+	if out.lines != nil {
+		report.KeepLines(out.lines)
+	}
+
+	if err = report.WriteNotes(c.stderr, !opts.Rewrite && out.reportsParseErrors()); err != nil {
+		return c.fail(statusError, err)
+	}
+	status := 0
+	for _, res := range report.Files {
+		if res.Err != nil {
+			status = worseStatus(status, errorStatus(res.Err))
+		}
+	}
+	if out.writeBaseline != "" {
+		if err = c.writeBaseline(out.writeBaseline, report); err != nil {
+			return c.fail(statusError, err)
+		}
+		return status
+	}
+	if out.baseline != nil {
+		c.applyBaseline(out.baseline, report)
+	}
+
+	if !opts.Rewrite {
+		shown, omitted := out.truncate(report)
+		if err = out.write(out.stream(c.stdout, c.stderr), shown); err != nil {
+			return c.fail(statusError, err)
+		}
+		if omitted > 0 {
+			fmt.Fprintf(c.stderr, "... and %d more violations\n", omitted)
+		}
+	}
+	if out.showSuppressed {
+		c.writeSuppressed(report)
+	}
+	if out.summary {
+		if err = out.writeSummary(c.stderr, report); err != nil {
+			return c.fail(statusError, err)
+		}
+	}
+	if !opts.Rewrite && report.HasViolationsAt(out.failAt) {
+		status = worseStatus(status, statusInvalidFile)
+	}
+	return status
+}
+
+// List the violations that comments suppress, and count them, for
+// -show-suppressed.
+func (c *command) writeSuppressed(report *gogroup.Report) {
+	for _, res := range report.Files {
+		for _, v := range res.Suppressed {
+			fmt.Fprintf(c.stderr, "Suppressed: %s:%d: %s at %q [%s]\n", res.Path, v.Line, v.Message, v.ImportPath,
+				v.ID())
+		}
+	}
+	fmt.Fprintf(c.stderr, "%d violations suppressed\n", report.Suppressed())
+}
+
+// Run runs the group-imports command with the given arguments, not including
+// the program name, and yields the status to exit with. It never exits the
+// process itself.
+//
+// Arguments of the form @FILE are replaced by the arguments in FILE, one per
+// line. With --persistent_worker, it serves Bazel work requests instead, each
+// of which runs the command with the other arguments followed by those of the
+// request.
+//
+// While files are processed, SIGINT and SIGTERM cancel any rewrites in
+// progress.
+func Run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	c := &command{stdin: stdin, stdout: stdout, stderr: stderr}
+	args, err := expandArgfiles(args)
+	if err != nil {
+		return c.fail(statusHelp, err)
+	}
+	for i, arg := range args {
+		if arg == persistentWorkerFlag {
+			return c.worker(append(args[:i:i], args[i+1:]...))
+		}
+	}
+	return c.main(args)
+}
@@ -0,0 +1,1450 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vasi-stripe/gogroup"
+)
+
+// Run the command, yielding its stdout, stderr and exit status.
+func runCommand(args ...string) (string, string, int) {
+	var stdout, stderr bytes.Buffer
+	status := Run(args, strings.NewReader(""), &stdout, &stderr)
+	return stdout.String(), stderr.String(), status
+}
+
+func TestCommand(t *testing.T) {
+	t.Parallel()
+
+	stdout, _, status := runCommand("testdata/valid.go")
+	assert.Equal(t, 0, status)
+	assert.Equal(t, "", stdout)
+
+	stdout, _, status = runCommand("testdata/valid.go", "testdata/invalid.go")
+	assert.Equal(t, statusInvalidFile, status)
+	assert.Equal(t, "testdata/invalid.go:5: Import in incorrect group at \"github.com/example/dep\" [GI004]\n"+
+		"testdata/invalid.go:6: Import in incorrect group at \"os\" [GI004]\n", stdout)
+
+	// Counting includes every violation in each file.
+	stdout, _, status = runCommand("-count", "testdata/valid.go", "testdata/invalid.go")
+	assert.Equal(t, statusInvalidFile, status)
+	assert.Equal(t, "2\n", stdout)
+	stdout, _, status = runCommand("-count-by", "rule", "testdata/invalid.go")
+	assert.Equal(t, statusInvalidFile, status)
+	assert.Equal(t, "statement-group 2\n", stdout)
+	stdout, _, status = runCommand("-count", "testdata/valid.go")
+	assert.Equal(t, 0, status)
+	assert.Equal(t, "0\n", stdout)
+
+	_, stderr, status := runCommand("-order", "bogus", "testdata/valid.go")
+	assert.Equal(t, statusHelp, status)
+	assert.Contains(t, stderr, "Unknown order specification 'bogus'")
+
+	_, _, status = runCommand()
+	assert.Equal(t, statusHelp, status)
+	_, stderr, status = runCommand("-help")
+	assert.Equal(t, 0, status)
+	assert.Contains(t, stderr, "Usage: group-imports")
+
+	stdout, _, status = runCommand("-order", "std,other", "hook", "print")
+	assert.Equal(t, 0, status)
+	assert.Contains(t, stdout, "entry: group-imports -order std,other hook run\n")
+
+	_, _, status = runCommand("testdata/missing.go")
+	assert.Equal(t, statusError, status)
+
+	// Parse errors are distinct from other errors, which take precedence.
+	_, _, status = runCommand("testdata/invalid.go", "testdata/broken.go")
+	assert.Equal(t, statusParseError, status)
+	_, _, status = runCommand("testdata/broken.go", "testdata/missing.go")
+	assert.Equal(t, statusError, status)
+
+	// Rewriting fixes the file.
+	dir, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	src, err := ioutil.ReadFile("testdata/invalid.go")
+	assert.Nil(t, err)
+	file := filepath.Join(dir, "invalid.go")
+	assert.Nil(t, ioutil.WriteFile(file, src, 0644))
+
+	_, stderr, status = runCommand("-rewrite", "-no-goimports", file)
+	assert.Equal(t, 0, status, stderr)
+	fixed, err := ioutil.ReadFile(file)
+	assert.Nil(t, err)
+	valid, err := ioutil.ReadFile("testdata/valid.go")
+	assert.Nil(t, err)
+	assert.Equal(t, string(valid), string(fixed))
+}
+
+func TestNewOutput(t *testing.T) {
+	t.Setenv("GITHUB_ACTIONS", "true")
+
+	out, err := newOutput("", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "github", out.format)
+
+	out, err = newOutput("junit", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "junit", out.format)
+
+	_, err = newOutput("bogus", "")
+	assert.NotNil(t, err)
+
+	out, err = newOutput("template", "{{.File}}:{{.Line}}")
+	assert.Nil(t, err)
+	assert.NotNil(t, out.tmpl)
+
+	_, err = newOutput("template", "")
+	assert.NotNil(t, err)
+	_, err = newOutput("text", "{{.File}}")
+	assert.NotNil(t, err)
+
+	// Parse errors mention the position.
+	_, err = newOutput("template", "{{.File}}\n{{.Line")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "template:2:")
+
+	// So do unknown fields.
+	_, err = newOutput("template", "{{.Bogus}}")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Bogus")
+}
+
+func TestApplyConfigEnv(t *testing.T) {
+	t.Setenv(orderEnvVar, "std,prefix=local/,other")
+
+	gr := gogroup.NewSpecGrouper()
+	assert.Nil(t, applyConfig(gr, ""))
+	assert.Equal(t, "std,prefix=local/,other", gr.String())
+
+	t.Setenv(orderEnvVar, "bogus")
+	err := applyConfig(gogroup.NewSpecGrouper(), "")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), orderEnvVar+": Unknown order specification 'bogus'")
+}
+
+func TestApplyConfigRules(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// A configuration file can be a rules document.
+	path := filepath.Join(dir, configFileName)
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`{
+  "groups": [
+    {"name": "Standard", "std": true},
+    {"name": "Local", "prefixes": ["corp.dev/"]},
+    {"name": "Third-party"}
+  ],
+  "default": "Third-party"
+}
+`), 0644))
+	gr := gogroup.NewSpecGrouper()
+	assert.Nil(t, applyConfig(gr, dir))
+	assert.Equal(t, 1, gr.Group("corp.dev/pkg"))
+	assert.Equal(t, 2, gr.Group("github.com/pkg/errors"))
+	assert.Equal(t, "Local", gr.GroupName(1))
+
+	// Errors are reported with the line they're on.
+	assert.Nil(t, ioutil.WriteFile(path, []byte("{\n\"groups\": [\n{\"name\": \"Standard\"}]}\n"), 0644))
+	err = applyConfig(gogroup.NewSpecGrouper(), dir)
+	assert.EqualError(t, err, path+": line 3 (groups[0]): Group 'Standard' has no rules")
+
+	// Order specifications can't be mixed with groups.
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`{"order": "std", "groups": []}`), 0644))
+	assert.NotNil(t, applyConfig(gogroup.NewSpecGrouper(), dir))
+
+	// Alias settings don't disturb the rules, or the lines of their errors.
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`{
+  "aliases": [{"pattern": "/proto/", "alias": "pb"}],
+  "groups": [
+    {"name": "Standard"}
+  ],
+  "forbiddenAliases": ["ctx"]
+}
+`), 0644))
+	err = applyConfig(gogroup.NewSpecGrouper(), dir)
+	assert.EqualError(t, err, path+": line 4 (groups[0]): Group 'Standard' has no rules")
+}
+
+func TestOrderJSON(t *testing.T) {
+	t.Parallel()
+
+	doc := `{"groups": [{"name": "Std", "std": true}, {"name": "Other"}], "default": "Other"}`
+	stdout, _, status := runCommand("check", "-order-json", doc, "testdata/invalid.go")
+	assert.Equal(t, statusInvalidFile, status)
+	assert.Contains(t, stdout, "expected group Other, found in Std")
+
+	// The document can be read from a file, and printed back.
+	dir, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "order.json")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(doc), 0644))
+	printed, _, status := runCommand("check", "-order-json", "@"+path, "-print-order-json")
+	assert.Equal(t, 0, status)
+	assert.Equal(t, `{
+  "groups": [
+    {
+      "name": "Std",
+      "std": true
+    },
+    {
+      "name": "Other"
+    }
+  ],
+  "default": "Other"
+}
+`, printed)
+	stdout, _, _ = runCommand("check", "-order-json", printed, "-print-order-json")
+	assert.Equal(t, printed, stdout)
+
+	// The document may be written in YAML.
+	yamlPath := filepath.Join(dir, "order.yaml")
+	assert.Nil(t, ioutil.WriteFile(yamlPath,
+		[]byte("groups:\n  - name: Std\n    std: true\n  - name: Other\ndefault: Other\n"), 0644))
+	stdout, _, status = runCommand("check", "-order-json=@"+yamlPath, "-print-order-json")
+	assert.Equal(t, 0, status)
+	assert.Equal(t, printed, stdout)
+
+	// Simple orders are converted.
+	stdout, _, status = runCommand("check", "-order", "std,other:Rest", "-print-order-json")
+	assert.Equal(t, 0, status)
+	assert.Contains(t, stdout, `"default": "Rest"`)
+
+	// Errors give the path to the value at fault.
+	_, stderr, status := runCommand("check", "-order-json",
+		`{"groups": [{"name": "A", "std": true}, {"name": "B", "regexes": ["x", "("]}]}`, "testdata/valid.go")
+	assert.Equal(t, statusHelp, status)
+	assert.Contains(t, stderr, "line 1 (groups[1].regexes[1]): Invalid regex '('")
+
+	_, stderr, status = runCommand("check", "-order", "std", "-order-json", doc, "testdata/valid.go")
+	assert.Equal(t, statusHelp, status)
+	assert.Contains(t, stderr, "can't be combined")
+}
+
+func TestPolicyConfig(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, configFileName)
+
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`{
+  "groups": [{"name": "Standard", "std": true}, {"name": "Other"}],
+  "aliases": [{"pattern": "/proto/", "alias": "pb"}],
+  "default": "Other",
+  "forbiddenAliases": ["ctx"],
+  "deny": [{"path": "github.com/pkg/errors", "message": "use errors"}, {"regex": "/v1$"}]
+}
+`), 0644))
+	o := newOptions()
+	assert.Nil(t, o.deny.Set("github.com/corp/old/..."))
+	assert.Nil(t, applyConfig(o.gr, dir))
+	assert.Nil(t, applyPolicyConfig(o, dir))
+	assert.Equal(t, []string{"ctx"}, o.forbiddenAliases)
+	if assert.Len(t, o.aliasRules, 1) {
+		assert.Equal(t, "pb", o.aliasRules[0].Alias)
+	}
+	if assert.Len(t, o.deny, 4) {
+		assert.Equal(t, gogroup.DenyRule{Path: "github.com/corp/old"}, o.deny[0])
+		assert.Equal(t, gogroup.DenyRule{Prefix: "github.com/corp/old/"}, o.deny[1])
+		assert.Equal(t, "use errors", o.deny[2].Message)
+		assert.Equal(t, "/v1$", o.deny[3].Pattern.String())
+	}
+
+	// Layering rules apply relative to the directory of the file.
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`{
+  "layers": [
+    {"dir": "cmd/*"},
+    {"dir": "internal/storage/...", "deny": ["corp.dev/app/internal/api"], "name": "storage below api"}
+  ]
+}
+`), 0644))
+	o = newOptions()
+	assert.Nil(t, applyPolicyConfig(o, dir))
+	src := "package storage\n\nimport \"corp.dev/app/internal/api\"\n"
+	proc := o.processor()
+	errs, err := proc.ValidateAll(filepath.Join(dir, "internal", "storage", "sql", "db.go"), strings.NewReader(src))
+	assert.Nil(t, err)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "Import not allowed by a layering rule (storage below api)", errs[0].Message)
+		assert.Equal(t, "statement-layer", errs[0].Rule)
+	}
+	errs, err = proc.ValidateAll(filepath.Join(dir, "cmd", "server", "main.go"), strings.NewReader(src))
+	assert.Nil(t, err)
+	assert.Empty(t, errs)
+	assert.Contains(t, o.cacheConfig(), "layers=")
+
+	// Invalid rules are reported.
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`{"layers": [{"deny": ["x"]}]}`), 0644))
+	assert.Contains(t, applyPolicyConfig(newOptions(), dir).Error(), "needs a \"dir\"")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`{"layers": [{"dir": "[", "deny": ["x"]}]}`), 0644))
+	assert.Contains(t, applyPolicyConfig(newOptions(), dir).Error(), "Invalid directory pattern '['")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`{"aliases": [{"pattern": "(", "alias": "pb"}]}`), 0644))
+	assert.Contains(t, applyPolicyConfig(newOptions(), dir).Error(), "Invalid regex '('")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`{"aliases": [{"pattern": "x"}]}`), 0644))
+	assert.Contains(t, applyPolicyConfig(newOptions(), dir).Error(), "has no alias")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`{"deny": [{"path": "x", "prefix": "y"}]}`), 0644))
+	assert.Contains(t, applyPolicyConfig(newOptions(), dir).Error(), "needs one of")
+}
+
+func TestNestedConfig(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	src := "package a\n\nimport (\n\t\"corp.dev/lib\"\n\t\"github.com/pkg/errors\"\n)\n"
+	for name, content := range map[string]string{
+		configFileName: `{"order": "std,other", "forbiddenAliases": ["ctx"],
+  "deny": [{"path": "github.com/pkg/errors"}]}`,
+		"mid/" + configFileName:      `{"extends": "../", "order": "std,prefix=corp.dev/,other"}`,
+		"mid/leaf/" + configFileName: `{"extends": "..", "deny": []}`,
+		"a.go":                       src,
+		"mid/leaf/b.go":              src,
+		"loop/" + configFileName:     `{"extends": "x/` + configFileName + `"}`,
+		"loop/x/" + configFileName:   `{"extends": "../` + configFileName + `"}`,
+		"missing/" + configFileName:  `{"extends": "nowhere"}`,
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+	root := filepath.Join(dir, configFileName)
+	mid := filepath.Join(dir, "mid", configFileName)
+	leaf := filepath.Join(dir, "mid", "leaf", configFileName)
+
+	// The nearest setting wins, and settings that are present but empty
+	// still replace those they extend.
+	cfg, err := findConfig(filepath.Join(dir, "mid", "leaf"))
+	assert.Nil(t, err)
+	assert.Equal(t, mid, cfg.order.path)
+	assert.Equal(t, []string{"ctx"}, cfg.ForbiddenAliases)
+	assert.Empty(t, cfg.denyRules)
+	assert.Equal(t, leaf+", extending "+mid+", extending "+root, cfg.describe())
+	cfg, err = findConfig(filepath.Join(dir, "mid"))
+	assert.Nil(t, err)
+	assert.Len(t, cfg.denyRules, 1)
+
+	_, err = findConfig(filepath.Join(dir, "loop"))
+	assert.Contains(t, err.Error(), "Configuration extends itself")
+	_, err = findConfig(filepath.Join(dir, "missing"))
+	assert.Contains(t, err.Error(), "Can't extend 'nowhere'")
+
+	// Each file is checked with its own configuration.
+	a, b := filepath.Join(dir, "a.go"), filepath.Join(dir, "mid", "leaf", "b.go")
+	stdout, stderr, status := runCommand("check", "-debug-config", "-format", "editor", a, b)
+	assert.Equal(t, statusInvalidFile, status)
+	assert.Contains(t, stderr, "Configuration for "+a+": "+root+"\n")
+	assert.Contains(t, stderr, "Configuration for "+b+": "+leaf+", extending ")
+	assert.Equal(t, a+":5:2: Import of a denied package (import \"github.com/pkg/errors\")\n"+
+		b+":5:2: Missing empty line between import groups (import \"github.com/pkg/errors\")\n", stdout)
+
+	// An order from flags applies everywhere.
+	stdout, _, _ = runCommand("check", "-order", "std,other", "-format", "editor", b)
+	assert.Equal(t, "", stdout)
+}
+
+func TestWhy(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module corp.dev/app\n"), 0644))
+	path := filepath.Join(dir, configFileName)
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`{
+  "groups": [
+    {"name": "Standard", "std": true},
+    {"name": "Local", "prefixes": ["corp.dev/"]},
+    {"name": "Third-party"}
+  ],
+  "default": "Third-party"
+}
+`), 0644))
+	file := filepath.Join(dir, "main.go")
+
+	stdout, _, status := runCommand("why", "corp.dev/app/util", file)
+	assert.Equal(t, 0, status)
+	assert.Equal(t, "corp.dev/app/util: group 1 (Local), matched prefix=corp.dev/ in the order from "+
+		path+"; within module corp.dev/app\n", stdout)
+	stdout, _, _ = runCommand("why", "github.com/pkg/errors", file)
+	assert.Equal(t, "github.com/pkg/errors: group 2 (Third-party), matched default in the order from "+
+		path+"\n", stdout)
+
+	// The -order flag takes precedence.
+	stdout, _, _ = runCommand("why", "-order", "std,prefix=corp.dev/:Corp,prefix=github.com/corp/",
+		"github.com/corp/svc", file)
+	assert.Equal(t, "github.com/corp/svc: group 3, matched prefix=github.com/corp/ declared 3rd in "+
+		"the order from -order\n", stdout)
+	stdout, _, _ = runCommand("why", "-order", "std,strict", "github.com/corp/svc")
+	assert.Equal(t, "github.com/corp/svc: no group, matched nothing in the order from -order, "+
+		"which is strict\n", stdout)
+
+	stdout, _, _ = runCommand("why", "-json", "corp.dev/app", file)
+	var res map[string]interface{}
+	assert.Nil(t, json.Unmarshal([]byte(stdout), &res))
+	assert.Equal(t, map[string]interface{}{
+		"path":     "corp.dev/app",
+		"group":    float64(1),
+		"name":     "Local",
+		"spec":     "prefix=corp.dev/",
+		"source":   path,
+		"module":   "corp.dev/app",
+		"inModule": true,
+	}, res)
+
+	_, _, status = runCommand("why")
+	assert.Equal(t, statusHelp, status)
+
+	assert.Equal(t, "1st", ordinal(1))
+	assert.Equal(t, "2nd", ordinal(2))
+	assert.Equal(t, "3rd", ordinal(3))
+	assert.Equal(t, "4th", ordinal(4))
+	assert.Equal(t, "11th", ordinal(11))
+	assert.Equal(t, "12th", ordinal(12))
+	assert.Equal(t, "22nd", ordinal(22))
+	assert.Equal(t, "113th", ordinal(113))
+}
+
+func TestRulesSubcommand(t *testing.T) {
+	t.Parallel()
+
+	stdout, _, status := runCommand("rules")
+	assert.Equal(t, 0, status)
+	lines := strings.Split(strings.TrimSuffix(stdout, "\n"), "\n")
+	assert.Equal(t, len(gogroup.Kinds()), len(lines))
+	assert.Regexp(t, `^GI004 +statement-group +Each import is in the group it belongs to$`, lines[3])
+
+	_, _, status = runCommand("rules", "extra")
+	assert.Equal(t, statusHelp, status)
+}
+
+func TestByteSize(t *testing.T) {
+	t.Parallel()
+
+	for str, expected := range map[string]int64{
+		"0":      0,
+		"1234":   1234,
+		"10B":    10,
+		"5MB":    5 << 20,
+		"2 kb":   2 << 10,
+		"1G":     1 << 30,
+		"100 MB": 100 << 20,
+	} {
+		var s byteSize
+		assert.Nil(t, s.Set(str), str)
+		assert.Equal(t, expected, int64(s), str)
+	}
+
+	for _, str := range []string{"", "MB", "-5MB", "5TB", "1.5MB", "9999999999G"} {
+		var s byteSize
+		assert.NotNil(t, s.Set(str), str)
+	}
+}
+
+func TestTestsFlag(t *testing.T) {
+	t.Parallel()
+
+	var tests testsFlag
+	assert.Equal(t, "include", tests.String())
+	assert.Nil(t, tests.Set("only"))
+	assert.Equal(t, testsFlag(gogroup.TestsOnly), tests)
+	assert.Equal(t, "only", tests.String())
+	assert.NotNil(t, tests.Set("all"))
+}
+
+func TestInferOrder(t *testing.T) {
+	t.Parallel()
+
+	local := "package a\n\nimport (\n\t\"fmt\"\n\t\"os\"\n\n\t\"github.com/pkg/errors\"\n\n" +
+		"\t\"example.com/mod/util\"\n)\n"
+	srcs := map[string][]byte{
+		"a.go":   []byte(local),
+		"b.go":   []byte(local),
+		"c.go":   []byte("package a\n\nimport (\n\t\"os\"\n\n\t\"example.com/mod/util\"\n)\n"),
+		"d.go":   []byte("package a\n\nimport \"os\"\n"),
+		"bad.go": []byte("package a\n\nimport (\n\t\"os\"\n\t\"fmt\"\n\t\"github.com/pkg/errors\"\n)\n"),
+	}
+	inf := inferOrder(srcs, []string{"example.com/mod"})
+	assert.Equal(t, "std,other,prefix=example.com/mod", inf.order)
+	assert.Equal(t, 3, inf.matched)
+	assert.Equal(t, 4, inf.total)
+	assert.Equal(t, []outlier{{"bad.go", 2}}, inf.outliers)
+
+	var buf bytes.Buffer
+	writeInference(&buf, inf)
+	assert.Equal(t, "Suggested order: std,other,prefix=example.com/mod\n"+
+		"Matches 3 of 4 files with several imports (75%)\n"+
+		"Files that don't match:\n  bad.go (2 violations)\n", buf.String())
+
+	// Without other imports, the default is suggested.
+	inf = inferOrder(map[string][]byte{"d.go": srcs["d.go"]}, nil)
+	assert.Equal(t, "std,other", inf.order)
+	assert.Equal(t, 0, inf.total)
+}
+
+func TestWriteConfig(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.Nil(t, writeConfig(dir, &config{Config: gogroup.Config{Order: "std,other"}}))
+	cfg, err := findConfig(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, "std,other", cfg.Order)
+
+	// Existing configuration is never replaced.
+	assert.NotNil(t, writeConfig(dir, &config{Config: gogroup.Config{Order: "other,std"}}))
+}
+
+func TestSubcommands(t *testing.T) {
+	t.Parallel()
+
+	// Checking works the same with or without the subcommand.
+	legacy, _, legacyStatus := runCommand("testdata/valid.go", "testdata/invalid.go")
+	stdout, _, status := runCommand("check", "testdata/valid.go", "testdata/invalid.go")
+	assert.Equal(t, legacyStatus, status)
+	assert.Equal(t, legacy, stdout)
+	stdout, _, status = runCommand("check", "-count", "testdata/invalid.go")
+	assert.Equal(t, statusInvalidFile, status)
+	assert.Equal(t, "2\n", stdout)
+
+	stdout, _, status = runCommand("list", "testdata/valid.go", "testdata/invalid.go")
+	assert.Equal(t, statusInvalidFile, status)
+	assert.Equal(t, "testdata/invalid.go\n", stdout)
+
+	// The editor format prints parse errors to standard output, not standard error.
+	stdout, stderr, status := runCommand("check", "-format", "editor", "testdata/invalid.go",
+		"testdata/broken.go")
+	assert.Equal(t, statusParseError, status)
+	assert.Equal(t, `testdata/invalid.go:5:2: Import in incorrect group (import "github.com/example/dep")
+testdata/invalid.go:6:2: Import in incorrect group (import "os")
+testdata/broken.go:4:8: expected ')', found 'EOF'
+`, stdout)
+	assert.Equal(t, "", stderr)
+
+	stdout, _, status = runCommand("check", "-format", "tap", "testdata/valid.go")
+	assert.Equal(t, 0, status)
+	assert.Equal(t, "TAP version 13\n1..1\nok 1 - testdata/valid.go\n", stdout)
+
+	stdout, _, status = runCommand("check", "-format", "json", "testdata/invalid.go", "testdata/broken.go")
+	assert.Equal(t, statusParseError, status)
+	var doc struct{ Files []map[string]interface{} }
+	assert.Nil(t, json.Unmarshal([]byte(stdout), &doc))
+	assert.Equal(t, 2, len(doc.Files))
+	assert.Contains(t, doc.Files[1]["error"], "expected ')'")
+
+	// Stats never fail for violations.
+	stdout, _, status = runCommand("stats", "-top", "1", "testdata/invalid.go")
+	assert.Equal(t, 0, status)
+	assert.Equal(t, `Files: 1
+Imports: 3 (3 distinct paths, 1 third-party roots)
+Imports by group: 0: 2, 1: 1
+Files with each group: 0: 1, 1: 1
+Files by number of groups: 2: 1
+Most imported:
+  1 fmt
+`, stdout)
+	_, _, status = runCommand("stats", "testdata/broken.go")
+	assert.Equal(t, statusParseError, status)
+	_, _, status = runCommand("stats", "-format", "yaml", "testdata/valid.go")
+	assert.Equal(t, statusHelp, status)
+
+	// Each subcommand only takes flags that apply to it.
+	_, _, status = runCommand("check", "-rewrite", "testdata/valid.go")
+	assert.Equal(t, statusHelp, status)
+	_, _, status = runCommand("list", "-format", "junit", "testdata/valid.go")
+	assert.Equal(t, statusHelp, status)
+	_, stderr, status = runCommand("fix", "-help")
+	assert.Equal(t, 0, status)
+	assert.Contains(t, stderr, "Usage: group-imports fix")
+	assert.Contains(t, stderr, "-no-goimports")
+	assert.NotContains(t, stderr, "  -format")
+
+	// Output can be capped, without affecting the status or summary.
+	stdout, stderr, status = runCommand("check", "-max-violations", "1", "-summary",
+		"testdata/invalid.go", "testdata/invalid.go")
+	assert.Equal(t, statusInvalidFile, status)
+	assert.Equal(t, 1, strings.Count(stdout, "\n"))
+	assert.Contains(t, stderr, "... and 3 more violations")
+	assert.Contains(t, stderr, "Violations: 4 in 2 files")
+	_, _, status = runCommand("check", "-max-violations", "-1", "testdata/invalid.go")
+	assert.Equal(t, statusHelp, status)
+
+	// Failing fast reports only the first invalid file.
+	stdout, _, status = runCommand("check", "-fail-fast", "testdata/valid.go",
+		"testdata/invalid.go", "testdata/invalid.go")
+	assert.Equal(t, statusInvalidFile, status)
+	assert.Equal(t, 2, strings.Count(stdout, "\n"))
+	_, _, status = runCommand("-fail-fast", "-rewrite", "testdata/valid.go")
+	assert.Equal(t, statusHelp, status)
+
+	// Violations can go to standard error instead.
+	stdout, stderr, status = runCommand("check", "-violations-to", "stderr", "testdata/invalid.go")
+	assert.Equal(t, statusInvalidFile, status)
+	assert.Equal(t, "", stdout)
+	assert.Contains(t, stderr, "testdata/invalid.go:")
+	_, _, status = runCommand("check", "-violations-to", "stdin", "testdata/invalid.go")
+	assert.Equal(t, statusHelp, status)
+
+	// Strict orders make unclassified imports violations.
+	stdout, stderr, status = runCommand("check", "-order", "std,strict", "testdata/valid.go")
+	assert.Equal(t, statusInvalidFile, status)
+	assert.Contains(t, stdout, "Import not assigned to any group")
+	assert.Contains(t, stderr, "Warning: the order is strict")
+
+	// Imports can be sorted by depth.
+	_, _, status = runCommand("check", "-sort", "depth-then-alpha", "testdata/valid.go")
+	assert.Equal(t, 0, status)
+	_, stderr, status = runCommand("check", "-sort", "depth", "testdata/valid.go")
+	assert.Equal(t, statusHelp, status)
+	assert.Contains(t, stderr, "expected one of: alpha, depth-then-alpha, natural")
+	_, _, status = runCommand("check", "-sort", "natural", "testdata/valid.go")
+	assert.Equal(t, 0, status)
+
+	// Declarations can be required to be parenthesized.
+	stdout, _, status = runCommand("check", "-blocks", "plain", "testdata/valid.go")
+	assert.Equal(t, 0, status)
+	_, _, status = runCommand("check", "-blocks", "factored", "testdata/valid.go")
+	assert.Equal(t, statusHelp, status)
+
+	// Named groups can require headers.
+	stdout, _, status = runCommand("check", "-headers", "-order", "std:Standard,other",
+		"testdata/valid.go")
+	assert.Equal(t, statusInvalidFile, status)
+	assert.Contains(t, stdout, "Missing import group header")
+	_, _, status = runCommand("check", "-headers", "testdata/valid.go")
+	assert.Equal(t, 0, status)
+
+	// Stripped comments must be valid regexes.
+	_, stderr, status = runCommand("check", "-strip-comments", "(", "testdata/valid.go")
+	assert.Equal(t, statusHelp, status)
+	assert.Contains(t, stderr, "Invalid regex")
+
+	// Duplicates are reported on request.
+	_, _, status = runCommand("check", "-duplicates", "testdata/valid.go")
+	assert.Equal(t, 0, status)
+
+	// Relative imports can be allowed.
+	_, _, status = runCommand("check", "-allow-relative", "testdata/valid.go")
+	assert.Equal(t, 0, status)
+
+	// Packages can be denied.
+	stdout, _, status = runCommand("check", "-deny", "github.com/example/...", "testdata/valid.go")
+	assert.Equal(t, statusInvalidFile, status)
+	assert.Contains(t, stdout, "Import of a denied package")
+	_, _, status = runCommand("check", "-deny", "github.com/example/other", "testdata/valid.go")
+	assert.Equal(t, 0, status)
+
+	// Lenience doesn't excuse imports in the wrong group.
+	stdout, _, status = runCommand("check", "-lenient", "testdata/invalid.go")
+	assert.Equal(t, statusInvalidFile, status)
+	assert.Contains(t, stdout, "Import in incorrect group")
+
+	// So do comment separators.
+	stdout, _, status = runCommand("check", "-comment-separators", "testdata/invalid.go")
+	assert.Equal(t, statusInvalidFile, status)
+	assert.Contains(t, stdout, "Import in incorrect group")
+	_, _, status = runCommand("check", "-comment-separators", "testdata/valid.go")
+	assert.Equal(t, 0, status)
+
+	// Summaries can be JSON.
+	_, stderr, status = runCommand("check", "-summary-format", "json", "testdata/invalid.go")
+	assert.Equal(t, statusInvalidFile, status)
+	assert.Contains(t, stderr, `"violationsByRule"`)
+	_, _, status = runCommand("check", "-summary-format", "yaml", "testdata/invalid.go")
+	assert.Equal(t, statusHelp, status)
+
+	// Fixing rewrites files, like -rewrite.
+	dir, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	src, err := ioutil.ReadFile("testdata/invalid.go")
+	assert.Nil(t, err)
+	file := filepath.Join(dir, "invalid.go")
+	assert.Nil(t, ioutil.WriteFile(file, src, 0644))
+
+	_, stderr, status = runCommand("fix", "-no-goimports", file)
+	assert.Equal(t, 0, status, stderr)
+	fixed, err := ioutil.ReadFile(file)
+	assert.Nil(t, err)
+	valid, err := ioutil.ReadFile("testdata/valid.go")
+	assert.Nil(t, err)
+	assert.Equal(t, string(valid), string(fixed))
+
+	// Checking shows which comments fixing strips.
+	banner := strings.Replace(string(valid), "\n\n\t\"github.com", "\n\n\t// --- 3rd party ---\n\t\"github.com", 1)
+	assert.Nil(t, ioutil.WriteFile(file, []byte(banner), 0644))
+	stdout, _, status = runCommand("check", "-strip-comments", "^// ---", file)
+	assert.Equal(t, statusInvalidFile, status)
+	assert.Contains(t, stdout, ":7: Comment to strip from import block")
+	_, stderr, status = runCommand("fix", "-no-goimports", "-strip-comments", "^// ---", file)
+	assert.Equal(t, 0, status, stderr)
+	fixed, err = ioutil.ReadFile(file)
+	assert.Nil(t, err)
+	assert.Equal(t, string(valid), string(fixed))
+}
+
+func TestSubcommandPaths(t *testing.T) {
+	t.Parallel()
+
+	// A first argument naming a command is the command, even if there's a
+	// path of that name.
+	_, stderr, status := runCommand("check")
+	assert.Equal(t, statusHelp, status)
+	assert.Contains(t, stderr, "No file provided.")
+
+	// Paths named like commands are written as relative paths, or after --.
+	for _, args := range [][]string{{"./check"}, {"--", "check"}, {"-count", "--", "hook"}} {
+		_, stderr, status = runCommand(args...)
+		assert.Equal(t, statusError, status, args)
+		assert.Contains(t, stderr, args[len(args)-1], args)
+	}
+}
+
+// Run git in a directory, failing the test if it fails.
+func runGit(t *testing.T, dir string, args ...string) {
+	args = append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+	out, err := exec.Command("git", args...).CombinedOutput()
+	assert.Nil(t, err, string(out))
+}
+
+func TestHookInstall(t *testing.T) {
+	t.Parallel()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	runGit(t, dir, "init", "-q")
+	hook := filepath.Join(dir, ".git", "hooks", "pre-commit")
+	gr := gogroup.NewSpecGrouper()
+	assert.Nil(t, gr.Set("std,other"))
+	readHook := func() string {
+		data, err := ioutil.ReadFile(hook)
+		assert.Nil(t, err)
+		return string(data)
+	}
+
+	// A new hook has just our section.
+	var out bytes.Buffer
+	assert.Nil(t, hookInstall(&out, dir, gr))
+	assert.Equal(t, "Installed "+hook+"\n", out.String())
+	content := readHook()
+	assert.True(t, strings.HasPrefix(content, "#!/bin/sh\n"+hookBegin+"\n"), content)
+	assert.Contains(t, content, " -order 'std,other' hook run || exit $?\n"+hookEnd+"\n")
+	info, err := os.Stat(hook)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+
+	// Installing again replaces the section rather than adding another.
+	assert.Nil(t, hookInstall(&out, dir, gr))
+	assert.Equal(t, content, readHook())
+
+	// An existing hook keeps its content, wherever our section is.
+	mine := "#!/bin/sh\necho mine\n"
+	assert.Nil(t, ioutil.WriteFile(hook, []byte(mine+"echo more"), 0644))
+	assert.Nil(t, hookInstall(&out, dir, gr))
+	content = readHook()
+	assert.True(t, strings.HasPrefix(content, mine+"echo more\n"+hookBegin+"\n"), content)
+	info, err = os.Stat(hook)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+
+	// Uninstalling leaves the rest of the hook.
+	out.Reset()
+	assert.Nil(t, hookUninstall(&out, dir))
+	assert.Equal(t, "Uninstalled "+hook+"\n", out.String())
+	assert.Equal(t, mine+"echo more\n", readHook())
+	out.Reset()
+	assert.Nil(t, hookUninstall(&out, dir))
+	assert.Empty(t, out.String())
+	assert.Equal(t, mine+"echo more\n", readHook())
+
+	// A hook with nothing else is removed.
+	assert.Nil(t, os.Remove(hook))
+	assert.Nil(t, hookInstall(&out, dir, gr))
+	assert.Nil(t, hookUninstall(&out, dir))
+	_, err = os.Stat(hook)
+	assert.True(t, os.IsNotExist(err))
+	assert.Nil(t, hookUninstall(&out, dir))
+}
+
+func TestStripHookSection(t *testing.T) {
+	t.Parallel()
+
+	section := hookBegin + "\ncmd\n" + hookEnd + "\n"
+	for content, expected := range map[string]string{
+		"":                                    "",
+		"#!/bin/sh\necho\n":                   "#!/bin/sh\necho\n",
+		"#!/bin/sh\n" + section:               "#!/bin/sh\n",
+		"#!/bin/sh\n" + section + "echo\n":    "#!/bin/sh\necho\n",
+		"#!/bin/sh\n" + hookBegin + "\ncmd\n": "#!/bin/sh\n" + hookBegin + "\ncmd\n",
+	} {
+		assert.Equal(t, expected, stripHookSection(content), content)
+	}
+}
+
+func TestChangedFiles(t *testing.T) {
+	t.Parallel()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, os.Mkdir(filepath.Join(dir, "sub"), 0755))
+	for _, name := range []string{"a.go", "old.go", "same.go", "sub/b.go", "notes.txt", ".gitignore"} {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), []byte("package a\n"), 0644))
+	}
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, ".gitignore"), []byte("ignored.go\n"), 0644))
+	runGit(t, dir, "init", "-q")
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "-q", "-m", "base")
+	runGit(t, dir, "tag", "base")
+
+	// A committed rename and deletion, and changes that aren't committed.
+	runGit(t, dir, "mv", "a.go", "renamed.go")
+	runGit(t, dir, "rm", "-q", "old.go")
+	runGit(t, dir, "commit", "-q", "-m", "change")
+	for _, name := range []string{"sub/b.go", "new.go", "ignored.go", "notes.txt"} {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), []byte("package b\n"), 0644))
+	}
+
+	files, err := changedFiles(dir, "base")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"new.go", "renamed.go", filepath.Join("sub", "b.go")}, files)
+
+	// Paths are relative to the directory given.
+	files, err = changedFiles(filepath.Join(dir, "sub"), "base")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{filepath.Join("..", "new.go"), filepath.Join("..", "renamed.go"), "b.go"}, files)
+
+	_, err = changedFiles(dir, "nonexistent")
+	assert.NotNil(t, err)
+
+	outside, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(outside)
+	_, err = changedFiles(outside, "main")
+	assert.Contains(t, err.Error(), "-since needs a git repository")
+}
+
+func TestParseDiff(t *testing.T) {
+	t.Parallel()
+
+	diff := `diff --git a/p/a.go b/p/a.go
+--- a/p/a.go
++++ b/p/a.go
+@@ -3,3 +3,3 @@ import (
+ 	"fmt"
+-	"os"
++	"io"
+ 	"strings"
+@@ -20,2 +20,0 @@ func f() {
+-	x()
+-	y()
+--- a/notes.txt
++++ b/notes.txt
+@@ -1 +1 @@
+-old
++new
+--- /dev/null
++++ b/new.go
+@@ -0,0 +1,2 @@
++package p
++
+--- a/gone.go
++++ /dev/null
+@@ -1 +0,0 @@
+-package p
+--- plain.go	2020-01-01 00:00:00
++++ plain.go	2020-01-02 00:00:00
+@@ -1,2 +1,3 @@
+ package p
++--- not a header
+ 
+`
+	files, err := parseDiff(strings.NewReader(diff))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"p/a.go", "new.go", "plain.go"}, files.paths)
+	assert.Equal(t, map[string][]int{
+		"p/a.go":   {4, 21},
+		"new.go":   {1, 2},
+		"plain.go": {2},
+	}, files.lines)
+
+	_, err = parseDiff(strings.NewReader("--- a.go\n+++ a.go\n@@ bogus @@\n"))
+	assert.Contains(t, err.Error(), "line 3 of diff: Invalid hunk header")
+}
+
+func TestDiffFlag(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	src, err := ioutil.ReadFile("testdata/invalid.go")
+	assert.Nil(t, err)
+	file := filepath.Join(dir, "invalid.go")
+	assert.Nil(t, ioutil.WriteFile(file, src, 0644))
+
+	run := func(diff string, args ...string) (string, string, int) {
+		var stdout, stderr bytes.Buffer
+		status := Run(append([]string{"-diff", "-"}, args...), strings.NewReader(diff), &stdout, &stderr)
+		return stdout.String(), stderr.String(), status
+	}
+	hunk := func(path string, line int) string {
+		return fmt.Sprintf("--- %s\n+++ %s\n@@ -%d +%d @@\n-x\n+y\n", path, path, line, line)
+	}
+
+	// Only violations on touched lines are reported.
+	stdout, _, status := run(hunk(file, 6))
+	assert.Equal(t, statusInvalidFile, status)
+	assert.Equal(t, file+":6: Import in incorrect group at \"os\" [GI004]\n", stdout)
+	stdout, _, status = run(hunk(file, 10))
+	assert.Equal(t, 0, status)
+	assert.Equal(t, "", stdout)
+
+	// Paths limit which files of the diff are processed.
+	stdout, _, status = run(hunk(file, 6), "testdata")
+	assert.Equal(t, 0, status)
+	assert.Equal(t, "", stdout)
+
+	missing := filepath.Join(dir, "missing.go")
+	_, stderr, status := run(hunk(missing, 1))
+	assert.Equal(t, 0, status)
+	assert.Contains(t, stderr, "Warning: "+missing+": in the diff, but not found")
+
+	_, stderr, status = run("", "-rewrite")
+	assert.Equal(t, statusHelp, status)
+	assert.Contains(t, stderr, "-diff can't be used with -rewrite")
+}
+
+func TestOutputFlag(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	invalid, err := ioutil.ReadFile("testdata/invalid.go")
+	assert.Nil(t, err)
+	valid, err := ioutil.ReadFile("testdata/valid.go")
+	assert.Nil(t, err)
+	output := filepath.Join(dir, "out.go")
+
+	// The fixed content is written, leaving the input alone.
+	_, stderr, status := runCommand("-output", output, "-no-goimports", "testdata/invalid.go")
+	assert.Equal(t, 0, status, stderr)
+	written, err := ioutil.ReadFile(output)
+	assert.Nil(t, err)
+	assert.Equal(t, string(valid), string(written))
+	unchanged, err := ioutil.ReadFile("testdata/invalid.go")
+	assert.Nil(t, err)
+	assert.Equal(t, string(invalid), string(unchanged))
+
+	// Content that needs no fixing is written as it is, replacing what's there.
+	_, stderr, status = runCommand("-output", output, "-no-goimports", "testdata/valid.go")
+	assert.Equal(t, 0, status, stderr)
+	written, err = ioutil.ReadFile(output)
+	assert.Nil(t, err)
+	assert.Equal(t, string(valid), string(written))
+
+	// Standard input can be the input.
+	var stdout, errout bytes.Buffer
+	assert.Nil(t, os.Remove(output))
+	status = Run([]string{"-output", output, "-no-goimports", "-"}, bytes.NewReader(invalid), &stdout, &errout)
+	assert.Equal(t, 0, status, errout.String())
+	written, err = ioutil.ReadFile(output)
+	assert.Nil(t, err)
+	assert.Equal(t, string(valid), string(written))
+
+	_, stderr, status = runCommand("-output", output, "testdata/valid.go", "testdata/invalid.go")
+	assert.Equal(t, statusHelp, status)
+	assert.Contains(t, stderr, "-output needs exactly one input file")
+	_, stderr, status = runCommand("-output", output, "testdata")
+	assert.Equal(t, statusHelp, status)
+	assert.Contains(t, stderr, "-output needs a file, not a directory")
+	_, stderr, status = runCommand("-output", output, "-rewrite", "testdata/valid.go")
+	assert.Equal(t, statusHelp, status)
+	assert.Contains(t, stderr, "-output can't be used with -rewrite")
+	_, _, status = runCommand("-output", output, "testdata/broken.go")
+	assert.Equal(t, statusParseError, status)
+}
+
+func TestAlwaysPrint(t *testing.T) {
+	t.Parallel()
+
+	invalid, err := ioutil.ReadFile("testdata/invalid.go")
+	assert.Nil(t, err)
+	valid, err := ioutil.ReadFile("testdata/valid.go")
+	assert.Nil(t, err)
+
+	// The fixed content is printed, with a status that says it was fixed.
+	var stdout, stderr bytes.Buffer
+	status := Run([]string{"-always-print", "-no-goimports", "-"}, bytes.NewReader(invalid), &stdout, &stderr)
+	assert.Equal(t, statusInvalidFile, status, stderr.String())
+	assert.Equal(t, string(valid), stdout.String())
+
+	// Content that needs no fixing is printed as it is.
+	out, errout, status := runCommand("-always-print", "-no-goimports", "testdata/valid.go")
+	assert.Equal(t, 0, status, errout)
+	assert.Equal(t, string(valid), out)
+
+	_, errout, status = runCommand("-always-print", "testdata/valid.go", "testdata/invalid.go")
+	assert.Equal(t, statusHelp, status)
+	assert.Contains(t, errout, "-always-print needs exactly one input file")
+	_, errout, status = runCommand("-always-print", "-output", "out.go", "testdata/valid.go")
+	assert.Equal(t, statusHelp, status)
+	assert.Contains(t, errout, "-output can't be used with -always-print")
+	out, _, status = runCommand("-always-print", "testdata/broken.go")
+	assert.Equal(t, statusParseError, status)
+	assert.Equal(t, "", out)
+}
+
+func TestWorkspaceModules(t *testing.T) {
+	t.Parallel()
+	if os.Getenv("GOWORK") != "" {
+		t.Skip("GOWORK is set")
+	}
+
+	dir, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	for name, content := range map[string]string{
+		"go.work":            "go 1.18\n\nuse (\n\t./a // The main module.\n\t\"./b\"\n)\n\nuse ./c\n",
+		"a/go.mod":           "module example.com/a\n",
+		"a/sub/x.go":         "package sub\n",
+		"b/go.mod":           "module \"example.com/b\"\n",
+		"c/go.mod":           "module example.com/c\n",
+		"outside/go.mod":     "module example.com/outside\n",
+		"outside/sub/dir.go": "package sub\n",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+
+	// Every module of the workspace is local, starting with the file's own.
+	ws := workspaces{}
+	assert.Equal(t, []string{"example.com/a", "example.com/b", "example.com/c"},
+		ws.localModules(filepath.Join(dir, "a", "sub")))
+	assert.Equal(t, []string{"example.com/c", "example.com/a", "example.com/b"},
+		ws.localModules(filepath.Join(dir, "c")))
+	assert.Equal(t, 1, len(ws))
+
+	// Modules the workspace doesn't use are on their own.
+	assert.Equal(t, []string{"example.com/outside"}, ws.localModules(filepath.Join(dir, "outside", "sub")))
+
+	stdout, _, _ := runCommand("why", "-order", "std,other", "example.com/b/pkg",
+		filepath.Join(dir, "a", "sub", "x.go"))
+	assert.Equal(t, "example.com/b/pkg: group 1, matched other declared 2nd in the order from -order; "+
+		"within module example.com/b\n", stdout)
+}
+
+func TestGopathRepo(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	first, second := filepath.Join(dir, "first"), filepath.Join(dir, "second")
+	gopath := first + string(filepath.ListSeparator) + second
+	src := filepath.Join(second, "src")
+
+	assert.Equal(t, "github.com/org/repo",
+		gopathRepo(filepath.Join(src, "github.com", "org", "repo", "pkg", "sub"), gopath))
+	assert.Equal(t, "github.com/org/repo", gopathRepo(filepath.Join(first, "src", "github.com", "org", "repo"), gopath))
+	assert.Equal(t, "corp", gopathRepo(filepath.Join(src, "corp", "svc", "pkg"), gopath))
+	assert.Equal(t, "github.com/org", gopathRepo(filepath.Join(src, "github.com", "org"), gopath))
+
+	// Outside any GOPATH, there's no repository.
+	assert.Equal(t, "", gopathRepo(src, gopath))
+	assert.Equal(t, "", gopathRepo(filepath.Join(second, "pkg", "mod"), gopath))
+	assert.Equal(t, "", gopathRepo(filepath.Join(dir, "elsewhere"), gopath))
+	assert.Equal(t, "", gopathRepo(filepath.Join(src, "corp"), ""))
+}
+
+// A format that fails once everything is written.
+type failingFormatter struct{}
+
+func (failingFormatter) Begin(w io.Writer)                   {}
+func (failingFormatter) WriteResult(res *gogroup.FileResult) {}
+func (failingFormatter) End() error                          { return errors.New("Disk full") }
+
+func TestRegisteredFormat(t *testing.T) {
+	t.Parallel()
+
+	gogroup.RegisterFormat("cli-test-failing", func() gogroup.Formatter { return failingFormatter{} })
+	_, stderr, status := runCommand("-format", "cli-test-failing", "testdata/valid.go")
+	assert.Equal(t, statusError, status)
+	assert.Contains(t, stderr, "Disk full")
+
+	_, stderr, status = runCommand("-format", "cli-test-missing", "testdata/valid.go")
+	assert.Equal(t, statusHelp, status)
+	assert.Contains(t, stderr, "cli-test-failing")
+}
+
+func TestSeverity(t *testing.T) {
+	t.Parallel()
+
+	// Warnings are reported, but don't fail unless asked to.
+	stdout, _, status := runCommand("-severity", "statement-group:warning", "testdata/invalid.go")
+	assert.Equal(t, 0, status)
+	assert.Equal(t, "testdata/invalid.go:5: warning: Import in incorrect group at \"github.com/example/dep\" [GI004]\n"+
+		"testdata/invalid.go:6: warning: Import in incorrect group at \"os\" [GI004]\n", stdout)
+	_, _, status = runCommand("-severity", "GI004:warning", "-warnings-as-errors", "testdata/invalid.go")
+	assert.Equal(t, statusInvalidFile, status)
+	_, _, status = runCommand("-severity", "GI004:info", "-warnings-as-errors", "testdata/invalid.go")
+	assert.Equal(t, 0, status)
+	_, stderr, _ := runCommand("check", "-severity", "GI004:warning", "-summary", "testdata/invalid.go")
+	assert.Contains(t, stderr, "Violations by severity: warning: 2\n")
+
+	_, stderr, status = runCommand("-severity", "statement-nothing:warning", "testdata/invalid.go")
+	assert.Equal(t, statusHelp, status)
+	assert.Contains(t, stderr, "Unknown rule 'statement-nothing'")
+	_, stderr, status = runCommand("-severity", "GI004:fatal", "testdata/invalid.go")
+	assert.Equal(t, statusHelp, status)
+	assert.Contains(t, stderr, "Unknown severity 'fatal'")
+
+	// The configuration file sets severities too, but flags take precedence.
+	dir, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	src := "package a\n\nimport (\n\t\"os\"\n\n\t\"fmt\"\n)\n"
+	file := filepath.Join(dir, "a.go")
+	assert.Nil(t, ioutil.WriteFile(file, []byte(src), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, configFileName),
+		[]byte(`{"severity": {"statement-extra-line": "warning"}}`), 0644))
+	stdout, _, status = runCommand("check", file)
+	assert.Equal(t, 0, status)
+	assert.Contains(t, stdout, ":6: warning: Extra empty line inside import group")
+	_, _, status = runCommand("check", "-severity", "GI003:error", file)
+	assert.Equal(t, statusInvalidFile, status)
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, configFileName),
+		[]byte(`{"severity": {"GI003": "loud"}}`), 0644))
+	_, stderr, status = runCommand("check", file)
+	assert.Equal(t, statusHelp, status)
+	assert.Contains(t, stderr, "Unknown severity 'loud'")
+}
+
+func TestDisable(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	src := "package a\n\nimport (\n\t\"os\"\n\t\"fmt\"\n\t\"github.com/x/y\"\n)\n"
+	file := filepath.Join(dir, "a.go")
+	assert.Nil(t, ioutil.WriteFile(file, []byte(src), 0644))
+
+	// A disabled rule has no violations, while others still do.
+	stdout, _, status := runCommand("check", file)
+	assert.Equal(t, statusInvalidFile, status)
+	assert.Contains(t, stdout, "[GI001]")
+	stdout, _, status = runCommand("check", "-disable", "statement-order", file)
+	assert.Equal(t, statusInvalidFile, status)
+	assert.NotContains(t, stdout, "[GI001]")
+	assert.Contains(t, stdout, "[GI006]")
+	_, _, status = runCommand("check", "-disable", "GI001,GI006", file)
+	assert.Equal(t, 0, status)
+
+	_, stderr, status := runCommand("check", "-disable", "GI001,alphabetical", file)
+	assert.Equal(t, statusHelp, status)
+	assert.Contains(t, stderr, "Unknown rule 'alphabetical', expected one of: GI001 (statement-order), ")
+
+	// Rewrites keep the order within groups when it isn't checked.
+	_, _, status = runCommand("fix", "-no-goimports", "-disable", "statement-order", file)
+	assert.Equal(t, 0, status)
+	fixed, err := ioutil.ReadFile(file)
+	assert.Nil(t, err)
+	assert.Equal(t, "package a\n\nimport (\n\t\"os\"\n\t\"fmt\"\n\n\t\"github.com/x/y\"\n)\n", string(fixed))
+
+	// The configuration file disables rules too.
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, configFileName), []byte(`{"disable": ["GI001"]}`), 0644))
+	_, _, status = runCommand("check", file)
+	assert.Equal(t, 0, status)
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, configFileName), []byte(`{"disable": ["GI999"]}`), 0644))
+	_, stderr, status = runCommand("check", file)
+	assert.Equal(t, statusHelp, status)
+	assert.Contains(t, stderr, "Unknown rule 'GI999'")
+}
+
+func TestConfigSortLenient(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	src := "package a\n\nimport (\n\t\"os\"\n\t\"example.com/v9\"\n\t\"example.com/v10\"\n)\n"
+	file := filepath.Join(dir, "a.go")
+	assert.Nil(t, ioutil.WriteFile(file, []byte(src), 0644))
+	stdout, _, status := runCommand("check", file)
+	assert.Equal(t, statusInvalidFile, status)
+	assert.Contains(t, stdout, "[GI001]")
+
+	// The configuration file sets the sort mode and leniency, but flags take
+	// precedence.
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, configFileName),
+		[]byte(`{"sort": "natural", "lenient": true}`), 0644))
+	_, _, status = runCommand("check", file)
+	assert.Equal(t, 0, status)
+	stdout, _, status = runCommand("check", "-sort", "alpha", file)
+	assert.Equal(t, statusInvalidFile, status)
+	assert.Contains(t, stdout, "[GI001]")
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, configFileName), []byte("{\n\"sort\": \"random\"}"), 0644))
+	_, stderr, status := runCommand("check", file)
+	assert.Equal(t, statusHelp, status)
+	assert.Contains(t, stderr, "line 2 (sort): Unknown sort mode 'random'")
+}
+
+func TestBaseline(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "a.go")
+	assert.Nil(t, ioutil.WriteFile(file, []byte("package a\n\nimport (\n\t\"os\"\n\t\"fmt\"\n)\n"), 0644))
+	baseline := filepath.Join(dir, "baseline.json")
+
+	stdout, stderr, status := runCommand("check", "-write-baseline", baseline, file)
+	assert.Equal(t, 0, status)
+	assert.Equal(t, "", stdout)
+	assert.Equal(t, "Wrote 1 violations to the baseline "+baseline+"\n", stderr)
+	data, err := ioutil.ReadFile(baseline)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), "\"rule\": \"GI001\",\n      \"import\": \"fmt\"")
+
+	// Recorded violations are tolerated, but new ones aren't.
+	stdout, stderr, status = runCommand("check", "-baseline", baseline, "-summary", file)
+	assert.Equal(t, 0, status)
+	assert.Equal(t, "", stdout)
+	assert.Contains(t, stderr, "Baselined: 1\n")
+	assert.Nil(t, ioutil.WriteFile(file, []byte("package a\n\nimport (\n\t\"os\"\n\t\"fmt\"\n\t\"bytes\"\n)\n"), 0644))
+	stdout, _, status = runCommand("check", "-baseline", baseline, file)
+	assert.Equal(t, statusInvalidFile, status)
+	assert.Contains(t, stdout, "at \"bytes\" [GI001]")
+	assert.NotContains(t, stdout, "\"fmt\"")
+
+	// Fixed violations leave stale entries.
+	assert.Nil(t, ioutil.WriteFile(file, []byte("package a\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n"), 0644))
+	_, stderr, status = runCommand("check", "-baseline", baseline, file)
+	assert.Equal(t, 0, status)
+	assert.Contains(t, stderr, "stale baseline entry, 1 fewer violations: ")
+	assert.Contains(t, stderr, "GI001 at \"fmt\"\n")
+
+	_, stderr, status = runCommand("-baseline", baseline, "-rewrite", file)
+	assert.Equal(t, statusHelp, status)
+	assert.Contains(t, stderr, "can't be used with -rewrite")
+	_, _, status = runCommand("check", "-write-baseline", baseline, "-fail-fast", file)
+	assert.Equal(t, statusHelp, status)
+	_, stderr, status = runCommand("check", "-baseline", filepath.Join(dir, "missing.json"), file)
+	assert.Equal(t, statusError, status)
+	assert.Contains(t, stderr, "missing.json")
+}
+
+func TestFixOnly(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	src := "package a\n\nimport (\n\t\"os\"\n\t\"github.com/x/y\"\n\t\"fmt\"\n\n\t\"bytes\"\n)\n"
+	file := filepath.Join(dir, "a.go")
+
+	for _, c := range []struct {
+		args  []string
+		fixed string
+	}{
+		{
+			[]string{"-no-fix", "statement-group"},
+			"package a\n\nimport (\n\t\"bytes\"\n\t\"fmt\"\n\t\"github.com/x/y\"\n\t\"os\"\n)\n",
+		},
+		{
+			[]string{"-fix-only", "GI004"},
+			"package a\n\nimport (\n\t\"os\"\n\t\"fmt\"\n\t\"bytes\"\n\n\t\"github.com/x/y\"\n)\n",
+		},
+		{
+			[]string{"-fix-only", "statement-order"},
+			src,
+		},
+	} {
+		assert.Nil(t, ioutil.WriteFile(file, []byte(src), 0644))
+		args := append([]string{"fix", "-no-goimports"}, c.args...)
+		_, stderr, status := runCommand(append(args, file)...)
+		assert.Equal(t, 0, status, stderr)
+		data, err := ioutil.ReadFile(file)
+		assert.Nil(t, err)
+		assert.Equal(t, c.fixed, string(data), c.args)
+	}
+
+	// Unfixed violations are still reported.
+	stdout, _, status := runCommand("check", file)
+	assert.Equal(t, statusInvalidFile, status)
+	assert.Contains(t, stdout, "[GI004]")
+
+	_, stderr, status := runCommand("fix", "-no-fix", "statement-nothing", file)
+	assert.Equal(t, statusHelp, status)
+	assert.Contains(t, stderr, "Unknown rule 'statement-nothing'")
+}
+
+func TestShowSuppressed(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "a.go")
+	src := "package a\n\nimport (\n\t\"strings\"\n\t\"os\" //nolint:gogroup\n)\n"
+	assert.Nil(t, ioutil.WriteFile(file, []byte(src), 0644))
+
+	stdout, stderr, status := runCommand("check", "-summary", file)
+	assert.Equal(t, 0, status)
+	assert.Equal(t, "", stdout)
+	assert.Contains(t, stderr, "Suppressed: 1\n")
+	assert.NotContains(t, stderr, "Suppressed: "+file)
+
+	_, stderr, status = runCommand("check", "-show-suppressed", file)
+	assert.Equal(t, 0, status)
+	assert.Equal(t, "Suppressed: "+file+":5: Import out of order within import group at \"os\" [GI001]\n"+
+		"1 violations suppressed\n", stderr)
+
+	// Rewrites leave the file alone.
+	_, _, status = runCommand("fix", "-no-goimports", file)
+	assert.Equal(t, 0, status)
+	data, err := ioutil.ReadFile(file)
+	assert.Nil(t, err)
+	assert.Equal(t, src, string(data))
+
+	_, _, status = runCommand("check", "-ignore-directives", file)
+	assert.Equal(t, statusInvalidFile, status)
+}
+
+func TestTeamcityFormat(t *testing.T) {
+	t.Parallel()
+
+	// Only service messages go to standard output.
+	stdout, _, status := runCommand("check", "-format", "teamcity", "-summary", "testdata/invalid.go",
+		"testdata/broken.go")
+	assert.Equal(t, statusParseError, status)
+	lines := strings.Split(strings.TrimSuffix(stdout, "\n"), "\n")
+	for _, line := range lines {
+		assert.True(t, strings.HasPrefix(line, "##teamcity["), line)
+	}
+	assert.Contains(t, stdout, "##teamcity[inspection typeId='GI004' message='Import in incorrect group at \"github.com/example/dep\"' file='testdata/invalid.go' line='5' SEVERITY='ERROR']\n")
+	assert.Contains(t, stdout, "##teamcity[buildProblem description='")
+	assert.Equal(t, "##teamcity[buildStatisticValue key='gogroupViolations' value='2']", lines[len(lines)-1])
+}
+
+func TestProfiling(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// Profiles are written even when the command fails.
+	cpu, mem, trace := filepath.Join(dir, "cpu.pprof"), filepath.Join(dir, "mem.pprof"), filepath.Join(dir, "trace.out")
+	_, stderr, status := runCommand("check", "-cpuprofile", cpu, "-memprofile", mem, "-trace", trace, "-debug-timing",
+		"testdata/invalid.go")
+	assert.Equal(t, statusInvalidFile, status)
+	for _, path := range []string{cpu, mem, trace} {
+		info, err := os.Stat(path)
+		if assert.Nil(t, err) {
+			assert.NotZero(t, info.Size(), path)
+		}
+	}
+	for _, phase := range []string{"walk", "parse", "group", "check", "repair", "output", "total"} {
+		assert.Regexp(t, "(?m)^Timing: "+phase+" +[0-9]", stderr)
+	}
+	assert.NotContains(t, stderr, "Timing: goimports")
+
+	_, stderr, status = runCommand("check", "-cpuprofile", filepath.Join(dir, "missing", "cpu.pprof"),
+		"testdata/valid.go")
+	assert.Equal(t, statusError, status)
+	assert.Contains(t, stderr, "no such file")
+}
+
+func TestDebugLog(t *testing.T) {
+	t.Parallel()
+
+	_, stderr, status := runCommand("check", "-debug", "testdata/valid.go")
+	assert.Equal(t, 0, status)
+	assert.Regexp(t, `(?m)^time=\S+ level=DEBUG msg="group assigned" file=testdata/valid.go import=os group=0 spec=std$`,
+		stderr)
+
+	_, stderr, status = runCommand("check", "testdata/valid.go")
+	assert.Equal(t, 0, status)
+	assert.Empty(t, stderr)
+}
+
+func TestOffsetsFormat(t *testing.T) {
+	t.Parallel()
+
+	stdout, _, status := runCommand("check", "-format", "offsets", "testdata/invalid.go")
+	assert.Equal(t, statusInvalidFile, status)
+	assert.Equal(t, "testdata/invalid.go:#35-59: Import in incorrect group (import \"github.com/example/dep\") [GI004]\n"+
+		"testdata/invalid.go:#61-65: Import in incorrect group (import \"os\") [GI004]\n", stdout)
+
+	// The offsets delimit the import in the file.
+	src, err := ioutil.ReadFile("testdata/invalid.go")
+	assert.Nil(t, err)
+	assert.Equal(t, `"github.com/example/dep"`, string(src[35:59]))
+}
+
+func TestCodeClimateFormat(t *testing.T) {
+	t.Parallel()
+
+	stdout, _, status := runCommand("check", "-format", "codeclimate", "-path-root", "testdata",
+		"testdata/invalid.go")
+	assert.Equal(t, statusInvalidFile, status)
+	var issues []map[string]interface{}
+	assert.Nil(t, json.Unmarshal([]byte(stdout), &issues))
+	if assert.Equal(t, 2, len(issues)) {
+		assert.Equal(t, "GI004", issues[0]["check_name"])
+		assert.Equal(t, map[string]interface{}{"path": "invalid.go", "lines": map[string]interface{}{"begin": 5.0}},
+			issues[0]["location"])
+	}
+
+	// Paths are relative to the top level of the repository by default, if
+	// the tests are run in one.
+	path := "testdata/invalid.go"
+	if top, err := gitOutput("rev-parse", "--show-toplevel"); err == nil {
+		abs, err := filepath.Abs(path)
+		assert.Nil(t, err)
+		rel, err := filepath.Rel(filepath.FromSlash(strings.TrimSpace(string(top))), abs)
+		assert.Nil(t, err)
+		path = filepath.ToSlash(rel)
+	}
+	stdout, _, _ = runCommand("check", "-format", "codeclimate", "testdata/invalid.go")
+	assert.Contains(t, stdout, "\"path\": \""+path+"\"")
+
+	_, stderr, status := runCommand("check", "-path-root", "testdata", "testdata/invalid.go")
+	assert.Equal(t, statusHelp, status)
+	assert.Contains(t, stderr, "-path-root requires -format codeclimate")
+}
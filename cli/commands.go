@@ -0,0 +1,615 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/vasi-stripe/gogroup"
+)
+
+// Options for processing files, set by flags. Each subcommand accepts only
+// the flags that make sense for it.
+type options struct {
+	gr               *gogroup.SpecGrouper
+	orderJSON        orderJSONFlag
+	printOrderJSON   bool
+	ignoreDirectives bool
+	sortMode         sortFlag
+	blocks           blocksFlag
+	lenient          bool
+	commentSeps      bool
+	headers          bool
+	stripComments    regexpFlag
+	duplicates       bool
+	allowRelative    bool
+	deny             denyFlag
+	severity         severityFlag
+	disable          rulesFlag
+	warningsAsErrors bool
+	aliasRules       []gogroup.AliasRule
+	forbiddenAliases []string
+	layers           []gogroup.LayerRule
+	cfgSeverity      map[string]gogroup.Severity
+	cfgDisable       []string
+
+	debugConfig        bool
+	diff               string
+	followSymlinks     bool
+	since              string
+	maxFileSize        byteSize
+	tests              testsFlag
+	buildContext       bool
+	goos, goarch, tags string
+
+	useCache bool
+	cacheDir string
+	noCache  bool
+
+	cpuProfile, memProfile, traceFile string
+	debugTiming, debug                bool
+	timings                           *gogroup.Timings
+	logger                            *slog.Logger
+
+	format, templateText string
+	count                bool
+	countBy              string
+	summary              bool
+	summaryFormat        string
+	list                 bool
+	violationsTo         string
+	showSuppressed       bool
+	pathRoot             string
+	maxViolations        int
+	failFast             bool
+	baseline             string
+	writeBaseline        string
+
+	rewrite, noGoimports, minimal, fixAliases bool
+	keepIndentation                           bool
+	fixOnly, noFix                            rulesFlag
+	outputPath                                string
+	alwaysPrint                               bool
+}
+
+func newOptions() *options {
+	gr := gogroup.NewSpecGrouper()
+	return &options{gr: gr, orderJSON: orderJSONFlag{gr: gr}, tests: testsFlag(gogroup.TestsInclude)}
+}
+
+// Add the flags used by every way of processing files.
+func (o *options) commonFlags(flags *flag.FlagSet) {
+	flags.Var(o.gr, "order", "")
+	flags.Var(&o.orderJSON, "order-json", "")
+	flags.BoolVar(&o.printOrderJSON, "print-order-json", false, "")
+	flags.BoolVar(&o.ignoreDirectives, "ignore-directives", false, "")
+	flags.Var(&o.sortMode, "sort", "")
+	flags.Var(&o.blocks, "blocks", "")
+	flags.BoolVar(&o.lenient, "lenient", false, "")
+	flags.BoolVar(&o.commentSeps, "comment-separators", false, "")
+	flags.BoolVar(&o.headers, "headers", false, "")
+	flags.Var(&o.stripComments, "strip-comments", "")
+	flags.BoolVar(&o.duplicates, "duplicates", false, "")
+	flags.BoolVar(&o.allowRelative, "allow-relative", false, "")
+	flags.Var(&o.deny, "deny", "")
+	flags.Var(&o.severity, "severity", "")
+	flags.Var(&o.disable, "disable", "")
+	flags.BoolVar(&o.warningsAsErrors, "warnings-as-errors", false, "")
+	flags.BoolVar(&o.summary, "summary", false, "")
+	flags.StringVar(&o.summaryFormat, "summary-format", "", "")
+
+	flags.BoolVar(&o.followSymlinks, "follow-symlinks", false, "")
+	flags.StringVar(&o.since, "since", "", "")
+	flags.Var(&o.maxFileSize, "max-file-size", "")
+	flags.Var(&o.tests, "tests", "")
+	flags.BoolVar(&o.buildContext, "build-context", false, "")
+	flags.StringVar(&o.goos, "goos", "", "")
+	flags.StringVar(&o.goarch, "goarch", "", "")
+	flags.StringVar(&o.tags, "tags", "", "")
+
+	flags.BoolVar(&o.useCache, "cache", false, "")
+	flags.StringVar(&o.cacheDir, "cache-dir", "", "")
+	flags.BoolVar(&o.noCache, "no-cache", false, "")
+
+	flags.StringVar(&o.cpuProfile, "cpuprofile", "", "")
+	flags.StringVar(&o.memProfile, "memprofile", "", "")
+	flags.StringVar(&o.traceFile, "trace", "", "")
+	flags.BoolVar(&o.debugTiming, "debug-timing", false, "")
+	flags.BoolVar(&o.debug, "debug", false, "")
+}
+
+// Add the flags for how configuration files are used by commands that
+// process files.
+func (o *options) configFlags(flags *flag.FlagSet) {
+	flags.BoolVar(&o.debugConfig, "debug-config", false, "")
+}
+
+// Add the flags for reporting violations.
+func (o *options) outputFlags(flags *flag.FlagSet) {
+	flags.StringVar(&o.format, "format", "", "")
+	flags.StringVar(&o.templateText, "template", "", "")
+	flags.BoolVar(&o.count, "count", false, "")
+	flags.StringVar(&o.countBy, "count-by", "", "")
+	flags.StringVar(&o.violationsTo, "violations-to", "", "")
+	flags.BoolVar(&o.showSuppressed, "show-suppressed", false, "")
+	flags.StringVar(&o.pathRoot, "path-root", "", "")
+	o.limitFlags(flags)
+}
+
+// Add the flags for limiting how much is printed.
+func (o *options) limitFlags(flags *flag.FlagSet) {
+	flags.IntVar(&o.maxViolations, "max-violations", 0, "")
+	flags.BoolVar(&o.failFast, "fail-fast", false, "")
+	flags.StringVar(&o.diff, "diff", "", "")
+	flags.StringVar(&o.baseline, "baseline", "", "")
+	flags.StringVar(&o.writeBaseline, "write-baseline", "", "")
+}
+
+// Add the flags for how to rewrite files.
+func (o *options) rewriteFlags(flags *flag.FlagSet) {
+	flags.BoolVar(&o.noGoimports, "no-goimports", false, "")
+	flags.BoolVar(&o.minimal, "minimal", false, "")
+	flags.BoolVar(&o.fixAliases, "fix-aliases", false, "")
+	flags.BoolVar(&o.keepIndentation, "keep-indentation", false, "")
+	flags.Var(&o.fixOnly, "fix-only", "")
+	flags.Var(&o.noFix, "no-fix", "")
+}
+
+// Create the processor the options describe.
+func (o *options) processor() *gogroup.Processor {
+	return gogroup.NewProcessor(o.gr, o.processorOptions()...)
+}
+
+// Yield the options of the processor the options describe.
+func (o *options) processorOptions() []gogroup.Option {
+	var headers map[int]string
+	if o.headers {
+		headers = o.gr.Headers()
+	}
+	// Flags take precedence over the configuration file.
+	severities := map[string]gogroup.Severity{}
+	for _, levels := range []map[string]gogroup.Severity{o.cfgSeverity, o.severity} {
+		for rule, severity := range levels {
+			severities[rule] = severity
+		}
+	}
+	opts := []gogroup.Option{gogroup.MinimalPatch(o.minimal),
+		gogroup.IgnoreDirectives(o.ignoreDirectives), gogroup.Sort(o.sortMode.mode),
+		gogroup.Blocks(gogroup.BlockStyle(o.blocks)), gogroup.Lenient(o.lenient),
+		gogroup.CommentSeparators(o.commentSeps),
+		gogroup.GroupHeaders(headers), gogroup.StripComments(o.stripComments.re),
+		gogroup.Duplicates(o.duplicates), gogroup.Aliases(o.aliasRules, o.forbiddenAliases),
+		gogroup.FixAliases(o.fixAliases), gogroup.AllowRelativeImports(o.allowRelative),
+		gogroup.Deny(o.deny), gogroup.Layers(o.layers), gogroup.KeepIndentation(o.keepIndentation),
+		gogroup.Severities(severities), gogroup.DisableRules(o.disabledRules()...),
+		gogroup.NoFix(o.noFix...), gogroup.RecordTimings(o.timings),
+		gogroup.WithLogger(o.logger)}
+	if o.fixOnly != nil {
+		opts = append(opts, gogroup.FixOnly(o.fixOnly...))
+	}
+	return opts
+}
+
+// Yield the rules not to check, from both flags and the configuration file.
+func (o *options) disabledRules() []string {
+	return append(append([]string{}, o.disable...), o.cfgDisable...)
+}
+
+// Describe the options that affect whether a file is valid, to key the cache.
+func (o *options) cacheConfig() string {
+	aliases := []string{}
+	for _, rule := range o.aliasRules {
+		aliases = append(aliases, fmt.Sprintf("%q=%s", rule.Pattern.String(), rule.Alias))
+	}
+	deny := []string{}
+	for _, rule := range o.deny {
+		pattern := ""
+		if rule.Pattern != nil {
+			pattern = rule.Pattern.String()
+		}
+		deny = append(deny, fmt.Sprintf("%q/%q/%q", rule.Path, rule.Prefix, pattern))
+	}
+	layers := []string{}
+	for _, rule := range o.layers {
+		layers = append(layers, fmt.Sprintf("%q:%q/%q", rule.Dir, rule.Allow, rule.Deny))
+	}
+	return fmt.Sprintf("order=%s ignore-directives=%t sort=%s blocks=%s lenient=%t headers=%t "+
+		"strip-comments=%q duplicates=%t aliases=%s forbidden-aliases=%s allow-relative=%t deny=%s "+
+		"layers=%s comment-separators=%t disable=%s",
+		o.gr.String(), o.ignoreDirectives, o.sortMode.String(), o.blocks.String(), o.lenient,
+		o.headers, o.stripComments.String(), o.duplicates, strings.Join(aliases, ","),
+		strings.Join(o.forbiddenAliases, ","), o.allowRelative, strings.Join(deny, ","),
+		strings.Join(layers, ","), o.commentSeps, strings.Join(o.disabledRules(), ","))
+}
+
+// Create the output the options describe.
+func (o *options) output() (*output, error) {
+	out, err := newOutput(o.format, o.templateText)
+	if err != nil {
+		return nil, err
+	}
+	if err = out.setCount(o.count, o.countBy); err != nil {
+		return nil, err
+	}
+	if err = out.setSummary(o.summary, o.summaryFormat); err != nil {
+		return nil, err
+	}
+	if o.maxViolations < 0 {
+		return nil, errors.New("-max-violations must not be negative")
+	}
+	out.list = o.list
+	if err = out.setViolationsTo(o.violationsTo); err != nil {
+		return nil, err
+	}
+	out.maxViolations = o.maxViolations
+	out.showSuppressed = o.showSuppressed
+	if err = out.setPathRoot(o.pathRoot); err != nil {
+		return nil, err
+	}
+	out.failAt = gogroup.SeverityError
+	if o.warningsAsErrors {
+		out.failAt = gogroup.SeverityWarning
+	}
+	return out, nil
+}
+
+// Create a flag set for a command, which writes its usage to w.
+func newFlagSet(name string, w io.Writer, usage func()) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(w)
+	flags.Usage = usage
+	return flags
+}
+
+// Parse flags, yielding whether to continue, and if not the status to exit
+// with.
+func parseFlags(flags *flag.FlagSet, args []string) (bool, int) {
+	if err := flags.Parse(args); err == flag.ErrHelp {
+		return false, 0
+	} else if err != nil {
+		return false, statusHelp
+	}
+	return true, 0
+}
+
+// Find and process the files and directories named by the remaining
+// arguments.
+func (c *command) process(o *options, out *output, flags *flag.FlagSet) int {
+	stop, err := c.startProfiling(o)
+	if err != nil {
+		return c.fail(statusError, err)
+	}
+	defer stop()
+	out.timings = o.timings
+
+	orderSet, base := o.gr.WasSet(), *o
+	if !orderSet {
+		if err := applyConfig(o.gr, "."); err != nil {
+			return c.fail(statusHelp, err)
+		}
+	}
+	if o.printOrderJSON {
+		return c.printOrder(o.gr)
+	}
+	if err := applyPolicyConfig(o, "."); err != nil {
+		return c.fail(statusHelp, err)
+	}
+	if o.gr.Strict() {
+		fmt.Fprintln(c.stderr, "Warning: the order is strict, so imports that match no group are violations")
+	}
+	if flags.NArg() == 0 && o.since == "" && o.diff == "" {
+		fmt.Fprintln(c.stderr, "No file provided.")
+		flags.Usage()
+		return statusHelp
+	}
+	if o.outputPath != "" || o.alwaysPrint {
+		return c.writeOutput(o, &base, orderSet, flags.Args())
+	}
+
+	if o.rewrite && o.failFast {
+		return c.fail(statusHelp, errors.New("-fail-fast can't be used with -rewrite"))
+	}
+	if o.diff != "" && (o.rewrite || o.failFast || o.since != "") {
+		return c.fail(statusHelp, errors.New("-diff can't be used with -rewrite, -fail-fast or -since"))
+	}
+	if (o.baseline != "" || o.writeBaseline != "") && o.rewrite {
+		return c.fail(statusHelp, errors.New("-baseline and -write-baseline can't be used with -rewrite"))
+	}
+	if o.writeBaseline != "" && (o.baseline != "" || o.diff != "" || o.since != "" || o.failFast) {
+		return c.fail(statusHelp, errors.New("-write-baseline records every violation, so it can't be used "+
+			"with -baseline, -diff, -since or -fail-fast"))
+	}
+	if o.baseline != "" {
+		var err error
+		if out.baseline, err = readBaselineFile(o.baseline); err != nil {
+			return c.fail(statusError, err)
+		}
+	}
+	out.writeBaseline = o.writeBaseline
+
+	opts := gogroup.RunOptions{Rewrite: o.rewrite, Goimports: !o.noGoimports, FailFast: o.failFast}
+	if (o.useCache || o.cacheDir != "") && !o.noCache {
+		opts.Cache = openCache(c.stderr, o.cacheDir, o.cacheConfig())
+	}
+	found := &gogroup.FoundFiles{}
+	if o.diff != "" {
+		diff, err := c.readDiff(o.diff)
+		if err != nil {
+			return c.fail(statusError, err)
+		}
+		if found.Files, err = c.diffTargets(diff, flags.Args()); err != nil {
+			return c.fail(statusError, err)
+		}
+		out.lines = diff.lines
+	} else {
+		var err error
+		if found, err = c.findFiles(o, flags.Args()); err != nil {
+			return c.fail(statusError, err)
+		}
+	}
+	procs, err := base.processorsByFile(found.Files, orderSet, c.stderr)
+	if err != nil {
+		return c.fail(statusHelp, err)
+	}
+	opts.ProcessorFor = func(path string) *gogroup.Processor {
+		return procs[path]
+	}
+	return c.run(o.processor(), opts, out, found.Files,
+		gogroup.SkippedFiles(found.TooLarge, gogroup.SkipTooLarge))
+}
+
+// Print an order as a JSON rules document, for -print-order-json.
+func (c *command) printOrder(gr *gogroup.SpecGrouper) int {
+	data, err := gr.JSON()
+	if err != nil {
+		return c.fail(statusHelp, err)
+	}
+	fmt.Fprintln(c.stdout, string(data))
+	return 0
+}
+
+// Find the files named by paths, and warn about any problems. With -since,
+// only files that have changed are kept, and paths default to the current
+// directory.
+func (c *command) findFiles(o *options, paths []string) (*gogroup.FoundFiles, error) {
+	if o.since != "" && len(paths) == 0 {
+		paths = []string{"."}
+	}
+	defer o.timings.Start(gogroup.PhaseWalk)()
+	found, err := gogroup.FindFiles(paths, gogroup.FindOptions{
+		FollowSymlinks: o.followSymlinks,
+		MaxFileSize:    int64(o.maxFileSize),
+		Tests:          gogroup.TestFiles(o.tests),
+		BuildContext:   newBuildContext(o.buildContext, o.goos, o.goarch, o.tags),
+		Logger:         o.logger,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if o.since != "" {
+		if err = filterChanged(found, o.since); err != nil {
+			return nil, err
+		}
+	}
+	for _, warning := range found.Warnings {
+		fmt.Fprintf(c.stderr, "Warning: %s\n", warning)
+	}
+	return found, nil
+}
+
+// Parse the flags of a subcommand, then process the files it names.
+func (c *command) parseAndProcess(o *options, flags *flag.FlagSet, args []string) int {
+	if ok, status := parseFlags(flags, args); !ok {
+		return status
+	}
+	out, err := o.output()
+	if err != nil {
+		return c.fail(statusHelp, err)
+	}
+	return c.process(o, out, flags)
+}
+
+// Handle the "check" subcommand, which reports incorrect import grouping.
+func (c *command) check(args []string) int {
+	o := newOptions()
+	flags := newFlagSet("group-imports check", c.stderr, func() {
+		writeUsage(c.stderr, "Usage: group-imports check [OPTIONS] PATH...\n\n"+
+			"  Report incorrect import grouping.",
+			usageFind, usageOutput, usageSummary, usageCache, usageProfile, usageConfig, usageGrouping)
+	})
+	o.commonFlags(flags)
+	o.configFlags(flags)
+	o.outputFlags(flags)
+	return c.parseAndProcess(o, flags, args)
+}
+
+// Handle the "fix" subcommand, which rewrites files with the correct import
+// grouping.
+func (c *command) fix(args []string) int {
+	o := newOptions()
+	o.rewrite = true
+	flags := newFlagSet("group-imports fix", c.stderr, func() {
+		writeUsage(c.stderr, "Usage: group-imports fix [OPTIONS] PATH...\n\n"+
+			"  Rewrite files with the correct import grouping. Files are replaced\n"+
+			"  atomically where possible, so they're never left partially written.",
+			usageFind, usageRewriteOptions, usageSummary, usageCache, usageProfile, usageConfig, usageGrouping)
+	})
+	o.commonFlags(flags)
+	o.configFlags(flags)
+	o.rewriteFlags(flags)
+	return c.parseAndProcess(o, flags, args)
+}
+
+// Handle the "list" subcommand, which prints the names of files with incorrect
+// import grouping.
+func (c *command) listFiles(args []string) int {
+	o := newOptions()
+	o.list = true
+	flags := newFlagSet("group-imports list", c.stderr, func() {
+		writeUsage(c.stderr, "Usage: group-imports list [OPTIONS] PATH...\n\n"+
+			"  Print the name of each file with incorrect import grouping.",
+			usageFind, usageLimit, usageSummary, usageCache, usageProfile, usageConfig, usageGrouping)
+	})
+	o.commonFlags(flags)
+	o.configFlags(flags)
+	o.limitFlags(flags)
+	return c.parseAndProcess(o, flags, args)
+}
+
+// Handle the "stats" subcommand, which describes how imports are grouped,
+// without checking anything.
+func (c *command) stats(args []string) int {
+	o := newOptions()
+	flags := newFlagSet("group-imports stats", c.stderr, func() {
+		writeUsage(c.stderr, "Usage: group-imports stats [OPTIONS] PATH...\n\n"+
+			"  Describe what files import, and how their imports are grouped.",
+			usageFind, usageStats, usageProfile, usageGrouping)
+	})
+	o.commonFlags(flags)
+	top := flags.Int("top", 10, "")
+	format := flags.String("format", "text", "")
+	if ok, status := parseFlags(flags, args); !ok {
+		return status
+	}
+	if *format != "text" && *format != "json" {
+		return c.fail(statusHelp, fmt.Errorf("Unknown -format '%s', expected one of: text, json", *format))
+	}
+	stop, err := c.startProfiling(o)
+	if err != nil {
+		return c.fail(statusError, err)
+	}
+	defer stop()
+	if !o.gr.WasSet() {
+		if err := applyConfig(o.gr, "."); err != nil {
+			return c.fail(statusHelp, err)
+		}
+	}
+	if o.printOrderJSON {
+		return c.printOrder(o.gr)
+	}
+	if flags.NArg() == 0 && o.since == "" {
+		fmt.Fprintln(c.stderr, "No file provided.")
+		flags.Usage()
+		return statusHelp
+	}
+
+	found, err := c.findFiles(o, flags.Args())
+	if err != nil {
+		return c.fail(statusError, err)
+	}
+	stats, err := gogroup.CollectStats(found.Files, o.processor())
+	if err != nil {
+		return c.fail(errorStatus(err), err)
+	}
+	if *format == "json" {
+		err = stats.WriteStatsJSON(c.stdout, *top)
+	} else {
+		err = stats.WriteStats(c.stdout, *top)
+	}
+	if err != nil {
+		return c.fail(statusError, err)
+	}
+	return 0
+}
+
+// Handle the "rules" subcommand, which lists the ID, rule and description of
+// every kind of violation. It's left out of the usage, being mostly for those
+// writing configuration for other tools.
+func (c *command) rules(args []string) int {
+	flags := newFlagSet("group-imports rules", c.stderr, func() {
+		writeUsage(c.stderr, "Usage: group-imports rules\n\n"+
+			"  List the ID, rule and description of every kind of violation.")
+	})
+	if ok, status := parseFlags(flags, args); !ok {
+		return status
+	}
+	if flags.NArg() > 0 {
+		flags.Usage()
+		return statusHelp
+	}
+	for _, kind := range gogroup.Kinds() {
+		fmt.Fprintf(c.stdout, "%s  %-26s %s\n", kind.ID(), kind.Rule(), kind.Description())
+	}
+	return 0
+}
+
+// Subcommands that take their own flags, by name. A first argument with one of
+// these names is always the subcommand, never a path, so a path with such a
+// name must be written as "./check", or follow "--".
+var subcommands = map[string]func(c *command, args []string) int{
+	"check": (*command).check,
+	"fix":   (*command).fix,
+	"list":  (*command).listFiles,
+	"rules": (*command).rules,
+	"stats": (*command).stats,
+	"why":   (*command).why,
+}
+
+// Handle a command line: either a subcommand with its own flags, or the
+// legacy style with all flags before any paths or other subcommands. After
+// "--", every argument is a path, even if it names a command.
+func (c *command) main(args []string) int {
+	if len(args) > 0 {
+		if sub, ok := subcommands[args[0]]; ok {
+			return sub(c, args[1:])
+		}
+	}
+
+	o := newOptions()
+	flags := newFlagSet("group-imports", c.stderr, func() {
+		writeUsage(c.stderr, usageLegacy, usageFind, usageOutput, usageSummary, usageCache,
+			usageProfile, usageConfig, usageRewrite, usageRewriteOptions, usageGrouping,
+			usageHook, usageInit, usageLSP, usageServe, usageWorker)
+	})
+	o.commonFlags(flags)
+	o.configFlags(flags)
+	o.outputFlags(flags)
+	o.rewriteFlags(flags)
+	flags.BoolVar(&o.rewrite, "rewrite", false, "")
+	flags.StringVar(&o.outputPath, "output", "", "")
+	flags.BoolVar(&o.alwaysPrint, "always-print", false, "")
+	if ok, status := parseFlags(flags, args); !ok {
+		return status
+	}
+
+	out, err := o.output()
+	if err != nil {
+		return c.fail(statusHelp, err)
+	}
+	cmd := flags.Arg(0)
+	if afterDashes(args, flags.Args()) {
+		cmd = ""
+	}
+	switch cmd {
+	case "hook":
+		if err := applyPolicyConfig(o, "."); err != nil {
+			return c.fail(statusHelp, err)
+		}
+		return c.hook(o.processor(), o.gr, out, flags.Args()[1:])
+	case "init":
+		return c.initOrder(flags.Args()[1:])
+	case "lsp":
+		return newLSPServer(c.stdin, c.stdout, c.stderr, o.processor(), o.gr).serve()
+	case "serve":
+		if !o.gr.WasSet() {
+			if err := applyConfig(o.gr, "."); err != nil {
+				return c.fail(statusHelp, err)
+			}
+		}
+		if err := applyPolicyConfig(o, "."); err != nil {
+			return c.fail(statusHelp, err)
+		}
+		return newBatchServer(c.stdin, c.stdout, c.stderr, o.processor(), !o.noGoimports).serve()
+	}
+	return c.process(o, out, flags)
+}
+
+// Determine whether the positional arguments of a command line follow "--",
+// which ends the flags.
+func afterDashes(args, positional []string) bool {
+	i := len(args) - len(positional) - 1
+	return len(positional) > 0 && i >= 0 && args[i] == "--"
+}
@@ -0,0 +1,326 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/vasi-stripe/gogroup"
+)
+
+// The name of the configuration file. It is looked up in the working directory
+// and its parents.
+const configFileName = ".group-imports.json"
+
+// The environment variable holding an order specification. It takes precedence
+// over the configuration file.
+const orderEnvVar = "GROUP_IMPORTS_ORDER"
+
+// The contents of a configuration file, in the format of gogroup.Config.
+type config struct {
+	gogroup.Config
+
+	// The path the configuration was read from.
+	path string
+
+	// The configuration this one extends, if any, and the one its order comes
+	// from: this one, one it extends, or nil if none of them has an order.
+	parent *config
+	order  *config
+
+	// The compiled alias, deny and layering rules, severities and sort mode.
+	aliasRules []gogroup.AliasRule
+	denyRules  []gogroup.DenyRule
+	layerRules []gogroup.LayerRule
+	severities map[string]gogroup.Severity
+	sortMode   gogroup.SortMode
+}
+
+// Find the configuration file that applies to a directory, and parse it,
+// along with any configurations it extends.
+//
+// If there's no configuration file, yields nil.
+func findConfig(dir string) (*config, error) {
+	path, err := nearestConfig(dir)
+	if err != nil || path == "" {
+		return nil, err
+	}
+	return loadConfig(path, map[string]bool{})
+}
+
+// Find the path of the nearest configuration file in a directory or its
+// parents, or "" if there's none.
+func nearestConfig(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		path := filepath.Join(dir, configFileName)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// Read and parse a configuration file, and the configurations it extends.
+// Seen holds the paths of configurations that extend this one, to catch
+// cycles.
+func loadConfig(path string, seen map[string]bool) (*config, error) {
+	if seen[path] {
+		return nil, fmt.Errorf("%s: Configuration extends itself", path)
+	}
+	seen[path] = true
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := parseConfig(path, data)
+	if err != nil || cfg.Extends == "" {
+		return cfg, err
+	}
+
+	target := filepath.Join(filepath.Dir(path), filepath.FromSlash(cfg.Extends))
+	info, err := os.Stat(target)
+	if err != nil {
+		return nil, fmt.Errorf("%s: Can't extend '%s': %s", path, cfg.Extends, err.Error())
+	}
+	if info.IsDir() {
+		if target, err = nearestConfig(target); err != nil {
+			return nil, err
+		} else if target == "" {
+			return nil, fmt.Errorf("%s: No configuration to extend in '%s' or its parents", path, cfg.Extends)
+		}
+	}
+	parent, err := loadConfig(target, seen)
+	if err != nil {
+		return nil, err
+	}
+	cfg.inherit(parent)
+	return cfg, nil
+}
+
+// Take the settings a configuration lacks from the one it extends. Settings
+// that are present replace those of the parent entirely, even if empty.
+func (c *config) inherit(parent *config) {
+	c.parent = parent
+	if c.order == nil {
+		c.order = parent.order
+	}
+	if c.Aliases == nil {
+		c.Aliases, c.aliasRules = parent.Aliases, parent.aliasRules
+	}
+	if c.ForbiddenAliases == nil {
+		c.ForbiddenAliases = parent.ForbiddenAliases
+	}
+	if c.Deny == nil {
+		c.Deny, c.denyRules = parent.Deny, parent.denyRules
+	}
+	if c.Layers == nil {
+		c.Layers, c.layerRules = parent.Layers, parent.layerRules
+	}
+	if c.Severity == nil {
+		c.Severity, c.severities = parent.Severity, parent.severities
+	}
+	if c.Disable == nil {
+		c.Disable = parent.Disable
+	}
+	if c.Sort == "" {
+		c.Sort, c.sortMode = parent.Sort, parent.sortMode
+	}
+	if c.Lenient == nil {
+		c.Lenient = parent.Lenient
+	}
+}
+
+// Describe where a configuration comes from, for -debug-config.
+func (c *config) describe() string {
+	desc := c.path
+	for parent := c.parent; parent != nil; parent = parent.parent {
+		desc += ", extending " + parent.path
+	}
+	return desc
+}
+
+// Parse the contents of a configuration file.
+func parseConfig(path string, data []byte) (*config, error) {
+	c, err := gogroup.ReadConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err.Error())
+	}
+	cfg := &config{Config: *c, path: path}
+	if cfg.HasOrder() {
+		cfg.order = cfg
+	}
+	// The configuration is valid, so compiling its rules can't fail.
+	cfg.aliasRules, _ = c.AliasRules()
+	cfg.denyRules, _ = c.DenyRules()
+	cfg.layerRules, _ = c.LayerRules(filepath.Dir(path))
+	cfg.severities, _ = c.Severities()
+	cfg.sortMode, _ = c.SortMode()
+	return cfg, nil
+}
+
+// Configure the alias, deny and layering rules of a processor's options from
+// the configuration file that applies to a directory, if there is one. Deny
+// rules from the file come after those from flags.
+func applyPolicyConfig(o *options, dir string) error {
+	cfg, err := findConfig(dir)
+	if err != nil || cfg == nil {
+		return err
+	}
+	o.usePolicy(cfg)
+	return nil
+}
+
+// Configure the alias, deny and layering rules of a processor's options, the
+// severities of rules and those disabled, and the sort mode and leniency,
+// from a configuration. Deny rules from the configuration come after those
+// from flags, and -sort and -lenient take precedence.
+func (o *options) usePolicy(cfg *config) {
+	o.aliasRules, o.forbiddenAliases = cfg.aliasRules, cfg.ForbiddenAliases
+	o.deny = append(append(denyFlag{}, o.deny...), cfg.denyRules...)
+	o.layers = cfg.layerRules
+	o.cfgSeverity, o.cfgDisable = cfg.severities, cfg.Disable
+	if cfg.Sort != "" && !o.sortMode.set {
+		o.sortMode.mode = cfg.sortMode
+	}
+	if cfg.Lenient != nil && *cfg.Lenient {
+		o.lenient = true
+	}
+}
+
+// Configure a grouper from the environment, or else from the configuration
+// file that applies to a directory, if there is one. An empty directory skips
+// looking for a configuration file.
+func applyConfig(gr *gogroup.SpecGrouper, dir string) error {
+	if ok, err := applyOrderEnv(gr); ok || err != nil {
+		return err
+	}
+	if dir == "" {
+		return nil
+	}
+
+	cfg, err := findConfig(dir)
+	if err != nil || cfg == nil {
+		return err
+	}
+	return useOrder(gr, cfg)
+}
+
+// Configure a grouper from the environment, yielding whether the environment
+// variable is set.
+func applyOrderEnv(gr *gogroup.SpecGrouper) (bool, error) {
+	order := os.Getenv(orderEnvVar)
+	if order == "" {
+		return false, nil
+	}
+	if err := gr.Set(order); err != nil {
+		return true, fmt.Errorf("%s: %s", orderEnvVar, err.Error())
+	}
+	return true, nil
+}
+
+// Configure a grouper with the order of a configuration, or one it extends.
+func useOrder(gr *gogroup.SpecGrouper, cfg *config) error {
+	order := cfg.order
+	if order == nil {
+		return nil
+	}
+	if err := gr.UseConfig(&order.Config); err != nil {
+		return fmt.Errorf("%s: %s", order.path, err.Error())
+	}
+	return nil
+}
+
+// Choose the processor for each file from the configuration file nearest to
+// it, so that configuration files in subdirectories override those above
+// them. The options must be only those from flags, and orderSet whether they
+// set the order, which then overrides that of every configuration, as the
+// environment does. Files under the same configuration share a processor,
+// whose cache entries are kept apart from those of others. With
+// -debug-config, the configuration of each file is written to w.
+func (o *options) processorsByFile(files []string, orderSet bool, w io.Writer) (map[string]*gogroup.Processor, error) {
+	byDir := map[string]*config{}
+	byConfig := map[string]*gogroup.Processor{}
+	procs := map[string]*gogroup.Processor{}
+	for _, file := range files {
+		dir := filepath.Dir(file)
+		cfg, ok := byDir[dir]
+		if !ok {
+			var err error
+			if cfg, err = findConfig(dir); err != nil {
+				return nil, err
+			}
+			byDir[dir] = cfg
+		}
+		key := ""
+		if cfg != nil {
+			key = cfg.path
+		}
+		if o.debugConfig {
+			desc := "none"
+			if cfg != nil {
+				desc = cfg.describe()
+			}
+			fmt.Fprintf(w, "Configuration for %s: %s\n", file, desc)
+		}
+
+		proc, ok := byConfig[key]
+		if !ok {
+			sub := *o
+			if !orderSet {
+				sub.gr = gogroup.NewSpecGrouper()
+				if env, err := applyOrderEnv(sub.gr); err != nil {
+					return nil, err
+				} else if !env && cfg != nil {
+					if err = useOrder(sub.gr, cfg); err != nil {
+						return nil, err
+					}
+				}
+			}
+			if cfg != nil {
+				sub.usePolicy(cfg)
+			}
+			proc = gogroup.NewProcessor(sub.gr, append(sub.processorOptions(),
+				gogroup.CacheKey(sub.cacheConfig()))...)
+			byConfig[key] = proc
+		}
+		procs[file] = proc
+	}
+	return procs, nil
+}
+
+// Write a configuration file in a directory. An existing configuration file
+// is never replaced.
+func writeConfig(dir string, cfg *config) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, configFileName)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		return fmt.Errorf("%s already exists", path)
+	} else if err != nil {
+		return err
+	}
+	if _, err = f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
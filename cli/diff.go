@@ -0,0 +1,168 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The header of a hunk of a unified diff, with the start and length of its
+// old and new lines. A missing length means one line.
+var reHunk = regexp.MustCompile(`^@@ -\d+(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// The Go files a unified diff changes, in the order it lists them, and the
+// lines of the new content of each that it touches.
+type diffFiles struct {
+	paths []string
+	lines map[string][]int
+}
+
+// Parse the path of a file from a "---" or "+++" line of a unified diff,
+// yielding "" for /dev/null. Any timestamp after a tab is dropped.
+func diffPath(line string) string {
+	path := strings.TrimSpace(strings.SplitN(line[4:], "\t", 2)[0])
+	if path == "/dev/null" {
+		return ""
+	}
+	return path
+}
+
+// Read a unified diff, finding the lines of each Go file it touches: those
+// that were added, and those that follow lines that were removed, since
+// removing a line can make the next one a violation. Files the diff deletes
+// are left out. The a/ and b/ prefixes of git diffs are removed.
+func parseDiff(r io.Reader) (*diffFiles, error) {
+	diff := &diffFiles{lines: map[string][]int{}}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+
+	oldPath, path := "", ""
+	oldLeft, newLeft, line := 0, 0, 0
+	touch := func() {
+		lines := diff.lines[path]
+		if path != "" && (len(lines) == 0 || lines[len(lines)-1] != line) {
+			diff.lines[path] = append(lines, line)
+		}
+	}
+	for n := 1; scanner.Scan(); n++ {
+		text := scanner.Text()
+		if oldLeft > 0 || newLeft > 0 {
+			// A line in a hunk, which may look like anything else.
+			switch {
+			case strings.HasPrefix(text, "+"):
+				touch()
+				line++
+				newLeft--
+			case strings.HasPrefix(text, "-"):
+				touch()
+				oldLeft--
+			case strings.HasPrefix(text, `\`):
+				// "\ No newline at end of file"
+			default:
+				line++
+				oldLeft--
+				newLeft--
+			}
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(text, "--- "):
+			oldPath = diffPath(text)
+		case strings.HasPrefix(text, "+++ "):
+			path = diffPath(text)
+			if (oldPath == "" || strings.HasPrefix(oldPath, "a/")) && strings.HasPrefix(path, "b/") {
+				path = path[2:]
+			}
+			if !strings.HasSuffix(path, ".go") {
+				path = ""
+			}
+			if _, ok := diff.lines[path]; path != "" && !ok {
+				diff.paths = append(diff.paths, path)
+				diff.lines[path] = nil
+			}
+		case strings.HasPrefix(text, "@@ "):
+			match := reHunk.FindStringSubmatch(text)
+			if match == nil {
+				return nil, fmt.Errorf("line %d of diff: Invalid hunk header '%s'", n, text)
+			}
+			count := func(s string) int {
+				if s == "" {
+					return 1
+				}
+				c, _ := strconv.Atoi(s)
+				return c
+			}
+			oldLeft, newLeft = count(match[1]), count(match[3])
+			line, _ = strconv.Atoi(match[2])
+			if newLeft == 0 {
+				// Only removals, after the given line.
+				line++
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return diff, nil
+}
+
+// Read the diff given by -diff, from standard input if it's "-".
+func (c *command) readDiff(name string) (*diffFiles, error) {
+	if name == "-" {
+		return parseDiff(c.stdin)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseDiff(f)
+}
+
+// Choose the files of a diff to process: those within the given paths, or
+// all of them if there are none. Files that don't exist are skipped, with a
+// warning.
+func (c *command) diffTargets(diff *diffFiles, paths []string) ([]string, error) {
+	dirs := []string{}
+	for _, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, abs)
+	}
+	within := func(file string) bool {
+		if len(dirs) == 0 {
+			return true
+		}
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return false
+		}
+		for _, dir := range dirs {
+			if abs == dir || strings.HasPrefix(abs, dir+string(filepath.Separator)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	files := []string{}
+	for _, file := range diff.paths {
+		if !within(file) {
+			continue
+		}
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			fmt.Fprintf(c.stderr, "Warning: %s: in the diff, but not found\n", file)
+			continue
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
@@ -0,0 +1,251 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vasi-stripe/gogroup"
+)
+
+// Units for byte sizes. Longer suffixes come first, so that "MB" isn't
+// mistaken for "B".
+var byteSizeUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+	{"B", 1},
+}
+
+// A size in bytes, which implements flag.Value. It accepts human-friendly
+// units, such as "5MB". Units are powers of 1024.
+type byteSize int64
+
+func (s *byteSize) String() string {
+	return strconv.FormatInt(int64(*s), 10)
+}
+
+func (s *byteSize) Set(str string) error {
+	num, mult := strings.ToUpper(strings.TrimSpace(str)), int64(1)
+	for _, unit := range byteSizeUnits {
+		if strings.HasSuffix(num, unit.suffix) {
+			num, mult = strings.TrimSpace(strings.TrimSuffix(num, unit.suffix)), unit.size
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("Invalid size '%s', expected a number of bytes such as 500KB or 5MB", str)
+	}
+	if n > math.MaxInt64/mult {
+		return fmt.Errorf("Invalid size '%s', too large", str)
+	}
+	*s = byteSize(n * mult)
+	return nil
+}
+
+// Names of the values of the -tests flag.
+var testsFlagNames = map[gogroup.TestFiles]string{
+	gogroup.TestsInclude: "include",
+	gogroup.TestsExclude: "exclude",
+	gogroup.TestsOnly:    "only",
+}
+
+// Which test files to include, which implements flag.Value.
+type testsFlag gogroup.TestFiles
+
+func (t *testsFlag) String() string {
+	return testsFlagNames[gogroup.TestFiles(*t)]
+}
+
+func (t *testsFlag) Set(str string) error {
+	for tests, name := range testsFlagNames {
+		if str == name {
+			*t = testsFlag(tests)
+			return nil
+		}
+	}
+	return fmt.Errorf("Invalid value '%s', expected one of: include, exclude, only", str)
+}
+
+// How to order imports within a group, which implements flag.Value. It
+// records whether the flag was given, since it takes precedence over the
+// configuration file.
+type sortFlag struct {
+	mode gogroup.SortMode
+	set  bool
+}
+
+func (s *sortFlag) String() string {
+	return s.mode.String()
+}
+
+func (s *sortFlag) Set(str string) error {
+	mode, err := gogroup.ParseSortMode(str)
+	if err != nil {
+		return err
+	}
+	s.mode, s.set = mode, true
+	return nil
+}
+
+// The required style of import declarations, which implements flag.Value.
+type blocksFlag gogroup.BlockStyle
+
+func (b *blocksFlag) String() string {
+	return gogroup.BlockStyle(*b).String()
+}
+
+func (b *blocksFlag) Set(str string) error {
+	style, err := gogroup.ParseBlockStyle(str)
+	if err != nil {
+		return err
+	}
+	*b = blocksFlag(style)
+	return nil
+}
+
+// A regular expression, which implements flag.Value. It's nil if unset.
+type regexpFlag struct {
+	re *regexp.Regexp
+}
+
+func (r *regexpFlag) String() string {
+	if r.re == nil {
+		return ""
+	}
+	return r.re.String()
+}
+
+func (r *regexpFlag) Set(str string) error {
+	re, err := regexp.Compile(str)
+	if err != nil {
+		return fmt.Errorf("Invalid regex '%s': %s", str, err.Error())
+	}
+	r.re = re
+	return nil
+}
+
+// An order given as a JSON rules document, which implements flag.Value. The
+// value is the document itself, or @ followed by the path of a file holding
+// it.
+type orderJSONFlag struct {
+	gr  *gogroup.SpecGrouper
+	set bool
+}
+
+func (f *orderJSONFlag) String() string {
+	return ""
+}
+
+func (f *orderJSONFlag) Set(str string) error {
+	data := []byte(str)
+	if strings.HasPrefix(str, "@") {
+		var err error
+		if data, err = ioutil.ReadFile(str[1:]); err != nil {
+			return err
+		}
+	}
+	if err := f.gr.SetJSON(data); err != nil {
+		return err
+	}
+	f.set = true
+	return nil
+}
+
+// Packages not to import, which implements flag.Value. Each use of the flag
+// adds a path, or a path ending in /... to also deny the packages below it.
+type denyFlag []gogroup.DenyRule
+
+func (d *denyFlag) String() string {
+	paths := []string{}
+	for _, rule := range *d {
+		if rule.Prefix != "" {
+			paths = append(paths, rule.Prefix+"...")
+		} else {
+			paths = append(paths, rule.Path)
+		}
+	}
+	return strings.Join(paths, ",")
+}
+
+func (d *denyFlag) Set(str string) error {
+	if str == "" || str == "..." || str == "/..." {
+		return errors.New("Empty path to deny")
+	}
+	if strings.HasSuffix(str, "/...") {
+		base := strings.TrimSuffix(str, "/...")
+		// The package itself, and those below it.
+		*d = append(*d, gogroup.DenyRule{Path: base}, gogroup.DenyRule{Prefix: base + "/"})
+	} else {
+		*d = append(*d, gogroup.DenyRule{Path: str})
+	}
+	return nil
+}
+
+// Parse the severity of a rule, given by its ID or rule name.
+func parseRuleSeverity(rule, level string) (gogroup.Severity, error) {
+	if _, err := gogroup.ParseRule(rule); err != nil {
+		return 0, err
+	}
+	return gogroup.ParseSeverity(level)
+}
+
+// A list of rules, by ID or rule name, which implements flag.Value. Each use
+// of the flag adds a comma-separated list of rules.
+type rulesFlag []string
+
+func (d *rulesFlag) String() string {
+	return strings.Join(*d, ",")
+}
+
+func (d *rulesFlag) Set(str string) error {
+	for _, rule := range strings.Split(str, ",") {
+		if _, err := gogroup.ParseRule(rule); err != nil {
+			return err
+		}
+		*d = append(*d, rule)
+	}
+	return nil
+}
+
+// The severities of rules, which implements flag.Value. Each use of the flag
+// sets the severity of a rule, as RULE:LEVEL.
+type severityFlag map[string]gogroup.Severity
+
+func (s *severityFlag) String() string {
+	items := []string{}
+	for rule, severity := range *s {
+		items = append(items, rule+":"+severity.String())
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+func (s *severityFlag) Set(str string) error {
+	i := strings.LastIndex(str, ":")
+	if i < 0 {
+		return fmt.Errorf("Invalid severity '%s', expected RULE:LEVEL", str)
+	}
+	severity, err := parseRuleSeverity(str[:i], str[i+1:])
+	if err != nil {
+		return err
+	}
+	if *s == nil {
+		*s = severityFlag{}
+	}
+	(*s)[str[:i]] = severity
+	return nil
+}
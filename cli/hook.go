@@ -0,0 +1,240 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/vasi-stripe/gogroup"
+)
+
+// Markers delimiting the section of a pre-commit hook that we manage. Anything
+// outside these markers belongs to the user, and is left alone.
+const (
+	hookBegin = "# BEGIN group-imports"
+	hookEnd   = "# END group-imports"
+)
+
+// Run a git command, yielding its standard output.
+func gitOutput(args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %s", strings.Join(args, " "),
+			strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// Quote a string for use in a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// The command line that a hook should use to invoke us.
+func hookCommand(gr *gogroup.SpecGrouper) string {
+	self := os.Args[0]
+	if strings.ContainsRune(self, filepath.Separator) {
+		if abs, err := filepath.Abs(self); err == nil {
+			self = abs
+		}
+	}
+
+	parts := []string{shellQuote(self)}
+	if gr.WasSet() {
+		parts = append(parts, "-order", shellQuote(gr.String()))
+	}
+	parts = append(parts, "hook", "run")
+	return strings.Join(parts, " ")
+}
+
+// Find the path of the pre-commit hook in the repository containing a
+// directory.
+func hookPath(dir string) (string, error) {
+	out, err := gitOutput("-C", dir, "rev-parse", "--git-path", "hooks/pre-commit")
+	if err != nil {
+		return "", err
+	}
+	path := strings.TrimSpace(string(out))
+	if !filepath.IsAbs(path) {
+		// The path is relative to the directory.
+		path = filepath.Join(dir, path)
+	}
+	return path, nil
+}
+
+// Remove our managed section from the content of a hook, if it's present.
+func stripHookSection(content string) string {
+	begin := strings.Index(content, hookBegin+"\n")
+	if begin < 0 {
+		return content
+	}
+	end := strings.Index(content[begin:], hookEnd+"\n")
+	if end < 0 {
+		return content
+	}
+	return content[:begin] + content[begin+end+len(hookEnd)+1:]
+}
+
+// Install our section into the pre-commit hook of the repository containing a
+// directory, replacing any previous one.
+func hookInstall(w io.Writer, dir string, gr *gogroup.SpecGrouper) error {
+	path, err := hookPath(dir)
+	if err != nil {
+		return err
+	}
+
+	content := ""
+	if existing, err := ioutil.ReadFile(path); err == nil {
+		content = stripHookSection(string(existing))
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if content == "" {
+		content = "#!/bin/sh\n"
+	} else if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	content += fmt.Sprintf("%s\n%s || exit $?\n%s\n", hookBegin, hookCommand(gr), hookEnd)
+
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(path, []byte(content), 0755); err != nil {
+		return err
+	}
+	// WriteFile doesn't change the mode of an existing file.
+	if err = os.Chmod(path, 0755); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Installed %s\n", path)
+	return nil
+}
+
+// Remove our section from the pre-commit hook of the repository containing a
+// directory. If nothing else remains, the hook is removed entirely.
+func hookUninstall(w io.Writer, dir string) error {
+	path, err := hookPath(dir)
+	if err != nil {
+		return err
+	}
+
+	existing, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	content := stripHookSection(string(existing))
+	if content == string(existing) {
+		return nil
+	}
+	if strings.TrimSpace(content) == "#!/bin/sh" {
+		err = os.Remove(path)
+	} else {
+		err = ioutil.WriteFile(path, []byte(content), 0755)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Uninstalled %s\n", path)
+	return nil
+}
+
+// Print a snippet suitable for a .pre-commit-config.yaml file.
+func hookPrint(w io.Writer, gr *gogroup.SpecGrouper) {
+	entry := "group-imports"
+	if gr.WasSet() {
+		entry += " -order " + gr.String()
+	}
+	fmt.Fprintf(w, `repos:
+  - repo: local
+    hooks:
+      - id: group-imports
+        name: group-imports
+        entry: %s hook run
+        language: system
+        pass_filenames: false
+        files: \.go$
+`, entry)
+}
+
+// List the Go files that are staged for commit, anywhere in the repository.
+func stagedFiles() ([]string, error) {
+	out, err := gitOutput("diff", "--cached", "--name-only", "--diff-filter=ACMR", "-z", "--", ":(top)*.go")
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{}
+	for _, file := range strings.Split(string(out), "\x00") {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// Validate the staged content of all staged Go files. Staged content is used
+// rather than the working tree, so that partially staged files are checked
+// exactly as they will be committed.
+func (c *command) hookRun(proc *gogroup.Processor, gr *gogroup.SpecGrouper, out *output) int {
+	// Paths from git are relative to the top level of the repository.
+	top, err := gitOutput("rev-parse", "--show-toplevel")
+	if err != nil {
+		return c.fail(statusError, err)
+	}
+
+	if !gr.WasSet() {
+		if err = applyConfig(gr, strings.TrimSpace(string(top))); err != nil {
+			return c.fail(statusHelp, err)
+		}
+	}
+
+	files, err := stagedFiles()
+	if err != nil {
+		return c.fail(statusError, err)
+	}
+
+	// Read the staged content, rather than the working tree. Index paths are
+	// always relative to the top level.
+	opts := gogroup.RunOptions{ReadFile: func(file string) ([]byte, error) {
+		return gitOutput("show", ":"+file)
+	}}
+	return c.run(proc, opts, out, files, nil)
+}
+
+// Handle the "hook" subcommand.
+func (c *command) hook(proc *gogroup.Processor, gr *gogroup.SpecGrouper, out *output, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(c.stderr, "Expected one of: hook install, hook uninstall, hook print, hook run.")
+		return statusHelp
+	}
+
+	var err error
+	switch args[0] {
+	case "install":
+		err = hookInstall(c.stderr, ".", gr)
+	case "uninstall":
+		err = hookUninstall(c.stderr, ".")
+	case "print":
+		hookPrint(c.stdout, gr)
+	case "run":
+		return c.hookRun(proc, gr, out)
+	default:
+		fmt.Fprintf(c.stderr, "Unknown hook command '%s'.\n", args[0])
+		return statusHelp
+	}
+	if err != nil {
+		return c.fail(statusError, err)
+	}
+	return 0
+}
@@ -0,0 +1,262 @@
+package cli
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vasi-stripe/gogroup"
+)
+
+// The most prefixes, other than the paths of local modules, to consider for groups of
+// their own.
+const initMaxPrefixes = 3
+
+// The most outlier files to list.
+const initMaxOutliers = 10
+
+// A file whose import grouping doesn't match an inferred order.
+type outlier struct {
+	path       string
+	violations int
+}
+
+// The result of inferring an order from existing files.
+type inference struct {
+	// The inferred order specification.
+	order string
+	// How many files had import grouping that matched the order, out of the
+	// total number of files with at least two imports.
+	matched, total int
+	// The files that didn't match, with the most violations first.
+	outliers []outlier
+}
+
+// The prefix shared by an import path and its siblings from the same
+// organization, such as "github.com/org/" or "golang.org/x/".
+func orgPrefix(path string) string {
+	elems := strings.Split(path, "/")
+	if len(elems) >= 3 {
+		return elems[0] + "/" + elems[1] + "/"
+	}
+	return elems[0] + "/"
+}
+
+// Find the module path of the module containing dir, or the empty string if
+// there's none.
+func modulePath(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		gomod := filepath.Join(dir, "go.mod")
+		if _, err := os.Stat(gomod); err == nil {
+			return readModulePath(gomod)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// Score an order specification against some files, yielding how many match,
+// and the violations in each file that doesn't.
+func scoreOrder(order string, srcs map[string][]byte) (int, []outlier) {
+	gr := gogroup.NewSpecGrouper()
+	if err := gr.Set(order); err != nil {
+		return 0, nil
+	}
+	proc := gogroup.NewProcessor(gr)
+
+	matched := 0
+	outliers := []outlier{}
+	for path, src := range srcs {
+		errs, err := proc.ValidateAll(path, bytes.NewReader(src))
+		if err != nil {
+			continue
+		}
+		if len(errs) == 0 {
+			matched++
+		} else {
+			outliers = append(outliers, outlier{path, len(errs)})
+		}
+	}
+	return matched, outliers
+}
+
+// Infer the order specification that best matches the import grouping of some
+// files. Prefixes that are imported often, along with the paths of the local
+// modules, are tried as groups of their own.
+func inferOrder(srcs map[string][]byte, modules []string) *inference {
+	// Only files with several imports say anything about the order.
+	informative := map[string][]byte{}
+	prefixFiles := map[string]int{}
+	for path, src := range srcs {
+		tree, err := parser.ParseFile(token.NewFileSet(), path, src, parser.ImportsOnly)
+		if err != nil || len(tree.Imports) < 2 {
+			continue
+		}
+		informative[path] = src
+
+		prefixes := map[string]bool{}
+		for _, ispec := range tree.Imports {
+			ipath, err := strconv.Unquote(ispec.Path.Value)
+			if err != nil || !strings.Contains(strings.SplitN(ipath, "/", 2)[0], ".") {
+				continue
+			}
+			if module := moduleOf(ipath, modules); module != "" {
+				prefixes[module] = true
+			} else {
+				prefixes[orgPrefix(ipath)] = true
+			}
+		}
+		for prefix := range prefixes {
+			prefixFiles[prefix]++
+		}
+	}
+
+	// Consider the module paths, and the prefixes imported by the most files.
+	candidates := []string{}
+	for prefix := range prefixFiles {
+		if !containsString(modules, prefix) {
+			candidates = append(candidates, prefix)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		return prefixFiles[a] > prefixFiles[b] || prefixFiles[a] == prefixFiles[b] && a < b
+	})
+	if len(candidates) > initMaxPrefixes {
+		candidates = candidates[:initMaxPrefixes]
+	}
+	for i := len(modules) - 1; i >= 0; i-- {
+		if prefixFiles[modules[i]] > 0 {
+			candidates = append([]string{modules[i]}, candidates...)
+		}
+	}
+
+	// Greedily add whichever prefix group, in whichever position, improves
+	// the match the most, until nothing helps.
+	groups := []string{"std", "other"}
+	best, outliers := scoreOrder(strings.Join(groups, ","), informative)
+	for {
+		var bestGroups []string
+		for _, prefix := range candidates {
+			group := "prefix=" + prefix
+			if containsString(groups, group) {
+				continue
+			}
+			for i := 1; i <= len(groups); i++ {
+				try := append(append(append([]string{}, groups[:i]...), group), groups[i:]...)
+				matched, out := scoreOrder(strings.Join(try, ","), informative)
+				if matched > best {
+					best, outliers, bestGroups = matched, out, try
+				}
+			}
+		}
+		if bestGroups == nil {
+			break
+		}
+		groups = bestGroups
+	}
+
+	sort.Slice(outliers, func(i, j int) bool {
+		a, b := outliers[i], outliers[j]
+		return a.violations > b.violations || a.violations == b.violations && a.path < b.path
+	})
+	return &inference{
+		order:    strings.Join(groups, ","),
+		matched:  best,
+		total:    len(informative),
+		outliers: outliers,
+	}
+}
+
+// Determine whether a list of strings contains a string.
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Print the result of inferring an order.
+func writeInference(w io.Writer, inf *inference) {
+	fmt.Fprintf(w, "Suggested order: %s\n", inf.order)
+	if inf.total == 0 {
+		fmt.Fprintln(w, "No files with several imports were found, so this is just the default.")
+		return
+	}
+	fmt.Fprintf(w, "Matches %d of %d files with several imports (%d%%)\n", inf.matched, inf.total,
+		inf.matched*100/inf.total)
+
+	if len(inf.outliers) > 0 {
+		fmt.Fprintln(w, "Files that don't match:")
+		for i, o := range inf.outliers {
+			if i == initMaxOutliers {
+				fmt.Fprintf(w, "  ...and %d more\n", len(inf.outliers)-i)
+				break
+			}
+			noun := "violations"
+			if o.violations == 1 {
+				noun = "violation"
+			}
+			fmt.Fprintf(w, "  %s (%d %s)\n", o.path, o.violations, noun)
+		}
+	}
+}
+
+// Handle the "init" subcommand, which suggests an order that matches existing
+// files. Source files are never modified.
+func (c *command) initOrder(args []string) int {
+	flags := flag.NewFlagSet("group-imports init", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	write := flags.Bool("write", false, "write the suggested order to "+configFileName)
+	if err := flags.Parse(args); err == flag.ErrHelp {
+		return 0
+	} else if err != nil {
+		return statusHelp
+	}
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	found, err := gogroup.FindFiles(paths, gogroup.FindOptions{})
+	if err != nil {
+		return c.fail(statusError, err)
+	}
+	srcs := map[string][]byte{}
+	for _, path := range found.Files {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return c.fail(statusError, err)
+		}
+		srcs[path] = src
+	}
+
+	inf := inferOrder(srcs, workspaces{}.localModules(paths[0]))
+	writeInference(c.stdout, inf)
+	if *write {
+		if err = writeConfig(".", &config{Config: gogroup.Config{Order: inf.order}}); err != nil {
+			return c.fail(statusError, err)
+		}
+		fmt.Fprintf(c.stderr, "Wrote %s\n", configFileName)
+	}
+	return 0
+}
@@ -0,0 +1,476 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"net/textproto"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/vasi-stripe/gogroup"
+)
+
+// A minimal Language Server Protocol server, supporting just enough of the
+// protocol to publish diagnostics and format documents.
+// See https://microsoft.github.io/language-server-protocol/specification
+
+// JSON-RPC error codes.
+const (
+	lspParseError     = -32700
+	lspMethodNotFound = -32601
+	lspInvalidParams  = -32602
+	lspInternalError  = -32603
+)
+
+// The kind of code action that organizes imports.
+const lspOrganizeImports = "source.organizeImports"
+
+type lspMessage struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+}
+
+type lspResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Result  interface{}      `json:"result"`
+}
+
+type lspErrorResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Error   *lspError        `json:"error"`
+}
+
+type lspNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *lspError) Error() string {
+	return e.Message
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspTextEdit struct {
+	Range   lspRange `json:"range"`
+	NewText string   `json:"newText"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspTextDocument struct {
+	URI  string `json:"uri"`
+	Text string `json:"text"`
+}
+
+type lspInitializeParams struct {
+	RootURI               string `json:"rootUri"`
+	InitializationOptions struct {
+		Order string `json:"order"`
+	} `json:"initializationOptions"`
+}
+
+type lspDocumentParams struct {
+	TextDocument   lspTextDocument `json:"textDocument"`
+	ContentChanges []struct {
+		Range *lspRange `json:"range"`
+		Text  string    `json:"text"`
+	} `json:"contentChanges"`
+	Text *string `json:"text"`
+}
+
+type lspCodeActionParams struct {
+	TextDocument lspTextDocument `json:"textDocument"`
+	Context      struct {
+		Only []string `json:"only"`
+	} `json:"context"`
+}
+
+type lspCodeAction struct {
+	Title string `json:"title"`
+	Kind  string `json:"kind"`
+	Edit  struct {
+		Changes map[string][]lspTextEdit `json:"changes"`
+	} `json:"edit"`
+}
+
+// An LSP server.
+type lspServer struct {
+	r *bufio.Reader
+	w io.Writer
+	// Where to report errors that can't be sent to the client.
+	errw io.Writer
+
+	gr   *gogroup.SpecGrouper
+	proc *gogroup.Processor
+
+	// The content of open documents, by URI.
+	docs map[string]string
+
+	shutdown bool
+}
+
+func newLSPServer(r io.Reader, w, errw io.Writer, proc *gogroup.Processor, gr *gogroup.SpecGrouper) *lspServer {
+	return &lspServer{
+		r:    bufio.NewReader(r),
+		w:    w,
+		errw: errw,
+		gr:   gr,
+		proc: proc,
+		docs: make(map[string]string),
+	}
+}
+
+// Read the body of a single message. Yields io.EOF when there are no more
+// messages.
+func readLSPBody(r *bufio.Reader) ([]byte, error) {
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, &lspError{lspParseError, err.Error()}
+	}
+
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, &lspError{lspParseError, "Missing Content-Length header"}
+	}
+	body := make([]byte, length)
+	if _, err = io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// Read a single message. Yields io.EOF when there are no more messages.
+func (s *lspServer) read() (*lspMessage, error) {
+	body, err := readLSPBody(s.r)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &lspMessage{}
+	if err = json.Unmarshal(body, msg); err != nil {
+		return nil, &lspError{lspParseError, err.Error()}
+	}
+	return msg, nil
+}
+
+// Write a single message.
+func (s *lspServer) write(msg interface{}) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.w, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
+
+// Serve requests until the client exits. Returns the status to exit with.
+func (s *lspServer) serve() int {
+	for {
+		msg, err := s.read()
+		if err == io.EOF {
+			break
+		} else if lerr, ok := err.(*lspError); ok {
+			// Report bad messages, but keep going.
+			if err = s.write(&lspErrorResponse{JSONRPC: "2.0", Error: lerr}); err == nil {
+				continue
+			}
+		}
+		if err != nil {
+			fmt.Fprintln(s.errw, err.Error())
+			return statusError
+		}
+
+		if msg.Method == "exit" {
+			break
+		}
+
+		result, err := s.handle(msg)
+		if msg.ID == nil {
+			// Notifications get no response.
+			if err != nil {
+				fmt.Fprintln(s.errw, err.Error())
+			}
+			continue
+		}
+
+		if err == nil {
+			err = s.write(&lspResponse{JSONRPC: "2.0", ID: msg.ID, Result: result})
+		} else {
+			lerr, ok := err.(*lspError)
+			if !ok {
+				lerr = &lspError{lspInternalError, err.Error()}
+			}
+			err = s.write(&lspErrorResponse{JSONRPC: "2.0", ID: msg.ID, Error: lerr})
+		}
+		if err != nil {
+			fmt.Fprintln(s.errw, err.Error())
+			return statusError
+		}
+	}
+
+	if !s.shutdown {
+		return statusError
+	}
+	return 0
+}
+
+// Handle a request or notification, yielding the result.
+func (s *lspServer) handle(msg *lspMessage) (interface{}, error) {
+	switch msg.Method {
+	case "initialize":
+		params := &lspInitializeParams{}
+		if err := s.params(msg, params); err != nil {
+			return nil, err
+		}
+		return s.initialize(params)
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen", "textDocument/didChange", "textDocument/didSave":
+		params := &lspDocumentParams{}
+		if err := s.params(msg, params); err != nil {
+			return nil, err
+		}
+		return nil, s.update(params)
+	case "textDocument/didClose":
+		params := &lspDocumentParams{}
+		if err := s.params(msg, params); err != nil {
+			return nil, err
+		}
+		delete(s.docs, params.TextDocument.URI)
+		return nil, s.publish(params.TextDocument.URI, []lspDiagnostic{})
+	case "textDocument/formatting":
+		params := &lspDocumentParams{}
+		if err := s.params(msg, params); err != nil {
+			return nil, err
+		}
+		return s.edits(params.TextDocument.URI)
+	case "textDocument/codeAction":
+		params := &lspCodeActionParams{}
+		if err := s.params(msg, params); err != nil {
+			return nil, err
+		}
+		return s.codeActions(params)
+	}
+
+	if msg.ID == nil {
+		// Ignore unknown notifications, such as "initialized".
+		return nil, nil
+	}
+	return nil, &lspError{lspMethodNotFound, fmt.Sprintf("Unknown method '%s'", msg.Method)}
+}
+
+// Parse the parameters of a message.
+func (s *lspServer) params(msg *lspMessage, params interface{}) error {
+	if err := json.Unmarshal(msg.Params, params); err != nil {
+		return &lspError{lspInvalidParams, err.Error()}
+	}
+	return nil
+}
+
+func (s *lspServer) initialize(params *lspInitializeParams) (interface{}, error) {
+	if !s.gr.WasSet() {
+		var err error
+		if params.InitializationOptions.Order != "" {
+			err = s.gr.Set(params.InitializationOptions.Order)
+		} else {
+			err = applyConfig(s.gr, uriPath(params.RootURI))
+		}
+		if err != nil {
+			return nil, &lspError{lspInvalidParams, err.Error()}
+		}
+	}
+
+	return map[string]interface{}{
+		"capabilities": map[string]interface{}{
+			"textDocumentSync": map[string]interface{}{
+				"openClose": true,
+				// Full document sync.
+				"change": 1,
+				"save":   map[string]interface{}{"includeText": true},
+			},
+			"documentFormattingProvider": true,
+			"codeActionProvider": map[string]interface{}{
+				"codeActionKinds": []string{lspOrganizeImports},
+			},
+		},
+		"serverInfo": map[string]interface{}{"name": "group-imports"},
+	}, nil
+}
+
+// Convert a file URI to a path. Yields an empty string for other URIs.
+func uriPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+// Update the content of a document, and publish its diagnostics.
+func (s *lspServer) update(params *lspDocumentParams) error {
+	uri := params.TextDocument.URI
+	if params.TextDocument.Text != "" {
+		s.docs[uri] = params.TextDocument.Text
+	}
+	for _, change := range params.ContentChanges {
+		if change.Range != nil {
+			return &lspError{lspInvalidParams, "Only full document sync is supported"}
+		}
+		s.docs[uri] = change.Text
+	}
+	if params.Text != nil {
+		s.docs[uri] = *params.Text
+	}
+
+	diags := []lspDiagnostic{}
+	text, ok := s.docs[uri]
+	if !ok {
+		// Saving a document that was never opened.
+		return nil
+	}
+	validErr, err := s.proc.Validate(uriPath(uri), strings.NewReader(text))
+	if err != nil {
+		// Unparseable code is the business of other tools.
+		return s.publish(uri, diags)
+	}
+	if validErr != nil {
+		line := validErr.Line - 1
+		diags = append(diags, lspDiagnostic{
+			Range: lspRange{
+				Start: lspPosition{Line: line},
+				End:   lspPosition{Line: line, Character: utf16Len(lineAt(text, line))},
+			},
+			// Error, warning or information, as the severities are ordered.
+			Severity: 1 + int(validErr.Severity),
+			Source:   "group-imports",
+			Message:  fmt.Sprintf("%s: %s", validErr.Message, strconv.Quote(validErr.ImportPath)),
+		})
+	}
+	return s.publish(uri, diags)
+}
+
+// Publish the diagnostics for a document.
+func (s *lspServer) publish(uri string, diags []lspDiagnostic) error {
+	return s.write(&lspNotification{
+		JSONRPC: "2.0",
+		Method:  "textDocument/publishDiagnostics",
+		Params: map[string]interface{}{
+			"uri":         uri,
+			"diagnostics": diags,
+		},
+	})
+}
+
+// Yield a line of text, without its line ending.
+func lineAt(text string, line int) string {
+	lines := strings.Split(text, "\n")
+	if line < 0 || line >= len(lines) {
+		return ""
+	}
+	return strings.TrimSuffix(lines[line], "\r")
+}
+
+// The length of a string in UTF-16 code units, as LSP measures characters.
+func utf16Len(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
+
+// Yield the edits that fix the import grouping of a document.
+func (s *lspServer) edits(uri string) ([]lspTextEdit, error) {
+	text, ok := s.docs[uri]
+	if !ok {
+		// We can still format unopened documents, if they're on disk.
+		data, err := ioutil.ReadFile(uriPath(uri))
+		if err != nil {
+			return nil, &lspError{lspInvalidParams, fmt.Sprintf("Unknown document '%s'", uri)}
+		}
+		text = string(data)
+	}
+
+	edits := []lspTextEdit{}
+	fixes, err := s.proc.Edits(uriPath(uri), []byte(text))
+	if err != nil {
+		// Don't complain about unparseable code, just leave it alone.
+		return edits, nil
+	}
+	for _, fix := range fixes {
+		edits = append(edits, lspTextEdit{
+			Range:   lspRange{Start: lspPositionOf(text, fix.Start), End: lspPositionOf(text, fix.End)},
+			NewText: fix.NewText,
+		})
+	}
+	return edits, nil
+}
+
+// Convert a position in a document to an LSP position, whose characters are
+// UTF-16 code units.
+func lspPositionOf(text string, pos token.Position) lspPosition {
+	line := lineAt(text, pos.Line-1)
+	col := pos.Column - 1
+	if col > len(line) {
+		col = len(line)
+	}
+	return lspPosition{Line: pos.Line - 1, Character: utf16Len(line[:col])}
+}
+
+// Yield the code actions for a document.
+func (s *lspServer) codeActions(params *lspCodeActionParams) ([]lspCodeAction, error) {
+	actions := []lspCodeAction{}
+	if len(params.Context.Only) > 0 {
+		wanted := false
+		for _, kind := range params.Context.Only {
+			// Kinds are hierarchical, so "source" includes our action.
+			if kind == lspOrganizeImports || strings.HasPrefix(lspOrganizeImports, kind+".") {
+				wanted = true
+			}
+		}
+		if !wanted {
+			return actions, nil
+		}
+	}
+
+	uri := params.TextDocument.URI
+	edits, err := s.edits(uri)
+	if err != nil || len(edits) == 0 {
+		return actions, err
+	}
+
+	action := lspCodeAction{Title: "Group imports", Kind: lspOrganizeImports}
+	action.Edit.Changes = map[string][]lspTextEdit{uri: edits}
+	return append(actions, action), nil
+}
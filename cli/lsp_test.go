@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vasi-stripe/gogroup"
+)
+
+// Encode LSP messages for a test client.
+func lspInput(t *testing.T, msgs ...interface{}) *bytes.Buffer {
+	var buf bytes.Buffer
+	for _, msg := range msgs {
+		body, err := json.Marshal(msg)
+		assert.Nil(t, err)
+		fmt.Fprintf(&buf, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+	return &buf
+}
+
+// Decode the messages sent by the server.
+func lspOutput(t *testing.T, out *bytes.Buffer) []map[string]interface{} {
+	r := bufio.NewReader(out)
+	msgs := []map[string]interface{}{}
+	for {
+		body, err := readLSPBody(r)
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		msg := map[string]interface{}{}
+		assert.Nil(t, json.Unmarshal(body, &msg))
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+func TestLSP(t *testing.T) {
+	t.Parallel()
+
+	uri := "file:///tmp/a.go"
+	text := "package a\n\nimport (\n\t\"os\"\n\t\"fmt\"\n)\n"
+	doc := map[string]interface{}{"uri": uri}
+	in := lspInput(t,
+		map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": "initialize",
+			"params": map[string]interface{}{
+				"initializationOptions": map[string]interface{}{"order": "std,other"},
+			}},
+		map[string]interface{}{"jsonrpc": "2.0", "method": "initialized", "params": map[string]interface{}{}},
+		map[string]interface{}{"jsonrpc": "2.0", "method": "textDocument/didOpen",
+			"params": map[string]interface{}{"textDocument": map[string]interface{}{
+				"uri": uri, "languageId": "go", "version": 1, "text": text,
+			}}},
+		map[string]interface{}{"jsonrpc": "2.0", "id": 2, "method": "textDocument/formatting",
+			"params": map[string]interface{}{"textDocument": doc}},
+		map[string]interface{}{"jsonrpc": "2.0", "id": 3, "method": "textDocument/codeAction",
+			"params": map[string]interface{}{"textDocument": doc,
+				"context": map[string]interface{}{"only": []string{"quickfix"}}}},
+		map[string]interface{}{"jsonrpc": "2.0", "id": 4, "method": "bogus"},
+		map[string]interface{}{"jsonrpc": "2.0", "id": 5, "method": "shutdown"},
+		map[string]interface{}{"jsonrpc": "2.0", "method": "exit"},
+	)
+
+	var out bytes.Buffer
+	gr := gogroup.NewSpecGrouper()
+	assert.Equal(t, 0, newLSPServer(in, &out, ioutil.Discard, gogroup.NewProcessor(gr), gr).serve())
+	assert.Equal(t, "std,other", gr.String())
+
+	msgs := lspOutput(t, &out)
+	assert.Len(t, msgs, 6)
+
+	// Diagnostics are published on open.
+	assert.Equal(t, "textDocument/publishDiagnostics", msgs[1]["method"])
+	diags := msgs[1]["params"].(map[string]interface{})["diagnostics"].([]interface{})
+	assert.Len(t, diags, 1)
+	diag := diags[0].(map[string]interface{})
+	assert.True(t, strings.HasPrefix(diag["message"].(string), "Import out of order"))
+	assert.Equal(t, map[string]interface{}{
+		"start": map[string]interface{}{"line": 4.0, "character": 0.0},
+		"end":   map[string]interface{}{"line": 4.0, "character": 6.0},
+	}, diag["range"])
+
+	// Formatting edits only the lines of the import block that change.
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{
+			"range": map[string]interface{}{
+				"start": map[string]interface{}{"line": 3.0, "character": 0.0},
+				"end":   map[string]interface{}{"line": 3.0, "character": 0.0},
+			},
+			"newText": "\t\"fmt\"\n",
+		},
+		map[string]interface{}{
+			"range": map[string]interface{}{
+				"start": map[string]interface{}{"line": 4.0, "character": 0.0},
+				"end":   map[string]interface{}{"line": 5.0, "character": 0.0},
+			},
+			"newText": "",
+		},
+	}, msgs[2]["result"])
+
+	// Code actions are filtered by kind.
+	assert.Equal(t, []interface{}{}, msgs[3]["result"])
+
+	// Unknown methods are errors.
+	assert.NotNil(t, msgs[4]["error"])
+	assert.Nil(t, msgs[5]["error"])
+}
@@ -0,0 +1,200 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/vasi-stripe/gogroup"
+)
+
+// The format name that uses the -template flag.
+const templateFormat = "template"
+
+// The format name that uses the -path-root flag.
+const codeclimateFormat = "codeclimate"
+
+// How to write reports.
+type output struct {
+	// The name of the format.
+	format string
+
+	// The template, for the template format.
+	tmpl *template.Template
+
+	// Whether to print only the number of violations, and how to break it
+	// down, if at all.
+	count   bool
+	countBy string
+
+	// Whether to print a summary, and whether it's JSON rather than text.
+	summary     bool
+	summaryJSON bool
+
+	// Whether to print only the paths of files with violations.
+	list bool
+
+	// Whether to report violations to standard error rather than standard
+	// output.
+	violationsToStderr bool
+
+	// The most violations to print, or zero for no limit.
+	maxViolations int
+
+	// The least severe violations that make the command fail.
+	failAt gogroup.Severity
+
+	// Whether to list the violations that comments suppress.
+	showSuppressed bool
+
+	// The directory paths are relative to, for the codeclimate format.
+	pathRoot string
+
+	// If non-nil, the only lines of each file whose violations are reported,
+	// for -diff.
+	lines map[string][]int
+
+	// The baseline of violations to tolerate, if any, and where to write a
+	// baseline instead of reporting violations, for -write-baseline.
+	baseline      *gogroup.Baseline
+	writeBaseline string
+
+	// Where to record how long output takes, for -debug-timing.
+	timings *gogroup.Timings
+}
+
+// Configure the output from the -format and -template flags. An empty format
+// picks a default suitable for the environment we're running in.
+func newOutput(format string, templateText string) (*output, error) {
+	if format == "" {
+		format = "text"
+		if os.Getenv("GITHUB_ACTIONS") == "true" {
+			format = "github"
+		}
+	}
+
+	if format == templateFormat {
+		if templateText == "" {
+			return nil, errors.New("The template format requires -template")
+		}
+		tmpl, err := gogroup.ParseTemplate(templateText)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid -template: %s", err.Error())
+		}
+		return &output{format: format, tmpl: tmpl}, nil
+	}
+	if templateText != "" {
+		return nil, errors.New("-template requires -format template")
+	}
+
+	names := append(gogroup.FormatNames(), templateFormat)
+	for _, known := range names {
+		if format == known {
+			return &output{format: format}, nil
+		}
+	}
+	return nil, fmt.Errorf("Unknown format '%s', expected one of: %s", format,
+		strings.Join(names, ", "))
+}
+
+// Determine whether parse errors are reported along with violations, rather
+// than only to standard error.
+func (o *output) reportsParseErrors() bool {
+	return o.format == "editor" && !o.count && !o.list
+}
+
+// Set the directory paths are relative to, for the codeclimate format. By
+// default, it's the top level of the git repository, if we're in one, so that
+// GitLab can match paths against its diffs.
+func (o *output) setPathRoot(root string) error {
+	if o.format != codeclimateFormat {
+		if root != "" {
+			return errors.New("-path-root requires -format codeclimate")
+		}
+		return nil
+	}
+	if root == "" {
+		if top, err := gitOutput("rev-parse", "--show-toplevel"); err == nil {
+			root = filepath.FromSlash(strings.TrimSpace(string(top)))
+		}
+	}
+	o.pathRoot = root
+	return nil
+}
+
+// Switch to printing the number of violations, optionally broken down by a
+// criterion.
+func (o *output) setCount(count bool, countBy string) error {
+	if countBy != "" && countBy != "rule" {
+		return fmt.Errorf("Unknown -count-by '%s', expected: rule", countBy)
+	}
+	o.count = count || countBy != ""
+	o.countBy = countBy
+	return nil
+}
+
+// Switch to printing a summary, optionally in a given format.
+func (o *output) setSummary(summary bool, format string) error {
+	if format != "" && format != "text" && format != "json" {
+		return fmt.Errorf("Unknown -summary-format '%s', expected one of: text, json", format)
+	}
+	o.summary = summary || format != ""
+	o.summaryJSON = format == "json"
+	return nil
+}
+
+// Write a summary of a report.
+func (o *output) writeSummary(w io.Writer, report *gogroup.Report) error {
+	if o.summaryJSON {
+		return report.WriteSummaryJSON(w)
+	}
+	return report.WriteSummary(w)
+}
+
+// Choose where violations are reported. Lists of paths always go to standard
+// output.
+func (o *output) setViolationsTo(stream string) error {
+	if stream != "" && stream != "stdout" && stream != "stderr" {
+		return fmt.Errorf("Unknown -violations-to '%s', expected one of: stdout, stderr", stream)
+	}
+	o.violationsToStderr = stream == "stderr" && !o.list
+	return nil
+}
+
+// Pick the stream to write a report to.
+func (o *output) stream(stdout, stderr io.Writer) io.Writer {
+	if o.violationsToStderr {
+		return stderr
+	}
+	return stdout
+}
+
+// Limit a report to what should be printed, yielding the number of violations
+// left out.
+func (o *output) truncate(report *gogroup.Report) (*gogroup.Report, int) {
+	if o.maxViolations <= 0 || o.count {
+		return report, 0
+	}
+	return report.Truncated(o.maxViolations)
+}
+
+// Write a report.
+func (o *output) write(w io.Writer, report *gogroup.Report) error {
+	if o.list {
+		return report.WritePaths(w)
+	}
+	if o.count {
+		return report.WriteCounts(w, o.countBy == "rule")
+	}
+	if o.tmpl != nil {
+		return report.WriteTemplate(w, o.tmpl)
+	}
+	if o.format == codeclimateFormat {
+		return report.WriteCodeClimate(w, o.pathRoot)
+	}
+	return report.Write(w, o.format)
+}
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/vasi-stripe/gogroup"
+)
+
+// Write the fixed content of a single input to a path, for -output, or to
+// standard output, for -always-print, leaving the input unchanged. The input
+// is a file, or standard input if it's "-". The output is written even if
+// nothing needs fixing, so it always exists afterwards. With -always-print,
+// the status is statusInvalidFile if the content changed, so callers can tell
+// whether it needed fixing.
+func (c *command) writeOutput(o, base *options, orderSet bool, args []string) int {
+	flag := "-output"
+	if o.alwaysPrint {
+		flag = "-always-print"
+	}
+	if o.outputPath != "" && o.alwaysPrint {
+		return c.fail(statusHelp, errors.New("-output can't be used with -always-print"))
+	}
+	if o.rewrite || o.since != "" || o.diff != "" {
+		return c.fail(statusHelp, fmt.Errorf("%s can't be used with -rewrite, -since or -diff", flag))
+	}
+	if len(args) != 1 {
+		return c.fail(statusHelp, fmt.Errorf("%s needs exactly one input file, or - for standard input", flag))
+	}
+
+	path, proc := args[0], o.processor()
+	var src []byte
+	var err error
+	if path == "-" {
+		path = ""
+		src, err = ioutil.ReadAll(c.stdin)
+	} else {
+		if info, serr := os.Stat(path); serr == nil && info.IsDir() {
+			return c.fail(statusHelp, fmt.Errorf("%s needs a file, not a directory: %s", flag, path))
+		}
+		src, err = ioutil.ReadFile(path)
+		if err == nil {
+			var procs map[string]*gogroup.Processor
+			if procs, err = base.processorsByFile([]string{path}, orderSet, c.stderr); err != nil {
+				return c.fail(statusHelp, err)
+			}
+			if p := procs[path]; p != nil {
+				proc = p
+			}
+		}
+	}
+	if err != nil {
+		return c.fail(statusError, err)
+	}
+
+	var fixed io.Reader
+	if o.noGoimports {
+		fixed, err = proc.Repair(path, bytes.NewReader(src))
+	} else {
+		fixed, err = proc.Reformat(path, bytes.NewReader(src))
+	}
+	if err != nil {
+		return c.fail(errorStatus(err), err)
+	}
+	result := src
+	if fixed != nil {
+		if result, err = ioutil.ReadAll(fixed); err != nil {
+			return c.fail(statusError, err)
+		}
+	}
+
+	if o.alwaysPrint {
+		if _, err = c.stdout.Write(result); err != nil {
+			return c.fail(statusError, err)
+		}
+		if !bytes.Equal(result, src) {
+			return statusInvalidFile
+		}
+		return 0
+	}
+	if err = ioutil.WriteFile(o.outputPath, result, 0644); err != nil {
+		return c.fail(statusError, err)
+	}
+	return 0
+}
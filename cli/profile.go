@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"time"
+
+	"github.com/vasi-stripe/gogroup"
+)
+
+// Start the profiles, timings and debug logging the options ask for, yielding
+// a function that stops them, writes the profiles, and prints the timings. It
+// must be called however processing ends, so that profiles are complete even
+// when there are violations or errors.
+func (c *command) startProfiling(o *options) (func(), error) {
+	stops := []func() error{}
+	stop := func() {
+		// Stop in the reverse order of starting.
+		for i := len(stops) - 1; i >= 0; i-- {
+			if err := stops[i](); err != nil {
+				fmt.Fprintf(c.stderr, "Warning: %s\n", err.Error())
+			}
+		}
+	}
+
+	if o.cpuProfile != "" {
+		f, err := os.Create(o.cpuProfile)
+		if err != nil {
+			return nil, err
+		}
+		if err = pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+		stops = append(stops, func() error {
+			pprof.StopCPUProfile()
+			return f.Close()
+		})
+	}
+	if o.traceFile != "" {
+		f, err := os.Create(o.traceFile)
+		if err == nil {
+			if err = trace.Start(f); err != nil {
+				f.Close()
+			}
+		}
+		if err != nil {
+			stop()
+			return nil, err
+		}
+		stops = append(stops, func() error {
+			trace.Stop()
+			return f.Close()
+		})
+	}
+	if o.memProfile != "" {
+		path := o.memProfile
+		stops = append(stops, func() error {
+			f, err := os.Create(path)
+			if err != nil {
+				return err
+			}
+			// Profile memory that's still in use, as of the last collection.
+			runtime.GC()
+			if err = pprof.WriteHeapProfile(f); err != nil {
+				f.Close()
+				return err
+			}
+			return f.Close()
+		})
+	}
+	if o.debugTiming {
+		o.timings = gogroup.NewTimings()
+		start := time.Now()
+		stops = append(stops, func() error {
+			if err := o.timings.Write(c.stderr); err != nil {
+				return err
+			}
+			_, err := fmt.Fprintf(c.stderr, "Timing: %-9s %v\n", "total", time.Since(start).Round(time.Microsecond))
+			return err
+		})
+	}
+	if o.debug {
+		o.logger = slog.New(slog.NewTextHandler(c.stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
+	return stop, nil
+}
@@ -0,0 +1,194 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"runtime"
+	"sync"
+
+	"github.com/vasi-stripe/gogroup"
+)
+
+// A server for batches of files, so that tools checking or fixing many small
+// files can keep one process warm rather than start one per file. Requests
+// and responses are JSON objects, one per line. Requests are handled
+// concurrently, so responses may come in any order, and carry the ID of their
+// request.
+
+// A request to the batch server.
+type serveRequest struct {
+	// ID identifies the request in its response. It may be any JSON value.
+	ID *json.RawMessage `json:"id"`
+	// Op is "check" or "fix".
+	Op string `json:"op"`
+	// Filename is the name of the file, for messages and goimports.
+	Filename string `json:"filename"`
+	// Content is the content of the file, encoded as base64.
+	Content []byte `json:"content"`
+}
+
+// A response of the batch server. Only the fields for the request's op are
+// set, and only Error if it failed.
+type serveResponse struct {
+	ID *json.RawMessage `json:"id"`
+	// Error describes why the request failed.
+	Error string `json:"error,omitempty"`
+	// Violations are the violations of a checked file, which has none if
+	// it's left out.
+	Violations []serveViolation `json:"violations,omitempty"`
+	// Content is the content of a fixed file, encoded as base64, and Changed
+	// whether it differs from the request's content.
+	Content []byte `json:"content,omitempty"`
+	Changed bool   `json:"changed,omitempty"`
+}
+
+type serveViolation struct {
+	ID         string           `json:"id,omitempty"`
+	Rule       string           `json:"rule,omitempty"`
+	Line       int              `json:"line"`
+	Column     int              `json:"column,omitempty"`
+	Offset     int              `json:"offset"`
+	EndOffset  int              `json:"endOffset,omitempty"`
+	ImportPath string           `json:"import"`
+	Message    string           `json:"message"`
+	Severity   gogroup.Severity `json:"severity"`
+}
+
+// A batch server.
+type batchServer struct {
+	r *bufio.Reader
+	w io.Writer
+	// Where to report errors that can't be sent to the client.
+	errw io.Writer
+
+	proc      *gogroup.Processor
+	goimports bool
+
+	// Serializes writes of responses.
+	mu sync.Mutex
+	// The first error writing a response.
+	writeErr error
+}
+
+func newBatchServer(r io.Reader, w, errw io.Writer, proc *gogroup.Processor, goimports bool) *batchServer {
+	return &batchServer{
+		r:         bufio.NewReader(r),
+		w:         w,
+		errw:      errw,
+		proc:      proc,
+		goimports: goimports,
+	}
+}
+
+// Write a single response, on a line of its own.
+func (s *batchServer) write(resp *serveResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.writeErr != nil {
+		return
+	}
+	body, err := json.Marshal(resp)
+	if err == nil {
+		_, err = s.w.Write(append(body, '\n'))
+	}
+	s.writeErr = err
+}
+
+// Determine whether writing a response failed, so there's no point going on.
+func (s *batchServer) failed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writeErr != nil
+}
+
+// Serve requests until standard input ends, then wait for those in progress.
+// Returns the status to exit with.
+func (s *batchServer) serve() int {
+	var wg sync.WaitGroup
+	// Limit the requests handled at once, as ProcessFiles does.
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	for {
+		line, err := s.r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			req := &serveRequest{}
+			if jerr := json.Unmarshal(line, req); jerr != nil {
+				// Report bad requests, but keep going.
+				s.write(&serveResponse{ID: req.ID, Error: "Invalid request: " + jerr.Error()})
+			} else {
+				sem <- struct{}{}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					s.write(s.handle(req))
+					<-sem
+				}()
+			}
+		}
+		if err == io.EOF || s.failed() {
+			break
+		} else if err != nil {
+			wg.Wait()
+			fmt.Fprintln(s.errw, err.Error())
+			return statusError
+		}
+	}
+
+	wg.Wait()
+	if s.writeErr != nil {
+		fmt.Fprintln(s.errw, s.writeErr.Error())
+		return statusError
+	}
+	return 0
+}
+
+// Handle a request, yielding its response.
+func (s *batchServer) handle(req *serveRequest) *serveResponse {
+	resp := &serveResponse{ID: req.ID}
+	var err error
+	switch req.Op {
+	case "check":
+		resp.Violations, err = s.check(req)
+	case "fix":
+		resp.Content, err = s.fix(req)
+		resp.Changed = err == nil && !bytes.Equal(resp.Content, req.Content)
+	default:
+		err = fmt.Errorf("Unknown op '%s', expected one of: check, fix", req.Op)
+	}
+	if err != nil {
+		return &serveResponse{ID: req.ID, Error: err.Error()}
+	}
+	return resp
+}
+
+// Check a file, yielding its violations.
+func (s *batchServer) check(req *serveRequest) ([]serveViolation, error) {
+	errs, err := s.proc.ValidateAll(req.Filename, bytes.NewReader(req.Content))
+	if err != nil {
+		return nil, err
+	}
+	violations := []serveViolation{}
+	for _, v := range errs {
+		violations = append(violations, serveViolation{v.ID(), v.Rule, v.Line, v.Pos.Column, v.Pos.Offset,
+			v.End.Offset, v.ImportPath, v.Message, v.Severity})
+	}
+	return violations, nil
+}
+
+// Fix a file, yielding its fixed content.
+func (s *batchServer) fix(req *serveRequest) ([]byte, error) {
+	var r io.Reader
+	var err error
+	if s.goimports {
+		r, err = s.proc.Reformat(req.Filename, bytes.NewReader(req.Content))
+	} else {
+		r, err = s.proc.Repair(req.Filename, bytes.NewReader(req.Content))
+	}
+	if err != nil || r == nil {
+		return req.Content, err
+	}
+	return ioutil.ReadAll(r)
+}
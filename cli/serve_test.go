@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vasi-stripe/gogroup"
+)
+
+func TestBatchServer(t *testing.T) {
+	t.Parallel()
+
+	src := "package a\n\nimport (\n\t\"os\"\n\t\"fmt\"\n)\n"
+	fixed := "package a\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n"
+	request := func(id interface{}, op, content string) string {
+		data, err := json.Marshal(map[string]interface{}{"id": id, "op": op, "filename": "a.go",
+			"content": []byte(content)})
+		assert.Nil(t, err)
+		return string(data) + "\n"
+	}
+	in := strings.NewReader(request(1, "check", src) + request("two", "fix", src) +
+		request(3, "fix", fixed) + "{bogus\n\n" + request(4, "lint", src) + request(5, "check", "package") +
+		request(6, "check", fixed))
+
+	var out bytes.Buffer
+	gr := gogroup.NewSpecGrouper()
+	assert.Nil(t, gr.Set("std,other"))
+	assert.Equal(t, 0, newBatchServer(in, &out, ioutil.Discard, gogroup.NewProcessor(gr), false).serve())
+
+	// Responses may come in any order, so find them by ID.
+	byID := map[string]map[string]interface{}{}
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		resp := map[string]interface{}{}
+		assert.Nil(t, json.Unmarshal(scanner.Bytes(), &resp))
+		id, err := json.Marshal(resp["id"])
+		assert.Nil(t, err)
+		byID[string(id)] = resp
+	}
+	assert.Len(t, byID, 7)
+
+	violations := byID["1"]["violations"].([]interface{})
+	if assert.Len(t, violations, 1) {
+		v := violations[0].(map[string]interface{})
+		assert.Equal(t, "GI001", v["id"])
+		assert.Equal(t, 5.0, v["line"])
+		assert.Equal(t, "fmt", v["import"])
+	}
+
+	// Content is in base64.
+	assert.Equal(t, map[string]interface{}{"id": "two", "content": "cGFja2FnZSBhCgppbXBvcnQgKAoJImZtdCIKCSJvcyIKKQo=",
+		"changed": true}, byID[`"two"`])
+	// Files that need no fixes come back as they were.
+	assert.Equal(t, map[string]interface{}{"id": 3.0, "content": base64.StdEncoding.EncodeToString([]byte(fixed))},
+		byID["3"])
+
+	// Bad requests get errors, without stopping the server.
+	assert.Contains(t, byID["null"]["error"], "Invalid request")
+	assert.Equal(t, "Unknown op 'lint', expected one of: check, fix", byID["4"]["error"])
+	assert.NotNil(t, byID["5"]["error"])
+	assert.Equal(t, map[string]interface{}{"id": 6.0}, byID["6"])
+}
@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/vasi-stripe/gogroup"
+)
+
+// List the Go files in the git repository containing dir that have changed
+// since ref, as paths relative to dir. The working tree is compared with the
+// merge base of ref and HEAD, so that changes made to ref after the current
+// branch left it don't count. Added files count as changed, including
+// untracked files that aren't ignored. Deleted files don't, and renamed files
+// are listed under their new names.
+func changedFiles(dir, ref string) ([]string, error) {
+	prefix, err := gitOutput("-C", dir, "rev-parse", "--show-prefix")
+	if err != nil {
+		return nil, fmt.Errorf("-since needs a git repository: %s", err.Error())
+	}
+	base, err := gitOutput("-C", dir, "merge-base", ref, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	diff, err := gitOutput("-C", dir, "diff", "--name-only", "--diff-filter=ACMR", "-z",
+		strings.TrimSpace(string(base)), "--", ":(top)*.go")
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := gitOutput("-C", dir, "ls-files", "--others", "--exclude-standard", "--full-name",
+		"-z", "--", ":(top)*.go")
+	if err != nil {
+		return nil, err
+	}
+
+	// Paths from git are relative to the top level of the repository.
+	top := filepath.FromSlash(strings.TrimSpace(string(prefix)))
+	files := []string{}
+	for _, file := range strings.Split(string(diff)+string(untracked), "\x00") {
+		if file == "" {
+			continue
+		}
+		rel, err := filepath.Rel(top, filepath.FromSlash(file))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, rel)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Keep only the found files that have changed since a ref, for -since.
+func filterChanged(found *gogroup.FoundFiles, ref string) error {
+	changed, err := changedFiles(".", ref)
+	if err != nil {
+		return err
+	}
+	keep := map[string]bool{}
+	for _, file := range changed {
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return err
+		}
+		keep[abs] = true
+	}
+
+	filter := func(files []string) []string {
+		kept := []string{}
+		for _, file := range files {
+			if abs, err := filepath.Abs(file); err == nil && keep[abs] {
+				kept = append(kept, file)
+			}
+		}
+		return kept
+	}
+	found.Files, found.TooLarge = filter(found.Files), filter(found.TooLarge)
+	return nil
+}
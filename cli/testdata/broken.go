@@ -0,0 +1,4 @@
+package testdata
+
+import (
+	"fmt"
@@ -0,0 +1,11 @@
+package testdata
+
+import (
+	"fmt"
+	"github.com/example/dep"
+	"os"
+)
+
+var _ = fmt.Sprint
+var _ = os.Args
+var _ = dep.Thing
@@ -0,0 +1,12 @@
+package testdata
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/example/dep"
+)
+
+var _ = fmt.Sprint
+var _ = os.Args
+var _ = dep.Thing
@@ -0,0 +1,496 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Usage text is assembled from sections, so that each subcommand documents
+// just the flags it accepts. It's hard to get the flag package to format long
+// usage well, so everything is written out here.
+
+const (
+	// The exit statuses.
+	usageStatus = `Exit status:
+
+  0  Import grouping is correct, or files were rewritten successfully
+  1  A file could not be read or written, or some other error occurred
+  2  Invalid command-line usage or configuration
+  3  Import grouping is violated, with a violation that's an error
+  4  A file is not valid Go, and could not be parsed
+
+  If several of these occur, the status that comes first in the order 1, 4,
+  3 is used.`
+
+	// Which output goes where.
+	usageStreams = `Output streams:
+
+  Violations, and the paths printed by list, go to standard output.
+  Warnings, errors, notes of rewritten files and summaries go to standard
+  error. With -violations-to stderr, violations go to standard error too,
+  but the paths printed by list never do.`
+
+	// The synopsis of the command, when used without a subcommand that takes
+	// its own flags.
+	usageLegacy = `Usage: group-imports check|fix|list|stats [OPTIONS] PATH...
+       group-imports [OPTIONS] PATH...
+       group-imports [OPTIONS] hook install|uninstall|print|run
+       group-imports init [-write] [PATH...]
+       group-imports why [-order ORDER] [-json] IMPORTPATH [FILE]
+       group-imports [OPTIONS] lsp
+       group-imports [OPTIONS] serve
+       group-imports [ARGS] --persistent_worker
+
+  check, fix, list
+      Check import grouping, rewrite files with the correct grouping, or
+      list files with incorrect grouping. Each takes only the OPTIONS that
+      apply to it, see "group-imports COMMAND -help". Without one of these,
+      files are checked, or rewritten with -rewrite. A PATH named like a
+      command is written as ./check, or after --.
+
+  stats
+      Describe what files import, and how their imports are grouped,
+      without checking anything.
+
+  why IMPORTPATH [FILE]
+      Explain which group IMPORTPATH belongs to, and which part of the order
+      put it there. The order is found as for FILE, if given.`
+
+	// Arguments and flags for finding files.
+	usageFind = `  PATH
+      A Go source file, or a directory to search recursively for Go source
+      files. Like the go command, directories named testdata or vendor, or
+      beginning with . or _, are skipped.
+
+  -follow-symlinks
+      When searching directories, descend into symlinked directories too.
+      Each directory is searched only once, so cycles are harmless.
+      Symlinked Go files are always processed. Default: false.
+
+  -max-file-size SIZE
+      When searching directories, skip files larger than SIZE, which is a
+      number of bytes optionally followed by a unit such as KB, MB or GB.
+      Files named explicitly are still processed, with a warning. Default:
+      no limit.
+
+  -tests include|exclude|only
+      When searching directories, whether to include test files, whose
+      names end in _test.go, along with other files, to exclude them, or
+      to include only test files. Files named explicitly are always
+      processed. Default: include.
+
+  -build-context
+      When searching directories, only process files that would be built
+      for the current platform, according to their build constraints and
+      file names. By default, all files are processed.
+
+  -goos GOOS, -goarch GOARCH, -tags TAG[,TAG...]
+      Like -build-context, but for the given operating system,
+      architecture, or build tags.
+
+  -since REF
+      Only process Go files that have changed in the git working tree
+      since REF, such as origin/main, counting from where the current
+      branch left it. Added and untracked files are included, and deleted
+      ones aren't. Without a PATH, changed files in the current directory
+      are processed.`
+
+	// Flags for reporting violations.
+	usageOutput = `  -format FORMAT
+      How to report import grouping violations. Formats include:
+
+      - text: One line per violation, the default
+      - github: GitHub Actions workflow commands, which show up as
+        annotations. This is the default when GITHUB_ACTIONS=true.
+      - rdjson, rdjsonl: Reviewdog Diagnostic Format, as one JSON document
+        or one diagnostic per line. Diagnostics include suggested fixes.
+      - junit: JUnit XML, with a test case for each file, and a failure
+        for each violation.
+      - tap: Test Anything Protocol, with a test point for each file.
+      - json: One JSON object with the result of every file, including
+        errors and skipped files, and a summary.
+      - editor: Exactly 'path:line:col: message (import "path")' for each
+        violation, for Emacs and Vim. Parse errors take the same form.
+      - teamcity: TeamCity service messages: an inspection for each
+        violation, a build problem for each file that couldn't be
+        processed, and a build statistic of the number of violations.
+      - offsets: 'path:#start-end: message (import "path") [ID]' for each
+        violation, with the byte offsets of the import, empty lines or
+        comment at fault.
+      - codeclimate: A JSON array of Code Climate issues, for GitLab's Code
+        Quality reports, with paths relative to -path-root.
+      - template: A line for each violation, using the -template flag.
+
+      Programs that embed the command can add formats with
+      gogroup.RegisterFormat.
+
+  -template TEMPLATE
+      A Go text/template to execute for each violation, with -format
+      template. Fields include .File, .Line, .Column, .Message,
+      .ImportPath, .Rule, .ID and .GroupName. For example:
+
+        '{{.File}}:{{.Line}}:{{.Column}}: {{.Message}} [{{.Rule}}]'
+        '{{.File}}({{.Line}}): error {{.Rule}}: {{.Message}}'
+
+  -count
+      Instead of reporting each violation, print the total number of
+      violations in all files. Every violation is counted, not just the
+      first one in each file. Default: false.
+
+  -count-by rule
+      Like -count, but print the number of violations for each kind of
+      violation, one per line.
+
+  -violations-to stdout|stderr
+      Where to report violations, in any format. Default: stdout.
+
+  -path-root DIR
+      With -format codeclimate, the directory that paths are relative to,
+      which GitLab needs to be the root of the repository. Default: the
+      top level of the git repository, or else the working directory.
+
+  -show-suppressed
+      After reporting violations, list those that //nolint:gogroup
+      comments suppress on standard error, and count them. Suppressed
+      violations are counted in the summary either way. Default: false.
+
+` + usageLimit
+
+	// The flag for limiting how much is printed.
+	usageLimit = `  -max-violations N
+      Print at most N violations, then note on standard error how many
+      violations were left out. All files are still processed, and
+      counted in the summary and exit status. Default: no limit.
+
+  -fail-fast
+      Stop at the first file with a violation, in the order files are
+      found, and report only that file. Later files aren't processed, so
+      the summary covers only the files before it. Can't be used with
+      -rewrite. Default: false.
+
+  -diff FILE
+      Process the Go files changed by the unified diff in FILE, or standard
+      input if FILE is "-", and report only violations on the lines the
+      diff adds, or that follow lines it removes. Paths given are optional,
+      and limit which files of the diff are processed. Files in the diff
+      that don't exist are skipped with a warning. Can't be used with
+      -rewrite, -fail-fast or -since.
+
+  -baseline FILE
+      Tolerate the violations recorded in the baseline FILE, reporting only
+      new ones. Entries for checked files that record more violations than
+      are found are noted on standard error, so the baseline can be shrunk.
+      Can't be used with -rewrite.
+
+  -write-baseline FILE
+      Record every violation found in the baseline FILE, instead of
+      reporting them. Only files that can't be processed fail. Can't be
+      used with -rewrite, -baseline, -diff, -fail-fast or -since.`
+
+	// The flags for the stats subcommand.
+	usageStats = `  -top N
+      List the N most imported paths, or all of them if N is 0. Default: 10.
+
+  -format FORMAT
+      Print the statistics as text or json. Default: text.`
+
+	// The flags for the why subcommand.
+	usageWhy = `  IMPORTPATH
+      The import path to explain.
+
+  FILE
+      A file the import would be in. The configuration file is found from
+      its directory rather than the current one, and the explanation notes
+      whether the import is part of the file's module, or of another module
+      of its go.work workspace.
+
+  -json
+      Print the explanation as a JSON object. Default: false.`
+
+	// The flag for printing a summary.
+	usageSummary = `  -summary
+      After processing, print counts of files, violations, changes, skipped
+      files and errors to standard error. Violations are also counted by
+      rule and by top-level directory. Default: false.
+
+  -summary-format text|json
+      Like -summary, but in the given format. Default: text.`
+
+	// Flags for the cache.
+	usageCache = `  -cache
+      Remember which files have correct import grouping, and skip them in
+      later runs if neither they nor the configuration have changed. Files
+      with violations are always checked again. Default: false.
+
+  -cache-dir DIR
+      Like -cache, but keep the cache in DIR rather than the user's cache
+      directory.
+
+  -no-cache
+      Don't use the cache, even if -cache or -cache-dir are given.`
+
+	// Flags for diagnosing slow runs.
+	usageProfile = `  -cpuprofile FILE
+      Write a CPU profile to FILE, for go tool pprof.
+
+  -memprofile FILE
+      Write a profile of memory in use once processing is done to FILE,
+      for go tool pprof.
+
+  -trace FILE
+      Write an execution trace to FILE, for go tool trace.
+
+  -debug-timing
+      Print how long each phase of processing took to standard error:
+      walk, parse, group, check, goimports, repair and output, and the
+      total. Files are processed concurrently, so phases may add up to
+      more than the total.
+
+  -debug
+      Log how files are processed to standard error: which files are
+      skipped and why, the group of each import and what in the order
+      matched it, whether goimports changed a file, and which rewrites
+      were skipped as identical.`
+
+	// Flags for how configuration files are used.
+	usageConfig = `  -debug-config
+      Print which .group-imports.json file governs each file processed,
+      and any it extends, to standard error. Each file is processed with
+      the configuration file nearest to it.`
+
+	// The legacy flag for rewriting.
+	usageRewrite = `  -rewrite
+      Instead of checking import grouping, rewrite the source files with
+      the correct grouping. Files are replaced atomically where possible,
+      so they're never left partially written. Default: false.
+
+  -output PATH
+      Instead of checking import grouping, write the fixed content of a
+      single file, or of standard input if the file is "-", to PATH. The
+      input isn't changed, and PATH is written even if nothing needs
+      fixing. Can't be used with -rewrite.
+
+  -always-print
+      Like -output, but print the content to standard output, so the
+      command is a filter. The exit status is 3 if the content was fixed,
+      and 0 if it's printed unchanged.`
+
+	// Flags for how to rewrite.
+	usageRewriteOptions = `  -no-goimports
+      When rewriting, only fix import grouping, rather than also formatting
+      the file and adding missing imports with goimports. Default: false.
+
+  -minimal
+      When rewriting with goimports, leave everything outside the import
+      declarations byte-for-byte unchanged. This is always the case with
+      -no-goimports. Default: false.
+
+  -fix-aliases
+      Rename imports to the alias that the "aliases" of the configuration
+      file require. Uses of the old name elsewhere in the file are NOT
+      renamed, so the file won't compile until they are. Default: false.
+
+  -keep-indentation
+      Keep the indentation of each import line as it is, rather than
+      indenting it with a single tab as gofmt does. goimports reindents the
+      whole file anyway, so this is mostly useful with -no-goimports.
+      Default: false.
+
+  -fix-only RULE[,RULE...]
+      Only fix the violations of the given rules, by ID or rule name as
+      'group-imports rules' lists them. Violations of other rules are
+      still reported. Files with no violations of the given rules aren't
+      rewritten. May be repeated. Default: fix every rule.
+
+  -no-fix RULE[,RULE...]
+      Fix the violations of every rule but the given ones. Rewrites leave
+      alone what unfixed rules require where they can: without
+      statement-group, imports stay in the group they're found in, and
+      only their order and the empty lines around them are fixed; without
+      statement-order, imports keep their order within groups. Likewise
+      for statement-alias, statement-duplicate, comment-stripped, the
+      header rules and the block rules. Other rules, such as those about
+      empty lines, are fixed whenever a file is rewritten. May be repeated.`
+
+	// Flags for how to group imports.
+	usageGrouping = `  -ignore-directives
+      Disregard directive comments: check and rewrite files even if they
+      contain a //group-imports:ignore comment before their imports, and
+      group imports even if they have a trailing //group-imports:keep
+      comment, and report violations that //nolint:gogroup comments
+      suppress. Default: false.
+
+  -order SPEC[,SPEC...]
+      Modify the import grouping strategy by listing the desired groups in
+      order. Group specifications include:
+
+      - std: Standard library imports
+      - prefix=PREFIX: Imports whose path starts with PREFIX. If several
+        prefixes match, the first one listed wins
+      - prefix-file=PATH: Imports whose path starts with any of the
+        prefixes in the file PATH, one per line, where # starts a comment
+      - other: Imports that match no other specification
+      - strict: Instead of other, make imports that match no other
+        specification violations, so each new dependency must be classified
+
+      Each of std and other may be listed at most once, and prefixes must
+      not be empty. Any specification may be followed by :NAME to name its
+      group, eg: prefix=github.com/corp/:Internal. Messages about imports in
+      the wrong group then mention the names. Names must be unique.
+
+      These groups can be specified in one comma-separated argument, or
+      multiple arguments. If not provided, the order is read from the
+      GROUP_IMPORTS_ORDER environment variable, or else from the "order"
+      key of the .group-imports.json file nearest each file, in its
+      directory or one of its parents. That file may instead define
+      "groups" with rules to match imports, or take them from the file
+      named by its "extends" key. Default: std,other
+
+  -order-json JSON|@FILE
+      Set the order with a JSON or YAML document defining "groups" with
+      rules to match imports, as a .group-imports.json file may, either
+      given inline or read from FILE. The document's schema is published as
+      order.schema.json. Errors give the path to the value at fault, such
+      as groups[1].regexes[0]. Can't be combined with -order.
+
+  -print-order-json
+      Print the order in use as a JSON document for -order-json, then
+      exit without processing any files. An -order specification is
+      converted to the equivalent document.
+
+  -sort alpha|depth-then-alpha|natural
+      How to order imports within a group: alphabetically by path; with
+      paths of fewer elements first, and alphabetically among those with
+      the same number; or alphabetically but comparing numbers in paths by
+      value, so that /v2 comes before /v10. Default: alpha, like gofmt.
+
+  -blocks any|parenthesized|plain
+      Whether imports must be in a parenthesized declaration, such as
+      import ( "os" ), even if there's only one; or whether a lone import
+      must be in a plain declaration, such as import "os". Files without
+      imports, or that import "C", are exempt. Default: any.
+
+  -lenient
+      Allow groups to be adjacent without an empty line between them, as
+      long as they're in the right order. More than one empty line is
+      still a violation, and -rewrite still separates groups.
+
+  -comment-separators
+      Allow a line holding only a comment, such as // --- third party ---,
+      to separate groups instead of an empty line. -rewrite keeps such
+      comments between the same groups. Default: false.
+
+  -headers
+      Require each named group to start with a comment of its name, such
+      as // Internal for prefix=github.com/corp/:Internal. Other comments
+      before the group's first import go below the header. -rewrite
+      inserts and corrects headers.
+
+  -strip-comments REGEX
+      Remove comments in the import declarations that match REGEX, such as
+      '^// -+ .* -+$' for banners like // --- 3rd party ---. Each is a
+      violation until -rewrite removes it, so check lists them first. The
+      whole comment is matched, including the //. Line comments and the
+      doc comments of imports are never removed, but a banner above a doc
+      comment is. Default: nothing is removed.
+
+  -duplicates
+      Report imports of a path that's already imported in the same file,
+      even under a different name. -rewrite removes exact repeats, but
+      leaves imports under different names for you to resolve.
+
+  -allow-relative
+      Allow relative import paths, such as "./util", and group them like
+      any other import. By default each is a violation, since they don't
+      work with modules, and it's otherwise left where it is.
+
+  -deny PATH
+      Report imports of the package PATH, or of it and the packages below
+      it if PATH ends in /... May be repeated. The "deny" list of the
+      configuration file adds to these. Denied imports are never removed.
+
+  -severity RULE:LEVEL
+      Make the violations of a rule, given by an ID or rule name that
+      'group-imports rules' lists, errors, warnings or info, with LEVEL
+      error, warning or info. May be repeated. The "severity" object of the
+      configuration file sets these too, but flags take precedence. Other
+      violations are errors. Warnings and info are reported, marked as
+      such, but don't make the command fail.
+
+  -warnings-as-errors
+      Fail on warnings as well as errors.
+
+  -disable RULE[,RULE...]
+      Don't check the given rules, by ID or rule name as 'group-imports
+      rules' lists them. May be repeated. The "disable" list of the
+      configuration file adds to these. Disabled rules aren't fixed either,
+      as with -no-fix.`
+
+	// The hook subcommand.
+	usageHook = `Git pre-commit hook:
+
+  hook install
+      Install a pre-commit hook into the current repository, which runs
+      "hook run" with the given OPTIONS. Installing again replaces the
+      previously installed hook section.
+  hook uninstall
+      Remove a previously installed pre-commit hook.
+  hook print
+      Print a configuration snippet for the pre-commit framework instead.
+  hook run
+      Validate the staged content of all staged Go files.`
+
+	// The init subcommand.
+	usageInit = `Adopting an order:
+
+  init [-write] [PATH...]
+      Suggest an order that matches the import grouping of the Go files
+      in PATH, or the current directory. Prints how many files match, and
+      lists those that don't. The paths of the local module, and of the
+      other modules of its go.work workspace, are considered for groups of
+      their own. In GOPATH mode, the repository containing PATH is the
+      local module. With -write, also create a .group-imports.json file with
+      the order in the current directory. Source files are never modified.`
+
+	// The lsp subcommand.
+	usageLSP = `Editor integration:
+
+  lsp
+      Run a Language Server Protocol server on standard input and output,
+      which publishes diagnostics and formats documents. The order may also
+      be set with the "order" initialization option.`
+
+	// The serve subcommand.
+	usageServe = `Batch server:
+
+  serve
+      Check or fix files sent on standard input, in one process, until
+      standard input ends. Each line is a JSON request like
+      {"id":1,"op":"fix","filename":"a.go","content":"<base64>"}, with op
+      "check" or "fix". Each response is a line of JSON with the request's
+      id, and either "violations", "content" in base64 and "changed", or
+      "error". Requests are handled concurrently, so responses may come in
+      any order. Fixes use goimports unless -no-goimports is given, and
+      the order is found as for files in the current directory.`
+
+	// Bazel support.
+	usageWorker = `Bazel:
+
+  @FILE
+      Any argument may be @FILE, which stands for the arguments in FILE,
+      one per line.
+
+  --persistent_worker
+      Run as a Bazel persistent worker, with the JSON worker protocol. Each
+      work request runs the command with the other ARGS followed by the
+      request's arguments, and its response has the exit status and
+      everything the command wrote. Multiplexed requests run concurrently.`
+)
+
+// Write the usage of a command, with a synopsis followed by sections of flags
+// and subcommands.
+func writeUsage(w io.Writer, synopsis string, sections ...string) {
+	fmt.Fprintf(w, "group-imports: Enforce import grouping in Go source files.\n\n%s\n\n%s\n\n%s\n\n%s\n",
+		usageStatus, usageStreams, synopsis, strings.Join(sections, "\n\n"))
+}
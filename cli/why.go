@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/vasi-stripe/gogroup"
+)
+
+// Why an import path belongs to its group, for the why subcommand.
+type whyResult struct {
+	Path string `json:"path"`
+	*gogroup.Explanation
+
+	// Where the order came from: -order, -order-json, the environment
+	// variable, the path of a configuration file, or "default".
+	Source string `json:"source"`
+
+	// The module the import is part of, if it's the module of the file it
+	// would be in or another module of the file's go.work workspace, and
+	// otherwise the module of the file, if known.
+	Module   string `json:"module,omitempty"`
+	InModule bool   `json:"inModule,omitempty"`
+}
+
+// Write an ordinal number, such as "3rd".
+func ordinal(n int) string {
+	suffix := "th"
+	if n%100 < 11 || n%100 > 13 {
+		switch n % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
+
+// Describe the order a result comes from.
+func (r *whyResult) order() string {
+	if r.Source == "default" {
+		return "the default order"
+	}
+	return "the order from " + r.Source
+}
+
+// Write a result as one line of text.
+func (r *whyResult) write(w io.Writer) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: ", r.Path)
+	switch r.Group {
+	case -1:
+		fmt.Fprintf(&b, "no group, matched nothing in %s, which is strict", r.order())
+	case gogroup.GroupIgnore:
+		fmt.Fprintf(&b, "ignored, matched %s in %s", r.Spec, r.order())
+	default:
+		fmt.Fprintf(&b, "group %d", r.Group)
+		if r.Name != "" {
+			fmt.Fprintf(&b, " (%s)", r.Name)
+		}
+		fmt.Fprintf(&b, ", matched %s", r.Spec)
+		if r.Position > 0 {
+			fmt.Fprintf(&b, " declared %s", ordinal(r.Position))
+		}
+		fmt.Fprintf(&b, " in %s", r.order())
+	}
+	if r.InModule {
+		fmt.Fprintf(&b, "; within module %s", r.Module)
+	}
+	fmt.Fprintln(w, b.String())
+}
+
+// Describe where the order of a grouper comes from, when it wasn't set by the
+// -order flag.
+func orderSource(dir string) (string, error) {
+	if os.Getenv(orderEnvVar) != "" {
+		return orderEnvVar, nil
+	}
+	cfg, err := findConfig(dir)
+	if err != nil {
+		return "", err
+	}
+	if cfg != nil && cfg.order != nil {
+		return cfg.order.path, nil
+	}
+	return "default", nil
+}
+
+// Handle the "why" subcommand, which explains how an import path is grouped.
+// Configuration is found from the directory of a file, if one is given.
+func (c *command) why(args []string) int {
+	o := newOptions()
+	flags := newFlagSet("group-imports why", c.stderr, func() {
+		writeUsage(c.stderr, "Usage: group-imports why [OPTIONS] IMPORTPATH [FILE]\n\n"+
+			"  Explain which group an import path belongs to, and why.",
+			usageWhy, usageGrouping)
+	})
+	flags.Var(o.gr, "order", "")
+	flags.Var(&o.orderJSON, "order-json", "")
+	asJSON := flags.Bool("json", false, "")
+	if ok, status := parseFlags(flags, args); !ok {
+		return status
+	}
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		flags.Usage()
+		return statusHelp
+	}
+
+	res := &whyResult{Path: flags.Arg(0), Source: "-order"}
+	if o.orderJSON.set {
+		res.Source = "-order-json"
+	}
+	dir := "."
+	if flags.NArg() == 2 {
+		dir = filepath.Dir(flags.Arg(1))
+		modules := workspaces{}.localModules(dir)
+		if res.Module = moduleOf(res.Path, modules); res.Module != "" {
+			res.InModule = true
+		} else if len(modules) > 0 {
+			res.Module = modules[0]
+		}
+	}
+	if !o.gr.WasSet() {
+		source, err := orderSource(dir)
+		if err != nil {
+			return c.fail(statusHelp, err)
+		}
+		if err = applyConfig(o.gr, dir); err != nil {
+			return c.fail(statusHelp, err)
+		}
+		res.Source = source
+	}
+	res.Explanation = o.gr.ExplainGroup(res.Path)
+
+	if *asJSON {
+		data, err := json.MarshalIndent(res, "", "  ")
+		if err != nil {
+			return c.fail(statusError, err)
+		}
+		fmt.Fprintln(c.stdout, string(data))
+	} else {
+		res.write(c.stdout)
+	}
+	return 0
+}
@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+)
+
+// Support for running as a Bazel persistent worker, with the JSON worker
+// protocol, and for the argument files Bazel passes otherwise.
+// See https://bazel.build/remote/persistent and
+// https://bazel.build/remote/creating
+
+// The flag Bazel starts persistent workers with.
+const persistentWorkerFlag = "--persistent_worker"
+
+// A work request from Bazel. Its inputs, verbosity and sandbox directory are
+// of no use to us, and cancellation isn't supported, so Bazel never sends
+// cancel requests.
+type workRequest struct {
+	Arguments []string `json:"arguments"`
+	// RequestID is zero for singleplex workers, and otherwise identifies the
+	// request among those of a multiplex worker.
+	RequestID int  `json:"requestId"`
+	Cancel    bool `json:"cancel"`
+}
+
+// A work response to Bazel.
+type workResponse struct {
+	ExitCode  int    `json:"exitCode"`
+	Output    string `json:"output"`
+	RequestID int    `json:"requestId"`
+}
+
+// A writer that can be shared by goroutines.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
+
+// Serve work requests until standard input ends, running the command with
+// the arguments the worker was started with, followed by those of each
+// request. Requests of a singleplex worker are run one at a time, and those
+// of a multiplex worker, which have IDs, concurrently. Returns the status to
+// exit with.
+func (c *command) worker(startup []string) int {
+	dec := json.NewDecoder(c.stdin)
+	// Responses are written whole, one at a time.
+	out := &lockedWriter{w: c.stdout}
+	var wg sync.WaitGroup
+	for {
+		req := &workRequest{}
+		if err := dec.Decode(req); err == io.EOF {
+			break
+		} else if err != nil {
+			// The stream can't be resynchronized, so Bazel must start another
+			// worker.
+			wg.Wait()
+			return c.fail(statusError, fmt.Errorf("Invalid work request: %s", err.Error()))
+		}
+		if req.Cancel {
+			continue
+		}
+
+		work := func() {
+			var output bytes.Buffer
+			w := &lockedWriter{w: &output}
+			sub := &command{stdin: strings.NewReader(""), stdout: w, stderr: w}
+			args, err := expandArgfiles(req.Arguments)
+			status := statusHelp
+			if err != nil {
+				fmt.Fprintln(w, err.Error())
+			} else {
+				status = sub.main(append(append([]string{}, startup...), args...))
+			}
+			data, err := json.Marshal(&workResponse{ExitCode: status, Output: output.String(),
+				RequestID: req.RequestID})
+			if err == nil {
+				_, err = out.Write(append(data, '\n'))
+			}
+			if err != nil {
+				fmt.Fprintln(c.stderr, err.Error())
+			}
+		}
+		if req.RequestID == 0 {
+			work()
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			work()
+		}()
+	}
+	wg.Wait()
+	return 0
+}
+
+// Replace each argument of the form @FILE with the arguments in FILE, one per
+// line, as Bazel writes them.
+func expandArgfiles(args []string) ([]string, error) {
+	expanded := []string{}
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "@") || arg == "@" {
+			expanded = append(expanded, arg)
+			continue
+		}
+		data, err := ioutil.ReadFile(arg[1:])
+		if err != nil {
+			return nil, fmt.Errorf("Can't read argument file: %s", err.Error())
+		}
+		for _, line := range strings.SplitAfter(string(data), "\n") {
+			if line != "" {
+				expanded = append(expanded, strings.TrimRight(line, "\r\n"))
+			}
+		}
+	}
+	return expanded, nil
+}
@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPersistentWorker(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	argfile := filepath.Join(dir, "args")
+	assert.Nil(t, ioutil.WriteFile(argfile, []byte("testdata/invalid.go\n"), 0644))
+
+	in := `{"arguments": ["testdata/valid.go"]}
+{"arguments": ["testdata/invalid.go"], "requestId": 7}
+{"arguments": ["@` + argfile + `"], "requestId": 8, "inputs": [{"path": "testdata/invalid.go", "digest": "AA=="}]}
+{"arguments": ["@` + filepath.Join(dir, "missing") + `"]}
+`
+	var stdout, stderr bytes.Buffer
+	status := Run([]string{"check", "-order", "std,other", persistentWorkerFlag}, strings.NewReader(in),
+		&stdout, &stderr)
+	assert.Equal(t, 0, status)
+	assert.Equal(t, "", stderr.String())
+
+	// Responses of multiplexed requests may come in any order.
+	responses := map[int][]workResponse{}
+	scanner := bufio.NewScanner(&stdout)
+	for scanner.Scan() {
+		resp := workResponse{}
+		assert.Nil(t, json.Unmarshal(scanner.Bytes(), &resp))
+		responses[resp.RequestID] = append(responses[resp.RequestID], resp)
+	}
+	if assert.Len(t, responses[0], 2) {
+		assert.Equal(t, workResponse{}, responses[0][0])
+		assert.Equal(t, statusHelp, responses[0][1].ExitCode)
+		assert.Contains(t, responses[0][1].Output, "Can't read argument file")
+	}
+	for _, id := range []int{7, 8} {
+		if assert.Len(t, responses[id], 1) {
+			assert.Equal(t, statusInvalidFile, responses[id][0].ExitCode)
+			assert.Contains(t, responses[id][0].Output,
+				"testdata/invalid.go:5: Import in incorrect group at \"github.com/example/dep\"")
+		}
+	}
+
+	// Bad requests stop the worker.
+	stdout.Reset()
+	status = Run([]string{persistentWorkerFlag}, strings.NewReader("{bogus"), &stdout, &stderr)
+	assert.Equal(t, statusError, status)
+	assert.Contains(t, stderr.String(), "Invalid work request")
+}
+
+func TestArgfile(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	argfile := filepath.Join(dir, "args")
+	assert.Nil(t, ioutil.WriteFile(argfile, []byte("-order\r\nstd,other\r\ntestdata/invalid.go\r\n"), 0644))
+
+	stdout, _, status := runCommand("check", "@"+argfile)
+	assert.Equal(t, statusInvalidFile, status)
+	assert.Contains(t, stdout, "testdata/invalid.go:5: ")
+
+	_, stderr, status := runCommand("check", "@"+filepath.Join(dir, "missing"))
+	assert.Equal(t, statusHelp, status)
+	assert.Contains(t, stderr, "Can't read argument file")
+}
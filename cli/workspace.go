@@ -0,0 +1,216 @@
+package cli
+
+import (
+	"bufio"
+	"go/build"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// A module used by a go.work workspace: its directory, and its module path.
+type workspaceModule struct {
+	dir, path string
+}
+
+// The modules of go.work workspaces, by the path of each go.work file, so
+// that each workspace is only resolved once in a run.
+type workspaces map[string][]workspaceModule
+
+// Find the module path declared by a go.mod file, or the empty string if it
+// can't be read or declares none.
+func readModulePath(gomod string) string {
+	f, err := os.Open(gomod)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "module" {
+			if path, err := strconv.Unquote(fields[1]); err == nil {
+				return path
+			}
+			return fields[1]
+		}
+	}
+	return ""
+}
+
+// Find the go.work file governing dir, as the go command would: the one
+// named by GOWORK, or the nearest one above dir. There's none if GOWORK is
+// "off".
+func findWorkFile(dir string) string {
+	switch gowork := os.Getenv("GOWORK"); gowork {
+	case "off":
+		return ""
+	case "":
+	default:
+		return gowork
+	}
+	for {
+		path := filepath.Join(dir, "go.work")
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// Read the directories of the modules a go.work file uses, from its use
+// directives, both single and in blocks. Directories are relative to the
+// go.work file, unless they're absolute.
+func readWorkFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dirs := []string{}
+	add := func(dir string) {
+		if unquoted, err := strconv.Unquote(dir); err == nil {
+			dir = unquoted
+		}
+		dir = filepath.FromSlash(dir)
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(filepath.Dir(path), dir)
+		}
+		dirs = append(dirs, dir)
+	}
+	inUse := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+		case inUse && fields[0] == ")":
+			inUse = false
+		case inUse:
+			add(fields[0])
+		case fields[0] == "use" && len(fields) == 2 && fields[1] == "(":
+			inUse = true
+		case fields[0] == "use" && len(fields) == 2:
+			add(fields[1])
+		}
+	}
+	return dirs, scanner.Err()
+}
+
+// Resolve the modules of the workspace of a go.work file. Used directories
+// without a readable module path are left out.
+func (ws workspaces) modules(workFile string) []workspaceModule {
+	if mods, ok := ws[workFile]; ok {
+		return mods
+	}
+	mods := []workspaceModule{}
+	dirs, _ := readWorkFile(workFile)
+	for _, dir := range dirs {
+		if path := readModulePath(filepath.Join(dir, "go.mod")); path != "" {
+			mods = append(mods, workspaceModule{dir, path})
+		}
+	}
+	ws[workFile] = mods
+	return mods
+}
+
+// Hosts whose repositories are named by two path elements after the host,
+// such as "github.com/org/repo".
+var repoHosts = map[string]bool{
+	"bitbucket.org": true,
+	"github.com":    true,
+	"gitlab.com":    true,
+	"golang.org":    true,
+}
+
+// Find the import path of the repository containing dir in GOPATH mode, from
+// where dir is under the src directory of an entry of gopath. That's the first
+// three elements of its import path for well-known hosts, such as
+// "github.com/org/repo", or otherwise the first element. Yields the empty
+// string if dir isn't within a GOPATH.
+func gopathRepo(dir, gopath string) string {
+	for _, root := range filepath.SplitList(gopath) {
+		if root == "" {
+			continue
+		}
+		rel, err := filepath.Rel(filepath.Join(root, "src"), dir)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		elems := strings.Split(filepath.ToSlash(rel), "/")
+		n := 1
+		if repoHosts[elems[0]] {
+			n = 3
+		}
+		if len(elems) < n {
+			n = len(elems)
+		}
+		return strings.Join(elems[:n], "/")
+	}
+	return ""
+}
+
+// Find the paths of the modules whose packages are local to dir. That's the
+// module containing dir, followed by the other modules of its go.work
+// workspace, if it's within one of them. Outside a workspace, it's just the
+// module containing dir, if any. Without a module, in GOPATH mode, it's the
+// repository containing dir.
+func (ws workspaces) localModules(dir string) []string {
+	own := modulePath(dir)
+	abs, err := filepath.Abs(dir)
+	workFile := ""
+	if err == nil {
+		workFile = findWorkFile(abs)
+		if own == "" {
+			own = gopathRepo(abs, build.Default.GOPATH)
+		}
+	}
+	if workFile == "" {
+		if own == "" {
+			return nil
+		}
+		return []string{own}
+	}
+
+	mods := ws.modules(workFile)
+	inside := false
+	for _, mod := range mods {
+		if abs == mod.dir || strings.HasPrefix(abs, mod.dir+string(filepath.Separator)) {
+			inside = true
+		}
+	}
+	ret := []string{}
+	if own != "" {
+		ret = append(ret, own)
+	}
+	if !inside {
+		return ret
+	}
+	for _, mod := range mods {
+		if !containsString(ret, mod.path) {
+			ret = append(ret, mod.path)
+		}
+	}
+	return ret
+}
+
+// Find which of some modules an import path is within, or the empty string if
+// it's in none of them.
+func moduleOf(path string, modules []string) string {
+	for _, module := range modules {
+		if path == module || strings.HasPrefix(path, module+"/") {
+			return module
+		}
+	}
+	return ""
+}
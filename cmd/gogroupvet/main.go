@@ -0,0 +1,18 @@
+// Command gogroupvet checks import grouping as part of go vet:
+//
+//	go vet -vettool=$(which gogroupvet) -gogroup.order=std,other ./...
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/unitchecker"
+
+	"github.com/vasi-stripe/gogroup"
+)
+
+func main() {
+	gr := gogroup.NewSpecGrouper()
+	analyzer := gogroup.NewAnalyzer(gr)
+	analyzer.Flags.Var(gr, "order",
+		"comma-separated list of import groups, in order: std, prefix=PREFIX, other")
+	unitchecker.Main(analyzer)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Write a file, creating parent directories as needed.
+func writeFile(t *testing.T, path, content string) {
+	assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+	assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0644))
+}
+
+func TestVettool(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds and runs go vet")
+	}
+
+	dir, err := ioutil.TempDir("", "gogroupvet")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	tool := filepath.Join(dir, "gogroupvet")
+	out, err := exec.Command("go", "build", "-o", tool, ".").CombinedOutput()
+	assert.Nil(t, err, string(out))
+
+	mod := filepath.Join(dir, "mod")
+	writeFile(t, filepath.Join(mod, "go.mod"), "module example.com/mod\n\ngo 1.12\n")
+	writeFile(t, filepath.Join(mod, "sub", "sub.go"), "package sub\n")
+	writeFile(t, filepath.Join(mod, "a.go"), `package mod
+
+import (
+	_ "example.com/mod/sub"
+
+	"os"
+)
+
+var _ = os.Args
+`)
+
+	vet := func(args ...string) string {
+		cmd := exec.Command("go", append([]string{"vet", "-vettool=" + tool}, args...)...)
+		cmd.Dir = mod
+		cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod")
+		out, _ := cmd.CombinedOutput()
+		return string(out)
+	}
+
+	// Grouped incorrectly by default.
+	res := vet("./...")
+	assert.Contains(t, res, "a.go:6:1")
+	assert.Contains(t, res, "Import groups out of order")
+
+	// The order is configurable.
+	res = vet("-gogroup.order=other,std", "./...")
+	assert.NotContains(t, res, "a.go")
+}
@@ -0,0 +1,131 @@
+package gogroup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Types for Code Climate issues, as GitLab reads them for its Code Quality
+// reports. See https://docs.gitlab.com/ee/ci/testing/code_quality.html
+
+type codeclimateLines struct {
+	Begin int `json:"begin"`
+}
+
+type codeclimateLocation struct {
+	Path  string           `json:"path"`
+	Lines codeclimateLines `json:"lines"`
+}
+
+type codeclimateIssue struct {
+	Type        string              `json:"type"`
+	Description string              `json:"description"`
+	CheckName   string              `json:"check_name"`
+	Categories  []string            `json:"categories"`
+	Fingerprint string              `json:"fingerprint"`
+	Severity    string              `json:"severity"`
+	Location    codeclimateLocation `json:"location"`
+}
+
+// The Code Climate severities of violations, by Severity.
+var codeclimateSeverities = []string{"major", "minor", "info"}
+
+// Code Climate output, as a JSON array with an issue for each violation.
+// Paths are relative to root, or to the working directory if it's empty.
+type codeclimateOutput struct {
+	w      io.Writer
+	root   string
+	issues []*codeclimateIssue
+	seen   map[string]int
+}
+
+func newCodeclimateOutput(w io.Writer) outputFormat {
+	return &codeclimateOutput{w: w, issues: []*codeclimateIssue{}, seen: map[string]int{}}
+}
+
+// Yield the slash-separated path of a file relative to root, or to the working
+// directory if root is empty. Paths outside it are kept as they are.
+func relativePath(root, path string) string {
+	abs, err := filepath.Abs(path)
+	if err == nil && root == "" {
+		root, err = os.Getwd()
+	}
+	if err == nil {
+		root, err = filepath.Abs(root)
+	}
+	if err == nil {
+		if rel, err := filepath.Rel(root, abs); err == nil && !strings.HasPrefix(rel, "..") {
+			path = rel
+		}
+	}
+	return filepath.ToSlash(filepath.Clean(path))
+}
+
+// Yield the fingerprint of an issue, which depends only on its file, rule and
+// import path, so that it's the same as long as the violation is there, even
+// if lines move. Repeats of the same violation in a file are numbered.
+func (o *codeclimateOutput) fingerprint(path, rule, importPath string) string {
+	key := strings.Join([]string{path, rule, importPath}, "\x00")
+	n := o.seen[key]
+	o.seen[key]++
+	if n > 0 {
+		key += "\x00" + strconv.Itoa(n)
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:16])
+}
+
+func (o *codeclimateOutput) result(res *FileResult) {
+	path := relativePath(o.root, res.Path)
+	for _, v := range res.Violations {
+		rule := v.ID()
+		if rule == "" {
+			rule = v.Rule
+		}
+		severity := SeverityError.String()
+		if v.Severity >= 0 && int(v.Severity) < len(codeclimateSeverities) {
+			severity = codeclimateSeverities[v.Severity]
+		}
+		o.issues = append(o.issues, &codeclimateIssue{
+			Type:        "issue",
+			Description: fmt.Sprintf("%s at %s", v.Message, strconv.Quote(v.ImportPath)),
+			CheckName:   rule,
+			Categories:  []string{"Style"},
+			Fingerprint: o.fingerprint(path, rule, v.ImportPath),
+			Severity:    severity,
+			Location:    codeclimateLocation{Path: path, Lines: codeclimateLines{Begin: v.Line}},
+		})
+	}
+}
+
+func (o *codeclimateOutput) finish() error {
+	data, err := json.MarshalIndent(o.issues, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = o.w.Write(append(data, '\n'))
+	return err
+}
+
+// WriteCodeClimate writes the violations in a report to w as Code Climate
+// issues, which GitLab shows in merge requests as a Code Quality report. Paths
+// are relative to root, which should be the root of the repository, so that
+// GitLab can match them against its diffs. If root is empty, paths are
+// relative to the working directory, as with the "codeclimate" format.
+//
+// Each issue's fingerprint is a hash of its file, rule ID and import path, so
+// it stays the same while the violation is unfixed, even as lines move.
+func (r *Report) WriteCodeClimate(w io.Writer, root string) error {
+	return r.WriteFormatter(w, builtinFormat(func(w io.Writer) outputFormat {
+		out := newCodeclimateOutput(w).(*codeclimateOutput)
+		out.root = root
+		return out
+	})())
+}
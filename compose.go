@@ -0,0 +1,96 @@
+package gogroup
+
+// Look up the group of an import with any Grouper, yielding whether it's in
+// any group. Only a StrictGrouper can decline.
+func lookupGroup(g Grouper, pkgPath string) (int, bool) {
+	if strict, ok := g.(StrictGrouper); ok {
+		return strict.LookupGroup(pkgPath)
+	}
+	return g.Group(pkgPath), true
+}
+
+// Name a group with any Grouper, or yield the empty string if it can't.
+func groupName(g Grouper, group int) string {
+	if namer, ok := g.(GroupNamer); ok {
+		return namer.GroupName(group)
+	}
+	return ""
+}
+
+type firstMatch []Grouper
+
+// FirstMatch combines Groupers, so that each import is grouped by the first
+// of them that assigns it a group. Since only a StrictGrouper can decline to
+// assign a group, every grouper but the last is typically strict. An import
+// that every grouper declines belongs to no group.
+//
+// Group numbers are used as each grouper yields them, so groupers whose
+// groups should be distinct must yield distinct numbers; see Offset. For
+// example, to put generated packages in a group of their own after those of
+// a base grouper with groups 0 and 1:
+//
+//	FirstMatch(Offset(generated, 2), base)
+//
+// where generated yields group 0 for generated packages, and declines all
+// others. Group names are taken from the first grouper that names the group.
+func FirstMatch(groupers ...Grouper) StrictGrouper {
+	return firstMatch(groupers)
+}
+
+func (f firstMatch) LookupGroup(pkgPath string) (int, bool) {
+	for _, g := range f {
+		if group, ok := lookupGroup(g, pkgPath); ok {
+			return group, true
+		}
+	}
+	return 0, false
+}
+
+func (f firstMatch) Group(pkgPath string) int {
+	if group, ok := f.LookupGroup(pkgPath); ok || len(f) == 0 {
+		return group
+	}
+	return f[len(f)-1].Group(pkgPath)
+}
+
+func (f firstMatch) GroupName(group int) string {
+	for _, g := range f {
+		if name := groupName(g, group); name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+type offset struct {
+	g Grouper
+	n int
+}
+
+// Offset shifts the group numbers of a Grouper by n, so that they don't
+// collide with those of another grouper it's combined with by FirstMatch.
+// GroupIgnore is left as it is, and imports the grouper declines are still
+// declined.
+func Offset(g Grouper, n int) StrictGrouper {
+	return offset{g, n}
+}
+
+func (o offset) LookupGroup(pkgPath string) (int, bool) {
+	group, ok := lookupGroup(o.g, pkgPath)
+	if ok && group != GroupIgnore {
+		group += o.n
+	}
+	return group, ok
+}
+
+func (o offset) Group(pkgPath string) int {
+	group := o.g.Group(pkgPath)
+	if group != GroupIgnore {
+		group += o.n
+	}
+	return group
+}
+
+func (o offset) GroupName(group int) string {
+	return groupName(o.g, group-o.n)
+}
@@ -0,0 +1,86 @@
+package gogroup
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Puts generated protobuf packages in group 0, and declines everything else.
+type grouperProto struct{}
+
+func (grouperProto) Group(pkg string) int {
+	group, _ := grouperProto{}.LookupGroup(pkg)
+	return group
+}
+
+func (grouperProto) LookupGroup(pkg string) (int, bool) {
+	return 0, strings.HasSuffix(pkg, "pb")
+}
+
+func (grouperProto) GroupName(group int) string {
+	if group == 0 {
+		return "Protobuf"
+	}
+	return ""
+}
+
+func TestFirstMatch(t *testing.T) {
+	t.Parallel()
+
+	g := FirstMatch(Offset(grouperProto{}, 2), grouperNamed{})
+	for path, group := range map[string]int{
+		"os":                      0,
+		"github.com/pkg/errors":   1,
+		"github.com/corp/api/apb": 2,
+	} {
+		actual, ok := g.LookupGroup(path)
+		assert.True(t, ok, path)
+		assert.Equal(t, group, actual, path)
+		assert.Equal(t, group, g.Group(path), path)
+	}
+	namer := g.(GroupNamer)
+	assert.Equal(t, "Standard", namer.GroupName(0))
+	assert.Equal(t, "Protobuf", namer.GroupName(2))
+	assert.Equal(t, "", namer.GroupName(3))
+
+	// Imports every grouper declines belong to no group.
+	_, ok := FirstMatch(grouperProto{}).LookupGroup("os")
+	assert.False(t, ok)
+	_, ok = Offset(grouperProto{}, 2).LookupGroup("os")
+	assert.False(t, ok)
+
+	// Ignored imports stay ignored.
+	assert.Equal(t, GroupIgnore, Offset(grouperIgnoring{}, 2).Group("example.com/a/register"))
+
+	// The layers work together through validation and repair.
+	proc := NewProcessor(g)
+	text := `package main
+
+import (
+	"github.com/corp/api/apb"
+	"github.com/pkg/errors"
+	"os"
+)
+`
+	validErr, err := proc.Validate("", strings.NewReader(text))
+	assert.Nil(t, err)
+	assert.NotNil(t, validErr)
+	r, err := proc.Repair("", strings.NewReader(text))
+	assert.Nil(t, err)
+	fixed := readAll(t, r)
+	assert.Equal(t, `package main
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+
+	"github.com/corp/api/apb"
+)
+`, fixed)
+	validErr, err = proc.Validate("", strings.NewReader(fixed))
+	assert.Nil(t, err)
+	assert.Nil(t, validErr)
+}
@@ -0,0 +1,432 @@
+package gogroup
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// A Config is a configuration document, in the format of the
+// .group-imports.json files the gogroup command reads, in JSON or YAML, such
+// as:
+//
+//	{
+//	  "order": "std,prefix=github.com/myorg/,other",
+//	  "sort": "natural",
+//	  "deny": [{"path": "github.com/pkg/errors", "message": "use errors"}],
+//	  "disable": ["statement-extra-line"]
+//	}
+//
+// DefaultConfig yields a starting point. Settings that are left out keep the
+// defaults of NewProcessor.
+type Config struct {
+	// Comment is ignored, so that documents can explain themselves.
+	Comment string `json:"$comment,omitempty"`
+
+	// Extends names the configuration to take settings from that this one
+	// lacks. Only the gogroup command follows it, since it's a file relative
+	// to the directory of this one.
+	Extends string `json:"extends,omitempty"`
+
+	// Order is an order specification, as SpecGrouper.Set takes.
+	Order string `json:"order,omitempty"`
+
+	// Groups and Default make the document a rules document instead, in the
+	// schema of GrouperFromConfig.
+	Groups  json.RawMessage `json:"groups,omitempty"`
+	Default json.RawMessage `json:"default,omitempty"`
+
+	// Sort is how to order imports within a group, as ParseSortMode takes.
+	Sort string `json:"sort,omitempty"`
+
+	// Lenient allows imports of different groups to be adjacent, as the
+	// Lenient option does.
+	Lenient *bool `json:"lenient,omitempty"`
+
+	// Aliases requires imports matching patterns to be under certain names,
+	// and ForbiddenAliases lists names no import may be under.
+	Aliases          []AliasConfig `json:"aliases,omitempty"`
+	ForbiddenAliases []string      `json:"forbiddenAliases,omitempty"`
+
+	// Deny forbids imports of certain packages.
+	Deny []DenyConfig `json:"deny,omitempty"`
+
+	// Layers restricts what packages in certain directories may import.
+	Layers []LayerConfig `json:"layers,omitempty"`
+
+	// Severity sets the severity of rules, by ID or rule name.
+	Severity map[string]string `json:"severity,omitempty"`
+
+	// Disable lists rules not to check, by ID or rule name.
+	Disable []string `json:"disable,omitempty"`
+
+	// The node of the document the configuration was read from, and the line
+	// of each value in it, by path.
+	node  *yaml.Node
+	lines map[string]int
+}
+
+// An AliasConfig requires imports whose path matches a regex to be under an
+// alias, as an AliasRule.
+type AliasConfig struct {
+	Pattern string `json:"pattern"`
+	Alias   string `json:"alias"`
+}
+
+// A DenyConfig forbids imports by exact path, prefix or regex, as a DenyRule.
+// Exactly one of them must be set.
+type DenyConfig struct {
+	Path    string `json:"path,omitempty"`
+	Prefix  string `json:"prefix,omitempty"`
+	Regex   string `json:"regex,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// A LayerConfig restricts the imports of files in directories matching a
+// pattern, relative to the directory of the configuration, as a LayerRule.
+type LayerConfig struct {
+	Dir   string   `json:"dir"`
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+	Name  string   `json:"name,omitempty"`
+}
+
+// The starter configuration of DefaultConfig.
+const defaultConfig = `{
+  "$comment": "Configuration for gogroup. See https://github.com/vasi-stripe/gogroup#configuration-file",
+  "order": "std,other",
+  "sort": "alpha",
+  "lenient": false,
+  "aliases": [],
+  "forbiddenAliases": [],
+  "deny": [],
+  "layers": [],
+  "severity": {},
+  "disable": []
+}
+`
+
+// DefaultConfig yields a configuration document to start from, with the
+// default order and sort, and every other setting present but empty.
+func DefaultConfig() []byte {
+	return []byte(defaultConfig)
+}
+
+// ReadConfig reads a configuration document in JSON or YAML, and checks that
+// it's valid. Its rules document, if any, is read by the same loader as
+// GrouperFromConfig. Errors are reported as a *ConfigError with the path to
+// the value at fault, such as "deny[1].regex", and the line it's at, where
+// that's known.
+func ReadConfig(r io.Reader) (*Config, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	root, err := parseDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	if root.Kind != yaml.MappingNode {
+		return nil, &ConfigError{Line: root.Line, Err: errors.New("Expected an object")}
+	}
+	if data, err = nodeJSON(root); err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	c := &Config{node: root, lines: nodeLines(root)}
+	if err = dec.Decode(c); err != nil {
+		cerr := &ConfigError{Err: err}
+		if err, ok := err.(*json.UnmarshalTypeError); ok {
+			cerr.Line, cerr.Path = c.lines[err.Field], err.Field
+		}
+		return nil, cerr
+	}
+	if err = c.validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Yield the line of a byte offset in a document.
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return 1 + bytes.Count(data[:offset], []byte("\n"))
+}
+
+// Find the line of each value of a document, by its path.
+func nodeLines(root *yaml.Node) map[string]int {
+	lines := map[string]int{}
+	var walk func(n *yaml.Node, path string)
+	walk = func(n *yaml.Node, path string) {
+		n = resolveAlias(n)
+		lines[path] = n.Line
+		switch n.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				sub := n.Content[i].Value
+				if path != "" {
+					sub = path + "." + sub
+				}
+				walk(n.Content[i+1], sub)
+			}
+		case yaml.SequenceNode:
+			for i, item := range n.Content {
+				walk(item, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	}
+	walk(root, "")
+	return lines
+}
+
+// Yield an error with a value of the configuration.
+func (c *Config) errorAt(path string, err error) error {
+	return &ConfigError{Line: c.lines[path], Path: path, Err: err}
+}
+
+// Check that every setting of a configuration is valid.
+func (c *Config) validate() error {
+	if c.Order != "" && (c.Groups != nil || c.Default != nil) {
+		return c.errorAt("order", errors.New("Use either \"order\" or \"groups\", not both"))
+	}
+	if err := NewSpecGrouper().UseConfig(c); err != nil {
+		return err
+	}
+	if _, err := c.SortMode(); err != nil {
+		return err
+	}
+	if _, err := c.AliasRules(); err != nil {
+		return err
+	}
+	if _, err := c.DenyRules(); err != nil {
+		return err
+	}
+	if _, err := c.LayerRules(""); err != nil {
+		return err
+	}
+	if _, err := c.Severities(); err != nil {
+		return err
+	}
+	for i, rule := range c.Disable {
+		if _, err := ParseRule(rule); err != nil {
+			return c.errorAt(fmt.Sprintf("disable[%d]", i), err)
+		}
+	}
+	return nil
+}
+
+// HasOrder determines whether a configuration sets the order, with either an
+// order specification or a rules document.
+func (c *Config) HasOrder() bool {
+	return c.Order != "" || c.Groups != nil || c.Default != nil
+}
+
+// SortMode yields how the configuration orders imports within a group, which
+// is SortAlpha if it doesn't say.
+func (c *Config) SortMode() (SortMode, error) {
+	if c.Sort == "" {
+		return SortAlpha, nil
+	}
+	mode, err := ParseSortMode(c.Sort)
+	if err != nil {
+		return mode, c.errorAt("sort", err)
+	}
+	return mode, nil
+}
+
+// AliasRules yields the alias rules of the configuration.
+func (c *Config) AliasRules() ([]AliasRule, error) {
+	rules := []AliasRule{}
+	for i, a := range c.Aliases {
+		if a.Alias == "" {
+			return nil, c.errorAt(fmt.Sprintf("aliases[%d]", i),
+				fmt.Errorf("Alias rule for '%s' has no alias", a.Pattern))
+		}
+		re, err := regexp.Compile(a.Pattern)
+		if err != nil {
+			return nil, c.errorAt(fmt.Sprintf("aliases[%d].pattern", i),
+				fmt.Errorf("Invalid regex '%s': %s", a.Pattern, err.Error()))
+		}
+		rules = append(rules, AliasRule{Pattern: re, Alias: a.Alias})
+	}
+	return rules, nil
+}
+
+// DenyRules yields the deny rules of the configuration.
+func (c *Config) DenyRules() ([]DenyRule, error) {
+	rules := []DenyRule{}
+	for i, d := range c.Deny {
+		rule := DenyRule{Path: d.Path, Prefix: d.Prefix, Message: d.Message}
+		set := 0
+		for _, s := range []string{d.Path, d.Prefix, d.Regex} {
+			if s != "" {
+				set++
+			}
+		}
+		if set != 1 {
+			return nil, c.errorAt(fmt.Sprintf("deny[%d]", i),
+				errors.New("Each \"deny\" entry needs one of \"path\", \"prefix\" or \"regex\""))
+		}
+		if d.Regex != "" {
+			re, err := regexp.Compile(d.Regex)
+			if err != nil {
+				return nil, c.errorAt(fmt.Sprintf("deny[%d].regex", i),
+					fmt.Errorf("Invalid regex '%s': %s", d.Regex, err.Error()))
+			}
+			rule.Pattern = re
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// LayerRules yields the layering rules of the configuration, with their
+// directories relative to dir, which is usually the one the configuration
+// file is in.
+func (c *Config) LayerRules(dir string) ([]LayerRule, error) {
+	rules := []LayerRule{}
+	for i, l := range c.Layers {
+		if l.Dir == "" {
+			return nil, c.errorAt(fmt.Sprintf("layers[%d]", i), errors.New("Each \"layers\" entry needs a \"dir\""))
+		}
+		if _, err := filepath.Match(l.Dir, ""); err != nil {
+			return nil, c.errorAt(fmt.Sprintf("layers[%d].dir", i),
+				fmt.Errorf("Invalid directory pattern '%s'", l.Dir))
+		}
+		name := l.Name
+		if name == "" {
+			name = l.Dir
+		}
+		rules = append(rules, LayerRule{
+			Dir:   filepath.Join(dir, filepath.FromSlash(l.Dir)),
+			Allow: l.Allow,
+			Deny:  l.Deny,
+			Name:  name,
+		})
+	}
+	return rules, nil
+}
+
+// Severities yields the severities the configuration sets, by ID or rule
+// name.
+func (c *Config) Severities() (map[string]Severity, error) {
+	rules := []string{}
+	for rule := range c.Severity {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+	severities := map[string]Severity{}
+	for _, rule := range rules {
+		path := "severity." + rule
+		if _, err := ParseRule(rule); err != nil {
+			return nil, c.errorAt(path, err)
+		}
+		severity, err := ParseSeverity(c.Severity[rule])
+		if err != nil {
+			return nil, c.errorAt(path, err)
+		}
+		severities[rule] = severity
+	}
+	return severities, nil
+}
+
+// Options yields the options of the Processor a configuration describes,
+// other than its Grouper. The directories of layering rules are relative to
+// dir.
+func (c *Config) Options(dir string) ([]Option, error) {
+	mode, err := c.SortMode()
+	if err != nil {
+		return nil, err
+	}
+	aliases, err := c.AliasRules()
+	if err != nil {
+		return nil, err
+	}
+	deny, err := c.DenyRules()
+	if err != nil {
+		return nil, err
+	}
+	layers, err := c.LayerRules(dir)
+	if err != nil {
+		return nil, err
+	}
+	severities, err := c.Severities()
+	if err != nil {
+		return nil, err
+	}
+	return []Option{Sort(mode), Lenient(c.Lenient != nil && *c.Lenient), Aliases(aliases, c.ForbiddenAliases),
+		Deny(deny), Layers(layers), Severities(severities), DisableRules(c.Disable...)}, nil
+}
+
+// UseConfig replaces the order with that of a configuration, if it has one.
+// An order specification sets the order, while a rules document leaves it
+// unset, as with UseRules.
+func (g *SpecGrouper) UseConfig(c *Config) error {
+	if c.Groups != nil || c.Default != nil {
+		root := c.node
+		if root == nil {
+			data, err := json.Marshal(&Config{Groups: c.Groups, Default: c.Default})
+			if err != nil {
+				return err
+			}
+			if root, err = parseDocument(data); err != nil {
+				return err
+			}
+		}
+		return g.useRules(rulesOnly(root))
+	}
+	if c.Order != "" {
+		if err := g.Set(c.Order); err != nil {
+			return c.errorAt("order", err)
+		}
+	}
+	return nil
+}
+
+// NewProcessorFromConfig creates a Processor from a configuration document,
+// as ReadConfig reads it, with the grouper and options it describes. The
+// configuration can't extend another, since there's no file to find it
+// relative to, and layering rules are relative to the working directory.
+func NewProcessorFromConfig(r io.Reader) (*Processor, error) {
+	c, err := ReadConfig(r)
+	if err != nil {
+		return nil, err
+	}
+	if c.Extends != "" {
+		return nil, c.errorAt("extends", errors.New("A configuration that isn't read from a file can't extend another"))
+	}
+	g := NewSpecGrouper()
+	if err = g.UseConfig(c); err != nil {
+		return nil, err
+	}
+	opts, err := c.Options("")
+	if err != nil {
+		return nil, err
+	}
+	return NewProcessor(g, opts...), nil
+}
+
+// Yield the node of a configuration document with everything but the rules
+// document left out. The values keep their lines, so that errors in the rules
+// refer to the right line.
+func rulesOnly(root *yaml.Node) *yaml.Node {
+	rules := *root
+	rules.Content = nil
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if key := root.Content[i].Value; key == "groups" || key == "default" {
+			rules.Content = append(rules.Content, root.Content[i], root.Content[i+1])
+		}
+	}
+	return &rules
+}
@@ -0,0 +1,153 @@
+package gogroup
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultConfig(t *testing.T) {
+	t.Parallel()
+
+	c, err := ReadConfig(bytes.NewReader(DefaultConfig()))
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.True(t, c.HasOrder())
+	assert.NotEmpty(t, c.Comment)
+
+	p, err := NewProcessorFromConfig(bytes.NewReader(DefaultConfig()))
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, SortAlpha, p.sortMode)
+	assert.False(t, p.lenient)
+	errs, err := p.ValidateAll("a.go", strings.NewReader("package a\n\nimport (\n\t\"os\"\n\n\t\"github.com/a/b\"\n)\n"))
+	assert.Nil(t, err)
+	assert.Empty(t, errs)
+}
+
+func TestNewProcessorFromConfig(t *testing.T) {
+	t.Parallel()
+
+	doc := `{
+  "order": "std,prefix=example.com/,other",
+  "sort": "natural",
+  "lenient": true,
+  "severity": {"GI004": "warning"},
+  "disable": ["statement-extra-line"]
+}`
+	p, err := NewProcessorFromConfig(strings.NewReader(doc))
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, SortNatural, p.sortMode)
+	assert.True(t, p.lenient)
+	yp, err := NewProcessorFromConfig(strings.NewReader(`# The same, in YAML.
+order: std,prefix=example.com/,other
+sort: natural
+lenient: true
+severity: {GI004: warning}
+disable: [statement-extra-line]
+`))
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, p.sortMode, yp.sortMode)
+	assert.Equal(t, p.lenient, yp.lenient)
+	assert.Equal(t, p.disabled, yp.disabled)
+	assert.Equal(t, p.severities, yp.severities)
+	assert.True(t, p.disabled["statement-extra-line"])
+	assert.Equal(t, SeverityWarning, p.severities["GI004"])
+
+	text := "package a\n\nimport (\n\t\"os\"\n\n\t\"github.com/a/b\"\n\t\"example.com/c\"\n)\n"
+	errs, err := p.ValidateAll("a.go", strings.NewReader(text))
+	assert.Nil(t, err)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "example.com/c", errs[0].ImportPath)
+		assert.Equal(t, SeverityWarning, errs[0].Severity)
+	}
+
+	// The order may be a rules document instead.
+	p, err = NewProcessorFromConfig(strings.NewReader(`{
+  "groups": [{"name": "Standard", "std": true}, {"name": "Ours", "prefixes": ["example.com/"]}],
+  "sort": "alpha"
+}`))
+	if !assert.Nil(t, err) {
+		return
+	}
+	errs, err = p.ValidateAll("a.go", strings.NewReader("package a\n\nimport (\n\t\"os\"\n\n\t\"example.com/c\"\n)\n"))
+	assert.Nil(t, err)
+	assert.Empty(t, errs)
+}
+
+func TestReadConfigErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		doc, path, message string
+		line               int
+	}{
+		{"{\n  \"order\": \"std,bogus\"\n}", "order", "bogus", 2},
+		{`{"order": "std", "groups": []}`, "order", "not both", 1},
+		{"{\n\"sort\": \"random\"}", "sort", "Unknown sort mode 'random'", 2},
+		{"{\"aliases\": [\n{\"pattern\": \"x\"}]}", "aliases[0]", "has no alias", 2},
+		{"{\"aliases\": [{\"pattern\": \"(\", \"alias\": \"x\"}]}", "aliases[0].pattern", "Invalid regex '('", 1},
+		{"{\"deny\": [{\"path\": \"a\"},\n {\"path\": \"b\", \"prefix\": \"c\"}]}", "deny[1]", "needs one of", 2},
+		{"{\"deny\": [\n{\"regex\": \"(\"}]}", "deny[0].regex", "Invalid regex '('", 2},
+		{"{\"layers\": [{\"allow\": []}]}", "layers[0]", "needs a \"dir\"", 1},
+		{"{\"layers\": [{\"dir\": \"[\"}]}", "layers[0].dir", "Invalid directory pattern '['", 1},
+		{"{\"severity\": {\n\"GI004\": \"loud\"}}", "severity.GI004", "Unknown severity", 2},
+		{"{\"severity\": {\"GI999\": \"error\"}}", "severity.GI999", "Unknown rule 'GI999'", 1},
+		{"{\"disable\": [\"statement-group\",\n\"nope\"]}", "disable[1]", "Unknown rule 'nope'", 2},
+		{"{\"groups\": [\n{\"regexes\": [\"(\"]}]}", "groups[0].regexes[0]", "(", 2},
+		{"{\n\"lenient\": \"yes\"}", "lenient", "bool", 2},
+		{"{\n\"order\": [}", "", "invalid character", 2},
+		{"order: std\ndisable:\n  - statement-group\n  - nope\n", "disable[1]", "Unknown rule 'nope'", 4},
+		{"sort: natural\ngroups:\n  - name: A\n    regexes: ['(']\n", "groups[0].regexes[0]", "(", 4},
+		{"lenient: [true]\n", "lenient", "bool", 1},
+		{"[]", "", "Expected an object", 1},
+		{`{"unknown": 1}`, "", "unknown field", 0},
+	} {
+		_, err := ReadConfig(strings.NewReader(tc.doc))
+		cerr, ok := err.(*ConfigError)
+		if !assert.True(t, ok, tc.doc) {
+			continue
+		}
+		assert.Equal(t, tc.path, cerr.Path, tc.doc)
+		assert.Equal(t, tc.line, cerr.Line, tc.doc)
+		assert.Contains(t, err.Error(), tc.message, tc.doc)
+	}
+
+	_, err := NewProcessorFromConfig(strings.NewReader(`{"extends": ".."}`))
+	assert.EqualError(t, err, "line 1 (extends): A configuration that isn't read from a file can't extend another")
+}
+
+func TestRulesOnly(t *testing.T) {
+	t.Parallel()
+
+	for doc, expected := range map[string]string{
+		`{"groups": [], "aliases": []}`:          `{"groups":[]}`,
+		`{"aliases": [], "groups": []}`:          `{"groups":[]}`,
+		"{\"a\": 1,\n\"groups\": [],\n\"b\": 2}": `{"groups":[]}`,
+		`{"default": "x"}`:                       `{"default":"x"}`,
+		`{"groups": [], "a": 1, "b": 2}`:         `{"groups":[]}`,
+		"a: 1\ndefault: x\ngroups: []\n":         `{"default":"x","groups":[]}`,
+	} {
+		root, err := parseDocument([]byte(doc))
+		if !assert.Nil(t, err, doc) {
+			continue
+		}
+		out, err := nodeJSON(rulesOnly(root))
+		assert.Nil(t, err, doc)
+		assert.Equal(t, expected, string(out), doc)
+	}
+
+	// The rules keep their lines.
+	root, err := parseDocument([]byte("{\"a\": 1,\n\"groups\": [],\n\"b\": 2}"))
+	if assert.Nil(t, err) {
+		assert.Equal(t, 2, nodeLines(rulesOnly(root))["groups"])
+	}
+}
@@ -0,0 +1,53 @@
+package gogroup
+
+import (
+	"regexp"
+	"strings"
+)
+
+// A DenyRule forbids imports whose path matches it. Exactly one of Path,
+// Prefix and Pattern should be set.
+type DenyRule struct {
+	// Path matches exactly one import path.
+	Path string
+	// Prefix matches import paths that start with it.
+	Prefix string
+	// Pattern matches import paths anywhere, unless anchored.
+	Pattern *regexp.Regexp
+
+	// Message optionally explains what to do instead, such as "use the
+	// standard errors package".
+	Message string
+}
+
+// Determine whether a rule matches an import path.
+func (r *DenyRule) matches(path string) bool {
+	switch {
+	case r.Path != "":
+		return path == r.Path
+	case r.Prefix != "":
+		return strings.HasPrefix(path, r.Prefix)
+	case r.Pattern != nil:
+		return r.Pattern.MatchString(path)
+	}
+	return false
+}
+
+// Deny forbids imports that match any of the rules. Each is a violation, with
+// the message of the first rule it matches. Repairs never remove such
+// imports, since the code using them must change too.
+func Deny(rules []DenyRule) Option {
+	return func(p *Processor) {
+		p.denyRules = rules
+	}
+}
+
+// Find the first deny rule that matches an import path, if any.
+func (p *Processor) findDenied(path string) *DenyRule {
+	for i := range p.denyRules {
+		if p.denyRules[i].matches(path) {
+			return &p.denyRules[i]
+		}
+	}
+	return nil
+}
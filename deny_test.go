@@ -0,0 +1,49 @@
+package gogroup
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeny(t *testing.T) {
+	t.Parallel()
+
+	text := `package main
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/corp/legacy/db"
+	"github.com/pkg/errors"
+	"github.com/user/lib/v1"
+)
+`
+	proc := NewProcessor(grouperGoimports{}, Deny([]DenyRule{
+		{Path: "github.com/pkg/errors", Message: "use the standard errors package"},
+		{Prefix: "github.com/corp/legacy/"},
+		{Pattern: regexp.MustCompile(`/v1$`), Message: "use v2"},
+		{Pattern: regexp.MustCompile(`^io/ioutil$`)},
+	}))
+	errs, err := proc.ValidateAll("", strings.NewReader(text))
+	assert.Nil(t, err)
+	messages := []string{}
+	for _, validErr := range errs {
+		assert.Equal(t, "statement-denied", validErr.Rule)
+		messages = append(messages, validErr.Message)
+	}
+	assert.Equal(t, []string{
+		"Import of a denied package",
+		"Import of a denied package",
+		"Import of a denied package (use the standard errors package)",
+		"Import of a denied package (use v2)",
+	}, messages)
+
+	// Repairs can't remove them.
+	r, err := proc.Repair("", strings.NewReader(text))
+	assert.Nil(t, err)
+	assert.Equal(t, text, readAll(t, r))
+}
@@ -0,0 +1,106 @@
+package gogroup
+
+import (
+	"bytes"
+	"go/token"
+	"sort"
+)
+
+// A TextEdit replaces a range of the content of a file with new text.
+type TextEdit struct {
+	// Start and End are the positions of the range to replace, with End just
+	// after its last byte. Their Offsets are byte offsets into the content,
+	// and their Lines and Columns are one-based, with columns counted in
+	// bytes. Where they're equal, the edit inserts text.
+	Start, End token.Position
+	// NewText is the text that replaces the range.
+	NewText string
+}
+
+// Find the edits that replace a block of lines of src with its fixed content.
+// Only whole lines of the block are replaced, and lines that are the same in
+// both are left alone, so the edits are as small as line-based edits can be.
+func textEdits(fileName string, src []byte, block *ImportBlock) []TextEdit {
+	// Only the lines of the block are compared, and the rest of the file is
+	// the same in both.
+	fixed := spliceBlock(src, block)
+	offs := lineOffsets(src)
+	start, end := offs[block.StartLine-1], offs[block.EndLine]
+	a := bytes.SplitAfter(src[start:end], []byte("\n"))
+	b := bytes.SplitAfter(fixed[start:len(fixed)-(len(src)-end)], []byte("\n"))
+
+	// The longest common subsequence of lines, by dynamic programming over
+	// the suffixes of each. Import blocks are short, so this is cheap.
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if bytes.Equal(a[i], b[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	starts := []int{0}
+	for i, c := range src {
+		if c == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	position := func(offset int) token.Position {
+		line := sort.Search(len(starts), func(i int) bool { return starts[i] > offset }) - 1
+		return token.Position{Filename: fileName, Offset: offset, Line: line + 1, Column: offset - starts[line] + 1}
+	}
+
+	edits := []TextEdit{}
+	offset := start
+	var edit *TextEdit
+	flush := func() {
+		if edit != nil {
+			edit.End = position(offset)
+			edits = append(edits, *edit)
+			edit = nil
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && bytes.Equal(a[i], b[j]):
+			flush()
+			offset += len(a[i])
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			// Insert a line of the fixed content.
+			if edit == nil {
+				edit = &TextEdit{Start: position(offset)}
+			}
+			edit.NewText += string(b[j])
+			j++
+		default:
+			// Remove a line of the original content.
+			if edit == nil {
+				edit = &TextEdit{Start: position(offset)}
+			}
+			offset += len(a[i])
+			i++
+		}
+	}
+	flush()
+	return edits
+}
+
+// Find the edits that repair the import grouping of a file.
+func (p *Processor) edits(fileName string, src []byte) ([]TextEdit, error) {
+	block, err := p.repairBlock(fileName, bytes.NewReader(src))
+	if err != nil || block == nil {
+		return []TextEdit{}, err
+	}
+	return textEdits(fileName, src, block), nil
+}
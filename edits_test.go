@@ -0,0 +1,72 @@
+package gogroup
+
+import (
+	"bytes"
+	"go/token"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEdits(t *testing.T) {
+	t.Parallel()
+
+	proc := NewProcessor(grouperGoimports{})
+	src := []byte("package main\n\nimport (\n\t\"os\"\n\t\"fmt\"\n\n\t\"github.com/a/b\"\n)\n")
+	edits, err := proc.Edits("a.go", src)
+	assert.Nil(t, err)
+	assert.Equal(t, []TextEdit{
+		{
+			Start:   token.Position{Filename: "a.go", Offset: 23, Line: 4, Column: 1},
+			End:     token.Position{Filename: "a.go", Offset: 23, Line: 4, Column: 1},
+			NewText: "\t\"fmt\"\n",
+		},
+		{
+			Start: token.Position{Filename: "a.go", Offset: 29, Line: 5, Column: 1},
+			End:   token.Position{Filename: "a.go", Offset: 36, Line: 6, Column: 1},
+		},
+	}, edits)
+
+	// Only the import block is compared, however long the file is.
+	long := append(append([]byte{}, src...), bytes.Repeat([]byte("var _ = 1\n"), 10000)...)
+	longEdits, err := proc.Edits("a.go", long)
+	assert.Nil(t, err)
+	assert.Equal(t, edits, longEdits)
+
+	edits, err = proc.Edits("", []byte("package main\n\nimport \"os\"\n"))
+	assert.Nil(t, err)
+	assert.Empty(t, edits)
+}
+
+func TestEditsMatchRepair(t *testing.T) {
+	t.Parallel()
+
+	proc := NewProcessor(grouperGoimports{})
+	for _, text := range []string{
+		"package main\n\nimport (\n\t\"os\"\n\t\"fmt\"\n)\n",
+		"package main\n\nimport (\n\t\"os\"\n\t\"fmt\"\n)",
+		"package main\r\n\r\nimport (\r\n\t\"os\"\r\n\t\"fmt\"\r\n)\r\n",
+		"package main\n\nimport \"os\"\nimport \"github.com/a/b\"\nimport \"fmt\"\n\nfunc main() {}\n",
+		"package main\n\nimport (\n\t// Doc.\n\t\"github.com/a/b\" // Line.\n\t\"fmt\"\n\n\n\t\"local/x\"\n\t\"os\"\n)\n",
+		"package main\n\nimport (\n\t\"github.com/a/b\"; \"fmt\"\n)\n\nvar x = 1\n",
+	} {
+		src := []byte(text)
+		r, err := proc.Repair("", bytes.NewReader(src))
+		assert.Nil(t, err)
+		repaired, err := ioutil.ReadAll(r)
+		assert.Nil(t, err)
+
+		edits, err := proc.Edits("", src)
+		assert.Nil(t, err)
+		assert.NotEmpty(t, edits, text)
+		applied, end := []byte{}, 0
+		for _, edit := range edits {
+			assert.True(t, edit.Start.Offset >= end && edit.End.Offset >= edit.Start.Offset, text)
+			applied = append(append(applied, src[end:edit.Start.Offset]...), edit.NewText...)
+			end = edit.End.Offset
+		}
+		applied = append(applied, src[end:]...)
+		assert.Equal(t, string(repaired), string(applied))
+	}
+}
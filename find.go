@@ -0,0 +1,251 @@
+package gogroup
+
+import (
+	"fmt"
+	"go/build"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// TestFiles determines whether FindFiles includes test files, whose names end
+// in "_test.go".
+type TestFiles int
+
+const (
+	// TestsInclude includes test files along with other files.
+	TestsInclude TestFiles = iota
+	// TestsExclude skips test files.
+	TestsExclude
+	// TestsOnly skips files that aren't test files.
+	TestsOnly
+)
+
+// FindOptions configures how FindFiles searches directories.
+type FindOptions struct {
+	// FollowSymlinks determines whether to descend into symlinked
+	// directories. Symlinked Go files are always included.
+	FollowSymlinks bool
+
+	// MaxFileSize is the size in bytes above which files found in directories
+	// are skipped. If zero, there's no limit. Files that are named explicitly
+	// are still included, but with a warning.
+	MaxFileSize int64
+
+	// Tests determines whether test files found in directories are included.
+	// Files that are named explicitly are always included.
+	Tests TestFiles
+
+	// BuildContext, if non-nil, restricts files found in directories to those
+	// that match it, considering build constraints and file name suffixes such
+	// as "_linux.go". Files that are named explicitly are always included.
+	BuildContext *build.Context
+
+	// Logger, if non-nil, logs the files and directories that are skipped,
+	// and why, at debug level.
+	Logger *slog.Logger
+}
+
+// FoundFiles are the files found by FindFiles.
+type FoundFiles struct {
+	// Files are the Go source files to process.
+	Files []string
+
+	// TooLarge are the files found in directories, but skipped because they
+	// exceed the MaxFileSize.
+	TooLarge []string
+
+	// Warnings describe problems that didn't stop files from being found.
+	Warnings []string
+}
+
+// FindFiles expands a list of paths into the Go source files to process.
+//
+// Paths to files are included as they are. Paths to directories are searched
+// recursively for files ending in ".go", which are included in lexical order. Like the go command, the search skips
+// directories named testdata or vendor, and those beginning with "." or "_".
+//
+// Even when following symlinks, each directory is only searched once, so
+// symlink cycles are harmless. A symlink that would be included or followed,
+// but whose target doesn't exist, is an error.
+func FindFiles(paths []string, opts FindOptions) (*FoundFiles, error) {
+	f := &finder{opts: opts, visited: map[string]bool{}}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			if f.tooLarge(info) {
+				f.found.Warnings = append(f.found.Warnings, fmt.Sprintf(
+					"%s: processing despite exceeding the maximum file size", path))
+			}
+			f.found.Files = append(f.found.Files, path)
+			continue
+		}
+		if err = f.findDir(path); err != nil {
+			return nil, err
+		}
+	}
+	return &f.found, nil
+}
+
+// State for finding files.
+type finder struct {
+	opts FindOptions
+
+	// The real paths of directories already searched.
+	visited map[string]bool
+
+	// The files found so far.
+	found FoundFiles
+}
+
+// Determine whether a file exceeds the maximum size.
+func (f *finder) tooLarge(info os.FileInfo) bool {
+	return f.opts.MaxFileSize > 0 && info.Size() > f.opts.MaxFileSize
+}
+
+// Determine whether a file found in a directory is excluded by the Tests
+// option.
+func (f *finder) excludedTest(name string) bool {
+	isTest := strings.HasSuffix(name, "_test.go")
+	switch f.opts.Tests {
+	case TestsExclude:
+		return isTest
+	case TestsOnly:
+		return !isTest
+	}
+	return false
+}
+
+// Add a file found in a directory.
+func (f *finder) add(path string, info os.FileInfo) error {
+	if f.excludedTest(filepath.Base(path)) {
+		f.skipped(path, "tests")
+		return nil
+	}
+	if f.opts.BuildContext != nil {
+		match, err := f.opts.BuildContext.MatchFile(filepath.Split(path))
+		if err != nil || !match {
+			if err == nil {
+				f.skipped(path, "build constraints")
+			}
+			return err
+		}
+	}
+	if f.tooLarge(info) {
+		f.skipped(path, SkipTooLarge)
+		f.found.TooLarge = append(f.found.TooLarge, path)
+	} else {
+		f.found.Files = append(f.found.Files, path)
+	}
+	return nil
+}
+
+// Log that a file or directory was skipped, and why.
+func (f *finder) skipped(path, reason string) {
+	if debugging(f.opts.Logger) {
+		f.opts.Logger.Debug("path skipped", "path", path, "reason", reason)
+	}
+}
+
+// Determine whether a file name is a Go source file.
+func isGoFile(name string) bool {
+	return strings.HasSuffix(name, ".go") && !strings.HasPrefix(name, ".")
+}
+
+// Determine whether a directory should be skipped, based on its name.
+func skipDir(name string) bool {
+	return name == "testdata" || name == "vendor" ||
+		strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
+}
+
+// Mark a directory as visited, yielding false if it already was.
+func (f *finder) visit(dir string) (bool, error) {
+	real, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return false, err
+	}
+	real, err = filepath.Abs(real)
+	if err != nil {
+		return false, err
+	}
+	if f.visited[real] {
+		return false, nil
+	}
+	f.visited[real] = true
+	return true, nil
+}
+
+// Search a directory for Go files.
+func (f *finder) findDir(root string) error {
+	if ok, err := f.visit(root); err != nil || !ok {
+		return err
+	}
+
+	// A trailing separator makes Walk descend into the root, even if it's a
+	// symlink.
+	walkRoot := root
+	if !strings.HasSuffix(walkRoot, string(filepath.Separator)) {
+		walkRoot += string(filepath.Separator)
+	}
+	return filepath.Walk(walkRoot, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == walkRoot {
+			return nil
+		}
+
+		if info.IsDir() {
+			if skipDir(info.Name()) {
+				f.skipped(path, "directory name")
+				return filepath.SkipDir
+			}
+			if f.opts.FollowSymlinks {
+				// Another symlink may already have led us here.
+				if ok, err := f.visit(path); err != nil || !ok {
+					if err == nil {
+						err = filepath.SkipDir
+					}
+					return err
+				}
+			}
+			return nil
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			return f.findLink(path, info)
+		}
+		if info.Mode().IsRegular() && isGoFile(info.Name()) {
+			return f.add(path, info)
+		}
+		return nil
+	})
+}
+
+// Handle a symlink found while searching a directory.
+func (f *finder) findLink(path string, info os.FileInfo) error {
+	if !isGoFile(info.Name()) && (!f.opts.FollowSymlinks || skipDir(info.Name())) {
+		return nil
+	}
+
+	target, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		dest, _ := os.Readlink(path)
+		return fmt.Errorf("%s: symlink target %s does not exist", path, dest)
+	} else if err != nil {
+		return err
+	}
+
+	if target.IsDir() {
+		if f.opts.FollowSymlinks {
+			return f.findDir(path)
+		}
+	} else if target.Mode().IsRegular() && isGoFile(info.Name()) {
+		return f.add(path, target)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package gogroup
+
+import (
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Create a tree of files for testing FindFiles. Call the returned function to
+// clean up.
+func findTree(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+
+	for _, file := range []string{
+		"a.go",
+		"README.md",
+		"sub/b.go",
+		"sub/testdata/c.go",
+		"sub/.hidden/d.go",
+		"vendor/e.go",
+		"shared/f.go",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(file))
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, ioutil.WriteFile(path, []byte("package a\n"), 0644))
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func TestFindFiles(t *testing.T) {
+	t.Parallel()
+
+	dir, cleanup := findTree(t)
+	defer cleanup()
+
+	found, err := FindFiles([]string{dir, filepath.Join(dir, "README.md")}, FindOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "a.go"),
+		filepath.Join(dir, "shared", "f.go"),
+		filepath.Join(dir, "sub", "b.go"),
+		filepath.Join(dir, "README.md"),
+	}, found.Files)
+
+	_, err = FindFiles([]string{filepath.Join(dir, "missing")}, FindOptions{})
+	assert.NotNil(t, err)
+}
+
+func TestFindFilesSymlinks(t *testing.T) {
+	t.Parallel()
+
+	dir, cleanup := findTree(t)
+	defer cleanup()
+	sub := filepath.Join(dir, "sub")
+	assert.Nil(t, os.Symlink(filepath.Join(dir, "shared"), filepath.Join(sub, "linked")))
+	assert.Nil(t, os.Symlink(filepath.Join(dir, "a.go"), filepath.Join(sub, "link.go")))
+	assert.Nil(t, os.Symlink(dir, filepath.Join(sub, "loop")))
+
+	// Symlinked files are always included, but directories only when
+	// following symlinks.
+	found, err := FindFiles([]string{sub}, FindOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(sub, "b.go"),
+		filepath.Join(sub, "link.go"),
+	}, found.Files)
+
+	// Cycles are only searched once.
+	found, err = FindFiles([]string{sub}, FindOptions{FollowSymlinks: true})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(sub, "b.go"),
+		filepath.Join(sub, "link.go"),
+		filepath.Join(sub, "linked", "f.go"),
+		filepath.Join(sub, "loop", "a.go"),
+	}, found.Files)
+
+	// Broken links are errors.
+	assert.Nil(t, os.Symlink(filepath.Join(dir, "missing"), filepath.Join(sub, "broken")))
+	_, err = FindFiles([]string{sub}, FindOptions{})
+	assert.Nil(t, err)
+	_, err = FindFiles([]string{sub}, FindOptions{FollowSymlinks: true})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "does not exist")
+}
+
+func TestFindFilesMaxSize(t *testing.T) {
+	t.Parallel()
+
+	dir, cleanup := findTree(t)
+	defer cleanup()
+	large := filepath.Join(dir, "sub", "large.go")
+	assert.Nil(t, ioutil.WriteFile(large, make([]byte, 100), 0644))
+
+	found, err := FindFiles([]string{dir, large}, FindOptions{MaxFileSize: 50})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "a.go"),
+		filepath.Join(dir, "shared", "f.go"),
+		filepath.Join(dir, "sub", "b.go"),
+		large,
+	}, found.Files)
+	assert.Equal(t, []string{large}, found.TooLarge)
+	assert.Len(t, found.Warnings, 1)
+}
+
+func TestFindFilesTests(t *testing.T) {
+	t.Parallel()
+
+	dir, cleanup := findTree(t)
+	defer cleanup()
+	test := filepath.Join(dir, "sub", "b_test.go")
+	assert.Nil(t, ioutil.WriteFile(test, []byte("package a\n"), 0644))
+
+	found, err := FindFiles([]string{filepath.Join(dir, "sub")}, FindOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "sub", "b.go"), test}, found.Files)
+
+	found, err = FindFiles([]string{filepath.Join(dir, "sub")}, FindOptions{Tests: TestsOnly})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{test}, found.Files)
+
+	// Explicitly named files are always included.
+	found, err = FindFiles([]string{filepath.Join(dir, "sub"), test}, FindOptions{Tests: TestsExclude})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "sub", "b.go"), test}, found.Files)
+}
+
+func TestFindFilesBuildContext(t *testing.T) {
+	t.Parallel()
+
+	dir, cleanup := findTree(t)
+	defer cleanup()
+	sub := filepath.Join(dir, "sub")
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(sub, "b_windows.go"), []byte("package a\n"), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(sub, "tagged.go"),
+		[]byte("// +build special\n\npackage a\n"), 0644))
+
+	found, err := FindFiles([]string{sub}, FindOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, found.Files, 3)
+
+	ctx := build.Default
+	ctx.GOOS = "linux"
+	found, err = FindFiles([]string{sub}, FindOptions{BuildContext: &ctx})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{filepath.Join(sub, "b.go")}, found.Files)
+
+	ctx.GOOS = "windows"
+	ctx.BuildTags = []string{"special"}
+	found, err = FindFiles([]string{sub}, FindOptions{BuildContext: &ctx})
+	assert.Nil(t, err)
+	assert.Len(t, found.Files, 3)
+}
@@ -1,5 +1,9 @@
 module github.com/vasi-stripe/gogroup
 
-go 1.12
+go 1.21
 
-require golang.org/x/tools v0.0.0-20190903025054-afe7f8212f0d
+require (
+	github.com/stretchr/testify v1.12.1
+	go.yaml.in/yaml/v3 v3.0.5
+	golang.org/x/tools v0.0.0-20190903025054-afe7f8212f0d
+)
@@ -0,0 +1,119 @@
+// Package gogrouptest checks the import grouping of a source tree from Go
+// tests, so a repository can enforce its conventions with "go test" alone:
+//
+//	func TestImportGrouping(t *testing.T) {
+//		gogrouptest.AssertTree(t, ".", grouper, gogrouptest.Options{})
+//	}
+//
+// Each violation fails the test with a "file:line:" prefix, which editors and
+// IDEs can jump to.
+package gogrouptest
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/vasi-stripe/gogroup"
+)
+
+// DefaultShortLimit is the number of files checked in short mode, if
+// Options.ShortLimit is zero.
+const DefaultShortLimit = 100
+
+// T is the part of *testing.T that AssertTree uses.
+type T interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+	Logf(format string, args ...interface{})
+}
+
+// Options configures AssertTree.
+type Options struct {
+	// Find configures how files are found in the tree, as with
+	// gogroup.FindFiles. The zero value finds files the usual way, including
+	// test files.
+	Find gogroup.FindOptions
+
+	// Processor are the options of the processor that checks each file.
+	Processor []gogroup.Option
+
+	// ShortLimit is the most files to check when testing.Short() is true.
+	// Files are sampled evenly across the tree, in a way that doesn't change
+	// from one run to the next. If zero, it's DefaultShortLimit. If negative,
+	// every file is checked even in short mode.
+	ShortLimit int
+
+	// Update logs how to fix each file with violations: the lines of its
+	// import block, and what they should be instead. Tests usually set it
+	// from a flag, such as -update.
+	Update bool
+}
+
+// AssertTree checks the import grouping of the Go files under root, and
+// reports an error for each violation, and for each file that can't be
+// processed. Violations that aren't errors, according to the Severities
+// option of the processor, are only logged. Directories are searched as with gogroup.FindFiles, so testdata
+// and vendor directories are skipped, and files with an ignore directive are
+// left alone.
+//
+// It yields whether the grouping of every file checked was correct.
+func AssertTree(t T, root string, grouper gogroup.Grouper, opts Options) bool {
+	t.Helper()
+	found, err := gogroup.FindFiles([]string{root}, opts.Find)
+	if err != nil {
+		t.Errorf("%s: %s", root, err.Error())
+		return false
+	}
+	for _, warning := range found.Warnings {
+		t.Logf("Warning: %s", warning)
+	}
+
+	files := found.Files
+	limit := opts.ShortLimit
+	if limit == 0 {
+		limit = DefaultShortLimit
+	}
+	if limit > 0 && len(files) > limit && testing.Short() {
+		t.Logf("Checking %d of %d files in short mode", limit, len(files))
+		files = sample(files, limit)
+	}
+
+	proc := gogroup.NewProcessor(grouper, opts.Processor...)
+	report, err := gogroup.ProcessFiles(context.Background(), files, proc, gogroup.RunOptions{})
+	if err != nil {
+		t.Errorf("%s: %s", root, err.Error())
+		return false
+	}
+
+	ok := true
+	for _, res := range report.Files {
+		if res.Err != nil {
+			t.Errorf("%s: %s", res.Path, res.Err.Error())
+			ok = false
+			continue
+		}
+		for _, v := range res.Violations {
+			if v.Severity != gogroup.SeverityError {
+				t.Logf("%s:%d: %s: %s at %q [%s]", res.Path, v.Line, v.Severity, v.Message, v.ImportPath, v.ID())
+				continue
+			}
+			t.Errorf("%s:%d: %s at %q [%s]", res.Path, v.Line, v.Message, v.ImportPath, v.ID())
+			ok = false
+		}
+		if opts.Update && res.Fix != nil {
+			t.Logf("%s:%d-%d: Import block should be:\n%s", res.Path, res.Fix.StartLine, res.Fix.EndLine,
+				strings.Join(res.Fix.Fixed, "\n"))
+		}
+	}
+	return ok
+}
+
+// Choose n of the files, spread evenly through them.
+func sample(files []string, n int) []string {
+	ret := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		ret = append(ret, files[i*len(files)/n])
+	}
+	return ret
+}
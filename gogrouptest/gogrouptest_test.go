@@ -0,0 +1,66 @@
+package gogrouptest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vasi-stripe/gogroup"
+)
+
+// Record what a test would report.
+type recorder struct {
+	errors, logs []string
+}
+
+func (r *recorder) Helper() {}
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+func (r *recorder) Logf(format string, args ...interface{}) {
+	r.logs = append(r.logs, fmt.Sprintf(format, args...))
+}
+
+func TestAssertTree(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	invalid := "package p\n\nimport (\n\t\"example.com/x\"\n\t\"os\"\n)\n"
+	for name, src := range map[string]string{
+		"a.go":          "package p\n\nimport (\n\t\"os\"\n\n\t\"example.com/x\"\n)\n",
+		"b.go":          invalid,
+		"testdata/c.go": invalid,
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, ioutil.WriteFile(path, []byte(src), 0644))
+	}
+
+	r := &recorder{}
+	assert.False(t, AssertTree(r, dir, gogroup.NewGoimportsGrouper(), Options{ShortLimit: -1}))
+	b := filepath.Join(dir, "b.go")
+	assert.Equal(t, []string{b + ":5: Import in incorrect group at \"os\" [GI004]"}, r.errors)
+	assert.Empty(t, r.logs)
+
+	// Fixes can be logged too.
+	r = &recorder{}
+	AssertTree(r, dir, gogroup.NewGoimportsGrouper(), Options{ShortLimit: -1, Update: true})
+	assert.Equal(t, []string{b + ":4-5: Import block should be:\n\t\"os\"\n\n\t\"example.com/x\""}, r.logs)
+
+	r = &recorder{}
+	assert.True(t, AssertTree(r, filepath.Join(dir, "a.go"), gogroup.NewGoimportsGrouper(), Options{}))
+	assert.Empty(t, r.errors)
+}
+
+func TestSample(t *testing.T) {
+	t.Parallel()
+
+	files := []string{"a", "b", "c", "d", "e", "f"}
+	assert.Equal(t, []string{"a", "c", "e"}, sample(files, 3))
+	assert.Equal(t, []string{"a", "b", "d", "e"}, sample(files, 4))
+}
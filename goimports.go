@@ -0,0 +1,52 @@
+package gogroup
+
+import "strings"
+
+// The groups of NewGoimportsGrouper, in the order goimports puts them.
+const (
+	goimportsStd = iota
+	goimportsThirdParty
+	goimportsAppengine
+	goimportsLocal
+)
+
+type goimportsGrouper []string
+
+// NewGoimportsGrouper creates a Grouper that groups imports exactly as
+// goimports does with its -local flag: the standard library, then third-party
+// packages, then App Engine packages, then local packages.
+//
+// Each of localPrefixes may be a comma-separated list, like the argument of
+// -local. As in goimports, a path is local if it starts with any of the
+// prefixes, or is a prefix without its trailing slash. Prefixes match
+// strings, not path elements, so "example.com/foo" matches
+// "example.com/foobar" too, while "example.com/foo/" matches only
+// "example.com/foo" and the paths within it. Other paths are App Engine
+// packages if they start with "appengine", or third-party packages if they
+// contain a dot anywhere.
+func NewGoimportsGrouper(localPrefixes ...string) Grouper {
+	g := goimportsGrouper{}
+	for _, list := range localPrefixes {
+		for _, prefix := range strings.Split(list, ",") {
+			if prefix != "" {
+				g = append(g, prefix)
+			}
+		}
+	}
+	return g
+}
+
+func (g goimportsGrouper) Group(pkgPath string) int {
+	for _, prefix := range g {
+		if strings.HasPrefix(pkgPath, prefix) || strings.TrimSuffix(prefix, "/") == pkgPath {
+			return goimportsLocal
+		}
+	}
+	if strings.HasPrefix(pkgPath, "appengine") {
+		return goimportsAppengine
+	}
+	if strings.Contains(pkgPath, ".") {
+		return goimportsThirdParty
+	}
+	return goimportsStd
+}
@@ -0,0 +1,35 @@
+package gogroup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoimportsGrouper(t *testing.T) {
+	t.Parallel()
+
+	g := NewGoimportsGrouper("example.com/a/,example.com/b", "local/")
+	for path, group := range map[string]int{
+		"os":                  0,
+		"net/http":            0,
+		"github.com/x/y":      1,
+		"appengine":           2,
+		"appengine/datastore": 2,
+		"example.com/a":       3,
+		"example.com/a/x":     3,
+		"example.com/ab":      1,
+		"example.com/b":       3,
+		"example.com/bc":      3,
+		"local/foo":           3,
+	} {
+		assert.Equal(t, group, g.Group(path), path)
+	}
+
+	// It's the grouping of goimports, as the tests use.
+	g = NewGoimportsGrouper("local/")
+	for _, path := range []string{"os", "github.com/x/y", "appengine/user", "local/foo", "foo/bar.v2"} {
+		assert.Equal(t, grouperGoimports{}.Group(path), g.Group(path), path)
+	}
+	assert.Equal(t, 0, NewGoimportsGrouper().Group("local/foo"))
+}
@@ -0,0 +1,115 @@
+package gogroup
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+type junitTestCase struct {
+	ClassName string          `xml:"classname,attr"`
+	Name      string          `xml:"name,attr"`
+	Skipped   *junitSkipped   `xml:"skipped,omitempty"`
+	Failures  []*junitFailure `xml:"failure"`
+	SystemOut string          `xml:"system-out,omitempty"`
+}
+
+type junitTestSuite struct {
+	XMLName   xml.Name         `xml:"testsuite"`
+	Name      string           `xml:"name,attr"`
+	Tests     int              `xml:"tests,attr"`
+	Failures  int              `xml:"failures,attr"`
+	Skipped   int              `xml:"skipped,attr,omitempty"`
+	TestCases []*junitTestCase `xml:"testcase"`
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name `xml:"testsuites"`
+	Suites  []*junitTestSuite
+}
+
+// JUnit XML output, with a test case for each file, and a failure for each
+// violation that's an error.
+type junitOutput struct {
+	w     io.Writer
+	suite junitTestSuite
+}
+
+func newJunitOutput(w io.Writer) outputFormat {
+	return &junitOutput{w: w, suite: junitTestSuite{Name: "group-imports"}}
+}
+
+func (o *junitOutput) result(res *FileResult) {
+	tc := &junitTestCase{
+		ClassName: filepath.ToSlash(filepath.Dir(res.Path)),
+		Name:      filepath.Base(res.Path),
+	}
+	if res.Skipped {
+		tc.Skipped = &junitSkipped{Message: junitSkipMessage(res.SkipReason)}
+		o.suite.Skipped++
+	}
+	out := []string{}
+	for _, v := range resultViolations(res) {
+		if v.Severity != SeverityError {
+			// Violations that aren't errors don't fail the test case.
+			out = append(out, fmt.Sprintf("%s:%d: %s%s: %s%s", res.Path, v.Line, severityPrefix(v), v.Message,
+				strconv.Quote(v.ImportPath), idSuffix(v)))
+			continue
+		}
+		msg := fmt.Sprintf("%s: %s", v.Message, strconv.Quote(v.ImportPath))
+		tc.Failures = append(tc.Failures, &junitFailure{
+			Message: msg,
+			Type:    junitType(v),
+			Text:    fmt.Sprintf("%s:%d: %s", res.Path, v.Line, msg),
+		})
+	}
+	tc.SystemOut = strings.Join(out, "\n")
+	if len(tc.Failures) > 0 {
+		o.suite.Failures++
+	}
+	o.suite.Tests++
+	o.suite.TestCases = append(o.suite.TestCases, tc)
+}
+
+func (o *junitOutput) finish() error {
+	if _, err := io.WriteString(o.w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(o.w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(&junitTestSuites{Suites: []*junitTestSuite{&o.suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(o.w, "\n")
+	return err
+}
+
+// Yield the type of the failure for a violation: its ID, if it has one.
+func junitType(v *ValidationError) string {
+	if v.ID() != "" {
+		return v.ID()
+	}
+	return "import grouping"
+}
+
+// junitSkipMessage describes why a file was skipped. Files skipped by a
+// directive keep the message earlier versions produced.
+func junitSkipMessage(reason string) string {
+	if reason == "" || reason == SkipDirective {
+		return "skipped by directive"
+	}
+	return "skipped: " + reason
+}
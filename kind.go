@@ -0,0 +1,182 @@
+package gogroup
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A Kind identifies what's wrong in a ValidationError, independent of how
+// its message is worded. Messages may change, but kinds won't.
+//
+// Each Kind is also an error, so that errors.Is can check the kind of a
+// *ValidationError returned as an error, such as:
+//
+//	if errors.Is(err, gogroup.ErrWrongGroup) { ... }
+type Kind int
+
+// The kinds of violation.
+const (
+	// KindUnknown is the zero Kind, of ValidationErrors not made by a
+	// Processor.
+	KindUnknown Kind = iota
+	// KindStatementOrder means an import is out of order within its group.
+	KindStatementOrder
+	// KindExtraBlankLine means there's an empty line within a group.
+	KindExtraBlankLine
+	// KindWrongGroup means an import is in the group of other imports.
+	KindWrongGroup
+	// KindGroupOrder means groups are out of order.
+	KindGroupOrder
+	// KindExtraGroupBlankLine means there's more than one empty line between
+	// groups.
+	KindExtraGroupBlankLine
+	// KindMissingGroupBlankLine means there's no empty line between groups.
+	KindMissingGroupBlankLine
+	// KindSameLine means several imports are on one line.
+	KindSameLine
+	// KindUnassigned means an import belongs to no group.
+	KindUnassigned
+	// KindUnparenthesized means an import isn't in a parenthesized
+	// declaration, but must be.
+	KindUnparenthesized
+	// KindSingleParenthesized means a lone import is in a parenthesized
+	// declaration, but must not be.
+	KindSingleParenthesized
+	// KindMissingHeader means a group has no header comment.
+	KindMissingHeader
+	// KindWrongHeader means a group has the header comment of another group,
+	// or a header comment not at its start.
+	KindWrongHeader
+	// KindStrippedComment means there's a comment that must be removed.
+	KindStrippedComment
+	// KindDuplicate means a path is imported more than once.
+	KindDuplicate
+	// KindWrongAlias means an import isn't under its required name.
+	KindWrongAlias
+	// KindForbiddenAlias means an import is under a forbidden name.
+	KindForbiddenAlias
+	// KindRelative means an import path is relative.
+	KindRelative
+	// KindDenied means a denied package is imported.
+	KindDenied
+	// KindLayer means an import is forbidden by a layering rule.
+	KindLayer
+)
+
+// Sentinel errors for each kind, for errors.Is.
+var (
+	ErrStatementOrder        error = KindStatementOrder
+	ErrExtraBlankLine        error = KindExtraBlankLine
+	ErrWrongGroup            error = KindWrongGroup
+	ErrGroupOrder            error = KindGroupOrder
+	ErrExtraGroupBlankLine   error = KindExtraGroupBlankLine
+	ErrMissingGroupBlankLine error = KindMissingGroupBlankLine
+	ErrSameLine              error = KindSameLine
+	ErrUnassigned            error = KindUnassigned
+	ErrUnparenthesized       error = KindUnparenthesized
+	ErrSingleParenthesized   error = KindSingleParenthesized
+	ErrMissingHeader         error = KindMissingHeader
+	ErrWrongHeader           error = KindWrongHeader
+	ErrStrippedComment       error = KindStrippedComment
+	ErrDuplicate             error = KindDuplicate
+	ErrWrongAlias            error = KindWrongAlias
+	ErrForbiddenAlias        error = KindForbiddenAlias
+	ErrRelative              error = KindRelative
+	ErrDenied                error = KindDenied
+	ErrLayer                 error = KindLayer
+)
+
+// The registry of kinds, with the name, ID, rule, message and description of
+// each. Every kind must be registered, with an ID that never changes, and a
+// short description of what the rule requires. IDs aren't reused, even for
+// kinds that are removed.
+var kindInfo = map[Kind]struct{ name, id, rule, message, description string }{
+	KindUnknown:               {"Unknown", "", "", "", ""},
+	KindStatementOrder:        {"StatementOrder", "GI001", "statement-order", errstrStatementOrder, "Imports within a group are sorted"},
+	KindExtraBlankLine:        {"ExtraBlankLine", "GI003", "statement-extra-line", errstrStatementExtraLine, "No empty lines within a group"},
+	KindWrongGroup:            {"WrongGroup", "GI004", "statement-group", errstrStatementGroup, "Each import is in the group it belongs to"},
+	KindGroupOrder:            {"GroupOrder", "GI002", "group-order", errstrGroupOrder, "Groups are in the order of the grouper"},
+	KindExtraGroupBlankLine:   {"ExtraGroupBlankLine", "GI005", "group-extra-line", errstrGroupExtraLine, "One empty line between groups, not more"},
+	KindMissingGroupBlankLine: {"MissingGroupBlankLine", "GI006", "group-missing-line", errstrGroupMissingLine, "An empty line between groups"},
+	KindSameLine:              {"SameLine", "GI007", "statement-same-line", errstrStatementSameLine, "One import per line"},
+	KindUnassigned:            {"Unassigned", "GI008", "statement-unassigned", errstrStatementUnassigned, "Every import belongs to a group, with a strict grouper"},
+	KindUnparenthesized:       {"Unparenthesized", "GI009", "block-parenthesized", errstrBlockUnparenthesized, "Imports are in a parenthesized declaration"},
+	KindSingleParenthesized:   {"SingleParenthesized", "GI010", "block-plain", errstrBlockSingle, "A lone import is in a plain declaration"},
+	KindMissingHeader:         {"MissingHeader", "GI011", "group-header-missing", errstrGroupHeaderMissing, "Each named group starts with a header comment"},
+	KindWrongHeader:           {"WrongHeader", "GI012", "group-header", errstrGroupHeaderWrong, "Header comments match their group, and start it"},
+	KindStrippedComment:       {"StrippedComment", "GI013", "comment-stripped", errstrCommentStripped, "No comments that match the strip pattern"},
+	KindDuplicate:             {"Duplicate", "GI014", "statement-duplicate", errstrStatementDuplicate, "Each path is imported once"},
+	KindWrongAlias:            {"WrongAlias", "GI015", "statement-alias", errstrStatementAlias, "Imports are under their required names"},
+	KindForbiddenAlias:        {"ForbiddenAlias", "GI016", "statement-forbidden-alias", errstrStatementBadAlias, "No imports under forbidden names"},
+	KindRelative:              {"Relative", "GI017", "statement-relative", errstrStatementRelative, "No relative import paths"},
+	KindDenied:                {"Denied", "GI018", "statement-denied", errstrStatementDenied, "No imports of denied packages"},
+	KindLayer:                 {"Layer", "GI019", "statement-layer", errstrStatementLayer, "Imports are allowed by the layering rules"},
+}
+
+// String yields the name of a kind, such as "WrongGroup".
+func (k Kind) String() string {
+	return kindInfo[k].name
+}
+
+// ID yields the stable identifier of a kind, such as "GI004", which output
+// formats include so that tools can refer to rules however they're worded.
+func (k Kind) ID() string {
+	return kindInfo[k].id
+}
+
+// Description yields a short description of what the rule a kind of
+// violation breaks requires, such as "Each import is in the group it belongs
+// to".
+func (k Kind) Description() string {
+	return kindInfo[k].description
+}
+
+// Kinds lists every kind of violation, in order of ID.
+func Kinds() []Kind {
+	kinds := []Kind{}
+	for kind := range kindInfo {
+		if kind != KindUnknown {
+			kinds = append(kinds, kind)
+		}
+	}
+	sort.Slice(kinds, func(i, j int) bool {
+		return kinds[i].ID() < kinds[j].ID()
+	})
+	return kinds
+}
+
+// Rule yields the short identifier of the rule a kind of violation breaks,
+// such as "statement-group", as in ValidationError.Rule.
+func (k Kind) Rule() string {
+	return kindInfo[k].rule
+}
+
+// ParseRule yields the kind of violation with an ID or rule name, such as
+// "GI004" or "statement-group".
+func ParseRule(name string) (Kind, error) {
+	names := []string{}
+	for _, kind := range Kinds() {
+		if name == kind.ID() || name == kind.Rule() {
+			return kind, nil
+		}
+		names = append(names, kind.ID()+" ("+kind.Rule()+")")
+	}
+	return KindUnknown, fmt.Errorf("Unknown rule '%s', expected one of: %s", name, strings.Join(names, ", "))
+}
+
+// Message yields the text describing a kind of violation, which messages
+// start with. It's for display only, and may change.
+func (k Kind) Message() string {
+	return kindInfo[k].message
+}
+
+// Error implements error, with the message of a kind.
+func (k Kind) Error() string {
+	return k.Message()
+}
+
+// MarshalText implements encoding.TextMarshaler, with the name of a kind.
+func (k Kind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
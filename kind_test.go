@@ -0,0 +1,58 @@
+package gogroup
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKinds(t *testing.T) {
+	t.Parallel()
+
+	// Every kind has a distinct name, ID, rule and message, and a description.
+	names, ids, rules, messages := map[string]bool{}, map[string]bool{}, map[string]bool{}, map[string]bool{}
+	for kind := KindStatementOrder; kind <= KindLayer; kind++ {
+		assert.NotEqual(t, "", kind.String(), int(kind))
+		assert.Regexp(t, `^GI\d{3}$`, kind.ID(), kind.String())
+		assert.NotEqual(t, "", kind.Rule(), kind.String())
+		assert.NotEqual(t, "", kind.Message(), kind.String())
+		assert.NotEqual(t, "", kind.Description(), kind.String())
+		assert.False(t, names[kind.String()] || ids[kind.ID()] || rules[kind.Rule()] || messages[kind.Message()],
+			kind.String())
+		names[kind.String()], ids[kind.ID()], rules[kind.Rule()], messages[kind.Message()] = true, true, true, true
+	}
+	assert.Equal(t, "", KindUnknown.ID())
+
+	// Kinds lists them all by ID, and the first few IDs never change.
+	kinds := Kinds()
+	assert.Equal(t, int(KindLayer), len(kinds))
+	assert.Equal(t, []Kind{KindStatementOrder, KindGroupOrder, KindExtraBlankLine, KindWrongGroup}, kinds[:4])
+	assert.Equal(t, "GI004", (&ValidationError{Kind: KindWrongGroup}).ID())
+
+	assert.Equal(t, "WrongGroup", KindWrongGroup.String())
+	assert.Equal(t, "statement-group", KindWrongGroup.Rule())
+	text, err := KindWrongGroup.MarshalText()
+	assert.Nil(t, err)
+	assert.Equal(t, "WrongGroup", string(text))
+}
+
+func TestKindErrors(t *testing.T) {
+	t.Parallel()
+
+	proc := NewProcessor(grouperNamed{})
+	validErr, err := proc.Validate("", strings.NewReader(
+		"package main\n\nimport (\n\t\"github.com/Sirupsen/logrus\"\n\t\"os\"\n)\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, KindWrongGroup, validErr.Kind)
+	assert.Equal(t, validErr.Kind.Rule(), validErr.Rule)
+
+	// The kind is found through wrapping, whatever the message says.
+	wrapped := fmt.Errorf("checking main.go: %w", validErr)
+	assert.True(t, errors.Is(wrapped, ErrWrongGroup))
+	assert.False(t, errors.Is(wrapped, ErrGroupOrder))
+	var target *ValidationError
+	assert.True(t, errors.As(wrapped, &target))
+}
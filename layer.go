@@ -0,0 +1,139 @@
+package gogroup
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// A LayerRule restricts what files in certain directories may import, to
+// enforce layering, such as storage packages not importing API packages.
+type LayerRule struct {
+	// Dir is a pattern matching the directories of the files the rule applies
+	// to, in the syntax of path.Match, such as "cmd/*". A pattern ending in
+	// "/..." also matches every directory below, and "..." alone matches all
+	// directories. Relative patterns are matched against file names as
+	// given, and absolute patterns against absolute file names.
+	Dir string
+
+	// Allow and Deny are prefixes of import paths that may and may not be
+	// imported. The entry "std" stands for the standard library. An import
+	// matching both is decided by the longest prefix it matches, and denied
+	// if they're the same length. If there's only an Allow list, imports
+	// matching none of its prefixes are denied too.
+	Allow []string
+	Deny  []string
+
+	// Name optionally identifies the rule in messages, instead of Dir.
+	Name string
+}
+
+// Determine whether a directory pattern matches a slash-separated directory.
+func matchDir(pattern, dir string) bool {
+	if pattern == "..." {
+		return true
+	}
+	if base := strings.TrimSuffix(pattern, "/..."); base != pattern {
+		// Match only as many leading elements as the pattern has.
+		n := strings.Count(base, "/") + 1
+		elems := strings.Split(dir, "/")
+		if len(elems) < n {
+			return false
+		}
+		dir, pattern = strings.Join(elems[:n], "/"), base
+	}
+	ok, _ := path.Match(pattern, dir)
+	return ok
+}
+
+// Determine whether a rule applies to a file.
+func (r *LayerRule) appliesTo(fileName string) bool {
+	dir := filepath.Dir(fileName)
+	if path.IsAbs(filepath.ToSlash(r.Dir)) {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return false
+		}
+		dir = abs
+	}
+	return matchDir(filepath.ToSlash(r.Dir), filepath.ToSlash(filepath.Clean(dir)))
+}
+
+// Yield the length of the longest of the prefixes that an import path
+// matches, or -1 if it matches none. The standard library counts as the
+// shortest of prefixes.
+func longestPrefix(prefixes []string, importPath string) int {
+	longest := -1
+	for _, prefix := range prefixes {
+		n := -1
+		if prefix == "std" {
+			if !strings.Contains(strings.SplitN(importPath, "/", 2)[0], ".") {
+				n = 0
+			}
+		} else if strings.HasPrefix(importPath, prefix) {
+			n = len(prefix)
+		}
+		if n > longest {
+			longest = n
+		}
+	}
+	return longest
+}
+
+// Determine whether a rule forbids an import path. The longest matching
+// prefix decides, and deny wins a tie.
+func (r *LayerRule) forbids(importPath string) bool {
+	allowed, denied := longestPrefix(r.Allow, importPath), longestPrefix(r.Deny, importPath)
+	if denied >= 0 && denied >= allowed {
+		return true
+	}
+	return allowed < 0 && len(r.Allow) > 0 && len(r.Deny) == 0
+}
+
+// Yield the name of a rule for messages.
+func (r *LayerRule) name() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return r.Dir
+}
+
+// Layers restricts what files may import according to the directory they're
+// in. Only the first rule that applies to a file is used, so rules for nested
+// directories should come before those for the directories containing them.
+// Each import the rule forbids is a violation, which repairs never fix.
+func Layers(rules []LayerRule) Option {
+	return func(p *Processor) {
+		p.layerRules = rules
+	}
+}
+
+// Describe which layering rule applies to a file, along with any CacheKey,
+// so that cache entries for files under different rules are kept apart.
+// Without layering rules or a key, every file is in the same scope.
+func (p *Processor) cacheScope(fileName string) string {
+	scope := ""
+	if p.cacheKey != "" {
+		scope = fmt.Sprintf("key=%q ", p.cacheKey)
+	}
+	if len(p.layerRules) == 0 {
+		return strings.TrimSuffix(scope, " ")
+	}
+	for i := range p.layerRules {
+		if p.layerRules[i].appliesTo(fileName) {
+			return scope + fmt.Sprintf("layer=%d", i)
+		}
+	}
+	return scope + "layer=none"
+}
+
+// Find the first layering rule that applies to a file, if any.
+func (p *Processor) findLayer(fileName string) *LayerRule {
+	for i := range p.layerRules {
+		if p.layerRules[i].appliesTo(fileName) {
+			return &p.layerRules[i]
+		}
+	}
+	return nil
+}
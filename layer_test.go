@@ -0,0 +1,102 @@
+package gogroup
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchDir(t *testing.T) {
+	t.Parallel()
+
+	for _, c := range []struct {
+		pattern, dir string
+		match        bool
+	}{
+		{"...", ".", true},
+		{"...", "a/b", true},
+		{"internal/storage", "internal/storage", true},
+		{"internal/storage", "internal/storage/sql", false},
+		{"internal/storage/...", "internal/storage", true},
+		{"internal/storage/...", "internal/storage/sql", true},
+		{"internal/storage/...", "internal/storagex", false},
+		{"internal/storage/...", "internal", false},
+		{"cmd/*", "cmd/server", true},
+		{"cmd/*", "cmd/server/sub", false},
+		{"cmd/*/...", "cmd/server/sub", true},
+		{"/repo/internal/...", "/repo/internal/api", true},
+		{"/repo/internal/...", "/other/internal/api", false},
+	} {
+		assert.Equal(t, c.match, matchDir(c.pattern, c.dir), "%s %s", c.pattern, c.dir)
+	}
+}
+
+func TestLayers(t *testing.T) {
+	t.Parallel()
+
+	text := `package storage
+
+import (
+	"fmt"
+
+	"corp.dev/app/internal/api"
+	"corp.dev/app/internal/api/types"
+	"corp.dev/app/internal/storage/sql"
+	"github.com/pkg/errors"
+)
+`
+	proc := NewProcessor(grouperGoimports{}, Layers([]LayerRule{
+		// Nested directories come first.
+		{Dir: "internal/storage/sql/...", Allow: []string{"std"}, Name: "sql is a leaf"},
+		{Dir: "internal/storage/...", Deny: []string{"corp.dev/app/internal/api", "github.com/"},
+			Allow: []string{"corp.dev/app/internal/api/types"}},
+		{Dir: "cmd/*"},
+		{Dir: "...", Deny: []string{"corp.dev/app/cmd/"}},
+	}))
+	check := func(fileName string) []string {
+		errs, err := proc.ValidateAll(fileName, strings.NewReader(text))
+		assert.Nil(t, err)
+		messages := []string{}
+		for _, validErr := range errs {
+			assert.Equal(t, "statement-layer", validErr.Rule)
+			messages = append(messages, validErr.ImportPath+": "+validErr.Message)
+		}
+		return messages
+	}
+
+	// A longer allowed prefix overrides a denied one.
+	assert.Equal(t, []string{
+		"corp.dev/app/internal/api: Import not allowed by a layering rule (internal/storage/...)",
+		"github.com/pkg/errors: Import not allowed by a layering rule (internal/storage/...)",
+	}, check(filepath.Join("internal", "storage", "store.go")))
+
+	// With an allow list, everything else is denied.
+	assert.Equal(t, []string{
+		"corp.dev/app/internal/api: Import not allowed by a layering rule (sql is a leaf)",
+		"corp.dev/app/internal/api/types: Import not allowed by a layering rule (sql is a leaf)",
+		"corp.dev/app/internal/storage/sql: Import not allowed by a layering rule (sql is a leaf)",
+		"github.com/pkg/errors: Import not allowed by a layering rule (sql is a leaf)",
+	}, check("internal/storage/sql/mysql/conn.go"))
+
+	// Only the first rule that applies is used.
+	assert.Equal(t, []string{}, check("cmd/server/main.go"))
+	assert.Equal(t, []string{}, check("internal/api/handler.go"))
+	assert.Equal(t, []string{}, check(""))
+
+	// Deny wins a tie.
+	proc = NewProcessor(grouperGoimports{}, Layers([]LayerRule{
+		{Dir: "...", Allow: []string{"github.com/"}, Deny: []string{"github.com/"}},
+	}))
+	errs, err := proc.ValidateAll("main.go", strings.NewReader(text))
+	assert.Nil(t, err)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "github.com/pkg/errors", errs[0].ImportPath)
+	}
+
+	// Repairs leave the imports alone.
+	r, err := proc.Repair("main.go", strings.NewReader(text))
+	assert.Nil(t, err)
+	assert.Equal(t, text, readAll(t, r))
+}
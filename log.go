@@ -0,0 +1,35 @@
+package gogroup
+
+import (
+	"context"
+	"log/slog"
+)
+
+// WithLogger logs the decisions the Processor makes to l, at debug level, to
+// diagnose surprising results: the files it skips and why, the group of each
+// import and the part of the order that matched it, whether goimports changed
+// a file, and whether a rewrite was skipped because nothing changed. Nothing
+// is logged by default, and then logging costs nothing.
+func WithLogger(l *slog.Logger) Option {
+	return func(p *Processor) {
+		p.logger = l
+	}
+}
+
+// Determine whether a logger records debug messages, so that callers only
+// assemble them if it does.
+func debugging(l *slog.Logger) bool {
+	return l != nil && l.Enabled(context.Background(), slog.LevelDebug)
+}
+
+// Describe the part of the order that assigned an import path its group, if
+// the grouper can say.
+func explainGroup(g Grouper, pkgPath string) string {
+	switch g := g.(type) {
+	case *SpecGrouper:
+		return g.ExplainGroup(pkgPath).Spec
+	case Explainer:
+		return g.Explain(pkgPath)
+	}
+	return ""
+}
@@ -0,0 +1,91 @@
+package gogroup
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Create a logger writing debug records to a buffer, without times.
+func newTestLogger(buf *bytes.Buffer) *slog.Logger {
+	return slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+}
+
+func TestWithLogger(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	valid := filepath.Join(dir, "a.go")
+	assert.Nil(t, ioutil.WriteFile(valid, []byte(
+		"package a\n\nimport (\n\t\"os\"\n\n\t\"example.com/x\"\n\n\t\"github.com/y/z\"\n)\n\n"+
+			"var _, _, _ = os.Args, x.X, z.Z\n"), 0644))
+	ignored := filepath.Join(dir, "b.go")
+	assert.Nil(t, ioutil.WriteFile(ignored, []byte(
+		"package a\n\n//group-imports:ignore\n\nimport \"os\"\n"), 0644))
+	assert.Nil(t, os.Mkdir(filepath.Join(dir, "testdata"), 0755))
+
+	var buf bytes.Buffer
+	logger := newTestLogger(&buf)
+	found, err := FindFiles([]string{dir}, FindOptions{Logger: logger})
+	assert.Nil(t, err)
+	g, err := ParseSpec("std,prefix=example.com/,other")
+	assert.Nil(t, err)
+	p := NewProcessor(g, WithLogger(logger))
+	_, err = ProcessFiles(context.Background(), found.Files, p,
+		RunOptions{Rewrite: true, Goimports: true, Concurrency: 1})
+	assert.Nil(t, err)
+
+	// Imports are grouped when checking, and again when repairing after
+	// goimports.
+	grouped := []string{
+		`level=DEBUG msg="group assigned" file=` + valid + ` import=os group=0 spec=std`,
+		`level=DEBUG msg="group assigned" file=` + valid + ` import=example.com/x group=1 spec="prefix=example.com/"`,
+		`level=DEBUG msg="group assigned" file=` + valid + ` import=github.com/y/z group=2 spec=other`,
+	}
+	expected := []string{
+		`level=DEBUG msg="path skipped" path=` + filepath.Join(dir, "testdata") + ` reason="directory name"`}
+	expected = append(expected, grouped...)
+	expected = append(expected, `level=DEBUG msg=goimports file=`+valid+` changed=false`)
+	expected = append(expected, grouped...)
+	expected = append(expected,
+		`level=DEBUG msg="write skipped" file=`+valid+` reason=identical`,
+		`level=DEBUG msg="file skipped" file=`+ignored+` reason=directive`)
+	assert.Equal(t, expected, strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n"))
+}
+
+func TestDebugging(t *testing.T) {
+	var buf bytes.Buffer
+	assert.False(t, debugging(nil))
+	assert.False(t, debugging(slog.New(slog.NewTextHandler(&buf, nil))))
+	assert.True(t, debugging(newTestLogger(&buf)))
+
+	// Nothing is assembled for a logger that doesn't record debug messages,
+	// so it costs no more than none.
+	p := NewProcessor(NewGoimportsGrouper())
+	src := "package a\n\nimport (\n\t\"os\"\n\n\t\"github.com/y/z\"\n)\n"
+	allocs := testing.AllocsPerRun(10, func() {
+		p.ValidateAll("a.go", strings.NewReader(src))
+	})
+	p = NewProcessor(NewGoimportsGrouper(), WithLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+	assert.Equal(t, allocs, testing.AllocsPerRun(10, func() {
+		p.ValidateAll("a.go", strings.NewReader(src))
+	}))
+	assert.Empty(t, buf.String())
+}
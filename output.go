@@ -0,0 +1,328 @@
+package gogroup
+
+import (
+	"errors"
+	"fmt"
+	"go/scanner"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// An outputFormat reports processing results.
+type outputFormat interface {
+	// Report the result of processing a file.
+	result(res *FileResult)
+
+	// Finish the output, once all files are processed.
+	finish() error
+}
+
+// An errorOutputFormat is an outputFormat that also reports files that
+// couldn't be processed.
+type errorOutputFormat interface {
+	outputFormat
+
+	// Report a file whose result has an error.
+	fileError(res *FileResult)
+}
+
+// A Formatter writes the results of processing files in an output format.
+// Begin is called first, with where to write, then WriteResult with the result
+// of each file in order, including files that couldn't be processed, and
+// finally End, which yields any error writing the output. Each Formatter is
+// used for a single report.
+type Formatter interface {
+	Begin(w io.Writer)
+	WriteResult(res *FileResult)
+	End() error
+}
+
+// A Formatter for one of the built-in output formats.
+type builtinFormatter struct {
+	ctor func(w io.Writer) outputFormat
+	out  outputFormat
+}
+
+// Make the constructor of a Formatter from that of a built-in output format.
+func builtinFormat(ctor func(w io.Writer) outputFormat) func() Formatter {
+	return func() Formatter {
+		return &builtinFormatter{ctor: ctor}
+	}
+}
+
+func (f *builtinFormatter) Begin(w io.Writer) {
+	f.out = f.ctor(w)
+}
+
+func (f *builtinFormatter) WriteResult(res *FileResult) {
+	if res.Err == nil {
+		f.out.result(res)
+	} else if eout, ok := f.out.(errorOutputFormat); ok {
+		eout.fileError(res)
+	}
+}
+
+func (f *builtinFormatter) End() error {
+	return f.out.finish()
+}
+
+// The registry of formats, with the constructor of each by name.
+var (
+	formatsMu sync.Mutex
+	formats   = map[string]func() Formatter{
+		"text":        builtinFormat(newTextOutput),
+		"github":      builtinFormat(newGithubOutput),
+		"rdjson":      builtinFormat(newRdjsonOutput),
+		"rdjsonl":     builtinFormat(newRdjsonlOutput),
+		"junit":       builtinFormat(newJunitOutput),
+		"editor":      builtinFormat(newEditorOutput),
+		"tap":         builtinFormat(newTapOutput),
+		"json":        builtinFormat(newJSONOutput),
+		"teamcity":    builtinFormat(newTeamcityOutput),
+		"codeclimate": builtinFormat(newCodeclimateOutput),
+		"offsets":     builtinFormat(newOffsetsOutput),
+	}
+)
+
+// RegisterFormat adds an output format, so that Report.Write, and the -format
+// flag of the command, can use it by name. Each report in the format is
+// written by a new Formatter from newFormatter.
+//
+// It panics if the name is empty or already registered, so formats, including
+// the built-in ones, can't be replaced.
+func RegisterFormat(name string, newFormatter func() Formatter) {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	if name == "" || newFormatter == nil {
+		panic("gogroup: RegisterFormat needs a name and a constructor")
+	}
+	if _, ok := formats[name]; ok {
+		panic("gogroup: RegisterFormat called twice for format " + name)
+	}
+	formats[name] = newFormatter
+}
+
+// FormatNames lists the names of all formats supported by Report.Write,
+// including those added by RegisterFormat.
+func FormatNames() []string {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	names := []string{}
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewFormatter creates a Formatter for the format with the given name.
+func NewFormatter(name string) (Formatter, error) {
+	formatsMu.Lock()
+	ctor, ok := formats[name]
+	formatsMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("Unknown format '%s', expected one of: %s", name,
+			strings.Join(FormatNames(), ", "))
+	}
+	return ctor(), nil
+}
+
+// The standard human-readable format.
+type textOutput struct {
+	w io.Writer
+}
+
+func newTextOutput(w io.Writer) outputFormat {
+	return &textOutput{w}
+}
+
+func (o *textOutput) result(res *FileResult) {
+	for _, v := range resultViolations(res) {
+		fmt.Fprintf(o.w, "%s:%d: %s%s at %s%s\n", res.Path, v.Line, severityPrefix(v), v.Message,
+			strconv.Quote(v.ImportPath), idSuffix(v))
+	}
+}
+
+// Yield the violations of a file. Results with only a first violation are
+// taken as having just that.
+func resultViolations(res *FileResult) []*ValidationError {
+	if len(res.Violations) == 0 && res.Violation != nil {
+		return []*ValidationError{res.Violation}
+	}
+	return res.Violations
+}
+
+// Yield the one-based column of a violation, or 1 if its position is unknown.
+func violationColumn(v *ValidationError) int {
+	if v.Pos.Column > 0 {
+		return v.Pos.Column
+	}
+	return 1
+}
+
+// Yield the first violation of a file that's an error, or nil if there's
+// none.
+func firstError(res *FileResult) *ValidationError {
+	for _, v := range resultViolations(res) {
+		if v.Severity == SeverityError {
+			return v
+		}
+	}
+	return nil
+}
+
+// Yield the severity of a violation followed by a colon and a space, such as
+// "warning: ", or nothing if it's an error, as most are.
+func severityPrefix(v *ValidationError) string {
+	if v.Severity == SeverityError {
+		return ""
+	}
+	return v.Severity.String() + ": "
+}
+
+// Yield the ID of a violation in brackets, after a space, or nothing if it
+// has none.
+func idSuffix(v *ValidationError) string {
+	if v.ID() == "" {
+		return ""
+	}
+	return " [" + v.ID() + "]"
+}
+
+func (o *textOutput) finish() error {
+	return nil
+}
+
+// GitHub Actions workflow commands, which show up as annotations.
+type githubOutput struct {
+	w io.Writer
+}
+
+func newGithubOutput(w io.Writer) outputFormat {
+	return &githubOutput{w}
+}
+
+// Escape the data of a workflow command.
+func githubEscapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// Escape a property value of a workflow command.
+func githubEscapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A",
+		":", "%3A", ",", "%2C").Replace(s)
+}
+
+// The workflow command for each severity of violation.
+var githubCommands = map[Severity]string{
+	SeverityError:   "error",
+	SeverityWarning: "warning",
+	SeverityInfo:    "notice",
+}
+
+func (o *githubOutput) result(res *FileResult) {
+	for _, v := range resultViolations(res) {
+		msg := fmt.Sprintf("%s: %s", v.Message, strconv.Quote(v.ImportPath))
+		fmt.Fprintf(o.w, "::%s file=%s,line=%d,title=%s::%s\n", githubCommands[v.Severity],
+			githubEscapeProperty(res.Path), v.Line, githubEscapeProperty("import grouping"+idSuffix(v)),
+			githubEscapeData(msg))
+	}
+}
+
+func (o *githubOutput) finish() error {
+	return nil
+}
+
+// A format for editors such as Emacs and Vim, with a line for every violation
+// in the form "path:line:column: message", and the same for parse errors.
+type editorOutput struct {
+	w io.Writer
+}
+
+func newEditorOutput(w io.Writer) outputFormat {
+	return &editorOutput{w}
+}
+
+func (o *editorOutput) result(res *FileResult) {
+	for _, v := range resultViolations(res) {
+		// Exactly this form, which editors parse, without severities or IDs.
+		fmt.Fprintf(o.w, "%s:%d:%d: %s (import %s)\n", res.Path, v.Line, violationColumn(v), v.Message,
+			strconv.Quote(v.ImportPath))
+	}
+}
+
+func (o *editorOutput) fileError(res *FileResult) {
+	var perr *ParseError
+	if !errors.As(res.Err, &perr) {
+		return
+	}
+	line, col, msg := perr.Line, perr.Column, perr.Err.Error()
+	if serr, ok := perr.Err.(*scanner.Error); ok {
+		// Leave out the position, which is already given.
+		msg = serr.Msg
+	}
+	if line <= 0 {
+		line = 1
+	}
+	if col <= 0 {
+		col = 1
+	}
+	fmt.Fprintf(o.w, "%s:%d:%d: %s\n", res.Path, line, col, msg)
+}
+
+func (o *editorOutput) finish() error {
+	return nil
+}
+
+// Violations by byte offset, as 'path:#start-end: message (import "path")
+// [ID]', for tools that address positions by offset, as gorename and guru do
+// with -offset. The offsets are those of Pos and End, so they delimit the
+// import, empty lines or comment at fault. Where the end is unknown, the range
+// is empty.
+type offsetsOutput struct {
+	w io.Writer
+}
+
+func newOffsetsOutput(w io.Writer) outputFormat {
+	return &offsetsOutput{w}
+}
+
+func (o *offsetsOutput) result(res *FileResult) {
+	for _, v := range resultViolations(res) {
+		start, end := v.Pos.Offset, v.End.Offset
+		if !v.End.IsValid() {
+			end = start
+		}
+		fmt.Fprintf(o.w, "%s:#%d-%d: %s%s (import %s)%s\n", res.Path, start, end, severityPrefix(v), v.Message,
+			strconv.Quote(v.ImportPath), idSuffix(v))
+	}
+}
+
+func (o *offsetsOutput) finish() error {
+	return nil
+}
+
+// Output as a single JSON document, written by Report.WriteJSON.
+type jsonOutput struct {
+	w      io.Writer
+	report Report
+}
+
+func newJSONOutput(w io.Writer) outputFormat {
+	return &jsonOutput{w: w}
+}
+
+func (o *jsonOutput) result(res *FileResult) {
+	o.report.Files = append(o.report.Files, res)
+}
+
+func (o *jsonOutput) fileError(res *FileResult) {
+	o.report.Files = append(o.report.Files, res)
+}
+
+func (o *jsonOutput) finish() error {
+	return o.report.WriteJSON(o.w)
+}
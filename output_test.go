@@ -0,0 +1,494 @@
+package gogroup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGithubOutput(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "100%25%0D%0Adone", githubEscapeData("100%\r\ndone"))
+	assert.Equal(t, "a%3Ab%2Cc%0A", githubEscapeProperty("a:b,c\n"))
+
+	var buf bytes.Buffer
+	out := newGithubOutput(&buf)
+	out.result(&FileResult{Path: "pkg/ok.go"})
+	out.result(&FileResult{Path: "pkg/foo.go", Violation: &ValidationError{
+		Line:       12,
+		ImportPath: "github.com/x/y",
+		Message:    "Import in incorrect group",
+	}})
+	assert.Nil(t, out.finish())
+	assert.Equal(t, "::error file=pkg/foo.go,line=12,title=import grouping::"+
+		"Import in incorrect group: \"github.com/x/y\"\n", buf.String())
+
+	// Every violation is annotated, not just the first.
+	buf.Reset()
+	violations := []*ValidationError{
+		{Line: 3, ImportPath: "os", Message: "Import in incorrect group", Kind: KindWrongGroup},
+		{Line: 5, ImportPath: "fmt", Message: "Imports are not sorted", Severity: SeverityWarning},
+	}
+	out.result(&FileResult{Path: "a.go", Violation: violations[0], Violations: violations})
+	assert.Equal(t, "::error file=a.go,line=3,title=import grouping [GI004]::"+
+		"Import in incorrect group: \"os\"\n"+
+		"::warning file=a.go,line=5,title=import grouping::Imports are not sorted: \"fmt\"\n", buf.String())
+}
+
+func TestEditorOutput(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	out := newEditorOutput(&buf).(errorOutputFormat)
+	out.result(&FileResult{Path: "pkg/ok.go"})
+	out.result(&FileResult{Path: "pkg/foo.go", Violations: []*ValidationError{{
+		Line:       12,
+		ImportPath: "github.com/x/y",
+		Message:    "Import in incorrect group",
+	}, {
+		Line:       14,
+		ImportPath: `weird"path`,
+		Message:    "Imports are not sorted",
+	}}})
+	out.fileError(&FileResult{Path: "pkg/bad.go", Err: &ParseError{
+		FileName: "pkg/bad.go",
+		Line:     3,
+		Column:   8,
+		Err: &scanner.Error{
+			Pos: token.Position{Filename: "pkg/bad.go", Line: 3, Column: 8},
+			Msg: "expected ';'",
+		},
+	}})
+	out.fileError(&FileResult{Path: "pkg/none.go", Err: &ParseError{
+		FileName: "pkg/none.go",
+		Err:      ErrNoPackageClause,
+	}})
+	out.fileError(&FileResult{Path: "pkg/gone.go", Err: errors.New("No such file")})
+	assert.Nil(t, out.finish())
+	assert.Equal(t, `pkg/foo.go:12:1: Import in incorrect group (import "github.com/x/y")
+pkg/foo.go:14:1: Imports are not sorted (import "weird\"path")
+pkg/bad.go:3:8: expected ';'
+pkg/none.go:1:1: `+ErrNoPackageClause.Error()+"\n", buf.String())
+}
+
+func TestTapOutput(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	out := newTapOutput(&buf).(errorOutputFormat)
+	assert.Nil(t, out.finish())
+	assert.Equal(t, "TAP version 13\n1..0\n", buf.String())
+
+	buf.Reset()
+	out = newTapOutput(&buf).(errorOutputFormat)
+	out.result(&FileResult{Path: "pkg/ok.go"})
+	out.result(&FileResult{Path: "pkg/skip.go", Skipped: true, SkipReason: SkipDirective})
+	out.result(&FileResult{Path: "pkg/#bad.go", Violations: []*ValidationError{{
+		Line:       4,
+		ImportPath: "github.com/x/y",
+		Message:    "Import in incorrect group",
+		Rule:       "group",
+	}, {
+		Line:       6,
+		ImportPath: `weird"path`,
+		Message:    "Imports are not sorted",
+	}}})
+	out.fileError(&FileResult{Path: "pkg/gone.go", Err: errors.New("No such file")})
+	assert.Nil(t, out.finish())
+	assert.Equal(t, `TAP version 13
+1..4
+ok 1 - pkg/ok.go
+ok 2 - pkg/skip.go # SKIP directive
+not ok 3 - pkg/\#bad.go
+  ---
+  violations:
+    - line: 4
+      message: "Import in incorrect group"
+      import: "github.com/x/y"
+      rule: group
+    - line: 6
+      message: "Imports are not sorted"
+      import: "weird\"path"
+  ...
+not ok 4 - pkg/gone.go
+  ---
+  error: "No such file"
+  ...
+`, buf.String())
+}
+
+func TestOutputFormat(t *testing.T) {
+	t.Parallel()
+
+	f, err := NewFormatter("text")
+	assert.Nil(t, err)
+	f.Begin(nil)
+	assert.IsType(t, &textOutput{}, f.(*builtinFormatter).out)
+
+	_, err = NewFormatter("bogus")
+	assert.NotNil(t, err)
+}
+
+func TestRdjsonSuggestion(t *testing.T) {
+	t.Parallel()
+
+	src := "package a\n\nimport (\n\t\"os\"\n\t\"fmt\"\n)\n"
+	proc := NewProcessor(grouperGoimports{})
+	validErr, err := proc.Validate("a.go", strings.NewReader(src))
+	assert.Nil(t, err)
+	block, err := proc.RepairBlock("a.go", strings.NewReader(src))
+	assert.Nil(t, err)
+
+	var buf bytes.Buffer
+	out := newRdjsonlOutput(&buf)
+	out.result(&FileResult{Path: "a.go", Src: []byte(src), Violation: validErr, Fix: block})
+	assert.Nil(t, out.finish())
+
+	var diag rdjsonDiagnostic
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &diag))
+	assert.Equal(t, "a.go", diag.Location.Path)
+	assert.Equal(t, &rdjsonCode{Value: "GI001"}, diag.Code)
+	assert.Equal(t, []rdjsonSuggestion{{
+		Range: rdjsonRange{
+			Start: rdjsonPosition{Line: 4, Column: 1},
+			End:   &rdjsonPosition{Line: 5, Column: 7},
+		},
+		Text: "\t\"fmt\"\n\t\"os\"",
+	}}, diag.Suggestions)
+
+	// Each violation is a diagnostic over what's wrong, and only the first
+	// suggests the fix.
+	src = "package a\n\nimport (\n\t\"os\"\n\t\"fmt\"\n\t\"github.com/x/y\"\n)\n"
+	errs, err := proc.ValidateAll("a.go", strings.NewReader(src))
+	assert.Nil(t, err)
+	block, err = proc.RepairBlock("a.go", strings.NewReader(src))
+	assert.Nil(t, err)
+	buf.Reset()
+	out = newRdjsonlOutput(&buf)
+	out.result(&FileResult{Path: "a.go", Src: []byte(src), Violation: errs[0], Violations: errs, Fix: block})
+	assert.Nil(t, out.finish())
+	dec := json.NewDecoder(&buf)
+	diags := []rdjsonDiagnostic{}
+	for dec.More() {
+		var diag rdjsonDiagnostic
+		assert.Nil(t, dec.Decode(&diag))
+		diags = append(diags, diag)
+	}
+	if assert.Len(t, diags, len(errs)) && assert.Len(t, errs, 2) {
+		assert.Equal(t, rdjsonRange{
+			Start: rdjsonPosition{Line: errs[0].Pos.Line, Column: errs[0].Pos.Column},
+			End:   &rdjsonPosition{Line: errs[0].End.Line, Column: errs[0].End.Column},
+		}, diags[0].Location.Range)
+		assert.Equal(t, &rdjsonCode{Value: errs[1].ID()}, diags[1].Code)
+		assert.NotEmpty(t, diags[0].Suggestions)
+		assert.Empty(t, diags[1].Suggestions)
+	}
+}
+
+func TestTeamcityOutput(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	out := newTeamcityOutput(&buf).(errorOutputFormat)
+	assert.Nil(t, out.finish())
+	assert.Equal(t, "##teamcity[buildStatisticValue key='gogroupViolations' value='0']\n", buf.String())
+
+	buf.Reset()
+	out = newTeamcityOutput(&buf).(errorOutputFormat)
+	out.result(&FileResult{Path: "pkg/ok.go"})
+	out.result(&FileResult{Path: "pkg/it's [odd].go", Violations: []*ValidationError{{
+		Kind:       KindWrongGroup,
+		Line:       4,
+		ImportPath: "a|b",
+		Message:    "Import in incorrect group\nreally",
+		Rule:       "statement-group",
+	}, {
+		Kind:       KindWrongGroup,
+		Line:       6,
+		ImportPath: "os",
+		Message:    "Import in incorrect group",
+		Rule:       "statement-group",
+		Severity:   SeverityInfo,
+	}, {
+		Line:       7,
+		ImportPath: "unsafe",
+		Message:    "Unsafe",
+		Rule:       "acme-unsafe",
+	}}})
+	out.fileError(&FileResult{Path: "pkg/gone.go", Err: errors.New("No such file\u2028or directory")})
+	assert.Nil(t, out.finish())
+	assert.Equal(t, `##teamcity[inspectionType id='GI004' name='statement-group' description='Each import is in the group it belongs to' category='Import grouping']
+##teamcity[inspection typeId='GI004' message='Import in incorrect group|nreally at "a||b"' file='pkg/it|'s |[odd|].go' line='4' SEVERITY='ERROR']
+##teamcity[inspection typeId='GI004' message='Import in incorrect group at "os"' file='pkg/it|'s |[odd|].go' line='6' SEVERITY='INFO']
+##teamcity[inspectionType id='acme-unsafe' name='acme-unsafe' description='Unsafe' category='Import grouping']
+##teamcity[inspection typeId='acme-unsafe' message='Unsafe at "unsafe"' file='pkg/it|'s |[odd|].go' line='7' SEVERITY='ERROR']
+##teamcity[buildProblem description='No such file|lor directory']
+##teamcity[buildStatisticValue key='gogroupViolations' value='3']
+`, buf.String())
+}
+
+func TestOffsetsOutput(t *testing.T) {
+	t.Parallel()
+
+	proc := NewProcessor(grouperGoimports{})
+	offsets := func(src string) string {
+		errs, err := proc.ValidateAll("a.go", strings.NewReader(src))
+		assert.Nil(t, err)
+		var buf bytes.Buffer
+		report := &Report{Files: []*FileResult{{Path: "a.go", Violation: errs[0], Violations: errs}}}
+		assert.Nil(t, report.Write(&buf, "offsets"))
+		return buf.String()
+	}
+
+	// Offsets delimit the import, without its comment.
+	src := "package a\n\nimport (\n\t\"os\"\n\t\"bytes\" // b\n)\n"
+	assert.Equal(t, "a.go:#27-34: Import out of order within import group (import \"bytes\") [GI001]\n", offsets(src))
+	assert.Equal(t, `"bytes"`, src[27:34])
+
+	// They delimit empty lines too, as the edit removing them does.
+	src = "package a\n\nimport (\n\t\"fmt\"\n\n\n\t\"os\"\n)\n"
+	assert.Equal(t, "a.go:#27-29: Extra empty line inside import group (import \"os\") [GI003]\n", offsets(src))
+	edits, err := proc.Edits("a.go", []byte(src))
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(edits)) {
+		assert.Equal(t, 27, edits[0].Start.Offset)
+		assert.Equal(t, 29, edits[0].End.Offset)
+	}
+
+	// Unknown ends make empty ranges.
+	var buf bytes.Buffer
+	out := newOffsetsOutput(&buf)
+	out.result(&FileResult{Path: "a.go", Violations: []*ValidationError{{
+		Line:       4,
+		Pos:        token.Position{Offset: 20, Line: 4, Column: 2},
+		ImportPath: "unsafe",
+		Message:    "Unsafe",
+	}}})
+	assert.Nil(t, out.finish())
+	assert.Equal(t, "a.go:#20-20: Unsafe (import \"unsafe\")\n", buf.String())
+}
+
+func TestJunitOutput(t *testing.T) {
+	t.Parallel()
+
+	assert.Contains(t, FormatNames(), "junit")
+
+	var buf bytes.Buffer
+	out := newJunitOutput(&buf)
+	assert.Nil(t, out.finish())
+	assert.Equal(t, xml.Header+`<testsuites>
+  <testsuite name="group-imports" tests="0" failures="0"></testsuite>
+</testsuites>
+`, buf.String())
+
+	buf.Reset()
+	out = newJunitOutput(&buf)
+	out.result(&FileResult{Path: "pkg/ok.go"})
+	out.result(&FileResult{Path: "pkg/skip.go", Skipped: true})
+	out.result(&FileResult{Path: "pkg/sub/bad.go", Violation: &ValidationError{
+		Line:       4,
+		ImportPath: "<weird>&",
+		Message:    "Import in incorrect group",
+	}})
+	assert.Nil(t, out.finish())
+	assert.Equal(t, xml.Header+`<testsuites>
+  <testsuite name="group-imports" tests="3" failures="1" skipped="1">
+    <testcase classname="pkg" name="ok.go"></testcase>
+    <testcase classname="pkg" name="skip.go">
+      <skipped message="skipped by directive"></skipped>
+    </testcase>
+    <testcase classname="pkg/sub" name="bad.go">
+      <failure message="Import in incorrect group: &#34;&lt;weird&gt;&amp;&#34;" type="import grouping">pkg/sub/bad.go:4: Import in incorrect group: &#34;&lt;weird&gt;&amp;&#34;</failure>
+    </testcase>
+  </testsuite>
+</testsuites>
+`, buf.String())
+
+	var parsed junitTestSuites
+	assert.Nil(t, xml.Unmarshal(buf.Bytes()[len(xml.Header):], &parsed))
+
+	// Each violation that's an error is a failure of the file's test case.
+	buf.Reset()
+	out = newJunitOutput(&buf)
+	violations := []*ValidationError{
+		{Kind: KindStatementOrder, Line: 4, ImportPath: "fmt", Message: "Import out of order within import group"},
+		{Kind: KindWrongGroup, Line: 5, ImportPath: "os", Message: "Import in incorrect group"},
+		{Kind: KindExtraBlankLine, Line: 6, ImportPath: "io", Message: "Extra empty line inside import group",
+			Severity: SeverityWarning},
+	}
+	out.result(&FileResult{Path: "bad.go", Violation: violations[0], Violations: violations})
+	assert.Nil(t, out.finish())
+	assert.Equal(t, xml.Header+`<testsuites>
+  <testsuite name="group-imports" tests="1" failures="1">
+    <testcase classname="." name="bad.go">
+      <failure message="Import out of order within import group: &#34;fmt&#34;" type="GI001">bad.go:4: Import out of order within import group: &#34;fmt&#34;</failure>
+      <failure message="Import in incorrect group: &#34;os&#34;" type="GI004">bad.go:5: Import in incorrect group: &#34;os&#34;</failure>
+      <system-out>bad.go:6: warning: Extra empty line inside import group: &#34;io&#34; [GI003]</system-out>
+    </testcase>
+  </testsuite>
+</testsuites>
+`, buf.String())
+}
+
+func TestWriteTemplate(t *testing.T) {
+	t.Parallel()
+
+	tmpl, err := ParseTemplate("{{.File}}:{{.Line}}:{{.Column}}: {{.Rule}} {{.ImportPath}} {{.GroupName}}")
+	assert.Nil(t, err)
+
+	report := &Report{Files: []*FileResult{
+		{Path: "ok.go"},
+		{Path: "bad.go", Violation: &ValidationError{
+			Line:          3,
+			ImportPath:    "os",
+			Message:       errstrStatementGroup,
+			Rule:          "statement-group",
+			ExpectedGroup: "Standard",
+		}},
+	}}
+	var buf bytes.Buffer
+	assert.Nil(t, report.WriteTemplate(&buf, tmpl))
+	assert.Equal(t, "bad.go:3:1: statement-group os Standard\n", buf.String())
+
+	// Each violation is written, at its column.
+	first := &ValidationError{Line: 3, Pos: token.Position{Line: 3, Column: 2}, ImportPath: "os", Rule: "statement-group"}
+	second := &ValidationError{Line: 5, Pos: token.Position{Line: 5, Column: 9}, ImportPath: "fmt", Rule: "statement-order"}
+	report = &Report{Files: []*FileResult{{Path: "bad.go", Violation: first, Violations: []*ValidationError{first, second}}}}
+	buf.Reset()
+	assert.Nil(t, report.WriteTemplate(&buf, tmpl))
+	assert.Equal(t, "bad.go:3:2: statement-group os \nbad.go:5:9: statement-order fmt \n", buf.String())
+
+	_, err = ParseTemplate("{{.Missing}}")
+	assert.NotNil(t, err)
+	_, err = ParseTemplate("{{.File")
+	assert.NotNil(t, err)
+}
+
+var updateGolden = flag.Bool("update", false, "update the golden files of output formats")
+
+func TestFormatGolden(t *testing.T) {
+	t.Parallel()
+
+	src := "package a\n\nimport (\n\t\"os\"\n\t\"fmt\"\n)\n"
+	proc := NewProcessor(grouperGoimports{})
+	errs, err := proc.ValidateAll("pkg/bad.go", strings.NewReader(src))
+	assert.Nil(t, err)
+	block, err := proc.RepairBlock("pkg/bad.go", strings.NewReader(src))
+	assert.Nil(t, err)
+	warnProc := NewProcessor(grouperGoimports{}, Severities(map[string]Severity{"GI001": SeverityWarning}))
+	warnings, err := warnProc.ValidateAll("pkg/warn.go", strings.NewReader(src))
+	assert.Nil(t, err)
+	report := &Report{Files: []*FileResult{
+		{Path: "pkg/ok.go"},
+		{Path: "pkg/bad.go", Src: []byte(src), Violation: errs[0], Violations: errs, Fix: block},
+		{Path: "pkg/warn.go", Violation: warnings[0], Violations: warnings},
+		{Path: "pkg/skip.go", Skipped: true, SkipReason: SkipDirective},
+		{Path: "pkg/broken.go", Err: &ParseError{FileName: "pkg/broken.go", Line: 3, Column: 8,
+			Err: errors.New("pkg/broken.go:3:8: expected ')', found 'EOF'")}},
+	}}
+
+	// Every built-in format is written through the registry.
+	for _, name := range []string{"text", "github", "rdjson", "rdjsonl", "junit", "editor", "tap", "json",
+		"teamcity", "codeclimate", "offsets"} {
+		var buf bytes.Buffer
+		assert.Nil(t, report.Write(&buf, name), name)
+		golden := filepath.Join("testdata", "formats", name+".golden")
+		if *updateGolden {
+			assert.Nil(t, ioutil.WriteFile(golden, buf.Bytes(), 0644))
+		}
+		expected, err := ioutil.ReadFile(golden)
+		assert.Nil(t, err)
+		assert.Equal(t, string(expected), buf.String(), name)
+	}
+}
+
+func TestCodeClimate(t *testing.T) {
+	t.Parallel()
+
+	root := filepath.Join("testdata", "codeclimate", "tree")
+	paths := []string{filepath.Join(root, "a.go"), filepath.Join(root, "pkg", "b.go")}
+	report, err := ProcessFiles(context.Background(), paths, NewProcessor(grouperGoimports{}), RunOptions{})
+	assert.Nil(t, err)
+	var buf bytes.Buffer
+	assert.Nil(t, report.WriteCodeClimate(&buf, root))
+	golden := filepath.Join("testdata", "codeclimate", "report.golden")
+	if *updateGolden {
+		assert.Nil(t, ioutil.WriteFile(golden, buf.Bytes(), 0644))
+	}
+	expected, err := ioutil.ReadFile(golden)
+	assert.Nil(t, err)
+	assert.Equal(t, string(expected), buf.String())
+
+	// Fingerprints don't change when lines move, but repeats differ.
+	v := func(line int) *ValidationError {
+		return &ValidationError{Kind: KindDuplicate, Line: line, ImportPath: "os", Message: "Duplicate"}
+	}
+	fingerprints := func(violations ...*ValidationError) []string {
+		buf.Reset()
+		report := &Report{Files: []*FileResult{{Path: "a.go", Violation: violations[0], Violations: violations}}}
+		assert.Nil(t, report.Write(&buf, "codeclimate"))
+		var issues []codeclimateIssue
+		assert.Nil(t, json.Unmarshal(buf.Bytes(), &issues))
+		ret := []string{}
+		for _, issue := range issues {
+			ret = append(ret, issue.Fingerprint)
+		}
+		return ret
+	}
+	before, after := fingerprints(v(4), v(5)), fingerprints(v(8), v(10))
+	assert.Equal(t, before, after)
+	assert.NotEqual(t, before[0], before[1])
+}
+
+// A custom format, which lists paths, and can fail.
+type pathsFormatter struct {
+	w    io.Writer
+	fail bool
+}
+
+func (f *pathsFormatter) Begin(w io.Writer) {
+	f.w = w
+}
+
+func (f *pathsFormatter) WriteResult(res *FileResult) {
+	fmt.Fprintln(f.w, res.Path)
+}
+
+func (f *pathsFormatter) End() error {
+	if f.fail {
+		return errors.New("failed")
+	}
+	return nil
+}
+
+func TestRegisterFormat(t *testing.T) {
+	t.Parallel()
+
+	RegisterFormat("test-paths", func() Formatter { return &pathsFormatter{} })
+	RegisterFormat("test-fail", func() Formatter { return &pathsFormatter{fail: true} })
+	assert.Contains(t, FormatNames(), "test-paths")
+
+	report := &Report{Files: []*FileResult{{Path: "a.go"}, {Path: "b.go", Err: errors.New("No such file")}}}
+	var buf bytes.Buffer
+	assert.Nil(t, report.Write(&buf, "test-paths"))
+	assert.Equal(t, "a.go\nb.go\n", buf.String())
+	assert.EqualError(t, report.Write(&buf, "test-fail"), "failed")
+
+	assert.Panics(t, func() { RegisterFormat("test-paths", func() Formatter { return &pathsFormatter{} }) })
+	assert.Panics(t, func() { RegisterFormat("text", func() Formatter { return &pathsFormatter{} }) })
+	assert.Panics(t, func() { RegisterFormat("", func() Formatter { return &pathsFormatter{} }) })
+}
@@ -0,0 +1,106 @@
+package gogroup
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// PackageOptions configures how ProcessPackages loads and processes packages.
+type PackageOptions struct {
+	RunOptions
+
+	// Dir is the directory in which to load packages, as the go command would
+	// run there. If empty, it's the current directory.
+	Dir string
+
+	// Env is the environment of the go command that loads packages. If nil,
+	// it's the current environment.
+	Env []string
+
+	// Tests determines whether the test files of packages are included.
+	Tests TestFiles
+}
+
+// A PackageError is the error recorded for a package that couldn't be loaded
+// completely by ProcessPackages.
+type PackageError struct {
+	// Package is the ID of the package.
+	Package string
+	// Errors describe what went wrong, as reported by go/packages.
+	Errors []string
+}
+
+func (e *PackageError) Error() string {
+	return e.Package + ": " + strings.Join(e.Errors, "; ")
+}
+
+// ProcessPackages validates, or optionally rewrites, the files of the packages
+// matching patterns, such as "./...", as loaded by go/packages.
+//
+// The files of each package are its GoFiles, as written, rather than its
+// CompiledGoFiles, which may be generated by cgo. Each file is processed
+// once, and its result's Package is the import path of the package it was
+// found in, preferring packages over their test variants. Results are in the
+// order of packages, then of files within them, followed by any packages that
+// failed to load.
+//
+// A package that fails to load is recorded in the report with a PackageError,
+// with its ID as the path, and any files it has are still processed. An error
+// is only returned if loading or processing as a whole fails.
+func ProcessPackages(ctx context.Context, patterns []string, p *Processor, opts PackageOptions) (*Report, error) {
+	cfg := &packages.Config{
+		Context: ctx,
+		Mode:    packages.NeedName | packages.NeedFiles,
+		Dir:     opts.Dir,
+		Env:     opts.Env,
+		Tests:   opts.Tests != TestsExclude,
+	}
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil, err
+	}
+	// Test variants have IDs such as "p [p.test]", which sort after "p".
+	sort.Slice(pkgs, func(i, j int) bool {
+		return pkgs[i].ID < pkgs[j].ID
+	})
+
+	files := []string{}
+	owners := map[string]string{}
+	failed := []*FileResult{}
+	for _, pkg := range pkgs {
+		// The generated main packages of tests have nothing to check.
+		if strings.HasSuffix(pkg.ID, ".test") {
+			continue
+		}
+		if len(pkg.Errors) > 0 {
+			perr := &PackageError{Package: pkg.ID}
+			for _, e := range pkg.Errors {
+				perr.Errors = append(perr.Errors, e.Error())
+			}
+			failed = append(failed, &FileResult{Path: pkg.ID, Package: pkg.PkgPath, Err: perr})
+		}
+		for _, file := range pkg.GoFiles {
+			if _, ok := owners[file]; ok {
+				continue
+			}
+			if opts.Tests == TestsOnly && !strings.HasSuffix(file, "_test.go") {
+				continue
+			}
+			owners[file] = pkg.PkgPath
+			files = append(files, file)
+		}
+	}
+
+	report, err := p.processFiles(ctx, files, opts.RunOptions)
+	if err != nil {
+		return nil, err
+	}
+	for _, res := range report.Files {
+		res.Package = owners[res.Path]
+	}
+	report.Merge(&Report{Files: failed})
+	return report, nil
+}
@@ -0,0 +1,89 @@
+package gogroup
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessPackages(t *testing.T) {
+	t.Parallel()
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("the go command is not installed")
+	}
+
+	dir, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	valid := "package p\n\nimport (\n\t\"os\"\n\n\t\"example.com/m/q\"\n)\n"
+	invalid := "package p\n\nimport (\n\t\"example.com/m/q\"\n\t\"os\"\n)\n"
+	for name, src := range map[string]string{
+		"go.mod":        "module example.com/m\n",
+		"p/a.go":        valid,
+		"p/a_test.go":   invalid,
+		"p/x_test.go":   strings.Replace(valid, "package p", "package p_test", 1),
+		"q/q.go":        "package q\n",
+		"mixed/a.go":    "package a\n",
+		"mixed/b.go":    "package b\n",
+		"testdata/t.go": invalid,
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, ioutil.WriteFile(path, []byte(src), 0644))
+	}
+
+	// Load packages in the temporary module, whatever module the test runs in.
+	env := []string{"GOFLAGS=", "GO111MODULE=on", "GOPROXY=off"}
+	for _, v := range os.Environ() {
+		if !strings.HasPrefix(v, "GOFLAGS=") && !strings.HasPrefix(v, "GO111MODULE=") &&
+			!strings.HasPrefix(v, "GOPROXY=") {
+			env = append(env, v)
+		}
+	}
+	opts := PackageOptions{Dir: dir, Env: env}
+	report, err := ProcessPackages(context.Background(), []string{"./..."}, NewProcessor(grouperGoimports{}), opts)
+	assert.Nil(t, err)
+
+	results := map[string]*FileResult{}
+	for _, res := range report.Files {
+		rel, err := filepath.Rel(dir, res.Path)
+		if err != nil {
+			rel = res.Path
+		}
+		results[filepath.ToSlash(rel)] = res
+	}
+	assert.Equal(t, "example.com/m/p", results["p/a.go"].Package)
+	assert.Nil(t, results["p/a.go"].Violation)
+	assert.Equal(t, "example.com/m/p", results["p/a_test.go"].Package)
+	assert.NotNil(t, results["p/a_test.go"].Violation)
+	assert.Equal(t, "example.com/m/p_test", results["p/x_test.go"].Package)
+	assert.Equal(t, "example.com/m/q", results["q/q.go"].Package)
+	assert.NotContains(t, results, "testdata/t.go")
+
+	// The package that can't be loaded is reported, without stopping others.
+	var perr *PackageError
+	mixed := results["example.com/m/mixed"]
+	if assert.NotNil(t, mixed) {
+		assert.True(t, errors.As(mixed.Err, &perr))
+		assert.Equal(t, "example.com/m/mixed", perr.Package)
+	}
+
+	// Test files can be left out, or processed alone.
+	opts.Tests = TestsExclude
+	report, err = ProcessPackages(context.Background(), []string{"./p"}, NewProcessor(grouperGoimports{}), opts)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(report.Files))
+	assert.Equal(t, filepath.Join(dir, "p", "a.go"), report.Files[0].Path)
+	opts.Tests = TestsOnly
+	report, err = ProcessPackages(context.Background(), []string{"./p"}, NewProcessor(grouperGoimports{}), opts)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(report.Files))
+	assert.True(t, report.HasViolations())
+}
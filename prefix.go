@@ -0,0 +1,72 @@
+package gogroup
+
+// A PrefixGroup assigns imports whose path starts with Prefix to Group.
+type PrefixGroup struct {
+	Prefix string
+	Group  int
+}
+
+// PrefixGrouper finds the group of an import path by prefix. Lookups take
+// time proportional to the length of the path, however many prefixes there
+// are, so it suits configurations with very many prefixes.
+//
+// Prefixes match the start of a path as strings, not as whole path elements,
+// so "github.com/corp" matches "github.com/corporate/x".
+type PrefixGrouper struct {
+	root *prefixNode
+}
+
+// A node of a trie of prefixes, keyed by byte.
+type prefixNode struct {
+	children map[byte]*prefixNode
+
+	// The position in the list of prefixes of the first one ending at this
+	// node, and its group. The position is -1 if no prefix ends here.
+	first, group int
+}
+
+func newPrefixNode() *prefixNode {
+	return &prefixNode{children: map[byte]*prefixNode{}, first: -1}
+}
+
+// NewPrefixGrouper creates a PrefixGrouper from prefixes and their groups. If
+// several prefixes match a path, the one listed first wins.
+func NewPrefixGrouper(prefixes []PrefixGroup) *PrefixGrouper {
+	root := newPrefixNode()
+	for i, pg := range prefixes {
+		node := root
+		for j := 0; j < len(pg.Prefix); j++ {
+			child, ok := node.children[pg.Prefix[j]]
+			if !ok {
+				child = newPrefixNode()
+				node.children[pg.Prefix[j]] = child
+			}
+			node = child
+		}
+		if node.first < 0 {
+			node.first, node.group = i, pg.Group
+		}
+	}
+	return &PrefixGrouper{root: root}
+}
+
+// Lookup yields the group of the first listed prefix that matches a path, and
+// whether any does.
+func (g *PrefixGrouper) Lookup(path string) (int, bool) {
+	first, group := -1, 0
+	node := g.root
+	for i := 0; ; i++ {
+		if node.first >= 0 && (first < 0 || node.first < first) {
+			first, group = node.first, node.group
+		}
+		if i == len(path) {
+			break
+		}
+		next, ok := node.children[path[i]]
+		if !ok {
+			break
+		}
+		node = next
+	}
+	return group, first >= 0
+}
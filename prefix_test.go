@@ -0,0 +1,101 @@
+package gogroup
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Look up a prefix by scanning every one, which PrefixGrouper must agree with.
+func linearLookup(prefixes []PrefixGroup, path string) (int, bool) {
+	for _, pg := range prefixes {
+		if strings.HasPrefix(path, pg.Prefix) {
+			return pg.Group, true
+		}
+	}
+	return 0, false
+}
+
+// Generate prefixes for many services, some nested within others.
+func servicePrefixes(n int) []PrefixGroup {
+	prefixes := []PrefixGroup{}
+	for i := 0; i < n; i++ {
+		prefix := fmt.Sprintf("github.com/corp/svc%d/", i)
+		if i%3 == 0 {
+			prefix = fmt.Sprintf("github.com/corp/svc%d/internal/", i/3)
+		}
+		prefixes = append(prefixes, PrefixGroup{Prefix: prefix, Group: i + 1})
+	}
+	return prefixes
+}
+
+func TestPrefixGrouper(t *testing.T) {
+	t.Parallel()
+
+	prefixes := []PrefixGroup{
+		{"github.com/corp/svc/", 1},
+		{"github.com/corp/", 2},
+		{"github.com/corp/svc/internal/", 3},
+		{"github.com/corp", 4},
+		{"github.com/corp/", 5},
+		{"", 6},
+	}
+	g := NewPrefixGrouper(prefixes[:5])
+	for _, path := range []string{
+		"github.com/corp/svc/internal/auth",
+		"github.com/corp/svc/x",
+		"github.com/corp/log",
+		"github.com/corporate/log",
+		"github.com/corp",
+		"github.com/other/x",
+		"os",
+		"",
+	} {
+		group, ok := g.Lookup(path)
+		wantGroup, wantOK := linearLookup(prefixes[:5], path)
+		assert.Equal(t, wantOK, ok, path)
+		assert.Equal(t, wantGroup, group, path)
+	}
+
+	// An empty prefix matches everything.
+	group, ok := NewPrefixGrouper(prefixes[5:]).Lookup("os")
+	assert.True(t, ok)
+	assert.Equal(t, 6, group)
+
+	// So do many generated prefixes.
+	prefixes = servicePrefixes(500)
+	g = NewPrefixGrouper(prefixes)
+	for i := 0; i < 600; i++ {
+		for _, path := range []string{
+			fmt.Sprintf("github.com/corp/svc%d/internal/x", i),
+			fmt.Sprintf("github.com/corp/svc%d/pkg", i),
+		} {
+			group, ok := g.Lookup(path)
+			wantGroup, wantOK := linearLookup(prefixes, path)
+			assert.Equal(t, wantOK, ok, path)
+			assert.Equal(t, wantGroup, group, path)
+		}
+	}
+}
+
+func BenchmarkPrefixGrouper(b *testing.B) {
+	for _, n := range []int{10, 100, 2000} {
+		prefixes := servicePrefixes(n)
+		path := "github.com/other/pkg/that/matches/nothing"
+
+		b.Run(fmt.Sprintf("linear-%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				linearLookup(prefixes, path)
+			}
+		})
+		b.Run(fmt.Sprintf("trie-%d", n), func(b *testing.B) {
+			g := NewPrefixGrouper(prefixes)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				g.Lookup(path)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package gogroup
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Types for the Reviewdog Diagnostic Format.
+// See https://github.com/reviewdog/reviewdog/tree/master/proto/rdf
+
+type rdjsonSource struct {
+	Name string `json:"name"`
+}
+
+type rdjsonPosition struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+type rdjsonRange struct {
+	Start rdjsonPosition  `json:"start"`
+	End   *rdjsonPosition `json:"end,omitempty"`
+}
+
+type rdjsonLocation struct {
+	Path  string      `json:"path"`
+	Range rdjsonRange `json:"range"`
+}
+
+type rdjsonSuggestion struct {
+	Range rdjsonRange `json:"range"`
+	Text  string      `json:"text"`
+}
+
+type rdjsonCode struct {
+	Value string `json:"value"`
+}
+
+type rdjsonDiagnostic struct {
+	Message     string             `json:"message"`
+	Location    rdjsonLocation     `json:"location"`
+	Severity    string             `json:"severity"`
+	Code        *rdjsonCode        `json:"code,omitempty"`
+	Source      *rdjsonSource      `json:"source,omitempty"`
+	Suggestions []rdjsonSuggestion `json:"suggestions,omitempty"`
+}
+
+type rdjsonResult struct {
+	Source      rdjsonSource        `json:"source"`
+	Severity    string              `json:"severity"`
+	Diagnostics []*rdjsonDiagnostic `json:"diagnostics"`
+}
+
+var rdjsonToolSource = rdjsonSource{Name: "group-imports"}
+
+// Reviewdog Diagnostic Format output, either as a single JSON document, or
+// as one diagnostic per line.
+type rdjsonOutput struct {
+	w     io.Writer
+	lines bool
+
+	diags []*rdjsonDiagnostic
+	err   error
+}
+
+func newRdjsonOutput(w io.Writer) outputFormat {
+	return &rdjsonOutput{w: w}
+}
+
+func newRdjsonlOutput(w io.Writer) outputFormat {
+	return &rdjsonOutput{w: w, lines: true}
+}
+
+// Build a suggestion that replaces the import block of a file with its
+// fixed content.
+func rdjsonSuggest(res *FileResult) *rdjsonSuggestion {
+	block := res.Fix
+	if block == nil {
+		return nil
+	}
+
+	// The range covers the whole lines of the block, excluding the final
+	// line ending, which the replacement text also excludes.
+	lines := strings.SplitAfter(string(res.Src), "\n")
+	last := strings.TrimRight(lines[block.EndLine-1], "\r\n")
+	return &rdjsonSuggestion{
+		Range: rdjsonRange{
+			Start: rdjsonPosition{Line: block.StartLine, Column: 1},
+			End:   &rdjsonPosition{Line: block.EndLine, Column: len(last) + 1},
+		},
+		Text: strings.Join(block.Fixed, "\n"),
+	}
+}
+
+// Yield the range of a violation, from its position to its end if they're
+// known, or else its line.
+func rdjsonViolationRange(v *ValidationError) rdjsonRange {
+	if v.Pos.Line == 0 {
+		return rdjsonRange{Start: rdjsonPosition{Line: v.Line, Column: 1}}
+	}
+	r := rdjsonRange{Start: rdjsonPosition{Line: v.Pos.Line, Column: v.Pos.Column}}
+	if v.End.Line != 0 {
+		r.End = &rdjsonPosition{Line: v.End.Line, Column: v.End.Column}
+	}
+	return r
+}
+
+func (o *rdjsonOutput) result(res *FileResult) {
+	for i, v := range resultViolations(res) {
+		if o.err != nil {
+			return
+		}
+
+		diag := &rdjsonDiagnostic{
+			Message: fmt.Sprintf("%s: %s", v.Message, strconv.Quote(v.ImportPath)),
+			Location: rdjsonLocation{
+				Path:  res.Path,
+				Range: rdjsonViolationRange(v),
+			},
+			Severity: strings.ToUpper(v.Severity.String()),
+		}
+		if v.ID() != "" {
+			diag.Code = &rdjsonCode{Value: v.ID()}
+		}
+
+		// The fix repairs every violation of the file at once, so only the
+		// first diagnostic suggests it.
+		if sugg := rdjsonSuggest(res); sugg != nil && i == 0 {
+			diag.Suggestions = []rdjsonSuggestion{*sugg}
+		}
+
+		if o.lines {
+			diag.Source = &rdjsonToolSource
+			o.err = json.NewEncoder(o.w).Encode(diag)
+		} else {
+			o.diags = append(o.diags, diag)
+		}
+	}
+}
+
+func (o *rdjsonOutput) finish() error {
+	if o.err != nil || o.lines {
+		return o.err
+	}
+
+	enc := json.NewEncoder(o.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(&rdjsonResult{
+		Source:      rdjsonToolSource,
+		Severity:    "ERROR",
+		Diagnostics: append([]*rdjsonDiagnostic{}, o.diags...),
+	})
+}
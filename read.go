@@ -1,23 +1,118 @@
 package gogroup
 
 import (
+	"go/ast"
 	"go/parser"
+	"go/scanner"
 	"go/token"
 	"io"
+	"io/ioutil"
 	"strconv"
+	"strings"
 )
 
+// Comments that cause a file to be ignored, or an import to be kept in place.
+const (
+	ignoreDirective = "//group-imports:ignore"
+	keepDirective   = "//group-imports:keep"
+)
+
+// The prefix of comments that suppress the violations of linters, as
+// golangci-lint uses them, and the name of this linter among them.
+const (
+	nolintDirective = "//nolint:"
+	nolintName      = "gogroup"
+)
+
+// Determine whether a comment is a directive. Directives may be followed by a
+// space and an explanation.
+func isDirective(c *ast.Comment, directive string) bool {
+	return c.Text == directive || strings.HasPrefix(c.Text, directive+" ")
+}
+
 // An import statement with a group.
 type groupedImport struct {
 	// The zero-based starting and ending lines in the file.
 	// The endLine is the last line of this statement, not the line after.
 	startLine, endLine int
 
+	// The parsed import statement, its physical position, and the file it's
+	// in.
+	spec *ast.ImportSpec
+	pos  token.Position
+	file *token.File
+
 	// The import package path.
 	path string
 
+	// The key to order the import by within its group.
+	sortKey string
+
 	// The import group.
 	group int
+
+	// Whether the import is pinned in place, by a keep directive or because
+	// the grouper ignores it.
+	keep bool
+
+	// Whether the import is of "C" in a declaration of its own, which stays
+	// where it is.
+	cgo bool
+
+	// The number of lines of kept imports hidden before this one, when it's
+	// part of a visible view of imports.
+	shift int
+
+	// Whether an import the grouper ignores was hidden just before this one,
+	// when it's part of a visible view of imports.
+	afterIgnored bool
+
+	// Whether the grouper assigned the import to no group.
+	unassigned bool
+
+	// Whether the import has a comment suppressing its violations.
+	suppressed bool
+
+	// Whether the import has a relative path that isn't allowed.
+	relative bool
+
+	// The deny rule the import matches, if any.
+	denied *DenyRule
+
+	// The layering rule that forbids the import, if any.
+	layer *LayerRule
+
+	// The comments right before the import, if any.
+	doc *ast.CommentGroup
+
+	// The group header comment before the import, if any.
+	header *ast.Comment
+
+	// The comments separating the import's group from the previous one, and
+	// whether an empty line comes before them too.
+	separator      []*ast.Comment
+	separatorBlank bool
+
+	// Comments to strip before the import, or after it if it's the last one.
+	stripped []strippedComment
+
+	// An earlier import of the same path, if duplicates are detected.
+	duplicateOf *groupedImport
+
+	// Whether the import repeats an earlier one exactly, so repairs can
+	// remove it.
+	redundant bool
+
+	// The alias the import should be under, if it's under another name, and
+	// whether its name is forbidden.
+	wantAlias      string
+	forbiddenAlias bool
+}
+
+// A comment to strip, and its zero-based line.
+type strippedComment struct {
+	comment *ast.Comment
+	line    int
 }
 
 // Allow sorting grouped imports.
@@ -33,43 +128,458 @@ func (gs groupedImports) Less(i, j int) bool {
 	if gs[i].group < gs[j].group {
 		return true
 	}
-	if gs[i].group == gs[j].group && gs[i].path < gs[j].path {
+	if gs[i].group == gs[j].group && gs[i].sortKey < gs[j].sortKey {
 		return true
 	}
 	return false
 }
 
-// Read import statements from a file, and assign them groups.
-func (p *Processor) readImports(fileName string, r io.Reader) (groupedImports, error) {
+// Determine whether a file has an ignore directive before its import
+// declarations.
+func hasIgnoreDirective(tree *ast.File) bool {
+	var end token.Pos
+	if len(tree.Decls) > 0 {
+		end = tree.Decls[0].Pos()
+	}
+	for _, cg := range tree.Comments {
+		if end.IsValid() && cg.Pos() > end {
+			break
+		}
+		for _, c := range cg.List {
+			if isDirective(c, ignoreDirective) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Determine whether an import has a trailing keep directive.
+func hasKeepDirective(ispec *ast.ImportSpec) bool {
+	if ispec.Comment == nil {
+		return false
+	}
+	for _, c := range ispec.Comment.List {
+		if isDirective(c, keepDirective) {
+			return true
+		}
+	}
+	return false
+}
+
+// Determine whether a comment suppresses the violations of this linter, such
+// as "//nolint:gogroup" or "//nolint:lll,gogroup // explanation".
+func isNolint(c *ast.Comment) bool {
+	if !strings.HasPrefix(c.Text, nolintDirective) {
+		return false
+	}
+	names := strings.TrimPrefix(c.Text, nolintDirective)
+	if i := strings.IndexAny(names, " \t"); i >= 0 {
+		names = names[:i]
+	}
+	for _, name := range strings.Split(names, ",") {
+		if name == nolintName {
+			return true
+		}
+	}
+	return false
+}
+
+// Determine whether an import has a comment suppressing its violations, at
+// the end of its line or on the line above, at the end of its doc comment.
+func hasNolint(ispec *ast.ImportSpec, doc *ast.CommentGroup) bool {
+	if ispec.Comment != nil {
+		for _, c := range ispec.Comment.List {
+			if isNolint(c) {
+				return true
+			}
+		}
+	}
+	return doc != nil && isNolint(doc.List[len(doc.List)-1])
+}
+
+// Yield the imports that aren't kept in place, as if the lines of kept imports
+// didn't exist. The original lines can be recovered using the shift.
+func (gs groupedImports) visible() groupedImports {
+	vs := make([]groupedImport, 0, len(gs))
+	ret := make(groupedImports, 0, len(gs))
+	shift := 0
+	ignored := false
+	for _, g := range gs {
+		if g.keep {
+			shift += g.endLine - g.startLine + 1
+			ignored = ignored || g.group == GroupIgnore
+			continue
+		}
+		vs = append(vs, *g)
+		v := &vs[len(vs)-1]
+		v.startLine -= shift
+		v.endLine -= shift
+		v.shift = shift
+		v.afterIgnored = ignored
+		ignored = false
+		ret = append(ret, v)
+	}
+	return ret
+}
+
+// Determine whether source has a package clause, ignoring any errors after
+// it.
+func hasPackageClause(src []byte) bool {
+	var s scanner.Scanner
 	fset := token.NewFileSet()
-	tree, err := parser.ParseFile(fset, fileName, r, parser.ImportsOnly|parser.ParseComments)
+	s.Init(fset.AddFile("", -1, len(src)), src, nil, 0)
+	_, tok, _ := s.Scan()
+	return tok == token.PACKAGE
+}
+
+// Wrap an error from parsing source in a ParseError, if it's due to invalid
+// source rather than some other failure.
+func parseError(fileName string, src []byte, err error) error {
+	list, ok := err.(scanner.ErrorList)
+	if !ok || len(list) == 0 {
+		return err
+	}
+	if !hasPackageClause(src) {
+		return &ParseError{FileName: fileName, Err: ErrNoPackageClause}
+	}
+	first := list[0]
+	return &ParseError{
+		FileName: fileName,
+		Line:     first.Pos.Line,
+		Column:   first.Pos.Column,
+		Err:      first,
+	}
+}
+
+// Parse the imports and comments of a file.
+func parseImports(fileName string, r io.Reader) (*token.FileSet, *ast.File, error) {
+	src, err := ioutil.ReadAll(r)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	return parseSource(fileName, src)
+}
+
+// Parse the imports and comments of source that's already been read.
+func parseSource(fileName string, src []byte) (*token.FileSet, *ast.File, error) {
+	fset := token.NewFileSet()
+	tree, err := parser.ParseFile(fset, fileName, src, parser.ImportsOnly|parser.ParseComments)
+	return fset, tree, parseError(fileName, src, err)
+}
 
-	gs := groupedImports{}
-	for _, ispec := range tree.Imports {
-		var path string
-		path, err = strconv.Unquote(ispec.Path.Value)
-		if err != nil {
-			return nil, err
+// Determine whether a file is ignored.
+func (p *Processor) ignored(fileName string, r io.Reader) (bool, error) {
+	_, tree, err := parseImports(fileName, r)
+	if err != nil {
+		return false, err
+	}
+	ignored := !p.ignoreDirectives && hasIgnoreDirective(tree)
+	if ignored && debugging(p.logger) {
+		p.logger.Debug("file skipped", "file", fileName, "reason", SkipDirective)
+	}
+	return ignored, nil
+}
+
+// Yield the import declarations of a file.
+func importDecls(tree *ast.File) []*ast.GenDecl {
+	decls := []*ast.GenDecl{}
+	for _, decl := range tree.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			decls = append(decls, gen)
 		}
+	}
+	return decls
+}
 
-		startPos, endPos := ispec.Pos(), ispec.End()
-		if ispec.Doc != nil {
-			// Comments go with the following import statement.
-			startPos = ispec.Doc.Pos()
+// Determine whether an import declaration only imports "C". Cgo takes the
+// comments before it as its preamble, so repairs leave it where it is rather
+// than merge it with the other declarations.
+func isCgoDecl(gen *ast.GenDecl) bool {
+	if len(gen.Specs) != 1 {
+		return false
+	}
+	path, err := strconv.Unquote(gen.Specs[0].(*ast.ImportSpec).Path.Value)
+	return err == nil && path == "C"
+}
+
+// Yield the import declarations of a file that repairs merge into one.
+func mergedDecls(tree *ast.File) []*ast.GenDecl {
+	decls := []*ast.GenDecl{}
+	for _, gen := range importDecls(tree) {
+		if !isCgoDecl(gen) {
+			decls = append(decls, gen)
 		}
+	}
+	return decls
+}
+
+// Read import statements from a file, and assign them groups. Ignored files
+// have no import statements. Also yields the parsed file.
+func (p *Processor) readImports(fileName string, r io.Reader) (*token.FileSet, *ast.File, groupedImports,
+	error) {
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return p.readSource(fileName, src)
+}
 
-		file := fset.File(startPos)
-		gs = append(gs, &groupedImport{
-			path: path,
-			// Line numbers are one-based in token.File.
-			startLine: file.Line(startPos) - 1,
-			endLine:   file.Line(endPos) - 1,
-			group:     p.grouper.Group(path),
-		})
+// Read import statements from source that's already been read.
+func (p *Processor) readSource(fileName string, src []byte) (*token.FileSet, *ast.File, groupedImports,
+	error) {
+	stop := p.timings.Start(PhaseParse)
+	fset, tree, err := parseSource(fileName, src)
+	stop()
+	if err != nil {
+		return nil, nil, nil, err
 	}
+	defer p.timings.Start(PhaseGroup)()
+	gs, err := p.groupImports(fset, tree)
+	return fset, tree, gs, err
+}
 
+// Determine whether an import path is relative.
+func isRelative(path string) bool {
+	return path == "." || path == ".." || strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../")
+}
+
+// Find the group header at the start of the comments before an import, if
+// any. With comment separators, it may follow the separator comments.
+func (p *Processor) findHeader(doc *ast.CommentGroup) *ast.Comment {
+	if doc == nil {
+		return nil
+	}
+	for i, c := range doc.List {
+		if i > 0 && !p.commentSeparators {
+			break
+		}
+		for _, header := range p.headers {
+			if c.Text == header {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+// Assign groups to the import statements of a parsed file.
+func (p *Processor) groupImports(fset *token.FileSet, tree *ast.File) (groupedImports, error) {
+	if !p.ignoreDirectives && hasIgnoreDirective(tree) {
+		if debugging(p.logger) {
+			p.logger.Debug("file skipped", "file", fset.Position(tree.Package).Filename, "reason", SkipDirective)
+		}
+		return groupedImports{}, nil
+	}
+
+	// Allocate the imports together, since there may be many of them.
+	imports := make([]groupedImport, 0, len(tree.Imports))
+	gs := make(groupedImports, 0, len(tree.Imports))
+	layer := p.findLayer(fset.Position(tree.Package).Filename)
+	file := fset.File(tree.Package)
+	for _, gen := range importDecls(tree) {
+		cgo := isCgoDecl(gen)
+		for _, spec := range gen.Specs {
+			ispec := spec.(*ast.ImportSpec)
+			path, err := strconv.Unquote(ispec.Path.Value)
+			if err != nil {
+				return nil, err
+			}
+
+			startPos, endPos := ispec.Pos(), ispec.End()
+			doc := ispec.Doc
+			if doc == nil && !gen.Lparen.IsValid() {
+				// Including those before an unparenthesized declaration.
+				doc = gen.Doc
+			}
+			if doc != nil {
+				// Comments go with the following import statement.
+				startPos = doc.Pos()
+			}
+			if ispec.Comment != nil {
+				endPos = ispec.Comment.End()
+			}
+
+			group, ok := lookupGroup(p.grouper, path)
+			if debugging(p.logger) {
+				p.logGroup(file.Name(), path, group, ok)
+			}
+			relative := !p.allowRelative && isRelative(path)
+			var header *ast.Comment
+			if !cgo {
+				// A cgo preamble has no header.
+				header = p.findHeader(doc)
+			}
+			imports = append(imports, groupedImport{
+				spec:    ispec,
+				pos:     fset.PositionFor(ispec.Pos(), false),
+				file:    file,
+				path:    path,
+				sortKey: p.sortKey(path, len(imports)),
+				// Line numbers are one-based in token.Position. Line directives
+				// are ignored, since we care about physical lines.
+				startLine: fset.PositionFor(startPos, false).Line - 1,
+				endLine:   fset.PositionFor(endPos, false).Line - 1,
+				group:     group,
+				keep: cgo || !ok || group == GroupIgnore || relative ||
+					!p.ignoreDirectives && hasKeepDirective(ispec),
+				cgo:        cgo,
+				unassigned: !ok && !relative,
+				suppressed: !p.ignoreDirectives && hasNolint(ispec, doc),
+				relative:   relative,
+				denied:     p.findDenied(path),
+				doc:        doc,
+				header:     header,
+			})
+			gs = append(gs, &imports[len(imports)-1])
+		}
+	}
+
+	p.findStripped(fset, tree, gs)
+	p.findSeparators(gs)
+	p.findDuplicates(gs)
+	for _, g := range gs {
+		p.checkAlias(g)
+		if layer != nil && layer.forbids(g.path) {
+			g.layer = layer
+		}
+	}
 	return gs, nil
 }
+
+// Yield the name an import is imported under, or the empty string if it has
+// none.
+func importName(ispec *ast.ImportSpec) string {
+	if ispec.Name == nil {
+		return ""
+	}
+	return ispec.Name.Name
+}
+
+// Find the comments that separate groups, when comment lines may stand in for
+// empty lines. They're the comments right before the first import of a group,
+// other than headers and comments to strip.
+func (p *Processor) findSeparators(gs groupedImports) {
+	if !p.commentSeparators {
+		return
+	}
+	var prev *groupedImport
+	for _, g := range gs {
+		if g.keep {
+			continue
+		}
+		if prev != nil && g.group != prev.group && g.doc != nil {
+			stripped := map[*ast.Comment]bool{}
+			for _, s := range g.stripped {
+				stripped[s.comment] = true
+			}
+			for _, c := range g.doc.List {
+				if c == g.header && len(g.separator) > 0 {
+					// The rest is the import's own doc comment.
+					break
+				}
+				if c != g.header && !stripped[c] {
+					g.separator = append(g.separator, c)
+				}
+			}
+			g.separatorBlank = g.startLine-prev.endLine > 1
+		}
+		prev = g
+	}
+}
+
+// Find imports of paths imported earlier in the file.
+func (p *Processor) findDuplicates(gs groupedImports) {
+	if !p.duplicates {
+		return
+	}
+	first := map[string]*groupedImport{}
+	for _, g := range gs {
+		prev, ok := first[g.path]
+		if !ok {
+			first[g.path] = g
+			continue
+		}
+		g.duplicateOf = prev
+		// Without comments, the import is on a line of its own.
+		g.redundant = !g.keep && importName(g.spec) == importName(prev.spec) &&
+			g.startLine == g.endLine && g.spec.Comment == nil && len(g.stripped) == 0
+	}
+}
+
+// Find the comments in the import declarations that match the strip pattern,
+// and note each on the import it comes before, or on the last import.
+func (p *Processor) findStripped(fset *token.FileSet, tree *ast.File, gs groupedImports) {
+	decls := mergedDecls(tree)
+	if p.stripComments == nil || len(decls) == 0 || p.disabledKind(KindStrippedComment) {
+		return
+	}
+	docs := map[*ast.CommentGroup]bool{}
+	keep := map[*ast.Comment]bool{}
+	for _, gen := range decls {
+		if !gen.Lparen.IsValid() && gen.Doc != nil {
+			docs[gen.Doc] = true
+		}
+	}
+	for _, g := range gs {
+		if g.cgo && g.doc != nil {
+			// Cgo preambles are left alone.
+			for _, c := range g.doc.List {
+				keep[c] = true
+			}
+		}
+		if g.spec.Doc != nil {
+			docs[g.spec.Doc] = true
+		}
+		if g.spec.Comment != nil {
+			for _, c := range g.spec.Comment.List {
+				keep[c] = true
+			}
+		}
+		if g.header != nil {
+			keep[g.header] = true
+		}
+	}
+
+	start, end := importDeclsSpan(decls)
+	i := 0
+	for _, cg := range tree.Comments {
+		if cg.Pos() < start || cg.End() > end {
+			continue
+		}
+		for i < len(gs)-1 && gs[i].spec.Pos() < cg.Pos() {
+			i++
+		}
+		for _, c := range cg.List {
+			if keep[c] {
+				continue
+			}
+			if !p.stripComments.MatchString(c.Text) {
+				if docs[cg] {
+					// The rest is the import's own doc comment.
+					break
+				}
+				continue
+			}
+			gs[i].stripped = append(gs[i].stripped, strippedComment{
+				comment: c,
+				// Line numbers are one-based in token.Position.
+				line: fset.PositionFor(c.Pos(), false).Line - 1,
+			})
+		}
+	}
+}
+
+// Log the group assigned to an import, and what in the order matched it.
+func (p *Processor) logGroup(fileName, pkgPath string, group int, ok bool) {
+	switch {
+	case !ok:
+		p.logger.Debug("import in no group", "file", fileName, "import", pkgPath)
+	case group == GroupIgnore:
+		p.logger.Debug("import ignored", "file", fileName, "import", pkgPath,
+			"spec", explainGroup(p.grouper, pkgPath))
+	default:
+		p.logger.Debug("group assigned", "file", fileName, "import", pkgPath, "group", group,
+			"spec", explainGroup(p.grouper, pkgPath))
+	}
+}
@@ -3,83 +3,514 @@ package gogroup
 import (
 	"bufio"
 	"bytes"
-	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
 	"io"
 	"io/ioutil"
 	"sort"
+	"strings"
 
 	"golang.org/x/tools/imports"
 )
 
-// Read lines from an io.Reader.
+// Read lines from an io.Reader, without their line endings.
+//
+// Unlike bufio.Scanner, there's no limit on the length of a line.
 func readLines(r io.Reader) ([]string, error) {
-	scanner := bufio.NewScanner(r)
+	br := bufio.NewReader(r)
 	ret := []string{}
-	for scanner.Scan() {
-		ret = append(ret, scanner.Text())
-	}
-	if scanner.Err() != nil {
-		return nil, scanner.Err()
+	for {
+		line, err := br.ReadString('\n')
+		if line != "" {
+			line = strings.TrimSuffix(line, "\n")
+			line = strings.TrimSuffix(line, "\r")
+			ret = append(ret, line)
+		}
+		if err == io.EOF {
+			return ret, nil
+		} else if err != nil {
+			return nil, err
+		}
 	}
-	return ret, nil
 }
 
-// Write some lines to an io.Writer.
-func writeLines(w io.Writer, lines []string) error {
-	for _, line := range lines {
-		_, err := fmt.Fprintln(w, line)
-		if err != nil {
-			return err
+// Order the imports of a file, with nil entries where empty lines go between
+// groups.
+//
+// Imports kept in place by a directive stay after the same number of other
+// imports as before.
+func sortedImports(gs groupedImports) groupedImports {
+	sorted := groupedImports{}
+	kept := groupedImports{}
+	keptAfter := []int{}
+	for _, g := range gs {
+		if g.keep {
+			kept = append(kept, g)
+			keptAfter = append(keptAfter, len(sorted))
+		} else {
+			sorted = append(sorted, g)
 		}
 	}
-	return nil
-}
+	sort.Sort(sorted)
 
-// Generate what the import section of a file should look like, properly
-// sorted.
-// Input is a set of grouped imports, and all the lines of text in the file.
-// Output is the lines of text that make up the sorted import section.
-func sortedImportLines(gs groupedImports, lines []string) []string {
-	sort.Sort(gs)
+	ret := groupedImports{}
+	k := 0
+	addKept := func(after int) {
+		for ; k < len(kept) && keptAfter[k] <= after; k++ {
+			ret = append(ret, kept[k])
+		}
+	}
 
-	ret := []string{}
+	addKept(0)
 	var prev *groupedImport
-	for _, g := range gs {
+	for i, g := range sorted {
 		if prev != nil && g.group != prev.group {
 			// Time for an empty line.
-			ret = append(ret, "")
+			ret = append(ret, nil)
 		}
-		ret = append(ret, lines[g.startLine:g.endLine+1]...)
+		ret = append(ret, g)
+		addKept(i + 1)
 		prev = g
 	}
-
 	return ret
 }
 
-// Given the contents of a source file and the parsed imports, yield
-// the contents of the file with imports sorted and grouped, as an
-// io.Reader.
-func fixImports(src []byte, gs groupedImports) (io.Reader, error) {
-	lines, err := readLines(bytes.NewReader(src))
+// Arrange the imports of a file as repairs do, dropping redundant duplicates
+// and sorting the rest, with nil entries where empty lines go between groups.
+func arrangeImports(gs groupedImports) groupedImports {
+	remaining := groupedImports{}
+	for _, g := range gs {
+		if !g.redundant {
+			remaining = append(remaining, g)
+		}
+	}
+	return sortedImports(remaining)
+}
+
+// Find the comments that repairs remove from where they are: group headers,
+// comments to strip, and the first separator comments of each group, which
+// move to the start of the group. The import each group's separator was found
+// before is yielded too.
+func movedComments(gs groupedImports) (map[*ast.Comment]bool, map[int]*groupedImport) {
+	drop := map[*ast.Comment]bool{}
+	separators := map[int]*groupedImport{}
+	for _, g := range gs {
+		if g.separator != nil && separators[g.group] == nil {
+			separators[g.group] = g
+			for _, c := range g.separator {
+				drop[c] = true
+			}
+		}
+		if g.header != nil {
+			drop[g.header] = true
+		}
+		for _, s := range g.stripped {
+			drop[s.comment] = true
+		}
+	}
+	return drop, separators
+}
+
+// Render the import declarations of a file as a single declaration, with its
+// imports sorted and grouped, and formatted with go/printer. If it's not to be
+// parenthesized, each import gets a declaration of its own instead.
+//
+// Each import keeps the comments before it and at the end of its line.
+// Comments at the start of a parenthesized declaration, separated from the
+// first import by an empty line, are about the file rather than that import,
+// so they stay at the start, as comments after the last import stay at the
+// end. Line comments are realigned as gofmt would, rather than keeping their
+// old padding. Group headers and comments to strip are dropped, and the first
+// import of each group gets the header from headers instead, if any. Redundant
+// duplicate imports are dropped too. If fixAliases is true, imports are
+// renamed to the alias they should have.
+//
+// Separator comments stay before the first import of their group, in place of
+// the empty line before it. If a group had more than one, only the first
+// moves, and the rest stay with their imports.
+//
+// Declarations importing only "C" aren't merged, since cgo needs its preamble
+// right before one. Those between the other declarations come first, as they
+// were, along with their comments.
+func renderImports(src []byte, fset *token.FileSet, tree *ast.File, gs groupedImports,
+	parens bool, headers map[int]string, fixAliases bool) ([]byte, error) {
+	decls := mergedDecls(tree)
+	file := fset.File(tree.Pos())
+	text := func(node ast.Node) string {
+		return string(src[file.Offset(node.Pos()):file.Offset(node.End())])
+	}
+
+	start, end := importDeclsSpan(decls)
+	comments := tree.Comments
+	for len(comments) > 0 && comments[0].Pos() < start {
+		comments = comments[1:]
+	}
+	drop, separators := movedComments(gs)
+	var cgo []string
+	for _, gen := range importDecls(tree) {
+		if !isCgoDecl(gen) || gen.Pos() < start || gen.End() > end {
+			continue
+		}
+		cgoStart, cgoEnd := importDeclsSpan([]*ast.GenDecl{gen})
+		if gen.Doc != nil {
+			cgoStart = gen.Doc.Pos()
+		}
+		cgo = append(cgo, string(src[file.Offset(cgoStart):file.Offset(cgoEnd)]))
+		for _, cg := range comments {
+			if cg.Pos() >= cgoStart && cg.End() <= cgoEnd {
+				for _, c := range cg.List {
+					drop[c] = true
+				}
+			}
+		}
+	}
+	rename := map[*ast.ImportSpec]string{}
+	for _, g := range gs {
+		if fixAliases && g.wantAlias != "" {
+			rename[g.spec] = g.wantAlias
+		}
+	}
+	// Yield the text of the comments of a group that aren't dropped, with
+	// each run of them on a line of its own.
+	kept := func(cg *ast.CommentGroup) []string {
+		lines := []string{}
+		for i := 0; i < len(cg.List); i++ {
+			if drop[cg.List[i]] {
+				continue
+			}
+			j := i
+			for j+1 < len(cg.List) && !drop[cg.List[j+1]] {
+				j++
+			}
+			lines = append(lines, string(src[file.Offset(cg.List[i].Pos()):file.Offset(cg.List[j].End())]))
+			i = j
+		}
+		return lines
+	}
+	var leading []string
+	if spec, doc := firstSpec(decls); spec != nil {
+		for ; len(comments) > 0 && comments[0].End() <= spec.Pos() && comments[0] != doc; comments = comments[1:] {
+			leading = append(leading, kept(comments[0])...)
+		}
+	}
+	texts := map[*ast.ImportSpec][]string{}
+	for _, gen := range decls {
+		for _, spec := range gen.Specs {
+			ispec := spec.(*ast.ImportSpec)
+			lines := []string{}
+			for ; len(comments) > 0 && comments[0].End() <= ispec.Pos(); comments = comments[1:] {
+				lines = append(lines, kept(comments[0])...)
+			}
+
+			line, end := text(ispec), ispec.End()
+			if alias, ok := rename[ispec]; ok {
+				line = alias + " " + text(ispec.Path)
+			}
+			if ispec.Comment != nil {
+				line += " " + text(ispec.Comment)
+				end = ispec.Comment.End()
+			}
+			for len(comments) > 0 && comments[0].Pos() < end {
+				// Comments within the import, or its line comment.
+				comments = comments[1:]
+			}
+			texts[ispec] = append(lines, line)
+		}
+	}
+
+	// The printer places comments better if they're already indented.
+	var buf bytes.Buffer
+	indent := ""
+	buf.WriteString("package p\n")
+	if parens {
+		buf.WriteString("import (\n")
+		indent = "\t"
+	}
+	for _, line := range leading {
+		buf.WriteString(indent + line + "\n")
+	}
+	if len(leading) > 0 {
+		buf.WriteString("\n")
+	}
+	var prev *groupedImport
+	for _, g := range arrangeImports(gs) {
+		if g == nil || g.cgo {
+			continue
+		}
+		lines := texts[g.spec]
+		if !g.keep {
+			if prev == nil || g.group != prev.group {
+				if header := headers[g.group]; header != "" {
+					lines = append([]string{header}, lines...)
+				}
+				sep := separators[g.group]
+				if sep != nil {
+					seps := []string{}
+					for _, c := range sep.separator {
+						seps = append(seps, text(c))
+					}
+					lines = append(seps, lines...)
+				}
+				if prev != nil && (sep == nil || sep.separatorBlank) {
+					// Time for an empty line.
+					buf.WriteString("\n")
+				}
+			}
+			prev = g
+		}
+		for i, line := range lines {
+			buf.WriteString(indent)
+			if !parens && i == len(lines)-1 {
+				buf.WriteString("import ")
+			}
+			buf.WriteString(line + "\n")
+		}
+	}
+	for ; len(comments) > 0 && comments[0].End() <= end; comments = comments[1:] {
+		for _, line := range kept(comments[0]) {
+			buf.WriteString(indent + line + "\n")
+		}
+	}
+	if parens {
+		buf.WriteString(")\n")
+	}
+
+	// Don't use go/format, it would sort the imports itself.
+	pfset := token.NewFileSet()
+	ptree, err := parser.ParseFile(pfset, "", buf.Bytes(), parser.ParseComments)
 	if err != nil {
 		return nil, err
 	}
+	var out bytes.Buffer
+	cfg := printer.Config{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: 8}
+	if err = cfg.Fprint(&out, pfset, ptree); err != nil {
+		return nil, err
+	}
+	rendered := bytes.Trim(bytes.TrimPrefix(out.Bytes(), []byte("package p\n")), "\n")
+	if len(cgo) > 0 {
+		// Preambles aren't printed, so they stay exactly as they were.
+		rendered = append([]byte(strings.Join(cgo, "\n\n")+"\n\n"), rendered...)
+	}
+	return rendered, nil
+}
+
+// Find the first import of a file's import declarations, and its doc comment.
+// The comments before an unparenthesized declaration are its import's doc.
+// Any other comments before the first import aren't attached to it, even if
+// the parser did so, since they're separated from it by an empty line, or are
+// before the import keyword.
+func firstSpec(decls []*ast.GenDecl) (*ast.ImportSpec, *ast.CommentGroup) {
+	for _, gen := range decls {
+		if len(gen.Specs) > 0 {
+			spec := gen.Specs[0].(*ast.ImportSpec)
+			if !gen.Lparen.IsValid() {
+				return spec, gen.Doc
+			}
+			return spec, spec.Doc
+		}
+	}
+	return nil, nil
+}
+
+// Find the span of the import declarations of a file. Where the first or last
+// declaration isn't parenthesized, this includes the comments of its import.
+func importDeclsSpan(decls []*ast.GenDecl) (token.Pos, token.Pos) {
+	first, last := decls[0], decls[len(decls)-1]
+	start, end := first.Pos(), last.End()
+	if !first.Lparen.IsValid() && first.Doc != nil {
+		start = first.Doc.Pos()
+	}
+	if !last.Lparen.IsValid() && len(last.Specs) > 0 {
+		if comment := last.Specs[len(last.Specs)-1].(*ast.ImportSpec).Comment; comment != nil {
+			end = comment.End()
+		}
+	}
+	return start, end
+}
+
+// Given the source of a file, its lines, and the parsed imports, yield the
+// block of lines containing the imports, and its fixed content. The imports
+// are parenthesized if parens is true, groups get the given headers, imports
+// are renamed to their required alias if fixAliases is true, and lines keep
+// their indentation if keepIndent is true.
+func fixBlock(src []byte, lines []string, fset *token.FileSet, tree *ast.File, gs groupedImports,
+	parens bool, headers map[int]string, fixAliases, keepIndent bool) (*ImportBlock, error) {
+	rendered, err := renderImports(src, fset, tree, gs, parens, headers, fixAliases)
+	if err != nil {
+		return nil, err
+	}
+
+	// Replace whole lines, keeping anything else on the lines of the
+	// declarations. Line directives are ignored, since we care about physical
+	// lines.
+	startPos, endPos := importDeclsSpan(mergedDecls(tree))
+	start := fset.PositionFor(startPos, false)
+	end := fset.PositionFor(endPos, false)
+	first, last := start.Line-1, end.Line-1
+	if keepIndent {
+		rendered = reindent(rendered, lines[first:last+1])
+	}
+	after := lines[last][end.Column-1:]
+	if rest := strings.TrimLeft(after, "; \t"); strings.HasPrefix(strings.TrimSpace(after), ";") {
+		// Keep code after a semicolon, but not the semicolon alone.
+		after = ""
+		if rest != "" {
+			after = "; " + rest
+		}
+	}
+	text := lines[first][:start.Column-1] + string(rendered) + after
+	fixed := strings.Split(text, "\n")
+
+	// Leave out unchanged lines before and after the imports, such as the
+	// parentheses of the declaration.
+	merged := groupedImports{}
+	for _, g := range gs {
+		if !g.cgo {
+			merged = append(merged, g)
+		}
+	}
+	gs = merged
+	for first < gs[0].startLine && len(fixed) > 1 && fixed[0] == lines[first] {
+		fixed = fixed[1:]
+		first++
+	}
+	for last > gs[len(gs)-1].endLine && len(fixed) > 1 && fixed[len(fixed)-1] == lines[last] {
+		fixed = fixed[:len(fixed)-1]
+		last--
+	}
+
+	return &ImportBlock{
+		// Line numbers are one-based for humans.
+		StartLine: first + 1,
+		EndLine:   last + 1,
+		Fixed:     fixed,
+	}, nil
+}
+
+// Give the indented lines of rendered imports the indentation they had among
+// the original lines, for KeepIndentation. Lines are matched by their content,
+// disregarding whitespace, so lines that changed, such as those of renamed
+// imports, keep the indentation go/printer gave them.
+func reindent(rendered []byte, orig []string) []byte {
+	indents := map[string]string{}
+	for _, line := range orig {
+		key := strings.Join(strings.Fields(line), " ")
+		if _, ok := indents[key]; !ok && key != "" {
+			indents[key] = line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		}
+	}
+	lines := strings.Split(string(rendered), "\n")
+	for i, line := range lines {
+		if !strings.HasPrefix(line, "\t") {
+			continue
+		}
+		if indent, ok := indents[strings.Join(strings.Fields(line), " ")]; ok {
+			lines[i] = indent + strings.TrimLeft(line, "\t")
+		}
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// Find the byte offset at which each line of src starts. For convenience, the
+// final entry is always len(src).
+func lineOffsets(src []byte) []int {
+	offs := []int{0}
+	for i, c := range src {
+		if c == '\n' {
+			offs = append(offs, i+1)
+		}
+	}
+	if offs[len(offs)-1] != len(src) {
+		offs = append(offs, len(src))
+	}
+	return offs
+}
+
+// Given the contents of a source file and a fixed import block, yield the
+// contents of the file with the block replaced. All bytes outside the block
+// are left unchanged.
+func spliceBlock(src []byte, block *ImportBlock) []byte {
+	offs := lineOffsets(src)
+	start, end := offs[block.StartLine-1], offs[block.EndLine]
+
+	// Use the same line endings as the original block.
+	eol := "\n"
+	if first := src[start:offs[block.StartLine]]; bytes.HasSuffix(first, []byte("\r\n")) {
+		eol = "\r\n"
+	}
+	text := strings.Join(block.Fixed, eol)
+	if bytes.HasSuffix(src[start:end], []byte("\n")) {
+		text += eol
+	}
 
-	min := gs[0].startLine
-	max := gs[len(gs)-1].endLine
+	out := make([]byte, 0, len(src)-(end-start)+len(text))
+	out = append(out, src[:start]...)
+	out = append(out, text...)
+	return append(out, src[end:]...)
+}
 
-	// Need to start a new slice, or we may modify lines as we append.
-	out := []string{}
-	out = append(out, lines[:min]...)
-	out = append(out, sortedImportLines(gs, lines)...)
-	out = append(out, lines[max+1:]...)
+// Find the zero-based range of lines spanned by the import declarations of a
+// file, with an exclusive end. If there are no import declarations, the range
+// is empty, and positioned right after the package clause.
+//
+// Also yields the line after the package clause.
+func importDeclSpan(fileName string, src []byte) (pkgEnd, start, end int, err error) {
+	fset := token.NewFileSet()
+	tree, err := parser.ParseFile(fset, fileName, src, parser.ImportsOnly)
+	if err != nil {
+		return 0, 0, 0, parseError(fileName, src, err)
+	}
 
-	var dst bytes.Buffer
-	if err = writeLines(&dst, out); err != nil {
+	// Line numbers are one-based in token.File.
+	pkgEnd = fset.PositionFor(tree.Name.End(), false).Line
+	start, end = pkgEnd, pkgEnd
+	for i, decl := range tree.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			if i == 0 {
+				start = fset.PositionFor(gen.Pos(), false).Line - 1
+			}
+			end = fset.PositionFor(gen.End(), false).Line
+		}
+	}
+	return pkgEnd, start, end, nil
+}
+
+// Given the original contents of a file and a formatted version, yield the
+// original contents with just the import declarations taken from the
+// formatted version.
+func spliceImportDecls(fileName string, orig, formatted []byte) ([]byte, error) {
+	_, oStart, oEnd, err := importDeclSpan(fileName, orig)
+	if err != nil {
 		return nil, err
 	}
+	fPkgEnd, fStart, fEnd, err := importDeclSpan(fileName, formatted)
+	if err != nil {
+		return nil, err
+	}
+	oOffs, fOffs := lineOffsets(orig), lineOffsets(formatted)
 
-	return &dst, nil
+	if oStart == oEnd {
+		// Take the formatted spacing after the package clause, too.
+		fStart = fPkgEnd
+	} else if fStart == fEnd && oStart > 0 && oEnd < len(oOffs)-1 &&
+		len(bytes.TrimSpace(orig[oOffs[oStart-1]:oOffs[oStart]])) == 0 &&
+		len(bytes.TrimSpace(orig[oOffs[oEnd]:oOffs[oEnd+1]])) == 0 {
+		// All imports were removed, don't leave behind two empty lines.
+		oEnd++
+	}
+
+	// Formatting drops carriage returns, restore them if the file had them.
+	decls := formatted[fOffs[fStart]:fOffs[fEnd]]
+	if bytes.HasSuffix(orig[:oOffs[1]], []byte("\r\n")) {
+		decls = bytes.Replace(decls, []byte("\n"), []byte("\r\n"), -1)
+	}
+
+	out := []byte{}
+	out = append(out, orig[:oOffs[oStart]]...)
+	out = append(out, decls...)
+	return append(out, orig[oOffs[oEnd]:]...), nil
 }
 
 // Repair the imports section of a file, to reflect sorting and grouping.
@@ -90,22 +521,170 @@ func (p *Processor) repair(fileName string, r io.Reader) (io.Reader, error) {
 		return nil, err
 	}
 
+	// Only the import block is rewritten, so everything else stays
+	// byte-for-byte identical.
+	block, err := p.repairBlock(fileName, bytes.NewReader(src))
+	if err != nil || block == nil {
+		return nil, err
+	}
+	return bytes.NewReader(spliceBlock(src, block)), nil
+}
+
+// Find the import block of a file, and what it should contain.
+func (p *Processor) repairBlock(fileName string, r io.Reader) (*ImportBlock, error) {
+	defer p.timings.Start(PhaseRepair)()
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
 	// Check if the file needs any fixing.
-	gs, err := p.readImports(fileName, bytes.NewReader(src))
+	fset, tree, err := parseImports(fileName, bytes.NewReader(src))
 	if err != nil {
 		return nil, err
 	}
-	if gs.validate() == nil {
+	gs, err := p.groupImports(fset, tree)
+	if err != nil {
+		return nil, err
+	}
+	if len(mergedDecls(tree)) == 0 {
+		// Only cgo declarations, which are left alone.
+		return nil, nil
+	}
+	needed := false
+	errs, _ := p.check(fset, tree, gs, nil, false)
+	for _, validErr := range errs {
+		needed = needed || p.fixes(validErr.Kind)
+	}
+	if !needed {
 		return nil, nil
 	}
 
-	// Generate the fixed version.
-	dst, err := fixImports(src, gs)
+	lines, err := readLines(bytes.NewReader(src))
 	if err != nil {
 		return nil, err
 	}
+	headers := p.headers
+	if !p.fixesHeaders() {
+		headers = nil
+	}
+	p.unfixed(gs)
+	return fixBlock(src, lines, fset, tree, gs, p.parenthesize(tree, gs), headers, p.fixesAliases(),
+		p.keepIndentation)
+}
+
+// Adjust the imports of a file so that repairs leave alone what the rules they
+// don't fix require, as NoFix describes, and keep imports whose violations are
+// suppressed in place.
+func (p *Processor) unfixed(gs groupedImports) {
+	for _, g := range gs {
+		g.keep = g.keep || g.suppressed
+	}
+	if !p.fixes(KindWrongGroup) {
+		gs.foundGroups()
+	}
+	for i, g := range gs {
+		if !p.fixes(KindStatementOrder) {
+			g.sortKey = indexKey(i)
+		}
+		if !p.fixes(KindStrippedComment) {
+			g.stripped = nil
+		}
+		if !p.fixes(KindDuplicate) {
+			g.redundant = false
+		}
+		if !p.fixesHeaders() {
+			// Leave the header with its import, like any other comment.
+			g.header = nil
+		}
+	}
+}
 
-	return dst, nil
+// Put each import in the group it's found in, rather than the one it belongs
+// to, so that repairs don't move it to another group. An import is found in
+// the group most of the run of adjacent imports it's in belong to, or if
+// there's a tie, that of the run's first import. But where the groups of a run
+// are already in order, the run only lacks empty lines between them, so its
+// imports stay in their own groups.
+func (gs groupedImports) foundGroups() {
+	moved := groupedImports{}
+	for _, g := range gs {
+		if !g.keep {
+			moved = append(moved, g)
+		}
+	}
+	vs := gs.visible()
+	for start := 0; start < len(vs); {
+		end := start + 1
+		for end < len(vs) && vs[end].startLine-vs[end-1].endLine <= 1 && !vs[end].afterIgnored &&
+			vs[end].separator == nil {
+			end++
+		}
+		ordered := true
+		counts := map[int]int{}
+		for i := start; i < end; i++ {
+			ordered = ordered && (i == start || vs[i].group >= vs[i-1].group)
+			counts[vs[i].group]++
+		}
+		if !ordered {
+			found := vs[start].group
+			for i := start; i < end; i++ {
+				if counts[vs[i].group] > counts[found] {
+					found = vs[i].group
+				}
+			}
+			for i := start; i < end; i++ {
+				moved[i].group = found
+			}
+		}
+		start = end
+	}
+}
+
+// Arrange the imports of a file into groups, as repairs would.
+func (p *Processor) layout(fileName string, r io.Reader) ([][]ImportInfo, error) {
+	fset, tree, err := parseImports(fileName, r)
+	if err != nil {
+		return nil, err
+	}
+	gs, err := p.groupImports(fset, tree)
+	if err != nil {
+		return nil, err
+	}
+
+	p.unfixed(gs)
+	drop, _ := movedComments(gs)
+	groups := [][]ImportInfo{}
+	var group []ImportInfo
+	for _, g := range append(arrangeImports(gs), nil) {
+		if g == nil {
+			if len(group) > 0 {
+				groups = append(groups, group)
+			}
+			group = nil
+			continue
+		}
+		info := ImportInfo{Path: g.path, Name: importName(g.spec), Group: g.group, Kept: g.keep}
+		if p.fixesAliases() && g.wantAlias != "" {
+			info.Name = g.wantAlias
+		}
+		if g.doc != nil {
+			for _, c := range g.doc.List {
+				if !drop[c] {
+					info.Doc = append(info.Doc, c.Text)
+				}
+			}
+		}
+		if g.spec.Comment != nil {
+			texts := []string{}
+			for _, c := range g.spec.Comment.List {
+				texts = append(texts, c.Text)
+			}
+			info.Comment = strings.Join(texts, " ")
+		}
+		group = append(group, info)
+	}
+	return groups, nil
 }
 
 // Both reformat the file and fix the imports section.
@@ -116,9 +695,26 @@ func (p *Processor) reformat(fileName string, r io.Reader) (io.Reader, error) {
 		return nil, err
 	}
 
+	ignored, err := p.ignored(fileName, bytes.NewReader(src))
+	if err != nil || ignored {
+		return nil, err
+	}
+
+	stop := p.timings.Start(PhaseGoimports)
 	formatted, err := imports.Process(fileName, src, nil)
+	stop()
 	if err != nil {
-		return nil, err
+		return nil, parseError(fileName, src, err)
+	}
+	if debugging(p.logger) {
+		p.logger.Debug("goimports", "file", fileName, "changed", !bytes.Equal(src, formatted))
+	}
+	if p.minimalPatch {
+		// Throw away any formatting changes outside the imports.
+		formatted, err = spliceImportDecls(fileName, src, formatted)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	ret, err := p.repair(fileName, bytes.NewReader(formatted))
@@ -0,0 +1,981 @@
+package gogroup
+
+import (
+	"go/format"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepairBlock(t *testing.T) {
+	t.Parallel()
+
+	text := `package main
+
+// Doc comment.
+import (
+	"os"
+	"github.com/Sirupsen/logrus"
+	"fmt"
+)
+
+func main() {}
+`
+	proc := NewProcessor(grouperGoimports{})
+	block, err := proc.RepairBlock("", strings.NewReader(text))
+	assert.Nil(t, err)
+	assert.Equal(t, &ImportBlock{
+		StartLine: 5,
+		EndLine:   7,
+		Fixed:     []string{"\t\"fmt\"", "\t\"os\"", "", "\t\"github.com/Sirupsen/logrus\""},
+	}, block)
+
+	// Applying the block yields the same result as Repair.
+	lines := strings.Split(text, "\n")
+	applied := append(append(append([]string{}, lines[:block.StartLine-1]...),
+		block.Fixed...), lines[block.EndLine:]...)
+	r, err := proc.Repair("", strings.NewReader(text))
+	assert.Nil(t, err)
+	repaired, err := ioutil.ReadAll(r)
+	assert.Nil(t, err)
+	assert.Equal(t, string(repaired), strings.Join(applied, "\n"))
+
+	// Nothing to do for valid files.
+	block, err = proc.RepairBlock("", strings.NewReader("package main\nimport \"os\"\n"))
+	assert.Nil(t, err)
+	assert.Nil(t, block)
+}
+
+// Read everything from a reader, failing the test on error.
+func readAll(t *testing.T, r io.Reader) string {
+	assert.NotNil(t, r)
+	data, err := ioutil.ReadAll(r)
+	assert.Nil(t, err)
+	return string(data)
+}
+
+func TestMinimalPatch(t *testing.T) {
+	t.Parallel()
+
+	// Deliberately weird formatting outside the imports, which goimports would
+	// change.
+	text := "// Header  \r\npackage main\r\n\r\nimport (\r\n\t\"os\"\r\n\t\"fmt\"\r\n)\r\n\r\n" +
+		"type  T struct {\r\n\ta int\r\n\tbbbbb string\r\n}\r\n" +
+		"func main( ) { fmt.Println(os.Args) }"
+	want := "// Header  \r\npackage main\r\n\r\nimport (\r\n\t\"fmt\"\r\n\t\"os\"\r\n)\r\n\r\n" +
+		"type  T struct {\r\n\ta int\r\n\tbbbbb string\r\n}\r\n" +
+		"func main( ) { fmt.Println(os.Args) }"
+
+	proc := NewProcessor(grouperGoimports{}, MinimalPatch(true))
+	r, err := proc.Repair("main.go", strings.NewReader(text))
+	assert.Nil(t, err)
+	assert.Equal(t, want, readAll(t, r))
+
+	r, err = proc.Reformat("main.go", strings.NewReader(text))
+	assert.Nil(t, err)
+	assert.Equal(t, want, readAll(t, r))
+
+	// Without the option, goimports reformats the whole file.
+	r, err = NewProcessor(grouperGoimports{}).Reformat("main.go", strings.NewReader(text))
+	assert.Nil(t, err)
+	assert.Contains(t, readAll(t, r), "func main() { fmt.Println(os.Args) }")
+}
+
+func TestMinimalPatchGoimports(t *testing.T) {
+	t.Parallel()
+
+	proc := NewProcessor(grouperGoimports{}, MinimalPatch(true))
+
+	// Missing imports are added, but formatting is untouched.
+	text := "package main\n\nfunc main( ) { fmt.Println(os.Args) }\n"
+	r, err := proc.Reformat("main.go", strings.NewReader(text))
+	assert.Nil(t, err)
+	assert.Equal(t, "package main\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n\n"+
+		"func main( ) { fmt.Println(os.Args) }\n", readAll(t, r))
+
+	// Unused imports are removed.
+	text = "package main\n\nimport \"os\"\n\nfunc main( ) {}\n"
+	r, err = proc.Reformat("main.go", strings.NewReader(text))
+	assert.Nil(t, err)
+	assert.Equal(t, "package main\n\nfunc main( ) {}\n", readAll(t, r))
+}
+
+func TestRepairLongLines(t *testing.T) {
+	t.Parallel()
+
+	long := "var s = \"" + strings.Repeat("x", 1<<20) + "\""
+	text := "package main\n\nimport (\n\t\"os\"\n\t\"fmt\"\n)\n\n" + long + "\n"
+	r, err := NewProcessor(grouperGoimports{}).Repair("", strings.NewReader(text))
+	assert.Nil(t, err)
+	assert.Equal(t, "package main\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n\n"+long+"\n", readAll(t, r))
+}
+
+func TestRepairTrailingNewlines(t *testing.T) {
+	t.Parallel()
+
+	proc := NewProcessor(grouperGoimports{})
+	for _, suffix := range []string{"", "\n", "\n\n\n"} {
+		text := "package main\n\nimport (\n\t\"os\"\n\t\"fmt\"\n)\n\nvar x = 1" + suffix
+		r, err := proc.Repair("", strings.NewReader(text))
+		assert.Nil(t, err)
+		assert.Equal(t, "package main\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n\nvar x = 1"+suffix,
+			readAll(t, r), "suffix %q", suffix)
+
+		// With the import block at the end of the file.
+		text = "package main\n\nimport \"os\"\nimport \"fmt\"" + suffix
+		r, err = proc.Repair("", strings.NewReader(text))
+		assert.Nil(t, err)
+		assert.Equal(t, "package main\n\nimport \"fmt\"\nimport \"os\""+suffix,
+			readAll(t, r), "suffix %q", suffix)
+	}
+}
+
+func TestRepairPreservesBytes(t *testing.T) {
+	t.Parallel()
+
+	prefix := "// Header with trailing space \r\npackage main \r\n\r\n\t\r\nimport (\r\n"
+	suffix := ")\r\n\n\nfunc  main( ) {\t}   \r\n\r\n"
+	text := prefix + "\t\"os\"\r\n\t\"fmt\"\r\n" + suffix
+
+	r, err := NewProcessor(grouperGoimports{}).Repair("", strings.NewReader(text))
+	assert.Nil(t, err)
+	out := readAll(t, r)
+	assert.True(t, strings.HasPrefix(out, prefix))
+	assert.True(t, strings.HasSuffix(out, suffix))
+	assert.Equal(t, "\t\"fmt\"\r\n\t\"os\"\r\n", out[len(prefix):len(out)-len(suffix)])
+}
+
+func TestRepairKeepDirective(t *testing.T) {
+	t.Parallel()
+
+	proc := NewProcessor(grouperGoimports{})
+
+	// A kept import in the middle of a group stays put.
+	text := `package main
+
+import (
+	"strings"
+	"unsafe" //group-imports:keep must stay here
+	"os"
+	"fmt"
+)
+`
+	r, err := proc.Repair("", strings.NewReader(text))
+	assert.Nil(t, err)
+	fixed := readAll(t, r)
+	assert.Equal(t, `package main
+
+import (
+	"fmt"
+	"unsafe" //group-imports:keep must stay here
+	"os"
+	"strings"
+)
+`, fixed)
+	validErr, err := proc.Validate("", strings.NewReader(fixed))
+	assert.Nil(t, err)
+	assert.Nil(t, validErr)
+
+	// A kept import creates no violations on either side.
+	valid := `package main
+
+import (
+	"fmt"
+	"github.com/Sirupsen/logrus" //group-imports:keep
+	"os"
+
+	"golang.org/x/tools/imports"
+	"unsafe" //group-imports:keep
+)
+`
+	validErr, err = proc.Validate("", strings.NewReader(valid))
+	assert.Nil(t, err)
+	assert.Nil(t, validErr)
+
+	// Without directives, the import is grouped like any other.
+	proc = NewProcessor(grouperGoimports{}, IgnoreDirectives(true))
+	r, err = proc.Repair("", strings.NewReader(text))
+	assert.Nil(t, err)
+	assert.Equal(t, `package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unsafe" //group-imports:keep must stay here
+)
+`, readAll(t, r))
+}
+
+// Ignores imports of registration packages, which may go anywhere.
+type grouperIgnoring struct {
+	grouperGoimports
+}
+
+func (g grouperIgnoring) Group(pkg string) int {
+	if strings.HasSuffix(pkg, "/register") {
+		return GroupIgnore
+	}
+	return g.grouperGoimports.Group(pkg)
+}
+
+func TestRepairGroupIgnore(t *testing.T) {
+	t.Parallel()
+
+	// Ignored imports create no violations on either side, even between
+	// groups.
+	proc := NewProcessor(grouperIgnoring{}, IgnoreDirectives(true))
+	for _, valid := range []string{
+		"import (\n\t\"fmt\"\n\t\"example.com/a/register\"\n\t\"os\"\n)\n",
+		"import (\n\t\"os\"\n\t_ \"example.com/a/register\"\n\t\"github.com/pkg/errors\"\n)\n",
+		"import (\n\t\"os\"\n\n\t_ \"example.com/a/register\"\n\n\t\"github.com/pkg/errors\"\n)\n",
+	} {
+		validErr, err := proc.Validate("", strings.NewReader("package main\n\n"+valid))
+		assert.Nil(t, err, valid)
+		assert.Nil(t, validErr, valid)
+	}
+
+	// Repairs leave them in place, in the middle of a block.
+	r, err := proc.Repair("", strings.NewReader(`package main
+
+import (
+	"strings"
+	"github.com/pkg/errors"
+	_ "example.com/a/register"
+	"os"
+)
+`))
+	assert.Nil(t, err)
+	fixed := readAll(t, r)
+	assert.Equal(t, `package main
+
+import (
+	"os"
+	"strings"
+	_ "example.com/a/register"
+
+	"github.com/pkg/errors"
+)
+`, fixed)
+	validErr, err := proc.Validate("", strings.NewReader(fixed))
+	assert.Nil(t, err)
+	assert.Nil(t, validErr)
+}
+
+func TestRepairSortDepth(t *testing.T) {
+	t.Parallel()
+
+	text := `package main
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/corp/svc/internal/auth/tokens"
+	"github.com/corp/log"
+	"github.com/corp/svc"
+)
+`
+	fixed := `package main
+
+import (
+	"os"
+	"net/http"
+
+	"github.com/corp/log"
+	"github.com/corp/svc"
+	"github.com/corp/svc/internal/auth/tokens"
+)
+`
+
+	proc := NewProcessor(grouperGoimports{}, Sort(SortDepthThenAlpha))
+	validErr, err := proc.Validate("", strings.NewReader(text))
+	assert.Nil(t, err)
+	if assert.NotNil(t, validErr) {
+		assert.Equal(t, "statement-order", validErr.Rule)
+	}
+	r, err := proc.Repair("", strings.NewReader(text))
+	assert.Nil(t, err)
+	assert.Equal(t, fixed, readAll(t, r))
+
+	// Repaired files round-trip as valid.
+	validErr, err = proc.Validate("", strings.NewReader(fixed))
+	assert.Nil(t, err)
+	assert.Nil(t, validErr)
+
+	// But they're out of order alphabetically.
+	validErr, err = NewProcessor(grouperGoimports{}).Validate("", strings.NewReader(fixed))
+	assert.Nil(t, err)
+	assert.NotNil(t, validErr)
+}
+
+func TestParseSortMode(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range SortModeNames() {
+		mode, err := ParseSortMode(name)
+		assert.Nil(t, err)
+		assert.Equal(t, name, mode.String())
+	}
+	_, err := ParseSortMode("depth")
+	assert.EqualError(t, err, "Unknown sort mode 'depth', expected one of: alpha, depth-then-alpha, natural")
+}
+
+func TestRepairSortNatural(t *testing.T) {
+	t.Parallel()
+
+	text := `package main
+
+import (
+	"github.com/foo/lib/v10"
+	"github.com/foo/lib/v2"
+	"github.com/foo/lib10x"
+	"github.com/foo/lib2x"
+	"github.com/foo/lib"
+	"gopkg.in/redis.v10"
+	"gopkg.in/redis.v3"
+	"gopkg.in/redis.v003"
+)
+`
+	fixed := `package main
+
+import (
+	"github.com/foo/lib"
+	"github.com/foo/lib/v2"
+	"github.com/foo/lib/v10"
+	"github.com/foo/lib2x"
+	"github.com/foo/lib10x"
+	"gopkg.in/redis.v003"
+	"gopkg.in/redis.v3"
+	"gopkg.in/redis.v10"
+)
+`
+
+	proc := NewProcessor(grouperCombined{}, Sort(SortNatural))
+	validErr, err := proc.Validate("", strings.NewReader(text))
+	assert.Nil(t, err)
+	if assert.NotNil(t, validErr) {
+		assert.Equal(t, "statement-order", validErr.Rule)
+	}
+	r, err := proc.Repair("", strings.NewReader(text))
+	assert.Nil(t, err)
+	assert.Equal(t, fixed, readAll(t, r))
+
+	validErr, err = proc.Validate("", strings.NewReader(fixed))
+	assert.Nil(t, err)
+	assert.Nil(t, validErr)
+
+	// Alphabetically, the versions are out of order.
+	validErr, err = NewProcessor(grouperCombined{}).Validate("", strings.NewReader(fixed))
+	assert.Nil(t, err)
+	assert.NotNil(t, validErr)
+}
+
+func TestRepairBlocks(t *testing.T) {
+	t.Parallel()
+
+	paren := NewProcessor(grouperGoimports{}, Blocks(BlockParenthesized))
+	plain := NewProcessor(grouperGoimports{}, Blocks(BlockPlain))
+	for _, c := range []struct {
+		proc       *Processor
+		text, rule string
+		line       int
+		fixed      string
+	}{
+		{
+			paren,
+			"// Logs.\nimport l \"log\" // logging\n", "block-parenthesized", 3,
+			"import (\n\t// Logs.\n\tl \"log\" // logging\n)\n",
+		},
+		{
+			paren,
+			"import \"os\"\nimport \"fmt\"\n", "block-parenthesized", 3,
+			"import (\n\t\"fmt\"\n\t\"os\"\n)\n",
+		},
+		{
+			plain,
+			"import (\n\t// Logs.\n\tl \"log\" // logging\n)\n", "block-plain", 4,
+			"// Logs.\nimport l \"log\" // logging\n",
+		},
+	} {
+		text := "package main\n\n" + c.text + "\nfunc main() {}\n"
+		errs, err := c.proc.ValidateAll("", strings.NewReader(text))
+		assert.Nil(t, err, c.text)
+		if assert.NotEmpty(t, errs, c.text) {
+			assert.Equal(t, c.rule, errs[0].Rule, c.text)
+			assert.Equal(t, c.line, errs[0].Line, c.text)
+		}
+
+		r, err := c.proc.Repair("", strings.NewReader(text))
+		assert.Nil(t, err, c.text)
+		fixed := readAll(t, r)
+		assert.Equal(t, "package main\n\n"+c.fixed+"\nfunc main() {}\n", fixed, c.text)
+		validErr, err := c.proc.Validate("", strings.NewReader(fixed))
+		assert.Nil(t, err, c.text)
+		assert.Nil(t, validErr, c.text)
+	}
+
+	// Several imports may be parenthesized even in the plain style, and files
+	// without imports, or that import "C", satisfy every style.
+	for _, text := range []string{
+		"import (\n\t\"fmt\"\n\t\"os\"\n)\n",
+		"",
+		"// #include <stdio.h>\nimport \"C\"\n",
+	} {
+		validErr, err := plain.Validate("", strings.NewReader("package main\n\n"+text))
+		assert.Nil(t, err, text)
+		assert.Nil(t, validErr, text)
+	}
+	for _, text := range []string{"", "// #include <stdio.h>\nimport \"C\"\n"} {
+		validErr, err := paren.Validate("", strings.NewReader("package main\n\n"+text))
+		assert.Nil(t, err, text)
+		assert.Nil(t, validErr, text)
+	}
+}
+
+func TestRepairFixOnly(t *testing.T) {
+	t.Parallel()
+
+	src := "import (\n\t\"os\"\n\t\"github.com/x/y\"\n\t\"fmt\"\n\n\t\"bytes\"\n\n\t\"github.com/a/b\"\n)\n"
+	for _, c := range []struct {
+		opts  []Option
+		fixed string
+		left  []string
+	}{
+		{
+			nil,
+			"import (\n\t\"bytes\"\n\t\"fmt\"\n\t\"os\"\n\n\t\"github.com/a/b\"\n\t\"github.com/x/y\"\n)\n",
+			[]string{},
+		},
+		{
+			// The import in the wrong group stays in the group it's found in,
+			// which is sorted, and joined by the rest of its group.
+			[]Option{NoFix("statement-group")},
+			"import (\n\t\"bytes\"\n\t\"fmt\"\n\t\"github.com/x/y\"\n\t\"os\"\n\n\t\"github.com/a/b\"\n)\n",
+			[]string{"GI004", "GI004"},
+		},
+		{
+			// The file has no violations of the rule, so it's left alone.
+			[]Option{FixOnly("statement-order")},
+			"",
+			[]string{"GI004", "GI004", "GI003"},
+		},
+		{
+			[]Option{NoFix("GI004", "GI001")},
+			"import (\n\t\"os\"\n\t\"github.com/x/y\"\n\t\"fmt\"\n\t\"bytes\"\n\n\t\"github.com/a/b\"\n)\n",
+			[]string{"GI004", "GI004", "GI001"},
+		},
+		{
+			[]Option{FixOnly("statement-group")},
+			"import (\n\t\"os\"\n\t\"fmt\"\n\t\"bytes\"\n\n\t\"github.com/x/y\"\n\t\"github.com/a/b\"\n)\n",
+			[]string{"GI001", "GI001", "GI001"},
+		},
+		{
+			// Disabled rules aren't fixed either.
+			[]Option{FixOnly("statement-group", "statement-order"), DisableRules("statement-order")},
+			"import (\n\t\"os\"\n\t\"fmt\"\n\t\"bytes\"\n\n\t\"github.com/x/y\"\n\t\"github.com/a/b\"\n)\n",
+			[]string{},
+		},
+	} {
+		proc := NewProcessor(grouperGoimports{}, c.opts...)
+		text := "package main\n\n" + src
+		r, err := proc.Repair("", strings.NewReader(text))
+		assert.Nil(t, err)
+		fixed := text
+		if c.fixed == "" {
+			assert.Nil(t, r)
+		} else {
+			fixed = readAll(t, r)
+			assert.Equal(t, "package main\n\n"+c.fixed, fixed)
+		}
+
+		// Violations that weren't fixed are still reported.
+		errs, err := proc.ValidateAll("", strings.NewReader(fixed))
+		assert.Nil(t, err)
+		ids := []string{}
+		for _, validErr := range errs {
+			ids = append(ids, validErr.ID())
+		}
+		assert.Equal(t, c.left, ids, c.fixed)
+	}
+
+	// Repeated imports are kept without statement-duplicate, and aliases
+	// aren't fixed without statement-alias.
+	proc := NewProcessor(grouperGoimports{}, Duplicates(true), NoFix("statement-duplicate", "GI015"),
+		Aliases([]AliasRule{{Pattern: regexp.MustCompile(`^gopkg\.in/yaml`), Alias: "yaml"}}, nil),
+		FixAliases(true))
+	r, err := proc.Repair("", strings.NewReader(
+		"package main\n\nimport (\n\t\"os\"\n\tgoyaml \"gopkg.in/yaml.v2\"\n\t\"fmt\"\n\t\"os\"\n)\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, "package main\n\nimport (\n\t\"fmt\"\n\t\"os\"\n\t\"os\"\n\n\tgoyaml \"gopkg.in/yaml.v2\"\n)\n",
+		readAll(t, r))
+}
+
+func TestRepairSameLine(t *testing.T) {
+	t.Parallel()
+
+	proc := NewProcessor(grouperGoimports{})
+	for _, c := range []struct{ text, fixed string }{
+		{
+			"import ( \"os\"; \"fmt\" )\n",
+			"import (\n\t\"fmt\"\n\t\"os\"\n)\n",
+		},
+		{
+			"import \"os\"; import \"fmt\";\n",
+			"import \"fmt\"\nimport \"os\"\n",
+		},
+		{
+			"import (\n\t\"os\"; \"fmt\"\n\t\"github.com/pkg/errors\" // errors\n)\n",
+			"import (\n\t\"fmt\"\n\t\"os\"\n\n\t\"github.com/pkg/errors\" // errors\n)\n",
+		},
+		{
+			"import (\"os\"\n\t\"fmt\")\n",
+			"import (\n\t\"fmt\"\n\t\"os\"\n)\n",
+		},
+	} {
+		text := "package main\n\n" + c.text + "\nfunc main() {}\n"
+		r, err := proc.Repair("", strings.NewReader(text))
+		assert.Nil(t, err, c.text)
+		if !assert.NotNil(t, r, c.text) {
+			continue
+		}
+		fixed := readAll(t, r)
+		assert.Equal(t, "package main\n\n"+c.fixed+"\nfunc main() {}\n", fixed, c.text)
+
+		validErr, err := proc.Validate("", strings.NewReader(fixed))
+		assert.Nil(t, err)
+		assert.Nil(t, validErr, c.text)
+	}
+}
+
+func TestRepairEdgeCases(t *testing.T) {
+	t.Parallel()
+
+	proc := NewProcessor(grouperGoimports{})
+	for _, c := range []struct{ text, fixed string }{
+		// Comments not attached to an import move with the next one, or stay
+		// at the end.
+		{
+			"import (\n\t\"os\"\n\n\t// Floating.\n\n\t\"github.com/pkg/errors\"\n\t\"fmt\"\n\t// Trailing.\n)\n",
+			"import (\n\t\"fmt\"\n\t\"os\"\n\n\t// Floating.\n\t\"github.com/pkg/errors\"\n\t// Trailing.\n)\n",
+		},
+		// Multiple declarations are merged.
+		{
+			"import (\n\t\"os\"\n)\n\n// Doc.\nimport (\n\t\"fmt\"\n)\n",
+			"import (\n\t// Doc.\n\t\"fmt\"\n\t\"os\"\n)\n",
+		},
+		// Unparenthesized imports keep their doc and line comments.
+		{
+			"// Doc.\nimport \"os\" // c\nimport \"fmt\" // d\n",
+			"import \"fmt\" // d\n// Doc.\nimport \"os\" // c\n",
+		},
+		// Line directives don't affect which lines are replaced.
+		{
+			"//line other.go:100\nimport (\n\t\"os\"\n\t\"fmt\"\n)\n",
+			"//line other.go:100\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n",
+		},
+		// Unusual whitespace is formatted.
+		{
+			"import (\n    \"os\"   // spaces\n      \"fmt\"\t// tab\n)\n",
+			"import (\n\t\"fmt\" // tab\n\t\"os\"  // spaces\n)\n",
+		},
+		// Multi-line comments stay intact.
+		{
+			"import (\n\t\"os\"\n\t/* Multi\n\t   line */\n\t\"fmt\"\n)\n",
+			"import (\n\t/* Multi\n\t   line */\n\t\"fmt\"\n\t\"os\"\n)\n",
+		},
+	} {
+		text := "package main\n\n" + c.text + "\nfunc main() {}\n"
+		r, err := proc.Repair("", strings.NewReader(text))
+		assert.Nil(t, err, c.text)
+		if !assert.NotNil(t, r, c.text) {
+			continue
+		}
+		fixed := readAll(t, r)
+		assert.Equal(t, "package main\n\n"+c.fixed+"\nfunc main() {}\n", fixed, c.text)
+
+		validErr, err := proc.Validate("", strings.NewReader(fixed))
+		assert.Nil(t, err)
+		assert.Nil(t, validErr, c.text)
+	}
+}
+
+func TestRepairFileComments(t *testing.T) {
+	t.Parallel()
+
+	proc := NewProcessor(grouperGoimports{})
+	for _, c := range []struct{ text, fixed string }{
+		// Comments above the import keyword stay there.
+		{
+			"// Copyright 2020 Corp.\n// All rights reserved.\nimport (\n\t\"os\"\n\t\"fmt\"\n)\n",
+			"// Copyright 2020 Corp.\n// All rights reserved.\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n",
+		},
+		// So do comments separated from the first import by an empty line.
+		{
+			"import (\n\t// TODO: trim these imports.\n\n\t\"os\"\n\t\"fmt\"\n)\n",
+			"import (\n\t// TODO: trim these imports.\n\n\t\"fmt\"\n\t\"os\"\n)\n",
+		},
+		{
+			"import (\n\t// TODO: trim these imports.\n\n\t// os is for exit.\n\t\"os\"\n\t\"fmt\"\n)\n",
+			"import (\n\t// TODO: trim these imports.\n\n\t\"fmt\"\n\t// os is for exit.\n\t\"os\"\n)\n",
+		},
+		// Doc comments of imports travel with them.
+		{
+			"import (\n\t// os is for exit.\n\t\"os\"\n\t\"fmt\"\n)\n",
+			"import (\n\t\"fmt\"\n\t// os is for exit.\n\t\"os\"\n)\n",
+		},
+	} {
+		text := "package main\n\n" + c.text + "\nfunc main() {}\n"
+		r, err := proc.Repair("", strings.NewReader(text))
+		assert.Nil(t, err, c.text)
+		if !assert.NotNil(t, r, c.text) {
+			continue
+		}
+		fixed := readAll(t, r)
+		assert.Equal(t, "package main\n\n"+c.fixed+"\nfunc main() {}\n", fixed, c.text)
+
+		validErr, err := proc.Validate("", strings.NewReader(fixed))
+		assert.Nil(t, err)
+		assert.Nil(t, validErr, c.text)
+	}
+}
+
+func TestRepairCgo(t *testing.T) {
+	t.Parallel()
+
+	proc := NewProcessor(grouperGoimports{})
+	for _, c := range []struct{ text, fixed string }{
+		// The declaration of "C" keeps its preamble, and isn't merged.
+		{
+			"// #include <stdio.h>\nimport \"C\"\n\nimport (\n\t\"os\"\n\t\"fmt\"\n)\n",
+			"// #include <stdio.h>\nimport \"C\"\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n",
+		},
+		{
+			"import (\n\t\"os\"\n\t\"fmt\"\n)\n\n/*\n#include <stdio.h>\n*/\nimport \"C\" // cgo\n",
+			"import (\n\t\"fmt\"\n\t\"os\"\n)\n\n/*\n#include <stdio.h>\n*/\nimport \"C\" // cgo\n",
+		},
+		// Between other declarations, it goes before them.
+		{
+			"import \"os\"\n\n//#include   <stdio.h>\nimport \"C\"\n\nimport \"fmt\"\n",
+			"//#include   <stdio.h>\nimport \"C\"\n\nimport \"fmt\"\nimport \"os\"\n",
+		},
+	} {
+		text := "package main\n\n" + c.text + "\nfunc main() {}\n"
+		r, err := proc.Repair("", strings.NewReader(text))
+		assert.Nil(t, err, c.text)
+		if !assert.NotNil(t, r, c.text) {
+			continue
+		}
+		fixed := readAll(t, r)
+		assert.Equal(t, "package main\n\n"+c.fixed+"\nfunc main() {}\n", fixed, c.text)
+
+		validErr, err := proc.Validate("", strings.NewReader(fixed))
+		assert.Nil(t, err)
+		assert.Nil(t, validErr, c.text)
+	}
+
+	// A lone declaration of "C" needs no repair.
+	r, err := proc.Repair("", strings.NewReader("package main\n\n// #include <stdio.h>\nimport \"C\"\n"))
+	assert.Nil(t, err)
+	assert.Nil(t, r)
+}
+
+func TestRepairAlignsComments(t *testing.T) {
+	t.Parallel()
+
+	proc := NewProcessor(grouperGoimports{})
+	for _, c := range []struct{ text, fixed string }{
+		{
+			"import (\n\t\"strings\"      // strings\n\t\"fmt\"          // formatting\n" +
+				"\t\"github.com/pkg/errors\" // errors\n\t\"os\"           // os\n)\n",
+			"import (\n\t\"fmt\"     // formatting\n\t\"os\"      // os\n\t\"strings\" // strings\n\n" +
+				"\t\"github.com/pkg/errors\" // errors\n)\n",
+		},
+		// Imports without comments, or with names, are aligned like gofmt.
+		{
+			"import (\n\t\"strings\" // strings\n\tx \"io\"\n\t\"fmt\"     /* a */   // b\n\t\"os\"\n)\n",
+			"import (\n\t\"fmt\" /* a */ // b\n\tx \"io\"\n\t\"os\"\n\t\"strings\" // strings\n)\n",
+		},
+		// Separate declarations are aligned too.
+		{
+			"import \"strings\"      // strings\nimport \"fmt\" // formatting\n",
+			"import \"fmt\"     // formatting\nimport \"strings\" // strings\n",
+		},
+	} {
+		text := "package main\n\n" + c.text + "\nfunc main() {}\n"
+		r, err := proc.Repair("", strings.NewReader(text))
+		assert.Nil(t, err, c.text)
+		fixed := readAll(t, r)
+		assert.Equal(t, "package main\n\n"+c.fixed+"\nfunc main() {}\n", fixed, c.text)
+
+		// The output is stable under gofmt.
+		formatted, err := format.Source([]byte(fixed))
+		assert.Nil(t, err)
+		assert.Equal(t, fixed, string(formatted), c.text)
+	}
+}
+
+func TestRepairHeaders(t *testing.T) {
+	t.Parallel()
+
+	proc := NewProcessor(grouperGoimports{}, GroupHeaders(map[int]string{
+		0: "// Standard library",
+		1: "// Third-party",
+	}))
+	for _, c := range []struct{ text, fixed string }{
+		// Missing headers are inserted, above any doc comments.
+		{
+			"import (\n\t\"os\"\n\t// Doc.\n\t\"github.com/pkg/errors\"\n\t\"fmt\"\n\t\"local/pkg\"\n)\n",
+			"import (\n\t// Standard library\n\t\"fmt\"\n\t\"os\"\n\n\t// Third-party\n\t// Doc.\n" +
+				"\t\"github.com/pkg/errors\"\n\n\t\"local/pkg\"\n)\n",
+		},
+		// Headers move to the start of their group, and wrong ones are fixed.
+		{
+			"import (\n\t// Third-party\n\t\"os\"\n\t// Standard library\n\t// Doc.\n\t\"fmt\"\n\n" +
+				"\t\"github.com/pkg/errors\"\n)\n",
+			"import (\n\t// Standard library\n\t// Doc.\n\t\"fmt\"\n\t\"os\"\n\n\t// Third-party\n" +
+				"\t\"github.com/pkg/errors\"\n)\n",
+		},
+		// Plain declarations get headers too.
+		{
+			"import \"os\"\n",
+			"// Standard library\nimport \"os\"\n",
+		},
+	} {
+		text := "package main\n\n" + c.text + "\nfunc main() {}\n"
+		r, err := proc.Repair("", strings.NewReader(text))
+		assert.Nil(t, err, c.text)
+		fixed := readAll(t, r)
+		assert.Equal(t, "package main\n\n"+c.fixed+"\nfunc main() {}\n", fixed, c.text)
+
+		validErr, err := proc.Validate("", strings.NewReader(fixed))
+		assert.Nil(t, err)
+		assert.Nil(t, validErr, c.text)
+	}
+}
+
+func TestRepairCommentSeparators(t *testing.T) {
+	t.Parallel()
+
+	proc := NewProcessor(grouperGoimports{}, CommentSeparators(true))
+	for _, c := range []struct{ text, fixed string }{
+		// Separators stay between the same groups as imports are sorted.
+		{
+			"import (\n\t\"os\"\n\t// --- third party ---\n\t\"github.com/x/y\"\n\t\"fmt\"\n\t\"github.com/a/b\"\n" +
+				"\t// --- local ---\n\t\"local/pkg\"\n)\n",
+			"import (\n\t\"fmt\"\n\t\"os\"\n\t// --- third party ---\n\t\"github.com/a/b\"\n\t\"github.com/x/y\"\n" +
+				"\t// --- local ---\n\t\"local/pkg\"\n)\n",
+		},
+		// Mixed separators keep their empty lines, and groups without a
+		// separator get an empty line.
+		{
+			"import (\n\t\"local/pkg\"\n\n\t// --- third party ---\n\t\"github.com/x/y\"\n\t\"os\"\n)\n",
+			"import (\n\t\"os\"\n\n\t// --- third party ---\n\t\"github.com/x/y\"\n\n\t\"local/pkg\"\n)\n",
+		},
+		// A separator before a group that ends up first stays before it.
+		{
+			"import (\n\t\"github.com/x/y\"\n\t// --- std ---\n\t\"os\"\n)\n",
+			"import (\n\t// --- std ---\n\t\"os\"\n\n\t\"github.com/x/y\"\n)\n",
+		},
+	} {
+		text := "package main\n\n" + c.text + "\nfunc main() {}\n"
+		r, err := proc.Repair("", strings.NewReader(text))
+		assert.Nil(t, err, c.text)
+		fixed := readAll(t, r)
+		assert.Equal(t, "package main\n\n"+c.fixed+"\nfunc main() {}\n", fixed, c.text)
+
+		validErr, err := proc.Validate("", strings.NewReader(fixed))
+		assert.Nil(t, err)
+		assert.Nil(t, validErr, c.text)
+	}
+
+	// Headers go below separators, so repairing again changes nothing.
+	headed := NewProcessor(grouperGoimports{}, CommentSeparators(true), GroupHeaders(map[int]string{1: "// other"}))
+	text := "package main\n\nimport (\n\t\"os\"\n\t// --- third party ---\n\t\"github.com/x/y\"\n)\n"
+	r, err := headed.Repair("", strings.NewReader(text))
+	assert.Nil(t, err)
+	fixed := readAll(t, r)
+	assert.Equal(t, "package main\n\nimport (\n\t\"os\"\n\t// --- third party ---\n\t// other\n"+
+		"\t\"github.com/x/y\"\n)\n", fixed)
+	validErr, err := headed.Validate("", strings.NewReader(fixed))
+	assert.Nil(t, err)
+	assert.Nil(t, validErr)
+	r, err = headed.Repair("", strings.NewReader(fixed))
+	assert.Nil(t, err)
+	assert.Nil(t, r)
+
+	// Without the option, separators are just comments on the next import.
+	r, err = NewProcessor(grouperGoimports{}).Repair("", strings.NewReader(
+		"package main\n\nimport (\n\t\"os\"\n\t// --- third party ---\n\t\"github.com/x/y\"\n)\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, "package main\n\nimport (\n\t\"os\"\n\n\t// --- third party ---\n\t\"github.com/x/y\"\n)\n",
+		readAll(t, r))
+}
+
+func TestRepairStripComments(t *testing.T) {
+	t.Parallel()
+
+	proc := NewProcessor(grouperGoimports{}, StripComments(regexp.MustCompile(`^// ---`)))
+	for _, c := range []struct {
+		text, fixed string
+		lines       []int
+	}{
+		// Banners are stripped, whether or not they're attached to an import.
+		{
+			"import (\n\t// --- std ---\n\t\"os\"\n\n\t// --- 3rd party ---\n\n\t\"github.com/pkg/errors\"\n" +
+				"\t// --- end ---\n)\n",
+			"import (\n\t\"os\"\n\n\t\"github.com/pkg/errors\"\n)\n",
+			[]int{4, 7, 10},
+		},
+		// Doc comments stay, even if part of them matches.
+		{
+			"import (\n\t\"os\"\n\n\t// --- 3rd party ---\n\t// Doc.\n\t// --- not a banner\n" +
+				"\t\"github.com/pkg/errors\" // --- line comment\n)\n",
+			"import (\n\t\"os\"\n\n\t// Doc.\n\t// --- not a banner\n" +
+				"\t\"github.com/pkg/errors\" // --- line comment\n)\n",
+			[]int{6},
+		},
+		// Stripping happens before regrouping.
+		{
+			"import (\n\t\"os\"\n\t// --- 3rd party ---\n\t\"github.com/pkg/errors\"\n\t\"fmt\"\n)\n",
+			"import (\n\t\"fmt\"\n\t\"os\"\n\n\t\"github.com/pkg/errors\"\n)\n",
+			[]int{5},
+		},
+	} {
+		text := "package main\n\n" + c.text + "\nfunc main() {}\n"
+		errs, err := proc.ValidateAll("", strings.NewReader(text))
+		assert.Nil(t, err)
+		lines := []int{}
+		for _, validErr := range errs {
+			if validErr.Rule == "comment-stripped" {
+				lines = append(lines, validErr.Line)
+			}
+		}
+		assert.Equal(t, c.lines, lines, c.text)
+
+		r, err := proc.Repair("", strings.NewReader(text))
+		assert.Nil(t, err, c.text)
+		fixed := readAll(t, r)
+		assert.Equal(t, "package main\n\n"+c.fixed+"\nfunc main() {}\n", fixed, c.text)
+
+		validErr, err := proc.Validate("", strings.NewReader(fixed))
+		assert.Nil(t, err)
+		assert.Nil(t, validErr, c.text)
+	}
+
+	// Without a pattern, nothing is stripped.
+	text := "package main\n\nimport (\n\t\"os\"\n\n\t// --- 3rd party ---\n\t\"github.com/pkg/errors\"\n)\n"
+	validErr, err := NewProcessor(grouperGoimports{}).Validate("", strings.NewReader(text))
+	assert.Nil(t, err)
+	assert.Nil(t, validErr)
+}
+
+func TestSource(t *testing.T) {
+	t.Parallel()
+
+	src := []byte("package main\n\nimport (\n\t\"github.com/pkg/errors\"\n\t\"os\"\n\t\"local/foo\"\n)\n")
+	fixed, err := Source(src)
+	assert.Nil(t, err)
+	assert.Equal(t, "package main\n\nimport (\n\t\"local/foo\"\n\t\"os\"\n\n\t\"github.com/pkg/errors\"\n)\n",
+		string(fixed))
+
+	fixed, err = Source(src, WithGrouper(grouperGoimports{}))
+	assert.Nil(t, err)
+	assert.Equal(t, "package main\n\nimport (\n\t\"os\"\n\n\t\"github.com/pkg/errors\"\n\n\t\"local/foo\"\n)\n",
+		string(fixed))
+
+	// Correct source is returned unchanged.
+	again, err := Source(fixed, WithGrouper(grouperGoimports{}))
+	assert.Nil(t, err)
+	assert.Equal(t, fixed, again)
+
+	_, err = Source([]byte("package main\nimport (\n"))
+	assert.IsType(t, &ParseError{}, err)
+}
+
+func TestLayout(t *testing.T) {
+	t.Parallel()
+
+	text := `package main
+
+import (
+	"os"
+	// Logging.
+	"github.com/Sirupsen/logrus" // For logs.
+	"fmt"
+	_ "local/driver"
+	"os"
+	yml "gopkg.in/yaml.v2"
+)
+
+func main() {}
+`
+	rules := []AliasRule{{Pattern: regexp.MustCompile(`^gopkg\.in/yaml`), Alias: "yaml"}}
+	proc := NewProcessor(grouperGoimports{}, Aliases(rules, nil), FixAliases(true), Duplicates(true))
+	layout, err := proc.Layout("", strings.NewReader(text))
+	assert.Nil(t, err)
+	assert.Equal(t, [][]ImportInfo{
+		{{Path: "fmt"}, {Path: "os"}},
+		{
+			{Path: "github.com/Sirupsen/logrus", Group: 1, Doc: []string{"// Logging."}, Comment: "// For logs."},
+			{Path: "gopkg.in/yaml.v2", Name: "yaml", Group: 1},
+		},
+		{{Path: "local/driver", Name: "_", Group: 3}},
+	}, layout)
+
+	// The layout matches the imports of the repaired file, line by line.
+	r, err := proc.Repair("", strings.NewReader(text))
+	assert.Nil(t, err)
+	repaired := readAll(t, r)
+	start := strings.Index(repaired, "import (\n") + len("import (\n")
+	block := repaired[start : start+strings.Index(repaired[start:], "\n)")]
+	expected := []string{}
+	for i, group := range layout {
+		if i > 0 {
+			expected = append(expected, "")
+		}
+		for _, info := range group {
+			expected = append(expected, info.Doc...)
+			line := strings.TrimSpace(info.Name + " " + strconv.Quote(info.Path) + " " + info.Comment)
+			expected = append(expected, line)
+		}
+	}
+	actual := []string{}
+	for _, line := range strings.Split(block, "\n") {
+		actual = append(actual, strings.Join(strings.Fields(line), " "))
+	}
+	assert.Equal(t, expected, actual)
+
+	// Layouts don't depend on whether repairs are needed.
+	again, err := proc.Layout("", strings.NewReader(repaired))
+	assert.Nil(t, err)
+	assert.Equal(t, layout, again)
+
+	layout, err = proc.Layout("", strings.NewReader("//group-imports:ignore\npackage main\nimport \"os\"\n"))
+	assert.Nil(t, err)
+	assert.Empty(t, layout)
+}
+
+func TestRepairIndentation(t *testing.T) {
+	t.Parallel()
+
+	text := "package main\n\nimport (\n        \"os\"\n\t\t\"fmt\" // For printing.\n  x \"strings\"\n" +
+		"    // Logging.\n    \"github.com/Sirupsen/logrus\"\n)\n"
+
+	// Import lines are indented as gofmt would, including their comments.
+	r, err := NewProcessor(grouperGoimports{}).Repair("", strings.NewReader(text))
+	assert.Nil(t, err)
+	assert.Equal(t, "package main\n\nimport (\n\t\"fmt\" // For printing.\n\t\"os\"\n\tx \"strings\"\n\n"+
+		"\t// Logging.\n\t\"github.com/Sirupsen/logrus\"\n)\n", readAll(t, r))
+
+	// Or they keep their indentation.
+	r, err = NewProcessor(grouperGoimports{}, KeepIndentation(true)).Repair("", strings.NewReader(text))
+	assert.Nil(t, err)
+	assert.Equal(t, "package main\n\nimport (\n\t\t\"fmt\" // For printing.\n        \"os\"\n  x \"strings\"\n\n"+
+		"    // Logging.\n    \"github.com/Sirupsen/logrus\"\n)\n", readAll(t, r))
+}
@@ -0,0 +1,504 @@
+package gogroup
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Report is the result of processing multiple files. It holds the violations,
+// rewrites, skipped files and errors of each file, with methods to count and
+// write them. Reports from ProcessFiles list files in the order they were
+// given, and others can be combined with Merge and put in a fixed order with
+// DeterministicSort.
+type Report struct {
+	// Files are the results for each file, in the order they were given.
+	Files []*FileResult
+}
+
+// Merge adds the files of other reports to the end of r, such as to combine
+// the reports of workers that processed files in parallel.
+func (r *Report) Merge(others ...*Report) {
+	for _, other := range others {
+		r.Files = append(r.Files, other.Files...)
+	}
+}
+
+// DeterministicSort puts the files of a report in order of path, and the
+// violations of each file in order of line, so that output written from it
+// doesn't depend on how it was put together. Ties keep their relative order,
+// and each file's first violation is updated to match.
+func (r *Report) DeterministicSort() {
+	sort.SliceStable(r.Files, func(i, j int) bool {
+		return r.Files[i].Path < r.Files[j].Path
+	})
+	for _, f := range r.Files {
+		vs := f.Violations
+		sort.SliceStable(vs, func(i, j int) bool {
+			if vs[i].Line != vs[j].Line {
+				return vs[i].Line < vs[j].Line
+			}
+			return vs[i].Rule < vs[j].Rule
+		})
+		if len(vs) > 0 {
+			f.Violation = vs[0]
+		}
+	}
+}
+
+// KeepLines drops the violations that aren't on the given lines of their
+// file, such as the lines a change touched, keyed by path. Files without
+// lines keep no violations. Each file's first violation is updated to match,
+// and files left without violations have no Fix.
+func (r *Report) KeepLines(lines map[string][]int) {
+	for _, f := range r.Files {
+		wanted := map[int]bool{}
+		for _, line := range lines[f.Path] {
+			wanted[line] = true
+		}
+		kept := []*ValidationError{}
+		for _, v := range f.Violations {
+			if wanted[v.Line] {
+				kept = append(kept, v)
+			}
+		}
+		if len(kept) == len(f.Violations) {
+			continue
+		}
+		f.Violations, f.Violation = kept, nil
+		if len(kept) > 0 {
+			f.Violation = kept[0]
+		} else {
+			f.Fix = nil
+		}
+	}
+}
+
+// HasViolations determines whether any file had incorrect import grouping.
+// When rewriting, violations that were fixed still count.
+func (r *Report) HasViolations() bool {
+	return r.Violations() > 0
+}
+
+// HasViolationsAt determines whether any file had a violation at least as
+// serious as the given severity, such as any error.
+func (r *Report) HasViolationsAt(s Severity) bool {
+	for _, f := range r.Files {
+		for _, v := range f.Violations {
+			if v.Severity.AtLeast(s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasErrors determines whether any file could not be processed.
+func (r *Report) HasErrors() bool {
+	return r.Errors() > 0
+}
+
+// Violations counts the import grouping violations in all files.
+func (r *Report) Violations() int {
+	n := 0
+	for _, f := range r.Files {
+		n += len(f.Violations)
+	}
+	return n
+}
+
+// ViolationsByRule counts the import grouping violations in all files, for
+// each rule.
+func (r *Report) ViolationsByRule() map[string]int {
+	counts := map[string]int{}
+	for _, f := range r.Files {
+		for _, v := range f.Violations {
+			counts[v.Rule]++
+		}
+	}
+	return counts
+}
+
+// ViolationsBySeverity counts the import grouping violations in all files,
+// for each severity.
+func (r *Report) ViolationsBySeverity() map[string]int {
+	counts := map[string]int{}
+	for _, f := range r.Files {
+		for _, v := range f.Violations {
+			counts[v.Severity.String()]++
+		}
+	}
+	return counts
+}
+
+// Baselined counts the violations in all files that a Baseline tolerates.
+func (r *Report) Baselined() int {
+	n := 0
+	for _, f := range r.Files {
+		n += len(f.Baselined)
+	}
+	return n
+}
+
+// Suppressed counts the violations in all files that comments suppress.
+func (r *Report) Suppressed() int {
+	n := 0
+	for _, f := range r.Files {
+		n += len(f.Suppressed)
+	}
+	return n
+}
+
+// Changed counts the files that were rewritten.
+func (r *Report) Changed() int {
+	n := 0
+	for _, f := range r.Files {
+		if f.Changed {
+			n++
+		}
+	}
+	return n
+}
+
+// Skipped counts the files that were skipped.
+func (r *Report) Skipped() int {
+	n := 0
+	for _, f := range r.Files {
+		if f.Skipped {
+			n++
+		}
+	}
+	return n
+}
+
+// SkippedByReason counts the files that were skipped, for each reason.
+func (r *Report) SkippedByReason() map[string]int {
+	counts := map[string]int{}
+	for _, f := range r.Files {
+		if f.Skipped {
+			counts[f.SkipReason]++
+		}
+	}
+	return counts
+}
+
+// Errors counts the files that could not be processed.
+func (r *Report) Errors() int {
+	n := 0
+	for _, f := range r.Files {
+		if f.Err != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// Truncated yields a copy of the report that keeps only the first max
+// violations, along with the number of violations that were left out. Files
+// with none of their violations kept are left out, and those with only some
+// kept are copied with just those. Files without violations are always kept.
+func (r *Report) Truncated(max int) (*Report, int) {
+	ret := &Report{}
+	shown, omitted := 0, 0
+	for _, f := range r.Files {
+		if vs := resultViolations(f); len(vs) > 0 {
+			keep := max - shown
+			if keep <= 0 {
+				omitted += len(vs)
+				continue
+			}
+			if keep < len(vs) {
+				omitted += len(vs) - keep
+				vs = vs[:keep]
+				partial := *f
+				partial.Violation, partial.Violations = vs[0], vs
+				f = &partial
+			}
+			shown += len(vs)
+		}
+		ret.Files = append(ret.Files, f)
+	}
+	return ret, omitted
+}
+
+// Write writes the violations in a report to w, in the named format. See
+// FormatNames for the available formats, and WriteTemplate or RegisterFormat
+// for custom formats.
+func (r *Report) Write(w io.Writer, format string) error {
+	f, err := NewFormatter(format)
+	if err != nil {
+		return err
+	}
+	return r.WriteFormatter(w, f)
+}
+
+// WriteFormatter writes a report to w with a Formatter, yielding the error of
+// its End.
+func (r *Report) WriteFormatter(w io.Writer, f Formatter) error {
+	f.Begin(w)
+	for _, res := range r.Files {
+		f.WriteResult(res)
+	}
+	return f.End()
+}
+
+// ViolationsByDir counts the import grouping violations in all files, for
+// each top-level directory. Paths are considered relative to the working
+// directory, and files directly in it count under ".".
+func (r *Report) ViolationsByDir() map[string]int {
+	counts := map[string]int{}
+	for _, f := range r.Files {
+		if len(f.Violations) > 0 {
+			counts[topDir(f.Path)] += len(f.Violations)
+		}
+	}
+	return counts
+}
+
+// Find the top-level directory of a path, relative to the working directory if
+// possible.
+func topDir(path string) string {
+	if filepath.IsAbs(path) {
+		wd, err := os.Getwd()
+		if err != nil {
+			return filepath.Dir(path)
+		}
+		rel, err := filepath.Rel(wd, path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return filepath.Dir(path)
+		}
+		path = rel
+	}
+	path = filepath.ToSlash(filepath.Clean(path))
+	if i := strings.Index(path, "/"); i >= 0 {
+		return filepath.FromSlash(path[:i])
+	}
+	return "."
+}
+
+// Summary counts the results of processing files.
+type Summary struct {
+	Files int `json:"files"`
+	// Violations counts every violation, and InvalidFiles counts files with
+	// any violations.
+	Violations           int            `json:"violations"`
+	InvalidFiles         int            `json:"invalidFiles"`
+	ViolationsByRule     map[string]int `json:"violationsByRule"`
+	ViolationsBySeverity map[string]int `json:"violationsBySeverity"`
+	ViolationsByDir      map[string]int `json:"violationsByDir"`
+	// Baselined counts the violations a Baseline tolerates, which aren't
+	// counted as violations.
+	Baselined int `json:"baselined"`
+	// Suppressed counts the violations that comments suppress, which aren't
+	// counted as violations either.
+	Suppressed      int            `json:"suppressed"`
+	Changed         int            `json:"changed"`
+	Skipped         int            `json:"skipped"`
+	SkippedByReason map[string]int `json:"skippedByReason"`
+	Errors          int            `json:"errors"`
+}
+
+// Summary counts the files, violations, changes, skipped files and errors in a
+// report.
+func (r *Report) Summary() *Summary {
+	invalid := 0
+	for _, f := range r.Files {
+		if f.Violation != nil {
+			invalid++
+		}
+	}
+	return &Summary{
+		Files:                len(r.Files),
+		Violations:           r.Violations(),
+		InvalidFiles:         invalid,
+		ViolationsByRule:     r.ViolationsByRule(),
+		ViolationsBySeverity: r.ViolationsBySeverity(),
+		ViolationsByDir:      r.ViolationsByDir(),
+		Baselined:            r.Baselined(),
+		Suppressed:           r.Suppressed(),
+		Changed:              r.Changed(),
+		Skipped:              r.Skipped(),
+		SkippedByReason:      r.SkippedByReason(),
+		Errors:               r.Errors(),
+	}
+}
+
+// Format counts as a list sorted by key, such as "a: 1, b: 2".
+func formatCounts(counts map[string]int) string {
+	items := []string{}
+	for key, n := range counts {
+		items = append(items, fmt.Sprintf("%s: %d", key, n))
+	}
+	sort.Strings(items)
+	return strings.Join(items, ", ")
+}
+
+// Format counts in parentheses, or as nothing if there are none.
+func formatCountsAside(counts map[string]int) string {
+	if len(counts) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%s)", formatCounts(counts))
+}
+
+// WriteSummary writes a summary of a report to w, with counts of files,
+// violations, changes, skipped files and errors. Violations are broken down by
+// rule and by top-level directory, and by severity if any aren't errors.
+// Violations a Baseline tolerates or comments suppress are counted apart, if
+// there are any.
+func (r *Report) WriteSummary(w io.Writer) error {
+	s := r.Summary()
+	breakdown := ""
+	if s.ViolationsBySeverity[SeverityError.String()] < s.Violations {
+		breakdown = fmt.Sprintf("Violations by severity: %s\n", formatCounts(s.ViolationsBySeverity))
+	}
+	if s.Violations > 0 {
+		breakdown += fmt.Sprintf("Violations by directory: %s\n", formatCounts(s.ViolationsByDir))
+	}
+	if s.Baselined > 0 {
+		breakdown += fmt.Sprintf("Baselined: %d\n", s.Baselined)
+	}
+	if s.Suppressed > 0 {
+		breakdown += fmt.Sprintf("Suppressed: %d\n", s.Suppressed)
+	}
+	_, err := fmt.Fprintf(w, "Files: %d\nViolations: %d in %d files%s\n%sChanged: %d\nSkipped: %d%s\nErrors: %d\n",
+		s.Files, s.Violations, s.InvalidFiles, formatCountsAside(s.ViolationsByRule), breakdown, s.Changed,
+		s.Skipped, formatCountsAside(s.SkippedByReason), s.Errors)
+	return err
+}
+
+// WriteSummaryJSON writes the summary of a report to w as a JSON object.
+func (r *Report) WriteSummaryJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(r.Summary(), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// WriteText writes the first violation of each file in a report to w, one
+// line each, like the "text" format.
+func (r *Report) WriteText(w io.Writer) error {
+	return r.WriteFormatter(w, builtinFormat(newTextOutput)())
+}
+
+// The result of processing a file, as WriteJSON writes it.
+type jsonFileResult struct {
+	Path       string           `json:"path"`
+	Package    string           `json:"package,omitempty"`
+	Violations []*jsonViolation `json:"violations,omitempty"`
+	Changed    bool             `json:"changed,omitempty"`
+	Cached     bool             `json:"cached,omitempty"`
+	SkipReason string           `json:"skipped,omitempty"`
+	Warnings   []string         `json:"warnings,omitempty"`
+	Error      string           `json:"error,omitempty"`
+}
+
+// A violation, as WriteJSON writes it. The column and offset are of its
+// position, if known.
+type jsonViolation struct {
+	Kind          Kind     `json:"kind,omitempty"`
+	ID            string   `json:"id,omitempty"`
+	Line          int      `json:"line"`
+	Column        int      `json:"column,omitempty"`
+	Offset        int      `json:"offset,omitempty"`
+	ImportPath    string   `json:"import"`
+	Message       string   `json:"message"`
+	Rule          string   `json:"rule,omitempty"`
+	Severity      Severity `json:"severity"`
+	ExpectedGroup string   `json:"expectedGroup,omitempty"`
+	FoundGroup    string   `json:"foundGroup,omitempty"`
+}
+
+// WriteJSON writes a report to w as a JSON object, with the result of every
+// file under "files", and the counts of Summary under "summary".
+func (r *Report) WriteJSON(w io.Writer) error {
+	files := []*jsonFileResult{}
+	for _, f := range r.Files {
+		jf := &jsonFileResult{Path: f.Path, Package: f.Package, Changed: f.Changed, Cached: f.Cached,
+			SkipReason: f.SkipReason, Warnings: f.Warnings}
+		for _, v := range f.Violations {
+			jf.Violations = append(jf.Violations, &jsonViolation{v.Kind, v.ID(), v.Line, v.Pos.Column, v.Pos.Offset, v.ImportPath, v.Message, v.Rule,
+				v.Severity, v.ExpectedGroup, v.FoundGroup})
+		}
+		if f.Err != nil {
+			jf.Error = f.Err.Error()
+		}
+		files = append(files, jf)
+	}
+	data, err := json.MarshalIndent(struct {
+		Files   []*jsonFileResult `json:"files"`
+		Summary *Summary          `json:"summary"`
+	}{files, r.Summary()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// WritePaths writes the path of each file with violations to w, one per line.
+func (r *Report) WritePaths(w io.Writer) error {
+	for _, f := range r.Files {
+		if f.Violation != nil {
+			if _, err := fmt.Fprintln(w, f.Path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WriteCounts writes the number of violations in a report to w. If byRule is
+// true, it instead writes the number for each rule, one per line, such as
+// "statement-order 3", sorted by rule.
+func (r *Report) WriteCounts(w io.Writer, byRule bool) error {
+	if !byRule {
+		_, err := fmt.Fprintln(w, r.Violations())
+		return err
+	}
+
+	counts := r.ViolationsByRule()
+	rules := []string{}
+	for rule := range counts {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+	for _, rule := range rules {
+		if _, err := fmt.Fprintf(w, "%s %d\n", rule, counts[rule]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteNotes writes what happened to each file besides its violations to w:
+// its warnings, then the error that stopped it being processed, or a note
+// that it was rewritten. Parse errors are left out if omitParseErrors is true,
+// such as when the format of the violations includes them.
+func (r *Report) WriteNotes(w io.Writer, omitParseErrors bool) error {
+	for _, f := range r.Files {
+		for _, warning := range f.Warnings {
+			if _, err := fmt.Fprintf(w, "Warning: %s\n", warning); err != nil {
+				return err
+			}
+		}
+		var err error
+		if f.Err != nil {
+			if _, ok := f.Err.(*ParseError); !ok || !omitParseErrors {
+				_, err = fmt.Fprintln(w, f.Err.Error())
+			}
+		} else if f.Changed {
+			_, err = fmt.Fprintf(w, "Fixed %s\n", f.Path)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
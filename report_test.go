@@ -0,0 +1,139 @@
+package gogroup
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeAndSort(t *testing.T) {
+	t.Parallel()
+
+	order := &ValidationError{Line: 5, Rule: "statement-order"}
+	group := &ValidationError{Line: 3, Rule: "group-order"}
+	blank := &ValidationError{Line: 5, Rule: "group-blank"}
+	workers := []*Report{
+		{Files: []*FileResult{{Path: "c.go"}, {Path: "a.go", Violation: order,
+			Violations: []*ValidationError{order, group, blank}}}},
+		{Files: []*FileResult{{Path: "b.go", Changed: true}}},
+		{},
+	}
+	report := &Report{}
+	report.Merge(workers...)
+	assert.Equal(t, 3, len(report.Files))
+	assert.Equal(t, "c.go", report.Files[0].Path)
+
+	report.DeterministicSort()
+	paths := []string{}
+	for _, f := range report.Files {
+		paths = append(paths, f.Path)
+	}
+	assert.Equal(t, []string{"a.go", "b.go", "c.go"}, paths)
+	assert.Equal(t, []*ValidationError{group, blank, order}, report.Files[0].Violations)
+	assert.Equal(t, group, report.Files[0].Violation)
+	assert.Equal(t, 3, report.Violations())
+	assert.Equal(t, 1, report.Changed())
+}
+
+func TestKeepLines(t *testing.T) {
+	t.Parallel()
+
+	at := func(line int) *ValidationError {
+		return &ValidationError{Line: line, Rule: "statement-order"}
+	}
+	a, b, c := []*ValidationError{at(3), at(5), at(7)}, []*ValidationError{at(4)}, []*ValidationError{at(2)}
+	report := &Report{Files: []*FileResult{
+		{Path: "a.go", Violation: a[0], Violations: a, Fix: &ImportBlock{}},
+		{Path: "b.go", Violation: b[0], Violations: b, Fix: &ImportBlock{}},
+		{Path: "c.go", Violation: c[0], Violations: c, Fix: &ImportBlock{}},
+	}}
+	report.KeepLines(map[string][]int{"a.go": {5, 6, 7}, "b.go": {1, 2}})
+	assert.Equal(t, []*ValidationError{a[1], a[2]}, report.Files[0].Violations)
+	assert.Equal(t, a[1], report.Files[0].Violation)
+	assert.NotNil(t, report.Files[0].Fix)
+	for _, f := range report.Files[1:] {
+		assert.Empty(t, f.Violations, f.Path)
+		assert.Nil(t, f.Violation, f.Path)
+		assert.Nil(t, f.Fix, f.Path)
+	}
+	assert.Equal(t, 2, report.Violations())
+}
+
+func TestReportWriters(t *testing.T) {
+	t.Parallel()
+
+	violations := []*ValidationError{
+		{Kind: KindWrongGroup, Line: 3, Pos: token.Position{Offset: 30, Line: 3, Column: 2}, ImportPath: "os", Message: "Import in incorrect group", Rule: "group-order",
+			ExpectedGroup: "Standard", FoundGroup: "Other"},
+		{Line: 4, ImportPath: "fmt", Message: "Imports are not sorted", Rule: "statement-order"},
+	}
+	report := &Report{Files: []*FileResult{
+		{Path: "a.go", Violation: violations[0], Violations: violations, Warnings: []string{"a.go: odd"}},
+		{Path: "b.go"},
+		{Path: "c.go", Violation: violations[1], Violations: violations[1:]},
+		{Path: "d.go", Err: &ParseError{FileName: "d.go", Err: errors.New("1:1: expected 'package'")}},
+		{Path: "e.go", Err: errors.New("e.go: permission denied")},
+		{Path: "f.go", Changed: true},
+		{Path: "g.go", Skipped: true, SkipReason: SkipTooLarge},
+	}}
+
+	var buf bytes.Buffer
+	assert.Nil(t, report.WriteText(&buf))
+	assert.Equal(t, "a.go:3: Import in incorrect group at \"os\" [GI004]\n"+
+		"a.go:4: Imports are not sorted at \"fmt\"\n"+
+		"c.go:4: Imports are not sorted at \"fmt\"\n", buf.String())
+
+	buf.Reset()
+	assert.Nil(t, report.WritePaths(&buf))
+	assert.Equal(t, "a.go\nc.go\n", buf.String())
+
+	buf.Reset()
+	assert.Nil(t, report.WriteCounts(&buf, false))
+	assert.Equal(t, "3\n", buf.String())
+	buf.Reset()
+	assert.Nil(t, report.WriteCounts(&buf, true))
+	assert.Equal(t, "group-order 1\nstatement-order 2\n", buf.String())
+
+	buf.Reset()
+	assert.Nil(t, report.WriteNotes(&buf, false))
+	assert.Equal(t, "Warning: a.go: odd\nd.go: 1:1: expected 'package'\n"+
+		"e.go: permission denied\nFixed f.go\n", buf.String())
+	buf.Reset()
+	assert.Nil(t, report.WriteNotes(&buf, true))
+	assert.Equal(t, "Warning: a.go: odd\ne.go: permission denied\nFixed f.go\n", buf.String())
+
+	buf.Reset()
+	assert.Nil(t, report.WriteJSON(&buf))
+	var doc struct {
+		Files []struct {
+			Path       string
+			Violations []map[string]interface{}
+			Changed    bool
+			Skipped    string
+			Warnings   []string
+			Error      string
+		}
+		Summary Summary
+	}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &doc))
+	assert.Equal(t, 7, len(doc.Files))
+	assert.Equal(t, map[string]interface{}{"kind": "WrongGroup", "line": float64(3), "column": float64(2),
+		"offset": float64(30), "id": "GI004", "import": "os",
+		"message": "Import in incorrect group", "rule": "group-order", "severity": "error",
+		"expectedGroup": "Standard", "foundGroup": "Other"}, doc.Files[0].Violations[0])
+	assert.Equal(t, []string{"a.go: odd"}, doc.Files[0].Warnings)
+	assert.Empty(t, doc.Files[1].Violations)
+	assert.Equal(t, "e.go: permission denied", doc.Files[4].Error)
+	assert.True(t, doc.Files[5].Changed)
+	assert.Equal(t, SkipTooLarge, doc.Files[6].Skipped)
+	assert.Equal(t, *report.Summary(), doc.Summary)
+
+	// The json format writes the same document.
+	var formatted bytes.Buffer
+	assert.Nil(t, report.Write(&formatted, "json"))
+	assert.Equal(t, buf.String(), formatted.String())
+}
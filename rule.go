@@ -0,0 +1,330 @@
+package gogroup
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"sort"
+)
+
+// A Rule checks the imports of a file. A Processor runs any rules added with
+// AddRule after its built-in checks, so that other conventions, such as how
+// certain packages are named, can be checked in the same pass as grouping.
+// The built-in checks aren't Rules, since they see more of a file than
+// FileImports describes, but each Kind has an ID as a rule would.
+type Rule interface {
+	// ID yields the stable identifier of the rule, such as "ACME001", which is
+	// the ID of every violation the rule yields. Output formats include it,
+	// and DisableRules refers to the rule by it. It mustn't be the ID of a
+	// Kind.
+	ID() string
+
+	// Check yields the violations of the rule in a file, in any order. Each
+	// should have a Line, Pos, ImportPath and Message, and its Kind is
+	// KindUnknown.
+	Check(f *FileImports) []*ValidationError
+}
+
+// FileImports describes the imports of a file, for a Rule to check.
+type FileImports struct {
+	// FileName is the name of the file.
+	FileName string
+	// Fset and File are the parsed file. Only its package clause, imports and
+	// comments are parsed.
+	Fset *token.FileSet
+	File *ast.File
+	// Imports are the imports of the file, in order.
+	Imports []FileImport
+}
+
+// FileImport describes an import of a file, for a Rule to check.
+type FileImport struct {
+	// Path is the path being imported.
+	Path string
+	// Name is the name the import is under, such as an alias, "." or "_", or
+	// the empty string if it has none.
+	Name string
+	// Group is the group the Grouper assigned the import to, which may be
+	// GroupIgnore. It's meaningless if Unassigned.
+	Group int
+	// Unassigned is true if the Grouper assigned the import to no group.
+	Unassigned bool
+	// Kept is true if the import is kept in place, by a keep directive or
+	// because the Grouper ignores it.
+	Kept bool
+	// Suppressed is true if the import has a //nolint:gogroup comment, so
+	// its violations are suppressed.
+	Suppressed bool
+	// Line is the one-based line of the import, or of its first doc comment,
+	// as in ValidationError.Line.
+	Line int
+	// Pos is the physical position of the import.
+	Pos token.Position
+	// Spec is the parsed import.
+	Spec *ast.ImportSpec
+}
+
+// The built-in checks. Each finds violations of several kinds, in the order
+// the built-in rules of those kinds are run.
+var builtinChecks = []func(p *Processor, tree *ast.File, gs groupedImports,
+	namer GroupNamer) []*ValidationError{
+	func(p *Processor, tree *ast.File, gs groupedImports, namer GroupNamer) []*ValidationError {
+		return gs.validateAll(namer, p.lenient)
+	},
+	func(p *Processor, tree *ast.File, gs groupedImports, namer GroupNamer) []*ValidationError {
+		return p.validateBlocks(tree, gs)
+	},
+	func(p *Processor, tree *ast.File, gs groupedImports, namer GroupNamer) []*ValidationError {
+		return p.validateHeaders(gs)
+	},
+	func(p *Processor, tree *ast.File, gs groupedImports, namer GroupNamer) []*ValidationError {
+		return gs.validateStripped()
+	},
+	func(p *Processor, tree *ast.File, gs groupedImports, namer GroupNamer) []*ValidationError {
+		return gs.validateDuplicates()
+	},
+	func(p *Processor, tree *ast.File, gs groupedImports, namer GroupNamer) []*ValidationError {
+		return gs.validateAliases()
+	},
+}
+
+// A built-in rule: the violations of one kind that a built-in check finds.
+type builtinRule struct {
+	kind  Kind
+	check int
+}
+
+// The built-in rules, in the order they're run.
+var builtinRules = []builtinRule{
+	{KindUnassigned, 0},
+	{KindRelative, 0},
+	{KindSameLine, 0},
+	{KindExtraBlankLine, 0},
+	{KindStatementOrder, 0},
+	{KindGroupOrder, 0},
+	{KindMissingGroupBlankLine, 0},
+	{KindWrongGroup, 0},
+	{KindExtraGroupBlankLine, 0},
+	{KindUnparenthesized, 1},
+	{KindSingleParenthesized, 1},
+	{KindMissingHeader, 2},
+	{KindWrongHeader, 2},
+	{KindStrippedComment, 3},
+	{KindDuplicate, 4},
+	{KindWrongAlias, 5},
+	{KindDenied, 5},
+	{KindLayer, 5},
+	{KindForbiddenAlias, 5},
+}
+
+// AddRule adds a rule for the Processor to check, after the built-in rules.
+// It must be called before the Processor is used.
+//
+// Violations of added rules are reported like any others, but repairs don't
+// fix them: Repair and Reformat only change files for violations of the
+// built-in rules, and leave those of added rules in place, so files may still
+// have violations once repaired. A Cache doesn't know about added rules, so
+// its configuration must describe them.
+func (p *Processor) AddRule(rule Rule) {
+	p.rules = append(p.rules, rule)
+}
+
+// DisableRules stops the rules with the given IDs, such as "GI001", from being
+// checked. Built-in rules may also be given by the names of their kinds'
+// rules, such as "statement-order". Unknown IDs are ignored.
+//
+// Repairs don't fix the violations of disabled rules, as with NoFix.
+func DisableRules(ids ...string) Option {
+	return func(p *Processor) {
+		p.disabled = ruleSet(ids)
+	}
+}
+
+// FixOnly limits repairs to fixing the violations of the rules with the given
+// IDs or names, as DisableRules takes them. Violations of other rules are
+// still reported, and repairs leave them be as NoFix describes.
+func FixOnly(ids ...string) Option {
+	return func(p *Processor) {
+		p.fixOnly = ruleSet(ids)
+	}
+}
+
+// NoFix stops repairs fixing the violations of the rules with the given IDs or
+// names, as DisableRules takes them, though they're still reported.
+//
+// Repairs only change files with violations of rules they fix. Where it's
+// coherent, they also leave alone what unfixed rules require: without
+// statement-group, imports stay in the group they're found in, which is the
+// one most of the imports around them belong to, so only their order within
+// it and the empty lines around it are fixed; without statement-order,
+// imports keep their order within each group; without statement-alias,
+// imports aren't renamed; without statement-duplicate, repeated imports
+// aren't removed; without comment-stripped, comments aren't stripped; without
+// both group-header-missing and group-header, headers are left as they are;
+// and without block-parenthesized or block-plain, the style of
+// declarations is kept. Repairs of changed files still fix the violations of
+// other rules, such as by removing extra empty lines.
+func NoFix(ids ...string) Option {
+	return func(p *Processor) {
+		p.noFix = ruleSet(ids)
+	}
+}
+
+// Yield the set of rules with the given IDs or names.
+func ruleSet(ids []string) map[string]bool {
+	set := map[string]bool{}
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
+
+// Determine whether the built-in rule of a kind is disabled.
+func (p *Processor) disabledKind(kind Kind) bool {
+	return p.disabled[kind.ID()] || p.disabled[kind.Rule()]
+}
+
+// Determine whether repairs fix the violations of the built-in rule of a kind.
+func (p *Processor) fixes(kind Kind) bool {
+	named := func(set map[string]bool) bool {
+		return set[kind.ID()] || set[kind.Rule()]
+	}
+	if p.disabledKind(kind) || named(p.noFix) {
+		return false
+	}
+	return p.fixOnly == nil || named(p.fixOnly)
+}
+
+// Yield the key to order an import by within its group: by its path, or by
+// its position among the imports of the file if the order isn't checked, so
+// that repairs keep it.
+func (p *Processor) sortKey(path string, index int) string {
+	if p.disabledKind(KindStatementOrder) {
+		return indexKey(index)
+	}
+	return p.sortMode.key(path)
+}
+
+// Yield the key to order an import by its position among the imports of the
+// file.
+func indexKey(index int) string {
+	return fmt.Sprintf("%010d", index)
+}
+
+// Determine whether repairs rename imports to their required aliases.
+func (p *Processor) fixesAliases() bool {
+	return p.fixAliases && p.fixes(KindWrongAlias)
+}
+
+// Determine whether repairs rewrite group headers.
+func (p *Processor) fixesHeaders() bool {
+	return p.fixes(KindMissingHeader) || p.fixes(KindWrongHeader)
+}
+
+// Describe the imports of a file for rules.
+func fileImports(fset *token.FileSet, tree *ast.File, gs groupedImports) *FileImports {
+	f := &FileImports{
+		FileName: fset.PositionFor(tree.Package, false).Filename,
+		Fset:     fset,
+		File:     tree,
+		Imports:  make([]FileImport, 0, len(gs)),
+	}
+	for _, g := range gs {
+		name := ""
+		if g.spec.Name != nil {
+			name = g.spec.Name.Name
+		}
+		f.Imports = append(f.Imports, FileImport{
+			Path:       g.path,
+			Name:       name,
+			Group:      g.group,
+			Unassigned: g.unassigned,
+			Kept:       g.keep,
+			Suppressed: g.suppressed,
+			// Line numbers are one-based for humans.
+			Line: g.startLine + 1,
+			Pos:  g.pos,
+			Spec: g.spec,
+		})
+	}
+	return f
+}
+
+// Validate the imports of a parsed file, yielding every violation of the
+// enabled rules, in order of line, and apart from them, the violations of
+// imports with a comment suppressing them. Violations on the same line are in
+// the order the built-in checks find them, followed by those of added rules
+// in the order they were added. If namer is non-nil, it's used to name groups
+// in error messages. Unless added is true, only the built-in rules are
+// checked.
+func (p *Processor) check(fset *token.FileSet, tree *ast.File, gs groupedImports, namer GroupNamer,
+	added bool) ([]*ValidationError, []*ValidationError) {
+	f := fileImports(fset, tree, gs)
+	errs := []*ValidationError{}
+
+	// Each built-in check runs at most once, and its violations are ordered
+	// by the check and where it found them.
+	found := map[int][]*ValidationError{}
+	order := map[*ValidationError]int{}
+	for _, rule := range builtinRules {
+		if p.disabledKind(rule.kind) {
+			continue
+		}
+		checked, ok := found[rule.check]
+		if !ok {
+			checked = builtinChecks[rule.check](p, tree, gs, namer)
+			found[rule.check] = checked
+			for i, validErr := range checked {
+				order[validErr] = rule.check<<20 + i
+			}
+		}
+		severity := p.kindSeverity(rule.kind)
+		for _, validErr := range checked {
+			if validErr.Kind == rule.kind {
+				validErr.Severity = severity
+				errs = append(errs, validErr)
+			}
+		}
+	}
+
+	next := len(builtinChecks) << 20
+	for _, rule := range p.rules {
+		if !added || p.disabled[rule.ID()] {
+			continue
+		}
+		severity := p.severity(rule.ID())
+		for _, validErr := range rule.Check(f) {
+			validErr.Severity = severity
+			validErr.ruleID = rule.ID()
+			if validErr.Rule == "" {
+				validErr.Rule = rule.ID()
+			}
+			order[validErr] = next
+			next++
+			errs = append(errs, validErr)
+		}
+	}
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].Line != errs[j].Line {
+			return errs[i].Line < errs[j].Line
+		}
+		return order[errs[i]] < order[errs[j]]
+	})
+
+	// Violations are attributed to imports by line.
+	suppressed := map[int]bool{}
+	for _, imp := range f.Imports {
+		if imp.Suppressed {
+			suppressed[imp.Line] = true
+		}
+	}
+	reported, hidden := []*ValidationError{}, []*ValidationError{}
+	for _, validErr := range errs {
+		if suppressed[validErr.Line] {
+			hidden = append(hidden, validErr)
+		} else {
+			reported = append(reported, validErr)
+		}
+	}
+	return reported, hidden
+}
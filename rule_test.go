@@ -0,0 +1,120 @@
+package gogroup
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// A rule requiring imports of "unsafe" to be renamed.
+type unsafeRule struct{}
+
+func (unsafeRule) ID() string {
+	return "ACME001"
+}
+
+func (unsafeRule) Check(f *FileImports) []*ValidationError {
+	errs := []*ValidationError{}
+	for _, imp := range f.Imports {
+		if imp.Path == "unsafe" && imp.Name == "" {
+			errs = append(errs, &ValidationError{
+				Line:       imp.Line,
+				Pos:        imp.Pos,
+				ImportPath: imp.Path,
+				Message:    "Import of unsafe without a name in " + f.FileName,
+			})
+		}
+	}
+	return errs
+}
+
+func TestAddRule(t *testing.T) {
+	t.Parallel()
+
+	src := "package a\n\nimport (\n\t\"unsafe\"\n\t\"os\"\n\n\t\"github.com/x/y\"\n)\n"
+	proc := NewProcessor(grouperGoimports{})
+	proc.AddRule(unsafeRule{})
+	errs, err := proc.ValidateAll("a.go", strings.NewReader(src))
+	assert.Nil(t, err)
+	if assert.Equal(t, 2, len(errs)) {
+		assert.Equal(t, KindUnknown, errs[0].Kind)
+		assert.Equal(t, "ACME001", errs[0].ID())
+		assert.Equal(t, "ACME001", errs[0].Rule)
+		assert.Equal(t, 4, errs[0].Line)
+		assert.Equal(t, 4, errs[0].Pos.Line)
+		assert.Equal(t, "Import of unsafe without a name in a.go", errs[0].Message)
+		assert.Equal(t, "GI001", errs[1].ID())
+		assert.Equal(t, 5, errs[1].Line)
+	}
+
+	// Violations on the same line follow those of the built-in rules.
+	src = "package a\n\nimport (\n\t\"os\"\n\t\"unsafe\"; \"fmt\"\n)\n"
+	errs, err = proc.ValidateAll("a.go", strings.NewReader(src))
+	assert.Nil(t, err)
+	ids := []string{}
+	for _, validErr := range errs {
+		ids = append(ids, validErr.ID())
+	}
+	assert.Equal(t, []string{"GI007", "GI001", "ACME001"}, ids)
+
+	// Output formats include the ID.
+	report := &Report{Files: []*FileResult{{Path: "a.go", Violation: errs[2], Violations: errs[2:]}}}
+	var buf bytes.Buffer
+	assert.Nil(t, report.Write(&buf, "text"))
+	assert.Equal(t, "a.go:5: Import of unsafe without a name in a.go at \"unsafe\" [ACME001]\n", buf.String())
+
+	// Repairs only fix the built-in rules, so a file with only violations of
+	// added rules isn't changed, and repaired files may still have some.
+	valid := "package a\n\nimport (\n\t\"os\"\n\t\"unsafe\"\n)\n"
+	r, err := proc.Repair("a.go", strings.NewReader(valid))
+	assert.Nil(t, err)
+	assert.Nil(t, r)
+	r, err = proc.Repair("a.go", strings.NewReader("package a\n\nimport (\n\t\"unsafe\"\n\t\"os\"\n)\n"))
+	assert.Nil(t, err)
+	if assert.NotNil(t, r) {
+		fixed, err := ioutil.ReadAll(r)
+		assert.Nil(t, err)
+		assert.Equal(t, valid, string(fixed))
+	}
+}
+
+func TestDisableRules(t *testing.T) {
+	t.Parallel()
+
+	src := "package a\n\nimport (\n\t\"unsafe\"\n\t\"os\"\n\t\"github.com/x/y\"\n)\n"
+	ids := func(opts ...Option) []string {
+		proc := NewProcessor(grouperGoimports{}, opts...)
+		proc.AddRule(unsafeRule{})
+		errs, err := proc.ValidateAll("a.go", strings.NewReader(src))
+		assert.Nil(t, err)
+		ids := []string{}
+		for _, validErr := range errs {
+			ids = append(ids, validErr.ID())
+		}
+		return ids
+	}
+	assert.Equal(t, []string{"ACME001", "GI001", "GI004"}, ids())
+	// Without a required order within groups, the only problem left with
+	// the last import is the missing empty line before it.
+	assert.Equal(t, []string{"ACME001", "GI006"}, ids(DisableRules("GI001")))
+	assert.Equal(t, []string{"ACME001", "GI001"}, ids(DisableRules("statement-group")))
+	assert.Equal(t, []string{"GI001", "GI004"}, ids(DisableRules("ACME001")))
+
+	// Repairs keep the order within groups when it isn't checked.
+	proc := NewProcessor(grouperGoimports{}, DisableRules("statement-order"))
+	r, err := proc.Repair("a.go", strings.NewReader(src))
+	assert.Nil(t, err)
+	if assert.NotNil(t, r) {
+		fixed, err := ioutil.ReadAll(r)
+		assert.Nil(t, err)
+		assert.Equal(t, "package a\n\nimport (\n\t\"unsafe\"\n\t\"os\"\n\n\t\"github.com/x/y\"\n)\n", string(fixed))
+	}
+
+	// Files with only violations of disabled rules aren't repaired.
+	r, err = proc.Repair("a.go", strings.NewReader("package a\n\nimport (\n\t\"unsafe\"\n\t\"os\"\n)\n"))
+	assert.Nil(t, err)
+	assert.Nil(t, r)
+}
@@ -0,0 +1,484 @@
+package gogroup
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// ConfigError is an error in a rules document read by GrouperFromConfig, or a
+// configuration read by ReadConfig.
+type ConfigError struct {
+	// Line is the line of the document at which the error occurred, or zero
+	// if it's not known.
+	Line int
+	// Path locates the value at fault within the document, such as
+	// "groups[1].regexes[0]", or is empty for the document as a whole.
+	Path string
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	switch {
+	case e.Line == 0 && e.Path == "":
+		return e.Err.Error()
+	case e.Line == 0:
+		return fmt.Sprintf("%s: %s", e.Path, e.Err.Error())
+	case e.Path == "":
+		return fmt.Sprintf("line %d: %s", e.Line, e.Err.Error())
+	}
+	return fmt.Sprintf("line %d (%s): %s", e.Line, e.Path, e.Err.Error())
+}
+
+// Unwrap yields the underlying error.
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// A group of a rules document.
+type rulesGroup struct {
+	name     string
+	std      bool
+	module   string
+	prefixes []string
+	regexes  []*regexp.Regexp
+	ignore   bool
+}
+
+// Determine whether an import path matches any of the rules of a group.
+func (gr *rulesGroup) matches(path string) bool {
+	return gr.match(path) != ""
+}
+
+// Describe the first rule of a group that an import path matches, or yield
+// the empty string if it matches none.
+func (gr *rulesGroup) match(path string) string {
+	if gr.std && !strings.Contains(strings.SplitN(path, "/", 2)[0], ".") {
+		return "std"
+	}
+	if gr.module != "" && (path == gr.module || strings.HasPrefix(path, gr.module+"/")) {
+		return "module=" + gr.module
+	}
+	for _, prefix := range gr.prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return "prefix=" + prefix
+		}
+	}
+	for _, re := range gr.regexes {
+		if re.MatchString(path) {
+			return "regex=" + re.String()
+		}
+	}
+	return ""
+}
+
+// A Grouper configured by a rules document.
+type rulesGrouper struct {
+	groups []*rulesGroup
+
+	// The group of imports that match no rules, or -1 if there's none.
+	fallback int
+}
+
+var (
+	_ StrictGrouper = (*rulesGrouper)(nil)
+	_ Explainer     = (*rulesGrouper)(nil)
+)
+
+func (g *rulesGrouper) Group(pkgPath string) int {
+	if group, ok := g.LookupGroup(pkgPath); ok {
+		return group
+	}
+	return len(g.groups)
+}
+
+func (g *rulesGrouper) LookupGroup(pkgPath string) (int, bool) {
+	group := g.fallback
+	for i, gr := range g.groups {
+		if gr.matches(pkgPath) {
+			group = i
+			break
+		}
+	}
+	if group < 0 {
+		return 0, false
+	}
+	if g.groups[group].ignore {
+		return GroupIgnore, true
+	}
+	return group, true
+}
+
+func (g *rulesGrouper) Explain(pkgPath string) string {
+	for _, gr := range g.groups {
+		if rule := gr.match(pkgPath); rule != "" {
+			return rule
+		}
+	}
+	if g.fallback >= 0 {
+		return "default"
+	}
+	return ""
+}
+
+func (g *rulesGrouper) GroupName(group int) string {
+	if group < 0 || group >= len(g.groups) {
+		return ""
+	}
+	return g.groups[group].name
+}
+
+// GrouperFromConfig creates a Grouper from a rules document in YAML or JSON,
+// such as:
+//
+//	groups:
+//	  - {name: Standard, std: true}
+//	  - {name: Generated, regexes: [/gen/], effect: ignore}
+//	  - name: Internal
+//	    module: github.com/corp/repo
+//	    prefixes: [github.com/corp/]
+//	  - name: Third-party
+//	default: Third-party
+//
+// or the same in JSON, which is a subset of YAML:
+//
+//	{
+//	  "groups": [
+//	    {"name": "Standard", "std": true},
+//	    {"name": "Generated", "regexes": ["/gen/"], "effect": "ignore"},
+//	    {"name": "Internal", "module": "github.com/corp/repo", "prefixes": ["github.com/corp/"]},
+//	    {"name": "Third-party"}
+//	  ],
+//	  "default": "Third-party"
+//	}
+//
+// Groups are listed in order, and each import belongs to the first group with
+// a rule that matches it. A group's rules may include:
+//
+//   - "std": true, to match standard library imports, whose first path
+//     element has no dot
+//   - "module": PATH, to match imports of a module and its packages
+//   - "prefixes": a list of prefixes of import paths to match
+//   - "regexes": a list of regular expressions, matching anywhere in import
+//     paths
+//
+// A group with "effect": "ignore" leaves the imports it matches out of
+// grouping, as if its Grouper yielded GroupIgnore. The default effect is
+// "group".
+//
+// Imports that match no rule belong to the group named by "default", which
+// needs no rules of its own. Without a default, such imports belong to no
+// group, and each is a violation. Group names must be unique and not empty.
+//
+// The document may also have a "$schema" key, which is ignored, so that editors
+// can validate it against the schema published as order.schema.json. The
+// "groups" and "default" keys of a configuration read by ReadConfig are read
+// the same way.
+//
+// Errors in the document, such as unknown keys or groups without rules, are
+// reported as a *ConfigError with the line they occurred at, and the path to
+// the value at fault.
+func GrouperFromConfig(r io.Reader) (Grouper, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	root, err := parseDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	return rulesFromNode(root)
+}
+
+// Parse a YAML or JSON document, yielding the node of its value.
+func parseDocument(data []byte) (*yaml.Node, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	var doc yaml.Node
+	if err := dec.Decode(&doc); err == io.EOF {
+		return nil, &ConfigError{Line: lineAt(data, int64(len(data))), Err: errors.New("Unexpected end of document")}
+	} else if err != nil {
+		return nil, syntaxError(data, err)
+	}
+	var extra yaml.Node
+	if err := dec.Decode(&extra); err == nil {
+		return nil, &ConfigError{Line: extra.Line, Err: errors.New("Unexpected content after the document")}
+	} else if err != io.EOF {
+		return nil, syntaxError(data, err)
+	}
+	return doc.Content[0], nil
+}
+
+// The line of a syntax error from the YAML parser, and the rest of its message.
+var yamlErrorLine = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// Yield a syntax error in a document as a *ConfigError. The YAML parser places
+// errors within braces and brackets at the line they open on, so the JSON
+// parser is asked for a closer line when the document looks like JSON.
+func syntaxError(data []byte, err error) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var v interface{}
+		if serr, ok := json.Unmarshal(data, &v).(*json.SyntaxError); ok {
+			return &ConfigError{Line: lineAt(data, serr.Offset), Err: serr}
+		}
+	}
+	if m := yamlErrorLine.FindStringSubmatch(err.Error()); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		return &ConfigError{Line: line, Err: errors.New(m[2])}
+	}
+	return &ConfigError{Err: err}
+}
+
+// Render a node of a document as compact JSON, keeping the order of keys.
+func nodeJSON(n *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	var write func(n *yaml.Node) error
+	write = func(n *yaml.Node) error {
+		switch n = resolveAlias(n); n.Kind {
+		case yaml.MappingNode:
+			buf.WriteByte('{')
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				key, _ := json.Marshal(n.Content[i].Value)
+				buf.Write(key)
+				buf.WriteByte(':')
+				if err := write(n.Content[i+1]); err != nil {
+					return err
+				}
+			}
+			buf.WriteByte('}')
+		case yaml.SequenceNode:
+			buf.WriteByte('[')
+			for i, item := range n.Content {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				if err := write(item); err != nil {
+					return err
+				}
+			}
+			buf.WriteByte(']')
+		default:
+			var v interface{}
+			if err := n.Decode(&v); err != nil {
+				return &ConfigError{Line: n.Line, Err: err}
+			}
+			data, err := json.Marshal(v)
+			if err != nil {
+				return &ConfigError{Line: n.Line, Err: err}
+			}
+			buf.Write(data)
+		}
+		return nil
+	}
+	err := write(n)
+	return buf.Bytes(), err
+}
+
+// Follow an alias to the node it refers to.
+func resolveAlias(n *yaml.Node) *yaml.Node {
+	for n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	return n
+}
+
+// Decodes a rules document, keeping track of paths for errors.
+type rulesDecoder struct {
+	// The keys and indexes leading to the value being read.
+	path []string
+}
+
+// Yield an error at a node of the document, about the value being read.
+func (d *rulesDecoder) errorAt(n *yaml.Node, format string, args ...interface{}) error {
+	path := strings.TrimPrefix(strings.Join(d.path, ""), ".")
+	return &ConfigError{Line: n.Line, Path: path, Err: fmt.Errorf(format, args...)}
+}
+
+// Read a value with a key or index appended to the path, such as ".name" or
+// "[2]".
+func (d *rulesDecoder) within(elem string, fn func() error) error {
+	d.path = append(d.path, elem)
+	err := fn()
+	if err == nil {
+		d.path = d.path[:len(d.path)-1]
+	}
+	return err
+}
+
+// Read an object, calling fn with the node of each key and its value.
+func (d *rulesDecoder) object(n *yaml.Node, fn func(key, value *yaml.Node) error) error {
+	if n.Kind != yaml.MappingNode {
+		return d.errorAt(n, "Expected an object")
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], resolveAlias(n.Content[i+1])
+		if err := d.within("."+key.Value, func() error { return fn(key, value) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Read a string.
+func (d *rulesDecoder) readString(n *yaml.Node, key string) (string, error) {
+	if n.Kind != yaml.ScalarNode || n.ShortTag() != "!!str" {
+		return "", d.errorAt(n, "Expected a string for '%s'", key)
+	}
+	return n.Value, nil
+}
+
+// Read a list of non-empty strings, calling fn with each one.
+func (d *rulesDecoder) readStrings(n *yaml.Node, key string, fn func(s string, n *yaml.Node) error) error {
+	if n.Kind != yaml.SequenceNode {
+		return d.errorAt(n, "Expected a list for '%s'", key)
+	}
+	for i, item := range n.Content {
+		item = resolveAlias(item)
+		err := d.within(fmt.Sprintf("[%d]", i), func() error {
+			s, err := d.readString(item, key)
+			if err != nil {
+				return err
+			}
+			if s == "" {
+				return d.errorAt(item, "Empty string in '%s'", key)
+			}
+			return fn(s, item)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Read a group.
+func (d *rulesDecoder) group(n *yaml.Node) (*rulesGroup, error) {
+	gr := &rulesGroup{}
+	err := d.object(n, func(key, value *yaml.Node) error {
+		switch key.Value {
+		case "name":
+			name, err := d.readString(value, key.Value)
+			gr.name = name
+			return err
+		case "std":
+			if value.Kind != yaml.ScalarNode || value.ShortTag() != "!!bool" {
+				return d.errorAt(value, "Expected true or false for 'std'")
+			}
+			return value.Decode(&gr.std)
+		case "module":
+			module, err := d.readString(value, key.Value)
+			if err == nil && module == "" {
+				err = d.errorAt(value, "Empty 'module'")
+			}
+			gr.module = module
+			return err
+		case "prefixes":
+			return d.readStrings(value, key.Value, func(s string, _ *yaml.Node) error {
+				gr.prefixes = append(gr.prefixes, s)
+				return nil
+			})
+		case "regexes":
+			return d.readStrings(value, key.Value, func(s string, n *yaml.Node) error {
+				re, err := regexp.Compile(s)
+				if err != nil {
+					return d.errorAt(n, "Invalid regex '%s': %s", s, err.Error())
+				}
+				gr.regexes = append(gr.regexes, re)
+				return nil
+			})
+		case "effect":
+			effect, err := d.readString(value, key.Value)
+			if err != nil {
+				return err
+			}
+			if effect != "group" && effect != "ignore" {
+				return d.errorAt(value, "Unknown effect '%s', expected one of: group, ignore", effect)
+			}
+			gr.ignore = effect == "ignore"
+			return nil
+		}
+		return d.errorAt(key, "Unknown key '%s' in group, expected one of: "+
+			"name, std, module, prefixes, regexes, effect", key.Value)
+	})
+	return gr, err
+}
+
+// Create a Grouper from the node of a whole rules document.
+func rulesFromNode(root *yaml.Node) (*rulesGrouper, error) {
+	d := &rulesDecoder{}
+	g := &rulesGrouper{fallback: -1}
+	var groupNodes []*yaml.Node
+	var fallback string
+	var fallbackNode *yaml.Node
+	sawGroups := false
+	err := d.object(root, func(key, value *yaml.Node) error {
+		switch key.Value {
+		case "groups":
+			sawGroups = true
+			if value.Kind != yaml.SequenceNode {
+				return d.errorAt(value, "Expected a list for 'groups'")
+			}
+			for i, item := range value.Content {
+				item = resolveAlias(item)
+				err := d.within(fmt.Sprintf("[%d]", i), func() error {
+					gr, err := d.group(item)
+					g.groups = append(g.groups, gr)
+					groupNodes = append(groupNodes, item)
+					return err
+				})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		case "default":
+			var err error
+			fallback, err = d.readString(value, key.Value)
+			fallbackNode = value
+			return err
+		case "$schema":
+			_, err := d.readString(value, key.Value)
+			return err
+		}
+		return d.errorAt(key, "Unknown key '%s', expected one of: groups, default", key.Value)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !sawGroups || len(g.groups) == 0 {
+		return nil, d.errorAt(root, "No groups defined")
+	}
+	names := map[string]bool{}
+	for i, gr := range g.groups {
+		d.path = []string{"groups", fmt.Sprintf("[%d]", i)}
+		if gr.name == "" {
+			return nil, d.errorAt(groupNodes[i], "Group has no name")
+		}
+		if names[gr.name] {
+			return nil, d.errorAt(groupNodes[i], "Group name '%s' used more than once", gr.name)
+		}
+		names[gr.name] = true
+		if gr.name == fallback {
+			g.fallback = i
+		} else if !gr.std && gr.module == "" && len(gr.prefixes) == 0 && len(gr.regexes) == 0 {
+			return nil, d.errorAt(groupNodes[i], "Group '%s' has no rules", gr.name)
+		}
+	}
+	if fallback != "" && g.fallback < 0 {
+		d.path = []string{"default"}
+		return nil, d.errorAt(fallbackNode, "Default group '%s' is not defined", fallback)
+	}
+	return g, nil
+}
@@ -0,0 +1,169 @@
+package gogroup
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrouperFromConfig(t *testing.T) {
+	t.Parallel()
+
+	g, err := GrouperFromConfig(strings.NewReader(`{
+  "groups": [
+    {"name": "Standard", "std": true},
+    {"name": "Generated", "regexes": ["/gen/"], "effect": "ignore"},
+    {"name": "Internal", "module": "github.com/corp/repo", "prefixes": ["corp.io/"]},
+    {"name": "Third-party"}
+  ],
+  "default": "Third-party"
+}`))
+	assert.Nil(t, err)
+	for path, group := range map[string]int{
+		"os":                         0,
+		"net/http":                   0,
+		"github.com/corp/repo/gen/x": GroupIgnore,
+		"github.com/corp/repo":       2,
+		"github.com/corp/repo/svc":   2,
+		"github.com/corp/repository": 3,
+		"corp.io/log":                2,
+		"github.com/pkg/errors":      3,
+	} {
+		assert.Equal(t, group, g.Group(path), path)
+	}
+	assert.Equal(t, "Internal", g.(GroupNamer).GroupName(2))
+	for path, rule := range map[string]string{
+		"os":                         "std",
+		"github.com/corp/repo/gen/x": "regex=/gen/",
+		"github.com/corp/repo/svc":   "module=github.com/corp/repo",
+		"corp.io/log":                "prefix=corp.io/",
+		"github.com/pkg/errors":      "default",
+	} {
+		assert.Equal(t, rule, g.(Explainer).Explain(path), path)
+	}
+
+	// Without a default, unmatched imports belong to no group.
+	g, err = GrouperFromConfig(strings.NewReader(
+		`{"groups": [{"name": "Standard", "std": true}]}`))
+	assert.Nil(t, err)
+	_, ok := g.(StrictGrouper).LookupGroup("github.com/pkg/errors")
+	assert.False(t, ok)
+	group, ok := g.(StrictGrouper).LookupGroup("os")
+	assert.True(t, ok)
+	assert.Equal(t, 0, group)
+	assert.Equal(t, "", g.(Explainer).Explain("github.com/pkg/errors"))
+
+	// The same document may be written in YAML.
+	g, err = GrouperFromConfig(strings.NewReader(`# Groups, in order.
+groups:
+  - {name: Standard, std: true}
+  - {name: Generated, regexes: [/gen/], effect: ignore}
+  - name: Internal
+    module: github.com/corp/repo
+    prefixes:
+      - corp.io/
+  - name: Third-party
+default: Third-party
+`))
+	assert.Nil(t, err)
+	for path, group := range map[string]int{
+		"os":                         0,
+		"github.com/corp/repo/gen/x": GroupIgnore,
+		"corp.io/log":                2,
+		"github.com/pkg/errors":      3,
+	} {
+		assert.Equal(t, group, g.Group(path), path)
+	}
+	assert.Equal(t, "Third-party", g.(GroupNamer).GroupName(3))
+}
+
+func TestGrouperFromConfigInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, c := range []struct {
+		doc  string
+		line int
+		path string
+		msg  string
+	}{
+		{`{"groups": [{"name": "A", "std": true}], "order": "std"}`, 1, "order",
+			"Unknown key 'order', expected one of: groups, default"},
+		{"{\n\"groups\": [\n{\"name\": \"A\",\n\"std\": true,\n\"prefix\": [\"x\"]}]}", 5, "groups[0].prefix",
+			"Unknown key 'prefix' in group"},
+		{"{\"groups\": [\n{\"name\": \"A\", \"std\": true},\n{\"name\": \"B\"}\n]}", 3, "groups[1]",
+			"Group 'B' has no rules"},
+		{"{\"groups\": [\n{\"std\": true}]}", 2, "groups[0]", "Group has no name"},
+		{"{\"groups\": [\n{\"name\": \"A\", \"std\": true},\n{\"name\": \"A\", \"std\": true}]}", 3, "groups[1]",
+			"Group name 'A' used more than once"},
+		{"{\"groups\": [{\"name\": \"A\",\n\"regexes\": [\"x\", \"(\"]}]}", 2, "groups[0].regexes[1]",
+			"Invalid regex '('"},
+		{"{\"groups\": [{\"name\": \"A\",\n\"prefixes\": [\"\"]}]}", 2, "groups[0].prefixes[0]",
+			"Empty string in 'prefixes'"},
+		{"{\"groups\": [{\"name\": \"A\", \"std\": true,\n\"effect\": \"drop\"}]}", 2, "groups[0].effect",
+			"Unknown effect 'drop'"},
+		{"{\"groups\": [{\"name\": \"A\", \"std\": \"yes\"}]}", 1, "groups[0].std",
+			"Expected true or false for 'std'"},
+		{"{\"groups\": [{\"name\": \"A\", \"std\": true}],\n\"default\": \"B\"}", 2, "default",
+			"Default group 'B' is not defined"},
+		{`{"groups": []}`, 1, "", "No groups defined"},
+		{"{\"groups\": [{\"name\": \"A\", \"std\": true}]}\n{}", 2, "", "after top-level value"},
+		{"{\"groups\": [\n{\"name\": \"A\" \"std\": true}]}", 2, "", "invalid character"},
+		{"{\"groups\": [", 1, "", "unexpected end"},
+		{"", 1, "", "Unexpected end of document"},
+		{"groups:\n  - name: A\n    std: true\n---\ngroups: []\n", 4, "", "Unexpected content after the document"},
+		{"groups:\n  - name: A\n    std: true\n  - name: B\n    prefix: [x]\n", 5, "groups[1].prefix",
+			"Unknown key 'prefix' in group"},
+		{"groups:\n  - name: A\n    std: yes\n", 3, "groups[0].std", "Expected true or false for 'std'"},
+		{"groups:\n  - name: A\n    regexes:\n      - x\n      - (\n", 5, "groups[0].regexes[1]",
+			"Invalid regex '('"},
+		{"groups:\n  - name: A\n    std: true\ndefault: [A]\n", 4, "default", "Expected a string for 'default'"},
+		{"groups:\n  - name: A\n    std: true\n  - name: \"B\n", 4, "", "unexpected end of stream"},
+		{"- name: A\n", 1, "", "Expected an object"},
+	} {
+		_, err := GrouperFromConfig(strings.NewReader(c.doc))
+		cerr, ok := err.(*ConfigError)
+		if !assert.True(t, ok, c.doc) {
+			continue
+		}
+		assert.Equal(t, c.line, cerr.Line, c.doc)
+		assert.Equal(t, c.path, cerr.Path, c.doc)
+		assert.Contains(t, cerr.Error(), c.msg, c.doc)
+	}
+}
+
+func TestOrderSchema(t *testing.T) {
+	t.Parallel()
+
+	// The published schema describes the keys the decoder accepts.
+	data, err := ioutil.ReadFile("order.schema.json")
+	assert.Nil(t, err)
+	var schema struct {
+		Properties  map[string]interface{}
+		Definitions struct {
+			Group struct {
+				Properties map[string]interface{}
+			}
+		}
+	}
+	assert.Nil(t, json.Unmarshal(data, &schema))
+	keys := func(m map[string]interface{}) []string {
+		ks := []string{}
+		for k := range m {
+			ks = append(ks, k)
+		}
+		sort.Strings(ks)
+		return ks
+	}
+	assert.Equal(t, []string{"$schema", "default", "groups"}, keys(schema.Properties))
+	assert.Equal(t, []string{"effect", "module", "name", "prefixes", "regexes", "std"},
+		keys(schema.Definitions.Group.Properties))
+
+	// Documents may refer to it.
+	_, err = GrouperFromConfig(strings.NewReader(`{"$schema": "order.schema.json", ` +
+		`"groups": [{"name": "Standard", "std": true}]}`))
+	assert.Nil(t, err)
+}
@@ -0,0 +1,306 @@
+package gogroup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"runtime"
+	"sync"
+)
+
+// RunOptions configures how ProcessFiles processes files.
+type RunOptions struct {
+	// Rewrite determines whether files with incorrect import grouping are
+	// rewritten, rather than just validated. Files are replaced atomically
+	// where possible. If the context is cancelled, files being rewritten are
+	// left unchanged.
+	Rewrite bool
+
+	// Goimports determines whether rewriting uses Reformat rather than Repair,
+	// so files are also formatted and have missing imports added.
+	Goimports bool
+
+	// Concurrency is the maximum number of files to process at once. If zero,
+	// it defaults to GOMAXPROCS.
+	Concurrency int
+
+	// ReadFile reads the content of a file. If nil, it defaults to reading
+	// from disk with ioutil.ReadFile. Custom readers allow validating content
+	// from elsewhere, such as a version control index. They can't be used
+	// with Rewrite.
+	ReadFile func(path string) ([]byte, error)
+
+	// Cache, if non-nil, is used to skip files known to be valid from earlier
+	// runs. It's not used when rewriting with Goimports, since goimports may
+	// change even valid files.
+	Cache *Cache
+
+	// FailFast stops processing at the first file with a violation, in the
+	// order given. Files later in the order are left unprocessed, or cancelled
+	// if they're in progress, and omitted from the report, so it includes just
+	// that one invalid file. It can't be used with Rewrite.
+	FailFast bool
+
+	// ProcessorFor, if non-nil, chooses the processor for each file, such as
+	// one with the configuration of the file's directory. If it yields nil,
+	// the processor passed to ProcessFiles is used. It may be called
+	// concurrently. Processors that differ should have different CacheKeys.
+	ProcessorFor func(path string) *Processor
+}
+
+// FileResult is the result of processing a single file.
+type FileResult struct {
+	// Path is the path of the file.
+	Path string
+	// Package is the import path of the package the file belongs to, if it
+	// was processed by ProcessPackages.
+	Package string
+	// Src is the original content of the file.
+	Src []byte
+	// Violation is the first import grouping violation, or nil if the import
+	// grouping was correct.
+	Violation *ValidationError
+	// Violations are all the import grouping violations.
+	Violations []*ValidationError
+	// Baselined are the violations a Baseline tolerates, which Apply moved
+	// out of Violations.
+	Baselined []*ValidationError
+	// Suppressed are the violations of imports with a //nolint:gogroup
+	// comment, which aren't among Violations.
+	Suppressed []*ValidationError
+	// Fix describes how to repair the import grouping, if it's incorrect.
+	Fix *ImportBlock
+	// Changed is true if the file was rewritten.
+	Changed bool
+	// Warnings describe problems that didn't stop the file being processed.
+	Warnings []string
+	// Cached is true if the file was known to be valid from the cache, so it
+	// wasn't processed further.
+	Cached bool
+	// Skipped is true if the file was not processed, and SkipReason says why.
+	Skipped    bool
+	SkipReason string
+	// Err is any error that prevented processing the file.
+	Err error
+}
+
+// Reasons for skipping files.
+const (
+	// SkipDirective means the file has an ignore directive.
+	SkipDirective = "directive"
+	// SkipTooLarge means the file exceeds the maximum file size.
+	SkipTooLarge = "too large"
+)
+
+// SkippedFiles yields results for files that were skipped without being
+// processed, such as files found by FindFiles that were too large. They can be
+// added to a Report, so they're counted.
+func SkippedFiles(paths []string, reason string) []*FileResult {
+	ret := []*FileResult{}
+	for _, path := range paths {
+		ret = append(ret, &FileResult{Path: path, Skipped: true, SkipReason: reason})
+	}
+	return ret
+}
+
+// ProcessFiles validates, or optionally rewrites, many files at once.
+//
+// Files are processed concurrently, but the report always lists them in the
+// order of paths, however processing is scheduled. So output written from it
+// is the same from one run to the next.
+//
+// Errors processing individual files are recorded in the report, rather than
+// stopping processing. An error is only returned if processing as a whole
+// fails, such as when the context is cancelled.
+func ProcessFiles(ctx context.Context, paths []string, p *Processor, opts RunOptions) (*Report, error) {
+	return p.processFiles(ctx, paths, opts)
+}
+
+// Process many files concurrently.
+func (p *Processor) processFiles(ctx context.Context, paths []string, opts RunOptions) (*Report, error) {
+	if opts.Rewrite && opts.ReadFile != nil {
+		return nil, errors.New("Can't rewrite files with a custom ReadFile")
+	}
+	if opts.Rewrite && opts.FailFast {
+		return nil, errors.New("Can't rewrite files when failing fast")
+	}
+
+	workers := opts.Concurrency
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	readFile := opts.ReadFile
+	if readFile == nil {
+		readFile = ioutil.ReadFile
+	}
+
+	report := &Report{Files: make([]*FileResult, len(paths))}
+	indices := make(chan int)
+	var wg sync.WaitGroup
+
+	// When failing fast, finding an invalid file cancels runCtx, so that no
+	// more files are started, along with the contexts of later files in
+	// progress, whose results aren't kept. Earlier files still finish, in case
+	// one of them is invalid too.
+	runCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	var mu sync.Mutex
+	last := len(paths) - 1
+	inProgress := map[int]context.CancelFunc{}
+	start := func(idx int) (context.Context, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		if idx > last {
+			return nil, false
+		}
+		fileCtx, cancel := context.WithCancel(ctx)
+		inProgress[idx] = cancel
+		return fileCtx, true
+	}
+	finish := func(idx int, res *FileResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		inProgress[idx]()
+		delete(inProgress, idx)
+		if opts.FailFast && res.Violation != nil && idx < last {
+			last = idx
+			stop()
+			for later, cancel := range inProgress {
+				if later > idx {
+					cancel()
+				}
+			}
+		}
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range indices {
+				fileCtx, ok := start(idx)
+				if !ok {
+					continue
+				}
+				proc := p
+				if opts.ProcessorFor != nil {
+					if chosen := opts.ProcessorFor(paths[idx]); chosen != nil {
+						proc = chosen
+					}
+				}
+				res := proc.processFile(fileCtx, paths[idx], readFile, opts)
+				report.Files[idx] = res
+				finish(idx, res)
+			}
+		}()
+	}
+
+	var err error
+feed:
+	for i := range paths {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		select {
+		case indices <- i:
+		case <-runCtx.Done():
+			err = ctx.Err()
+			break feed
+		}
+	}
+	close(indices)
+	wg.Wait()
+
+	if err != nil {
+		return nil, err
+	}
+	report.Files = report.Files[:last+1]
+	return report, nil
+}
+
+// Process a single file.
+func (p *Processor) processFile(ctx context.Context, path string, readFile func(string) ([]byte, error),
+	opts RunOptions) *FileResult {
+	res := &FileResult{Path: path}
+	res.Src, res.Err = readFile(path)
+	if res.Err == nil {
+		res.Err = ctx.Err()
+	}
+	if res.Err != nil {
+		return res
+	}
+
+	cache := opts.Cache
+	if opts.Rewrite && opts.Goimports {
+		cache = nil
+	}
+	scope := p.cacheScope(path)
+	if cache != nil && cache.valid(res.Src, scope) {
+		if debugging(p.logger) {
+			p.logger.Debug("file skipped", "file", path, "reason", "cached")
+		}
+		res.Cached = true
+		return res
+	}
+
+	res.Skipped, res.Violations, res.Suppressed, res.Err = p.validateSource(path, res.Src)
+	if res.Err != nil || res.Skipped {
+		if res.Skipped {
+			res.SkipReason = SkipDirective
+		}
+		return res
+	}
+	if len(res.Violations) > 0 {
+		res.Violation = res.Violations[0]
+	} else if cache != nil && len(res.Suppressed) == 0 {
+		// Files with suppressed violations are checked again, to count them.
+		cache.markValid(res.Src, scope)
+	}
+	if res.Violation != nil {
+		res.Fix, res.Err = p.RepairBlock(path, bytes.NewReader(res.Src))
+		if res.Err != nil {
+			return res
+		}
+	}
+
+	if opts.Rewrite && (res.Violation != nil || opts.Goimports) {
+		var warning string
+		res.Changed, warning, res.Err = p.rewriteFile(ctx, path, res.Src, opts.Goimports)
+		if warning != "" {
+			res.Warnings = append(res.Warnings, warning)
+		}
+	}
+	return res
+}
+
+// Rewrite a file with its import grouping repaired. Yields whether the file
+// was changed, and any warning.
+func (p *Processor) rewriteFile(ctx context.Context, path string, src []byte, goimports bool) (bool, string, error) {
+	var r io.Reader
+	var err error
+	if goimports {
+		r, err = p.Reformat(path, bytes.NewReader(src))
+	} else {
+		r, err = p.Repair(path, bytes.NewReader(src))
+	}
+	if err != nil {
+		return false, "", err
+	}
+
+	var data []byte
+	if r != nil {
+		if data, err = ioutil.ReadAll(r); err != nil {
+			return false, "", err
+		}
+	}
+	if r == nil || bytes.Equal(data, src) {
+		if debugging(p.logger) {
+			p.logger.Debug("write skipped", "file", path, "reason", "identical")
+		}
+		return false, "", nil
+	}
+	warning, err := writeFileAtomic(ctx, path, data)
+	return err == nil, warning, err
+}
@@ -0,0 +1,276 @@
+package gogroup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessFiles(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	valid := filepath.Join(dir, "valid.go")
+	invalid := filepath.Join(dir, "invalid.go")
+	missing := filepath.Join(dir, "missing.go")
+	assert.Nil(t, ioutil.WriteFile(valid, []byte("package a\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n"), 0644))
+	assert.Nil(t, ioutil.WriteFile(invalid, []byte("package a\n\nimport (\n\t\"os\"\n\t\"fmt\"\n)\n"), 0644))
+
+	proc := NewProcessor(grouperGoimports{})
+	paths := []string{valid, invalid, missing}
+	report, err := ProcessFiles(context.Background(), paths, proc, RunOptions{Concurrency: 2})
+	assert.Nil(t, err)
+	assert.Len(t, report.Files, 3)
+	for i, res := range report.Files {
+		assert.Equal(t, paths[i], res.Path)
+	}
+	assert.Nil(t, report.Files[0].Violation)
+	assert.Equal(t, 5, report.Files[1].Violation.Line)
+	assert.NotNil(t, report.Files[1].Fix)
+	assert.NotNil(t, report.Files[2].Err)
+	assert.True(t, report.HasViolations())
+	assert.True(t, report.HasErrors())
+	assert.Equal(t, 1, report.Violations())
+	assert.Equal(t, 0, report.Changed())
+
+	var buf bytes.Buffer
+	assert.Nil(t, report.Write(&buf, "text"))
+	assert.Equal(t, invalid+":5: Import out of order within import group at \"fmt\" [GI001]\n", buf.String())
+	assert.NotNil(t, report.Write(&buf, "bogus"))
+
+	// Rewriting fixes only the invalid file.
+	paths = []string{valid, invalid}
+	report, err = ProcessFiles(context.Background(), paths, proc, RunOptions{Rewrite: true})
+	assert.Nil(t, err)
+	assert.False(t, report.HasErrors())
+	assert.False(t, report.Files[0].Changed)
+	assert.True(t, report.Files[1].Changed)
+	fixed, err := ioutil.ReadFile(invalid)
+	assert.Nil(t, err)
+	assert.Equal(t, "package a\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n", string(fixed))
+
+	// Custom readers can't be combined with rewriting.
+	_, err = ProcessFiles(context.Background(), paths, proc, RunOptions{
+		Rewrite:  true,
+		ReadFile: ioutil.ReadFile,
+	})
+	assert.NotNil(t, err)
+
+	// Summaries count everything.
+	report.Files = append(report.Files, SkippedFiles([]string{"large.go"}, SkipTooLarge)...)
+	buf.Reset()
+	assert.Nil(t, report.WriteSummary(&buf))
+	assert.Equal(t, "Files: 3\nViolations: 1 in 1 files (statement-order: 1)\n"+
+		"Violations by directory: "+dir+": 1\nChanged: 1\n"+
+		"Skipped: 1 (too large: 1)\nErrors: 0\n", buf.String())
+
+	buf.Reset()
+	assert.Nil(t, report.WriteSummaryJSON(&buf))
+	summary := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &summary))
+	assert.Equal(t, 1.0, summary["violations"])
+	assert.Equal(t, map[string]interface{}{"statement-order": 1.0}, summary["violationsByRule"])
+	assert.Equal(t, map[string]interface{}{"too large": 1.0}, summary["skippedByReason"])
+
+	// Ignored files are skipped.
+	skipped := filepath.Join(dir, "skipped.go")
+	assert.Nil(t, ioutil.WriteFile(skipped, []byte("//group-imports:ignore\npackage a\n\nimport (\n\t\"os\"\n\t\"fmt\"\n)\n"), 0644))
+	report, err = ProcessFiles(context.Background(), []string{skipped}, proc, RunOptions{Rewrite: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, report.Skipped())
+	assert.Equal(t, map[string]int{SkipDirective: 1}, report.SkippedByReason())
+	assert.False(t, report.HasViolations())
+	assert.False(t, report.Files[0].Changed)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = ProcessFiles(ctx, paths, proc, RunOptions{})
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestViolationsByDir(t *testing.T) {
+	t.Parallel()
+
+	violations := []*ValidationError{{Rule: "statement-order"}, {Rule: "group-order"}}
+	report := &Report{Files: []*FileResult{
+		{Path: "a.go", Violations: violations},
+		{Path: "cli/b.go", Violations: violations[:1]},
+		{Path: "cli/sub/c.go", Violations: violations},
+		{Path: "internal/d.go"},
+	}}
+	assert.Equal(t, map[string]int{".": 2, "cli": 3}, report.ViolationsByDir())
+	assert.Equal(t, map[string]int{"statement-order": 3, "group-order": 2}, report.ViolationsByRule())
+}
+
+func TestTruncated(t *testing.T) {
+	t.Parallel()
+
+	violations := []*ValidationError{{Rule: "statement-order"}, {Rule: "group-order"}}
+	report := &Report{Files: []*FileResult{
+		{Path: "a.go", Violation: violations[0], Violations: violations},
+		{Path: "b.go"},
+		{Path: "c.go", Violation: violations[0], Violations: violations[:1]},
+		{Path: "d.go", Violation: violations[0], Violations: violations},
+		{Path: "e.go"},
+	}}
+
+	truncated, omitted := report.Truncated(1)
+	assert.Equal(t, 4, omitted)
+	paths := []string{}
+	for _, f := range truncated.Files {
+		paths = append(paths, f.Path)
+	}
+	assert.Equal(t, []string{"a.go", "b.go", "e.go"}, paths)
+	assert.Equal(t, violations[:1], truncated.Files[0].Violations)
+	assert.Equal(t, 1, truncated.Violations())
+	assert.Equal(t, 5, report.Violations())
+	assert.Len(t, report.Files[0].Violations, 2)
+
+	truncated, omitted = report.Truncated(3)
+	assert.Equal(t, 2, omitted)
+	assert.Equal(t, report.Files[:3], truncated.Files[:3])
+	assert.Equal(t, 3, truncated.Violations())
+
+	truncated, omitted = report.Truncated(5)
+	assert.Equal(t, 0, omitted)
+	assert.Equal(t, report.Files, truncated.Files)
+}
+
+func TestFailFast(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	valid := "package main\n\nimport (\n\t\"os\"\n\n\t\"github.com/x/y\"\n)\n"
+	invalid := "package main\n\nimport (\n\t\"github.com/x/y\"\n\n\t\"os\"\n)\n"
+	paths := []string{}
+	for i, src := range []string{valid, valid, invalid, valid, invalid, valid} {
+		path := filepath.Join(dir, fmt.Sprintf("%d.go", i))
+		assert.Nil(t, ioutil.WriteFile(path, []byte(src), 0644))
+		paths = append(paths, path)
+	}
+
+	proc := NewProcessor(grouperGoimports{})
+	for _, workers := range []int{1, 2, 8} {
+		report, err := ProcessFiles(context.Background(), paths, proc,
+			RunOptions{FailFast: true, Concurrency: workers})
+		assert.Nil(t, err)
+		assert.Equal(t, 3, len(report.Files))
+		assert.Equal(t, paths[2], report.Files[2].Path)
+		assert.Equal(t, 1, report.Violations())
+	}
+
+	// Without any violations, every file is processed.
+	report, err := ProcessFiles(context.Background(), paths[:2], proc, RunOptions{FailFast: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(report.Files))
+
+	_, err = ProcessFiles(context.Background(), paths, proc, RunOptions{FailFast: true, Rewrite: true})
+	assert.NotNil(t, err)
+
+	// A later file in progress when an invalid one is found is cancelled
+	// before it's validated, so it's never cached.
+	cache, err := OpenCache(filepath.Join(dir, "cache"), "config")
+	assert.Nil(t, err)
+	started, read := make(chan struct{}), make(chan struct{})
+	readFile := func(path string) ([]byte, error) {
+		switch path {
+		case paths[2]:
+			<-started
+			defer close(read)
+		case paths[3]:
+			close(started)
+			<-read
+			time.Sleep(100 * time.Millisecond)
+		}
+		return ioutil.ReadFile(path)
+	}
+	report, err = ProcessFiles(context.Background(), paths[2:4], proc,
+		RunOptions{FailFast: true, Concurrency: 2, ReadFile: readFile, Cache: cache})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(report.Files))
+	assert.False(t, cache.valid([]byte(valid), proc.cacheScope(paths[3])))
+}
+
+func TestProcessorFor(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// The file is valid with one processor, but not the other.
+	path := filepath.Join(dir, "a.go")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("package a\n\nimport (\n\t\"os\"\n\t\"github.com/x/y\"\n)\n"), 0644))
+	strict := NewProcessor(grouperGoimports{})
+	lenient := NewProcessor(grouperGoimports{}, Lenient(true), CacheKey("lenient"))
+	cache, err := OpenCache(filepath.Join(dir, "cache"), "config")
+	assert.Nil(t, err)
+
+	opts := RunOptions{Cache: cache, ProcessorFor: func(string) *Processor { return lenient }}
+	report, err := ProcessFiles(context.Background(), []string{path}, strict, opts)
+	assert.Nil(t, err)
+	assert.False(t, report.HasViolations())
+
+	// The lenient processor's cache entries don't apply to the other.
+	opts.ProcessorFor = func(string) *Processor { return nil }
+	report, err = ProcessFiles(context.Background(), []string{path}, strict, opts)
+	assert.Nil(t, err)
+	assert.False(t, report.Files[0].Cached)
+	assert.True(t, report.HasViolations())
+}
+
+func TestProcessFilesDeterministic(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	srcs := []string{
+		"package main\n\nimport (\n\t\"os\"\n\n\t\"github.com/x/y\"\n)\n",
+		"package main\n\nimport (\n\t\"github.com/x/y\"\n\n\t\"os\"\n)\n",
+		"package main\n\nimport (\n\t\"os\"\n\t\"github.com/x/y\"\n)\n",
+		"package main\n\nimport \"os\n",
+	}
+	for i := 0; i < 60; i++ {
+		sub := filepath.Join(dir, fmt.Sprintf("d%d", i%7))
+		assert.Nil(t, os.MkdirAll(sub, 0755))
+		path := filepath.Join(sub, fmt.Sprintf("f%d.go", i))
+		assert.Nil(t, ioutil.WriteFile(path, []byte(srcs[i%len(srcs)]), 0644))
+	}
+	found, err := FindFiles([]string{dir}, FindOptions{})
+	assert.Nil(t, err)
+
+	// Every format yields the same output from run to run, however files are
+	// scheduled.
+	proc := NewProcessor(grouperGoimports{})
+	output := func() string {
+		report, err := ProcessFiles(context.Background(), found.Files, proc, RunOptions{Concurrency: 16})
+		assert.Nil(t, err)
+		var buf bytes.Buffer
+		for _, format := range FormatNames() {
+			assert.Nil(t, report.Write(&buf, format))
+		}
+		assert.Nil(t, report.WriteSummary(&buf))
+		assert.Nil(t, report.WriteSummaryJSON(&buf))
+		return buf.String()
+	}
+	first := output()
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, first, output())
+	}
+}
@@ -0,0 +1,80 @@
+package gogroup
+
+import "fmt"
+
+// Severity is how serious a violation is. The zero Severity is
+// SeverityError, so violations are errors unless configured otherwise.
+type Severity int
+
+// The severities, from most to least serious.
+const (
+	// SeverityError is for violations that must be fixed.
+	SeverityError Severity = iota
+	// SeverityWarning is for violations that are reported, but which
+	// needn't be fixed yet, such as during a migration.
+	SeverityWarning
+	// SeverityInfo is for violations that are only of interest.
+	SeverityInfo
+)
+
+var severityNames = []string{"error", "warning", "info"}
+
+// String yields the name of a severity, such as "warning".
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+// SeverityNames lists the names of the severities, from most to least
+// serious.
+func SeverityNames() []string {
+	return append([]string{}, severityNames...)
+}
+
+// ParseSeverity yields the severity with the given name.
+func ParseSeverity(name string) (Severity, error) {
+	for i, n := range severityNames {
+		if n == name {
+			return Severity(i), nil
+		}
+	}
+	return SeverityError, fmt.Errorf("Unknown severity '%s', expected one of: error, warning, info", name)
+}
+
+// MarshalText implements encoding.TextMarshaler, with the name of a severity.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// AtLeast determines whether a severity is at least as serious as another.
+func (s Severity) AtLeast(other Severity) bool {
+	return s <= other
+}
+
+// Severities sets the severity of the violations of rules, keyed by their
+// IDs, such as "GI003", or for built-in rules, by the names of their kinds'
+// rules, such as "statement-extra-line". An ID takes precedence over a name.
+// Violations of other rules are errors.
+func Severities(levels map[string]Severity) Option {
+	return func(p *Processor) {
+		p.severities = levels
+	}
+}
+
+// Determine the severity of the violations of the rule with an ID.
+func (p *Processor) severity(id string) Severity {
+	if s, ok := p.severities[id]; ok {
+		return s
+	}
+	return SeverityError
+}
+
+// Determine the severity of the violations of the built-in rule of a kind.
+func (p *Processor) kindSeverity(kind Kind) Severity {
+	if s, ok := p.severities[kind.ID()]; ok {
+		return s
+	}
+	return p.severity(kind.Rule())
+}
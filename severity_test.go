@@ -0,0 +1,78 @@
+package gogroup
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSeverity(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range SeverityNames() {
+		severity, err := ParseSeverity(name)
+		assert.Nil(t, err)
+		assert.Equal(t, name, severity.String())
+	}
+	_, err := ParseSeverity("fatal")
+	assert.NotNil(t, err)
+	assert.True(t, SeverityError.AtLeast(SeverityWarning))
+	assert.False(t, SeverityInfo.AtLeast(SeverityWarning))
+}
+
+func TestSeverities(t *testing.T) {
+	t.Parallel()
+
+	src := "package a\n\nimport (\n\t\"unsafe\"\n\t\"os\"\n\t\"github.com/x/y\"\n)\n"
+	severities := func(levels map[string]Severity) []Severity {
+		proc := NewProcessor(grouperGoimports{}, Severities(levels))
+		proc.AddRule(unsafeRule{})
+		errs, err := proc.ValidateAll("a.go", strings.NewReader(src))
+		assert.Nil(t, err)
+		ret := []Severity{}
+		for _, validErr := range errs {
+			ret = append(ret, validErr.Severity)
+		}
+		return ret
+	}
+	// The violations are of ACME001, GI001 and GI004.
+	assert.Equal(t, []Severity{SeverityError, SeverityError, SeverityError}, severities(nil))
+	assert.Equal(t, []Severity{SeverityInfo, SeverityWarning, SeverityError}, severities(map[string]Severity{
+		"ACME001":         SeverityInfo,
+		"statement-order": SeverityWarning,
+	}))
+	// IDs take precedence over rule names.
+	assert.Equal(t, []Severity{SeverityError, SeverityError, SeverityInfo}, severities(map[string]Severity{
+		"statement-group": SeverityWarning,
+		"GI004":           SeverityInfo,
+	}))
+}
+
+func TestReportSeverities(t *testing.T) {
+	t.Parallel()
+
+	report := &Report{Files: []*FileResult{
+		{Path: "a.go", Violations: []*ValidationError{
+			{Line: 3, Rule: "statement-order", Severity: SeverityWarning},
+			{Line: 4, Rule: "statement-order", Severity: SeverityInfo},
+		}},
+	}}
+	assert.True(t, report.HasViolations())
+	assert.False(t, report.HasViolationsAt(SeverityError))
+	assert.True(t, report.HasViolationsAt(SeverityWarning))
+	assert.Equal(t, map[string]int{"warning": 1, "info": 1}, report.ViolationsBySeverity())
+
+	var buf bytes.Buffer
+	assert.Nil(t, report.WriteSummary(&buf))
+	assert.Contains(t, buf.String(), "Violations by severity: info: 1, warning: 1\n")
+
+	// The breakdown is left out when every violation is an error.
+	report.Files[0].Violations[0].Severity = SeverityError
+	report.Files[0].Violations[1].Severity = SeverityError
+	buf.Reset()
+	assert.Nil(t, report.WriteSummary(&buf))
+	assert.NotContains(t, buf.String(), "by severity")
+	assert.True(t, report.HasViolationsAt(SeverityError))
+}
@@ -0,0 +1,513 @@
+package gogroup
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+var (
+	_ GroupNamer    = (*SpecGrouper)(nil)
+	_ StrictGrouper = (*SpecGrouper)(nil)
+	_ flag.Value    = (*SpecGrouper)(nil)
+)
+
+// The kinds of group in an order specification.
+type specKind int
+
+const (
+	specStd specKind = iota
+	specOther
+	specPrefix
+)
+
+// A single group in an order specification.
+type specGroup struct {
+	kind specKind
+
+	// The prefixes, for prefix groups, and the file they were read from if
+	// they weren't given directly.
+	prefixes []string
+	file     string
+
+	// The name of the group, if any.
+	name string
+
+	// Whether the group was declared, rather than defaulted.
+	declared bool
+
+	// The one-based position of the declaration in the order specification,
+	// or zero if it wasn't declared.
+	position int
+}
+
+// The specification of a group, without its name.
+func (gr specGroup) spec() string {
+	switch gr.kind {
+	case specStd:
+		return "std"
+	case specOther:
+		return "other"
+	default:
+		if gr.file != "" {
+			return fmt.Sprintf("prefix-file=%s", gr.file)
+		}
+		return fmt.Sprintf("prefix=%s", gr.prefixes[0])
+	}
+}
+
+// Determine whether an import path matches any prefix of a group.
+func (gr specGroup) matches(pkg string) bool {
+	for _, prefix := range gr.prefixes {
+		if strings.HasPrefix(pkg, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (gr specGroup) String() string {
+	s := gr.spec()
+	if gr.name != "" {
+		s += ":" + gr.name
+	}
+	return s
+}
+
+// SpecGrouper is a Grouper configured by an order specification, such as
+// "std,prefix=github.com/example/,other". Each group may be followed by a
+// name, such as "prefix=github.com/example/:Internal".
+//
+// A group may also match any of the prefixes listed in a file, such as
+// "prefix-file=first-party.txt". The file is read when the order is set,
+// with a prefix on each line. Blank lines are skipped, and # starts a
+// comment.
+//
+// Listing "strict" removes the default other group, so that imports matching
+// no group belong to none.
+//
+// It implements flag.Value, so it can be configured by command-line flags,
+// with the same specifications as the -order flag of the gogroup command.
+type SpecGrouper struct {
+	// The groups, in order. The index of each group is its group number.
+	groups []specGroup
+
+	// Whether the order was set, rather than defaulted.
+	set bool
+
+	// Whether there's no other group, so some imports match no group.
+	strict bool
+
+	// The number of parts of the order specification declared so far.
+	parts int
+
+	// A trie of the prefix groups, if there are enough of them that scanning
+	// them one by one would be slow.
+	prefixes *PrefixGrouper
+
+	// The Grouper from a rules document, and the compacted document, if one
+	// replaced the order.
+	rules     StrictGrouper
+	rulesText string
+}
+
+// The number of prefix groups above which they're looked up in a trie.
+const triePrefixes = 64
+
+// NewSpecGrouper creates a SpecGrouper with the default order, "std,other".
+func NewSpecGrouper() *SpecGrouper {
+	return &SpecGrouper{
+		groups: []specGroup{{kind: specStd}, {kind: specOther}},
+	}
+}
+
+// ParseSpec creates a SpecGrouper with the groups of an order specification,
+// as Set appends them to the default order.
+func ParseSpec(s string) (*SpecGrouper, error) {
+	g := NewSpecGrouper()
+	if err := g.Set(s); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Find the group number of the group of a kind, or -1 if there is none.
+func (g *SpecGrouper) find(k specKind) int {
+	for i, gr := range g.groups {
+		if gr.kind == k {
+			return i
+		}
+	}
+	return -1
+}
+
+// Determine whether any group has the given name.
+func (g *SpecGrouper) named(name string) bool {
+	for _, gr := range g.groups {
+		if gr.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Group implements Grouper.
+//
+// Prefix groups are checked in the order they were declared, so the first
+// matching prefix wins.
+func (g *SpecGrouper) Group(pkg string) int {
+	if g.rules != nil {
+		return g.rules.Group(pkg)
+	}
+	if g.prefixes != nil {
+		if group, ok := g.prefixes.Lookup(pkg); ok {
+			return group
+		}
+	} else {
+		for i, gr := range g.groups {
+			if gr.kind == specPrefix && gr.matches(pkg) {
+				return i
+			}
+		}
+	}
+
+	// A dot distinguishes non-standard packages.
+	if strings.Contains(pkg, ".") {
+		return g.find(specOther)
+	}
+
+	return g.find(specStd)
+}
+
+// LookupGroup implements StrictGrouper. Imports match no group only
+// if the order is strict.
+func (g *SpecGrouper) LookupGroup(pkg string) (int, bool) {
+	if g.rules != nil {
+		return g.rules.LookupGroup(pkg)
+	}
+	group := g.Group(pkg)
+	return group, group >= 0
+}
+
+// Strict determines whether imports may match no group, because "strict" was
+// listed instead of the other group.
+func (g *SpecGrouper) Strict() bool {
+	return g.strict
+}
+
+// GroupName implements GroupNamer.
+func (g *SpecGrouper) GroupName(group int) string {
+	if g.rules != nil {
+		return g.rules.(GroupNamer).GroupName(group)
+	}
+	if group < 0 || group >= len(g.groups) {
+		return ""
+	}
+	return g.groups[group].name
+}
+
+// An Explanation describes how an import path was assigned its group.
+type Explanation struct {
+	// Group is the group number, or -1 if the path matches no group.
+	Group int `json:"group"`
+	// Name is the name of the group, if it has one.
+	Name string `json:"name,omitempty"`
+	// Spec is the specification of the group, such as "std" or
+	// "prefix=github.com/corp/", or for a rules document the rule that
+	// matched, or "default".
+	Spec string `json:"spec,omitempty"`
+	// Position is the one-based position at which the group was declared in
+	// the order specification, or zero if it's there by default or the order
+	// is a rules document.
+	Position int `json:"position,omitempty"`
+}
+
+// ExplainGroup describes how an import path is assigned its group. Unlike
+// Explainer, it describes groups of order specifications too.
+func (g *SpecGrouper) ExplainGroup(pkg string) *Explanation {
+	group, ok := g.LookupGroup(pkg)
+	if !ok {
+		return &Explanation{Group: -1}
+	}
+	if g.rules != nil {
+		ex := &Explanation{Group: group, Name: g.GroupName(group)}
+		if explainer, ok := g.rules.(Explainer); ok {
+			ex.Spec = explainer.Explain(pkg)
+		}
+		return ex
+	}
+	gr := g.groups[group]
+	return &Explanation{Group: group, Name: gr.name, Spec: gr.spec(), Position: gr.position}
+}
+
+// Headers yields a header comment for each named group, for
+// GroupHeaders. The header of a group named "Internal" is
+// "// Internal".
+func (g *SpecGrouper) Headers() map[int]string {
+	headers := map[int]string{}
+	if g.rules != nil {
+		// Every group of a rules document is named.
+		for i := 0; g.GroupName(i) != ""; i++ {
+			headers[i] = "// " + g.GroupName(i)
+		}
+		return headers
+	}
+	for i, gr := range g.groups {
+		if gr.name != "" {
+			headers[i] = "// " + gr.name
+		}
+	}
+	return headers
+}
+
+// WasSet determines whether the order has been set, rather than defaulted.
+func (g *SpecGrouper) WasSet() bool {
+	return g.set
+}
+
+// Build a trie of the prefix groups if there are many of them, or remove it if
+// there are few.
+func (g *SpecGrouper) indexPrefixes() {
+	prefixes := []PrefixGroup{}
+	for i, gr := range g.groups {
+		if gr.kind == specPrefix {
+			for _, prefix := range gr.prefixes {
+				prefixes = append(prefixes, PrefixGroup{Prefix: prefix, Group: i})
+			}
+		}
+	}
+	g.prefixes = nil
+	if len(prefixes) > triePrefixes {
+		g.prefixes = NewPrefixGrouper(prefixes)
+	}
+}
+
+// String yields the order specification.
+func (g *SpecGrouper) String() string {
+	if g.rules != nil {
+		return g.rulesText
+	}
+	parts := []string{}
+	for _, gr := range g.groups {
+		parts = append(parts, gr.String())
+	}
+	if g.strict {
+		parts = append(parts, "strict")
+	}
+	return strings.Join(parts, ",")
+}
+
+var (
+	rePrefix     = regexp.MustCompile(`^prefix=(.*)$`)
+	rePrefixFile = regexp.MustCompile(`^prefix-file=(.*)$`)
+)
+
+// The order specifications that Set accepts, for error messages.
+const validSpecs = "std, other, prefix=PREFIX, prefix-file=PATH, each optionally followed by :NAME, or strict"
+
+// Read the prefixes listed in a file, one per line. Blank lines are skipped,
+// and # starts a comment, since import paths can't contain it.
+func readPrefixes(path string) ([]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	prefixes := []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			prefixes = append(prefixes, line)
+		}
+	}
+	if len(prefixes) == 0 {
+		return nil, fmt.Errorf("%s: No prefixes", path)
+	}
+	return prefixes, nil
+}
+
+// The error combining an order specification with a rules document.
+var errCombined = errors.New("An order specification can't be combined with a JSON order")
+
+// UseRules replaces the order with the groups of a rules document in YAML or
+// JSON, as read by GrouperFromConfig. The order is still considered unset.
+func (g *SpecGrouper) UseRules(data []byte) error {
+	root, err := parseDocument(data)
+	if err != nil {
+		return err
+	}
+	return g.useRules(root)
+}
+
+// Replace the order with the groups of the rules document at a node, keeping
+// the document as compact JSON.
+func (g *SpecGrouper) useRules(root *yaml.Node) error {
+	rules, err := rulesFromNode(root)
+	if err != nil {
+		return err
+	}
+	text, err := nodeJSON(root)
+	if err != nil {
+		return err
+	}
+	g.rules, g.rulesText = rules, string(text)
+	return nil
+}
+
+// SetJSON replaces the order with the groups of a rules document, like
+// UseRules, but the order is considered set. It's an error to combine it with
+// an order specification.
+func (g *SpecGrouper) SetJSON(data []byte) error {
+	if g.set && g.rules == nil {
+		return errCombined
+	}
+	if err := g.UseRules(data); err != nil {
+		return err
+	}
+	g.set = true
+	return nil
+}
+
+// JSON yields the order as an indented rules document, which SetJSON accepts.
+// An order specification is converted to the equivalent document, in which
+// unnamed groups are named after their specification, such as "std".
+//
+// Prefix groups take precedence over std in an order specification, but not
+// in a rules document, so it's an error for a prefix group that std could
+// match to come after it.
+func (g *SpecGrouper) JSON() ([]byte, error) {
+	if g.rules != nil {
+		var buf bytes.Buffer
+		err := json.Indent(&buf, []byte(g.rulesText), "", "  ")
+		return buf.Bytes(), err
+	}
+
+	type jsonGroup struct {
+		Name     string   `json:"name"`
+		Std      bool     `json:"std,omitempty"`
+		Prefixes []string `json:"prefixes,omitempty"`
+	}
+	var doc struct {
+		Groups  []jsonGroup `json:"groups"`
+		Default string      `json:"default,omitempty"`
+	}
+	std := false
+	for _, gr := range g.groups {
+		jg := jsonGroup{Name: gr.name}
+		if jg.Name == "" {
+			jg.Name = gr.spec()
+		}
+		switch gr.kind {
+		case specStd:
+			jg.Std, std = true, true
+		case specOther:
+			doc.Default = jg.Name
+		case specPrefix:
+			for _, prefix := range gr.prefixes {
+				if std && !strings.Contains(strings.SplitN(prefix, "/", 2)[0], ".") {
+					return nil, fmt.Errorf("Order specification '%s' can't be written as JSON, "+
+						"since the earlier std group would match its imports", gr)
+				}
+			}
+			jg.Prefixes = gr.prefixes
+		}
+		doc.Groups = append(doc.Groups, jg)
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// Set appends the groups of an order specification.
+//
+// Declaring the std or other group moves it from its default position. It's
+// an error to declare either of them more than once, to declare an empty
+// prefix, to name a prefix file that can't be read or lists no prefixes, or
+// to use the same name for more than one group. It's also an error
+// to declare other in a strict order.
+func (g *SpecGrouper) Set(s string) error {
+	if g.set && g.rules != nil {
+		return errCombined
+	}
+	defer g.indexPrefixes()
+	g.rules = nil
+	parts := strings.Split(s, ",")
+	for _, part := range parts {
+		g.parts++
+		if part == "strict" {
+			if i := g.find(specOther); i >= 0 {
+				if g.groups[i].declared {
+					return errors.New("Order specification 'strict' conflicts with 'other'")
+				}
+				g.groups = append(g.groups[:i], g.groups[i+1:]...)
+			}
+			g.strict = true
+			g.set = true
+			continue
+		}
+
+		gr := specGroup{declared: true, position: g.parts}
+
+		// Import paths can't contain colons, so the first one starts the name.
+		p := part
+		if i := strings.Index(part, ":"); i >= 0 {
+			p, gr.name = part[:i], part[i+1:]
+			if gr.name == "" {
+				return fmt.Errorf("Empty name in order specification '%s'", part)
+			}
+			if g.named(gr.name) {
+				return fmt.Errorf("Group name '%s' used more than once", gr.name)
+			}
+		}
+
+		if p == "std" {
+			gr.kind = specStd
+		} else if p == "other" {
+			if g.strict {
+				return errors.New("Order specification 'strict' conflicts with 'other'")
+			}
+			gr.kind = specOther
+		} else if match := rePrefix.FindStringSubmatch(p); match != nil {
+			if match[1] == "" {
+				return fmt.Errorf("Empty prefix in order specification '%s'", part)
+			}
+			gr.kind = specPrefix
+			gr.prefixes = []string{match[1]}
+		} else if match := rePrefixFile.FindStringSubmatch(p); match != nil {
+			if match[1] == "" {
+				return fmt.Errorf("Empty path in order specification '%s'", part)
+			}
+			prefixes, err := readPrefixes(match[1])
+			if err != nil {
+				return fmt.Errorf("Can't read prefixes for order specification '%s': %s", part, err.Error())
+			}
+			gr.kind = specPrefix
+			gr.prefixes, gr.file = prefixes, match[1]
+		} else {
+			return fmt.Errorf("Unknown order specification '%s', expected one of: %s",
+				part, validSpecs)
+		}
+
+		if gr.kind != specPrefix {
+			if i := g.find(gr.kind); i >= 0 {
+				if g.groups[i].declared {
+					return fmt.Errorf("Order specification '%s' given more than once", p)
+				}
+				g.groups = append(g.groups[:i], g.groups[i+1:]...)
+			}
+		}
+		g.groups = append(g.groups, gr)
+		g.set = true
+	}
+
+	if g.find(specStd) < 0 && g.find(specOther) < 0 {
+		return fmt.Errorf("Order specification '%s' defines neither std nor other", s)
+	}
+	return nil
+}
@@ -0,0 +1,314 @@
+package gogroup
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpecString(t *testing.T) {
+	t.Parallel()
+
+	g := NewSpecGrouper()
+	assert.False(t, g.WasSet())
+	assert.Equal(t, "std,other", g.String())
+
+	assert.Nil(t, g.Set("prefix=local/"))
+	assert.True(t, g.WasSet())
+	assert.Equal(t, "std,other,prefix=local/", g.String())
+
+	g = NewSpecGrouper()
+	assert.Nil(t, g.Set("other,prefix=local/"))
+	assert.Nil(t, g.Set("std"))
+	assert.Equal(t, "other,prefix=local/,std", g.String())
+
+	assert.NotNil(t, NewSpecGrouper().Set("prefx=local/"))
+}
+
+func TestParseSpec(t *testing.T) {
+	t.Parallel()
+
+	// Specifications survive a round trip through String.
+	for _, s := range []string{"std,other", "other,std", "std:Standard,prefix=local/:Local,other",
+		"prefix=a/,std,prefix=b/,strict"} {
+		g, err := ParseSpec(s)
+		if assert.Nil(t, err, s) {
+			assert.Equal(t, s, g.String())
+			again, err := ParseSpec(g.String())
+			assert.Nil(t, err)
+			assert.Equal(t, g.String(), again.String())
+		}
+	}
+
+	// Groups not declared keep their default positions.
+	g, err := ParseSpec("prefix=local/")
+	assert.Nil(t, err)
+	assert.Equal(t, "std,other,prefix=local/", g.String())
+	assert.Equal(t, 2, g.Group("local/x"))
+
+	_, err = ParseSpec("std,bogus")
+	assert.EqualError(t, err, "Unknown order specification 'bogus', "+
+		"expected one of: std, other, prefix=PREFIX, prefix-file=PATH, each optionally followed by :NAME, or strict")
+}
+
+func TestSpecSetInvalid(t *testing.T) {
+	t.Parallel()
+
+	err := NewSpecGrouper().Set("prefx=local/")
+	assert.EqualError(t, err, "Unknown order specification 'prefx=local/', "+
+		"expected one of: std, other, prefix=PREFIX, prefix-file=PATH, each optionally followed by :NAME, or strict")
+	assert.EqualError(t, NewSpecGrouper().Set("std,prefix="),
+		"Empty prefix in order specification 'prefix='")
+	assert.EqualError(t, NewSpecGrouper().Set("std,std"),
+		"Order specification 'std' given more than once")
+
+	// Repeats are caught across multiple specifications.
+	g := NewSpecGrouper()
+	assert.Nil(t, g.Set("other"))
+	assert.NotNil(t, g.Set("prefix=local/,other"))
+}
+
+func TestSpecGroup(t *testing.T) {
+	t.Parallel()
+
+	g := NewSpecGrouper()
+	assert.Nil(t, g.Set("std,prefix=github.com/corp/,other"))
+	assert.Equal(t, 0, g.Group("os"))
+	assert.Equal(t, 1, g.Group("github.com/corp/svc"))
+	assert.Equal(t, 2, g.Group("github.com/other/svc"))
+}
+
+func TestSpecExplain(t *testing.T) {
+	t.Parallel()
+
+	g := NewSpecGrouper()
+	assert.Nil(t, g.Set("std,prefix=github.com/corp/:Internal"))
+	assert.Nil(t, g.Set("prefix=local/"))
+	assert.Equal(t, &Explanation{Group: 1, Spec: "std", Position: 1}, g.ExplainGroup("os"))
+	assert.Equal(t, &Explanation{Group: 2, Name: "Internal", Spec: "prefix=github.com/corp/", Position: 2},
+		g.ExplainGroup("github.com/corp/svc"))
+	assert.Equal(t, &Explanation{Group: 3, Spec: "prefix=local/", Position: 3}, g.ExplainGroup("local/pkg"))
+	assert.Equal(t, &Explanation{Group: 0, Spec: "other"}, g.ExplainGroup("github.com/other/svc"))
+
+	g = NewSpecGrouper()
+	assert.Nil(t, g.Set("std,strict"))
+	assert.Equal(t, &Explanation{Group: -1}, g.ExplainGroup("github.com/other/svc"))
+
+	g = NewSpecGrouper()
+	assert.Nil(t, g.UseRules([]byte(`{"groups": [{"name": "Standard", "std": true},
+		{"name": "Corp", "prefixes": ["github.com/corp/"]}, {"name": "Other"}], "default": "Other"}`)))
+	assert.Equal(t, &Explanation{Group: 1, Name: "Corp", Spec: "prefix=github.com/corp/"},
+		g.ExplainGroup("github.com/corp/svc"))
+	assert.Equal(t, &Explanation{Group: 2, Name: "Other", Spec: "default"}, g.ExplainGroup("github.com/other/svc"))
+}
+
+func TestSpecStrict(t *testing.T) {
+	t.Parallel()
+
+	g := NewSpecGrouper()
+	assert.Nil(t, g.Set("std,prefix=github.com/corp/,strict"))
+	assert.True(t, g.Strict())
+	assert.Equal(t, "std,prefix=github.com/corp/,strict", g.String())
+	group, ok := g.LookupGroup("github.com/corp/svc")
+	assert.Equal(t, 1, group)
+	assert.True(t, ok)
+	_, ok = g.LookupGroup("github.com/other/svc")
+	assert.False(t, ok)
+	_, ok = g.LookupGroup("os")
+	assert.True(t, ok)
+
+	// The string form can be parsed again.
+	again := NewSpecGrouper()
+	assert.Nil(t, again.Set(g.String()))
+	assert.Equal(t, g.String(), again.String())
+
+	// Without strict, every import matches a group.
+	_, ok = NewSpecGrouper().LookupGroup("github.com/other/svc")
+	assert.True(t, ok)
+	assert.False(t, NewSpecGrouper().Strict())
+
+	assert.NotNil(t, NewSpecGrouper().Set("other,strict"))
+	assert.NotNil(t, NewSpecGrouper().Set("strict,other"))
+}
+
+func TestSpecPrefixFile(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "prefixes.txt")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("# First-party modules\n"+
+		"github.com/corp/\n\n  corp.dev/  # the new domain\n"), 0644))
+
+	g := NewSpecGrouper()
+	spec := "std,prefix-file=" + path + ":Corp,other"
+	assert.Nil(t, g.Set(spec))
+	assert.Equal(t, spec, g.String())
+	assert.Equal(t, 1, g.Group("github.com/corp/x"))
+	assert.Equal(t, 1, g.Group("corp.dev/y"))
+	assert.Equal(t, 2, g.Group("github.com/other/z"))
+	assert.Equal(t, "prefix-file="+path, g.ExplainGroup("corp.dev/y").Spec)
+	data, err := g.JSON()
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"prefixes": [
+        "github.com/corp/",
+        "corp.dev/"
+      ]`)
+
+	// The file is read each time the order is set.
+	assert.Nil(t, ioutil.WriteFile(path, []byte("corp.dev/\n"), 0644))
+	g = NewSpecGrouper()
+	assert.Nil(t, g.Set(spec))
+	assert.Equal(t, 2, g.Group("github.com/corp/x"))
+
+	missing := filepath.Join(dir, "missing.txt")
+	err = NewSpecGrouper().Set("prefix-file=" + missing)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), missing)
+	}
+	assert.Nil(t, ioutil.WriteFile(path, []byte("# nothing\n"), 0644))
+	assert.EqualError(t, NewSpecGrouper().Set("prefix-file="+path),
+		"Can't read prefixes for order specification 'prefix-file="+path+"': "+path+": No prefixes")
+	assert.EqualError(t, NewSpecGrouper().Set("std,prefix-file="),
+		"Empty path in order specification 'prefix-file='")
+}
+
+func TestSpecGroupDeterministic(t *testing.T) {
+	t.Parallel()
+
+	// Overlapping prefixes match in the order they were declared.
+	g := NewSpecGrouper()
+	assert.Nil(t, g.Set("std,prefix=github.com/,prefix=github.com/corp/,prefix=github.com/corp/svc,other"))
+	for i := 0; i < 5000; i++ {
+		if !assert.Equal(t, 1, g.Group("github.com/corp/svc/pkg")) {
+			break
+		}
+	}
+}
+
+func TestSpecGroupManyPrefixes(t *testing.T) {
+	t.Parallel()
+
+	// With many prefixes, they're looked up in a trie, with the same results.
+	specs := []string{"std"}
+	for i := 0; i < 2*triePrefixes; i++ {
+		specs = append(specs, fmt.Sprintf("prefix=github.com/corp/svc%d/", i))
+	}
+	specs = append(specs, "prefix=github.com/corp/", "other")
+	g := NewSpecGrouper()
+	assert.Nil(t, g.Set(strings.Join(specs, ",")))
+	assert.NotNil(t, g.prefixes)
+	assert.Equal(t, 0, g.Group("os"))
+	assert.Equal(t, 1, g.Group("github.com/corp/svc0/pkg"))
+	assert.Equal(t, 11, g.Group("github.com/corp/svc10/pkg"))
+	assert.Equal(t, 2, g.Group("github.com/corp/svc1/pkg"))
+	assert.Equal(t, 2*triePrefixes+1, g.Group("github.com/corp/log"))
+	assert.Equal(t, 2*triePrefixes+2, g.Group("github.com/other/log"))
+
+	// Few prefixes are scanned instead.
+	g = NewSpecGrouper()
+	assert.Nil(t, g.Set("std,prefix=github.com/corp/,other"))
+	assert.Nil(t, g.prefixes)
+}
+
+func TestSpecNames(t *testing.T) {
+	t.Parallel()
+
+	g := NewSpecGrouper()
+	assert.Nil(t, g.Set("std:Standard,other:Third-party,prefix=github.com/corp:Internal"))
+	assert.Equal(t, "std:Standard,other:Third-party,prefix=github.com/corp:Internal", g.String())
+	assert.Equal(t, "Internal", g.GroupName(g.Group("github.com/corp/svc")))
+	assert.Equal(t, "Third-party", g.GroupName(g.Group("github.com/other/svc")))
+	assert.Equal(t, "", g.GroupName(-1))
+
+	// Names are optional.
+	g = NewSpecGrouper()
+	assert.Nil(t, g.Set("std,prefix=local/:Local"))
+	assert.Equal(t, "", g.GroupName(g.Group("os")))
+	assert.Equal(t, "Local", g.GroupName(g.Group("local/pkg")))
+
+	assert.Equal(t, map[int]string{2: "// Local"}, g.Headers())
+
+	assert.EqualError(t, NewSpecGrouper().Set("std:"), "Empty name in order specification 'std:'")
+	assert.EqualError(t, NewSpecGrouper().Set("std:A,other:A"), "Group name 'A' used more than once")
+}
+
+func TestSpecJSON(t *testing.T) {
+	t.Parallel()
+
+	// Rules documents round-trip.
+	doc := `{
+  "groups": [
+    {"name": "Standard", "std": true},
+    {"name": "Internal", "regexes": ["^corp\\.dev/(a,b)"]},
+    {"name": "Third-party"}
+  ],
+  "default": "Third-party"
+}`
+	g := NewSpecGrouper()
+	assert.Nil(t, g.SetJSON([]byte(doc)))
+	assert.True(t, g.WasSet())
+	assert.Equal(t, 1, g.Group("corp.dev/a,b/pkg"))
+	data, err := g.JSON()
+	assert.Nil(t, err)
+	again := NewSpecGrouper()
+	assert.Nil(t, again.SetJSON(data))
+	dataAgain, err := again.JSON()
+	assert.Nil(t, err)
+	assert.Equal(t, string(data), string(dataAgain))
+
+	// Order specifications are converted.
+	g = NewSpecGrouper()
+	assert.Nil(t, g.Set("prefix=local/:Local,std,prefix=github.com/corp/,other:Third-party"))
+	data, err = g.JSON()
+	assert.Nil(t, err)
+	assert.Equal(t, `{
+  "groups": [
+    {
+      "name": "Local",
+      "prefixes": [
+        "local/"
+      ]
+    },
+    {
+      "name": "std",
+      "std": true
+    },
+    {
+      "name": "prefix=github.com/corp/",
+      "prefixes": [
+        "github.com/corp/"
+      ]
+    },
+    {
+      "name": "Third-party"
+    }
+  ],
+  "default": "Third-party"
+}`, string(data))
+	converted := NewSpecGrouper()
+	assert.Nil(t, converted.SetJSON(data))
+	for _, pkg := range []string{"os", "local/pkg", "github.com/corp/svc", "github.com/other/svc"} {
+		assert.Equal(t, g.Group(pkg), converted.Group(pkg), pkg)
+	}
+
+	// Unless std would match a prefix group first.
+	g = NewSpecGrouper()
+	assert.Nil(t, g.Set("std,prefix=local/"))
+	_, err = g.JSON()
+	assert.NotNil(t, err)
+
+	// The two forms can't be combined.
+	g = NewSpecGrouper()
+	assert.Nil(t, g.Set("std"))
+	assert.EqualError(t, g.SetJSON([]byte(doc)), "An order specification can't be combined with a JSON order")
+	g = NewSpecGrouper()
+	assert.Nil(t, g.SetJSON([]byte(doc)))
+	assert.NotNil(t, g.Set("std"))
+}
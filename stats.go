@@ -0,0 +1,169 @@
+package gogroup
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ImportStats describes what many files import, and how their imports are
+// grouped.
+type ImportStats struct {
+	Files   int `json:"files"`
+	Imports int `json:"imports"`
+	// ImportsByGroup counts the imports in each group, and FilesByGroup counts
+	// the files with any imports in each group. Groups are keyed by name, or by
+	// number if the grouper doesn't name them, and imports in no group are
+	// under "none".
+	ImportsByGroup map[string]int `json:"importsByGroup"`
+	FilesByGroup   map[string]int `json:"filesByGroup"`
+	// FilesByGroupCount counts the files with each number of groups.
+	FilesByGroupCount map[string]int `json:"filesByGroupCount"`
+	// Paths counts the imports of each path.
+	Paths map[string]int `json:"-"`
+}
+
+// A PathCount is the number of times a path is imported.
+type PathCount struct {
+	Path  string `json:"path"`
+	Count int    `json:"count"`
+}
+
+// CollectStats reads the imports of many files, and describes how they're
+// grouped. Nothing is validated, but it's an error for any file not to parse.
+func CollectStats(paths []string, p *Processor) (*ImportStats, error) {
+	stats := &ImportStats{
+		ImportsByGroup:    map[string]int{},
+		FilesByGroup:      map[string]int{},
+		FilesByGroupCount: map[string]int{},
+		Paths:             map[string]int{},
+	}
+	for _, path := range paths {
+		if err := p.addStats(stats, path); err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
+
+// Add the imports of a file to stats.
+func (p *Processor) addStats(stats *ImportStats, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, _, gs, err := p.readImports(path, f)
+	if err != nil {
+		return err
+	}
+
+	groups := map[string]bool{}
+	for _, g := range gs {
+		name := "none"
+		if !g.unassigned {
+			name = groupName(p.grouper, g.group)
+			if name == "" {
+				name = strconv.Itoa(g.group)
+			}
+		}
+		stats.Imports++
+		stats.ImportsByGroup[name]++
+		stats.Paths[g.path]++
+		groups[name] = true
+	}
+	for name := range groups {
+		stats.FilesByGroup[name]++
+	}
+	stats.FilesByGroupCount[strconv.Itoa(len(groups))]++
+	stats.Files++
+	return nil
+}
+
+// ThirdPartyRoots counts the distinct roots of the imported paths whose first
+// element looks like a domain name. The root is the first three elements for
+// well-known code hosts, such as github.com/owner/repo, and otherwise the
+// first element alone.
+func (s *ImportStats) ThirdPartyRoots() int {
+	roots := map[string]bool{}
+	for path := range s.Paths {
+		elems := strings.Split(path, "/")
+		if !strings.Contains(elems[0], ".") {
+			continue
+		}
+		n := 1
+		switch elems[0] {
+		case "github.com", "gitlab.com", "bitbucket.org":
+			n = 3
+		}
+		if n > len(elems) {
+			n = len(elems)
+		}
+		roots[strings.Join(elems[:n], "/")] = true
+	}
+	return len(roots)
+}
+
+// TopPaths yields the n most imported paths, most imported first, or all of
+// them if n is zero.
+func (s *ImportStats) TopPaths(n int) []PathCount {
+	counts := []PathCount{}
+	for path, count := range s.Paths {
+		counts = append(counts, PathCount{path, count})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Path < counts[j].Path
+	})
+	if n > 0 && n < len(counts) {
+		counts = counts[:n]
+	}
+	return counts
+}
+
+// WriteStats writes stats to w as text, including the top most imported
+// paths.
+func (s *ImportStats) WriteStats(w io.Writer, top int) error {
+	_, err := fmt.Fprintf(w, "Files: %d\nImports: %d (%d distinct paths, %d third-party roots)\n"+
+		"Imports by group: %s\nFiles with each group: %s\nFiles by number of groups: %s\n",
+		s.Files, s.Imports, len(s.Paths), s.ThirdPartyRoots(), formatCounts(s.ImportsByGroup),
+		formatCounts(s.FilesByGroup), formatCounts(s.FilesByGroupCount))
+	if err != nil {
+		return err
+	}
+	paths := s.TopPaths(top)
+	if len(paths) == 0 {
+		return nil
+	}
+	if _, err = io.WriteString(w, "Most imported:\n"); err != nil {
+		return err
+	}
+	for _, pc := range paths {
+		if _, err = fmt.Fprintf(w, "  %d %s\n", pc.Count, pc.Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteStatsJSON writes stats to w as a JSON object, including the top most
+// imported paths.
+func (s *ImportStats) WriteStatsJSON(w io.Writer, top int) error {
+	data, err := json.MarshalIndent(struct {
+		*ImportStats
+		DistinctPaths   int         `json:"distinctPaths"`
+		ThirdPartyRoots int         `json:"thirdPartyRoots"`
+		TopPaths        []PathCount `json:"topPaths"`
+	}{s, len(s.Paths), s.ThirdPartyRoots(), s.TopPaths(top)}, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
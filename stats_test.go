@@ -0,0 +1,78 @@
+package gogroup
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectStats(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	a := filepath.Join(dir, "a.go")
+	b := filepath.Join(dir, "b.go")
+	c := filepath.Join(dir, "c.go")
+	assert.Nil(t, ioutil.WriteFile(a, []byte(`package a
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/x/y"
+	"github.com/x/y/z"
+	"golang.org/x/tools/go/ast"
+
+	"local/pkg"
+)
+`), 0644))
+	assert.Nil(t, ioutil.WriteFile(b, []byte("package a\n\nimport (\n\t\"os\"\n\t\"fmt\"\n)\n"), 0644))
+	assert.Nil(t, ioutil.WriteFile(c, []byte("package a\n"), 0644))
+
+	proc := NewProcessor(grouperGoimports{})
+	stats, err := CollectStats([]string{a, b, c}, proc)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, stats.Files)
+	assert.Equal(t, 8, stats.Imports)
+	assert.Equal(t, map[string]int{"0": 4, "1": 3, "3": 1}, stats.ImportsByGroup)
+	assert.Equal(t, map[string]int{"0": 2, "1": 1, "3": 1}, stats.FilesByGroup)
+	assert.Equal(t, map[string]int{"0": 1, "1": 1, "3": 1}, stats.FilesByGroupCount)
+	assert.Equal(t, 2, stats.ThirdPartyRoots())
+	assert.Equal(t, []PathCount{{"fmt", 2}, {"os", 2}}, stats.TopPaths(2))
+	assert.Len(t, stats.TopPaths(0), 6)
+
+	var buf bytes.Buffer
+	assert.Nil(t, stats.WriteStats(&buf, 3))
+	assert.Equal(t, `Files: 3
+Imports: 8 (6 distinct paths, 2 third-party roots)
+Imports by group: 0: 4, 1: 3, 3: 1
+Files with each group: 0: 2, 1: 1, 3: 1
+Files by number of groups: 0: 1, 1: 1, 3: 1
+Most imported:
+  2 fmt
+  2 os
+  1 github.com/x/y
+`, buf.String())
+
+	buf.Reset()
+	assert.Nil(t, stats.WriteStatsJSON(&buf, 1))
+	var parsed map[string]interface{}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &parsed))
+	assert.Equal(t, float64(6), parsed["distinctPaths"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"path": "fmt", "count": float64(2)}},
+		parsed["topPaths"])
+
+	// Files that don't parse are errors.
+	bad := filepath.Join(dir, "bad.go")
+	assert.Nil(t, ioutil.WriteFile(bad, []byte("package a\n\nimport (\n"), 0644))
+	_, err = CollectStats([]string{a, bad}, proc)
+	assert.IsType(t, &ParseError{}, err)
+}
@@ -0,0 +1,83 @@
+package gogroup
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// TAP (Test Anything Protocol) version 13 output, with a test point for each
+// file. Violations are described in YAML diagnostics under failing files.
+type tapOutput struct {
+	w      io.Writer
+	tests  int
+	points bytes.Buffer
+}
+
+func newTapOutput(w io.Writer) outputFormat {
+	return &tapOutput{w: w}
+}
+
+// Escape a test point description, so it can't be mistaken for a directive.
+func tapEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "#", `\#`, "\n", " ").Replace(s)
+}
+
+// Add a test point to the output.
+func (o *tapOutput) point(ok bool, path string, directive string) {
+	o.tests++
+	status := "ok"
+	if !ok {
+		status = "not ok"
+	}
+	fmt.Fprintf(&o.points, "%s %d - %s", status, o.tests, tapEscape(path))
+	if directive != "" {
+		fmt.Fprintf(&o.points, " # %s", directive)
+	}
+	o.points.WriteString("\n")
+}
+
+func (o *tapOutput) result(res *FileResult) {
+	if res.Skipped {
+		o.point(true, res.Path, "SKIP "+tapEscape(res.SkipReason))
+		return
+	}
+	if len(res.Violations) == 0 {
+		o.point(true, res.Path, "")
+		return
+	}
+
+	// Violations that aren't errors are described under a passing file.
+	o.point(firstError(res) == nil, res.Path, "")
+	o.points.WriteString("  ---\n  violations:\n")
+	for _, v := range res.Violations {
+		fmt.Fprintf(&o.points, "    - line: %d\n", v.Line)
+		fmt.Fprintf(&o.points, "      message: %s\n", strconv.Quote(v.Message))
+		fmt.Fprintf(&o.points, "      import: %s\n", strconv.Quote(v.ImportPath))
+		if v.Rule != "" {
+			fmt.Fprintf(&o.points, "      rule: %s\n", v.Rule)
+		}
+		if v.ID() != "" {
+			fmt.Fprintf(&o.points, "      id: %s\n", v.ID())
+		}
+		if v.Severity != SeverityError {
+			fmt.Fprintf(&o.points, "      severity: %s\n", v.Severity)
+		}
+	}
+	o.points.WriteString("  ...\n")
+}
+
+func (o *tapOutput) fileError(res *FileResult) {
+	o.point(false, res.Path, "")
+	fmt.Fprintf(&o.points, "  ---\n  error: %s\n  ...\n", strconv.Quote(res.Err.Error()))
+}
+
+func (o *tapOutput) finish() error {
+	if _, err := fmt.Fprintf(o.w, "TAP version 13\n1..%d\n", o.tests); err != nil {
+		return err
+	}
+	_, err := o.points.WriteTo(o.w)
+	return err
+}
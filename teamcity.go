@@ -0,0 +1,87 @@
+package gogroup
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// The category of the inspections of TeamCity output, and the key of the
+// statistic of how many violations there are.
+const (
+	teamcityCategory  = "Import grouping"
+	teamcityStatistic = "gogroupViolations"
+)
+
+// TeamCity service messages, with an inspection for each violation, a build
+// problem for each file that couldn't be processed, and a build statistic of
+// the number of violations. Each message is on a line of its own.
+type teamcityOutput struct {
+	w          io.Writer
+	types      map[string]bool
+	violations int
+}
+
+func newTeamcityOutput(w io.Writer) outputFormat {
+	return &teamcityOutput{w: w, types: map[string]bool{}}
+}
+
+// Escape a value of a service message.
+func teamcityEscape(s string) string {
+	return strings.NewReplacer("|", "||", "'", "|'", "\n", "|n", "\r", "|r", "[", "|[", "]", "|]",
+		"\u0085", "|x", "\u2028", "|l", "\u2029", "|p").Replace(s)
+}
+
+// Write a service message, with its attributes as pairs of names and values.
+func (o *teamcityOutput) message(name string, attrs ...string) {
+	fmt.Fprintf(o.w, "##teamcity[%s", name)
+	for i := 0; i+1 < len(attrs); i += 2 {
+		fmt.Fprintf(o.w, " %s='%s'", attrs[i], teamcityEscape(attrs[i+1]))
+	}
+	fmt.Fprint(o.w, "]\n")
+}
+
+// Yield the inspection type of a violation.
+func teamcityType(v *ValidationError) string {
+	if v.ID() != "" {
+		return v.ID()
+	}
+	if v.Rule != "" {
+		return v.Rule
+	}
+	return "gogroup"
+}
+
+func (o *teamcityOutput) result(res *FileResult) {
+	for _, v := range res.Violations {
+		o.violations++
+		typeID := teamcityType(v)
+		if !o.types[typeID] {
+			// Each inspection type is described before it's first used.
+			o.types[typeID] = true
+			name, description := v.Rule, v.Message
+			if v.Kind != KindUnknown {
+				description = v.Kind.Description()
+			}
+			if name == "" {
+				name = typeID
+			}
+			o.message("inspectionType", "id", typeID, "name", name, "description", description,
+				"category", teamcityCategory)
+		}
+		o.message("inspection", "typeId", typeID,
+			"message", fmt.Sprintf("%s at %s", v.Message, strconv.Quote(v.ImportPath)),
+			"file", res.Path, "line", strconv.Itoa(v.Line), "SEVERITY", strings.ToUpper(v.Severity.String()))
+	}
+}
+
+func (o *teamcityOutput) fileError(res *FileResult) {
+	o.message("buildProblem", "description", res.Err.Error())
+}
+
+func (o *teamcityOutput) finish() error {
+	_, err := fmt.Fprintf(o.w, "##teamcity[buildStatisticValue key='%s' value='%d']\n", teamcityStatistic,
+		o.violations)
+	return err
+}
@@ -0,0 +1,93 @@
+package gogroup
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"text/template"
+)
+
+// TemplateData is the data available to templates used with WriteTemplate,
+// for each violation.
+type TemplateData struct {
+	// File is the path of the file.
+	File string
+	// Line is the one-based line of the violation.
+	Line int
+	// Column is the one-based column of the violation, or 1 if its position
+	// is unknown.
+	Column int
+	// Message describes the violation.
+	Message string
+	// ImportPath is the path being imported.
+	ImportPath string
+	// Rule is a short identifier for the kind of violation.
+	Rule string
+	// ID is the stable identifier of the kind of violation, such as "GI004",
+	// or empty if it's unknown.
+	ID string
+	// GroupName is the name of the group the import belongs in, if the
+	// Grouper names its groups.
+	GroupName string
+	// Severity is how serious the violation is, such as "warning".
+	Severity string
+}
+
+// ParseTemplate parses a template for use with WriteTemplate.
+//
+// Besides syntax errors, it's an error for the template to refer to fields
+// that TemplateData lacks.
+func ParseTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("template").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	// Catch references to unknown fields now, rather than for each violation.
+	sample := TemplateData{Line: 1, Column: 1}
+	if err = tmpl.Execute(ioutil.Discard, &sample); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// WriteTemplate writes the violations in a report to w, executing a template
+// for each one with TemplateData. A newline follows each violation.
+func (r *Report) WriteTemplate(w io.Writer, tmpl *template.Template) error {
+	return r.WriteFormatter(w, builtinFormat(func(w io.Writer) outputFormat {
+		return &templateOutput{w: w, tmpl: tmpl}
+	})())
+}
+
+// Output using a template.
+type templateOutput struct {
+	w    io.Writer
+	tmpl *template.Template
+	err  error
+}
+
+func (o *templateOutput) result(res *FileResult) {
+	for _, v := range resultViolations(res) {
+		if o.err != nil {
+			return
+		}
+		o.err = o.tmpl.Execute(o.w, &TemplateData{
+			File:       res.Path,
+			Line:       v.Line,
+			Column:     violationColumn(v),
+			Message:    v.Message,
+			ImportPath: v.ImportPath,
+			Rule:       v.Rule,
+			ID:         v.ID(),
+			GroupName:  v.ExpectedGroup,
+			Severity:   v.Severity.String(),
+		})
+		if o.err == nil {
+			_, o.err = fmt.Fprintln(o.w)
+		}
+	}
+}
+
+func (o *templateOutput) finish() error {
+	return o.err
+}
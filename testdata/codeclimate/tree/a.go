@@ -0,0 +1,8 @@
+package tree
+
+import (
+	"fmt"
+	"os"
+)
+
+var _, _ = fmt.Println, os.Exit
@@ -0,0 +1,8 @@
+package pkg
+
+import (
+	"fmt"
+	"github.com/x/y"
+
+	"os"
+)
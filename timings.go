@@ -0,0 +1,138 @@
+package gogroup
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// The phases of processing that Timings records. A Processor records the
+// phases from PhaseParse to PhaseRepair, and callers may record the others,
+// or phases of their own.
+const (
+	// PhaseWalk is finding the files to process.
+	PhaseWalk = "walk"
+	// PhaseParse is parsing the imports of files.
+	PhaseParse = "parse"
+	// PhaseGroup is assigning imports to groups with the Grouper.
+	PhaseGroup = "group"
+	// PhaseCheck is checking the rules.
+	PhaseCheck = "check"
+	// PhaseGoimports is formatting files with goimports, in Reformat.
+	PhaseGoimports = "goimports"
+	// PhaseRepair is finding how to repair files, including parsing them
+	// again.
+	PhaseRepair = "repair"
+	// PhaseOutput is reporting the results.
+	PhaseOutput = "output"
+)
+
+// The order phases are listed in.
+var phaseOrder = []string{PhaseWalk, PhaseParse, PhaseGroup, PhaseCheck, PhaseGoimports, PhaseRepair,
+	PhaseOutput}
+
+// Timings accumulates how long is spent in each phase of processing, to
+// diagnose slow runs. It's safe for concurrent use, so it may be shared by
+// processors running concurrently, as in ProcessFiles; then the durations
+// of phases add up the time of each file, so their total may exceed the time
+// that passed. A nil Timings records nothing.
+type Timings struct {
+	mu     sync.Mutex
+	phases map[string]*phaseTiming
+}
+
+type phaseTiming struct {
+	total time.Duration
+	count int
+}
+
+// NewTimings creates Timings with nothing recorded.
+func NewTimings() *Timings {
+	return &Timings{phases: map[string]*phaseTiming{}}
+}
+
+// RecordTimings records how long the Processor spends in each phase in t.
+func RecordTimings(t *Timings) Option {
+	return func(p *Processor) {
+		p.timings = t
+	}
+}
+
+// Start starts timing a phase, yielding a function that stops timing it and
+// records the duration. Phases may be timed many times, even concurrently.
+func (t *Timings) Start(phase string) func() {
+	if t == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		t.Add(phase, time.Since(start))
+	}
+}
+
+// Add records that a phase took a duration.
+func (t *Timings) Add(phase string, d time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	pt, ok := t.phases[phase]
+	if !ok {
+		pt = &phaseTiming{}
+		t.phases[phase] = pt
+	}
+	pt.total += d
+	pt.count++
+}
+
+// Duration yields the total duration of a phase, and how many times it was
+// timed.
+func (t *Timings) Duration(phase string) (time.Duration, int) {
+	if t == nil {
+		return 0, 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if pt, ok := t.phases[phase]; ok {
+		return pt.total, pt.count
+	}
+	return 0, 0
+}
+
+// Write writes the total duration of each phase that was timed, and how many
+// times it was, one per line, such as "Timing: parse 1.5s (1200 times)". The
+// phases are in the order of processing, followed by others by name.
+func (t *Timings) Write(w io.Writer) error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	names := []string{}
+	for name := range t.phases {
+		names = append(names, name)
+	}
+	t.mu.Unlock()
+
+	rank := map[string]int{}
+	for i, name := range phaseOrder {
+		rank[name] = i + 1
+	}
+	sort.Slice(names, func(i, j int) bool {
+		ri, rj := rank[names[i]], rank[names[j]]
+		if ri != rj {
+			return ri != 0 && (rj == 0 || ri < rj)
+		}
+		return names[i] < names[j]
+	})
+	for _, name := range names {
+		total, count := t.Duration(name)
+		if _, err := fmt.Fprintf(w, "Timing: %-9s %v (%d times)\n", name, total.Round(time.Microsecond),
+			count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
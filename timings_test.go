@@ -0,0 +1,42 @@
+package gogroup
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimings(t *testing.T) {
+	t.Parallel()
+
+	timings := NewTimings()
+	proc := NewProcessor(grouperGoimports{}, RecordTimings(timings))
+	_, err := proc.ValidateAll("a.go", strings.NewReader("package a\n\nimport (\n\t\"os\"\n\t\"fmt\"\n)\n"))
+	assert.Nil(t, err)
+	for _, phase := range []string{PhaseParse, PhaseGroup, PhaseCheck} {
+		_, count := timings.Duration(phase)
+		assert.Equal(t, 1, count, phase)
+	}
+	_, count := timings.Duration(PhaseRepair)
+	assert.Equal(t, 0, count)
+
+	// Phases are listed in order of processing, then by name.
+	timings = NewTimings()
+	timings.Add("lint", time.Second)
+	timings.Add(PhaseOutput, time.Millisecond)
+	timings.Add(PhaseWalk, 2*time.Millisecond)
+	timings.Add(PhaseWalk, time.Millisecond)
+	var buf bytes.Buffer
+	assert.Nil(t, timings.Write(&buf))
+	assert.Equal(t, "Timing: walk      3ms (2 times)\nTiming: output    1ms (1 times)\n"+
+		"Timing: lint      1s (1 times)\n", buf.String())
+
+	// Nil timings record nothing.
+	var none *Timings
+	none.Start(PhaseParse)()
+	_, count = none.Duration(PhaseParse)
+	assert.Equal(t, 0, count)
+}
@@ -2,68 +2,370 @@ package gogroup
 
 import (
 	"fmt"
+	"go/ast"
 	"io"
 )
 
 func (e *ValidationError) Error() string {
-	return fmt.Sprintf("%s: %s (line %s)", e.Message, e.ImportPath, e.Line)
+	return fmt.Sprintf("%s: %s (line %d)", e.Message, e.ImportPath, e.Line)
 }
 
-// Yield a validation error.
-func validationError(g *groupedImport, msg string) *ValidationError {
+// Is determines whether a violation is of the kind target, for errors.Is.
+func (e *ValidationError) Is(target error) bool {
+	kind, ok := target.(Kind)
+	return ok && kind == e.Kind
+}
+
+// ID yields the stable identifier of the rule a violation breaks: of its kind,
+// such as "GI004", or of the Rule added to the Processor that found it. It's
+// "" for violations of an unknown kind that no Processor found.
+func (e *ValidationError) ID() string {
+	if e.Kind == KindUnknown {
+		return e.ruleID
+	}
+	return e.Kind.ID()
+}
+
+// Yield a validation error of a kind, about an import.
+func validationError(g *groupedImport, kind Kind) *ValidationError {
 	return &ValidationError{
-		Message:    msg,
+		Kind:       kind,
+		Message:    kind.Message(),
+		Rule:       kind.Rule(),
 		ImportPath: g.path,
-		Line:       g.startLine,
+		// Line numbers are one-based for humans.
+		Line: g.startLine + g.shift + 1,
+		Pos:  g.pos,
+		End:  g.file.PositionFor(g.spec.End(), false),
 	}
 }
 
+// Yield a validation error of a kind, about an empty line n lines after the
+// previous import, prev. The error is still about the import g.
+func emptyLineError(g, prev *groupedImport, kind Kind, n int) *ValidationError {
+	validErr := validationError(g, kind)
+	// Line numbers are one-based in token.File.
+	line := prev.endLine + prev.shift + n + 1
+	if line <= g.file.LineCount() {
+		validErr.Pos = g.file.PositionFor(g.file.LineStart(line), false)
+		// The empty lines end where the import's first line starts.
+		validErr.End = g.file.PositionFor(g.file.LineStart(g.startLine+g.shift+1), false)
+	}
+	return validErr
+}
+
+// The messages of each kind of violation, which are for display only.
 const (
-	errstrStatementOrder     = "Import out of order within import group"
-	errstrStatementExtraLine = "Extra empty line inside import group"
-	errstrStatementGroup     = "Import in incorrect group"
-	errstrGroupOrder         = "Import groups out of order"
-	errstrGroupExtraLine     = "Extra empty line between import groups"
+	errstrStatementOrder       = "Import out of order within import group"
+	errstrStatementExtraLine   = "Extra empty line inside import group"
+	errstrStatementGroup       = "Import in incorrect group"
+	errstrGroupOrder           = "Import groups out of order"
+	errstrGroupExtraLine       = "Extra empty line between import groups"
+	errstrGroupMissingLine     = "Missing empty line between import groups"
+	errstrStatementSameLine    = "Multiple imports on one line"
+	errstrStatementUnassigned  = "Import not assigned to any group"
+	errstrBlockUnparenthesized = "Import outside a parenthesized block"
+	errstrBlockSingle          = "Single import in a parenthesized block"
+	errstrGroupHeaderMissing   = "Missing import group header"
+	errstrGroupHeaderWrong     = "Incorrect import group header"
+	errstrCommentStripped      = "Comment to strip from import block"
+	errstrStatementDuplicate   = "Import of a path imported more than once"
+	errstrStatementAlias       = "Import not under its required alias"
+	errstrStatementBadAlias    = "Import under a forbidden alias"
+	errstrStatementRelative    = "Relative import path"
+	errstrStatementDenied      = "Import of a denied package"
+	errstrStatementLayer       = "Import not allowed by a layering rule"
 )
 
-// Validate an import group.
-func (gs groupedImports) validate() *ValidationError {
-	if len(gs) < 2 {
-		// Always valid!
-		return nil
+// Determine whether the run of adjacent imports containing the import at
+// index i, with no empty lines between them, would be correctly ordered if
+// empty lines were inserted between its groups.
+func (gs groupedImports) runOrdered(i int) bool {
+	start, end := i, i
+	for start > 0 && gs[start].startLine-gs[start-1].endLine <= 1 {
+		start--
+	}
+	for end < len(gs)-1 && gs[end+1].startLine-gs[end].endLine <= 1 {
+		end++
 	}
 
-	var prev *groupedImport
+	for j := start + 1; j <= end; j++ {
+		prev, g := gs[j-1], gs[j]
+		if g.group < prev.group || (g.group == prev.group && g.sortKey < prev.sortKey) {
+			return false
+		}
+	}
+	return true
+}
+
+// Yield a validation error for an import found in the group of another
+// import, naming both groups if possible.
+func groupError(g *groupedImport, found *groupedImport, namer GroupNamer) *ValidationError {
+	validErr := validationError(g, KindWrongGroup)
+	if namer == nil {
+		return validErr
+	}
+
+	expectedName, foundName := namer.GroupName(g.group), namer.GroupName(found.group)
+	if expectedName != "" && foundName != "" {
+		validErr.ExpectedGroup = expectedName
+		validErr.FoundGroup = foundName
+		validErr.Message = fmt.Sprintf("%s (expected group %s, found in %s)",
+			KindWrongGroup.Message(), expectedName, foundName)
+	}
+	return validErr
+}
+
+// Validate an import group, yielding every violation. If namer is non-nil,
+// it's used to name groups in error messages.
+//
+// Imports kept in place are skipped, as if their lines weren't there. But
+// where the grouper ignores imports between two groups, the ignored imports
+// separate the groups, so any empty lines around them are fine. Imports
+// sharing a line with the previous import are always a violation, since
+// repairs put each import on its own line. So are imports the grouper assigned
+// to no group, and disallowed relative imports.
+//
+// If lenient, groups in the right order needn't be separated by an empty
+// line. Nor do groups with a separator comment between them.
+func (gs groupedImports) validateAll(namer GroupNamer, lenient bool) []*ValidationError {
+	errs := []*ValidationError{}
 	for _, g := range gs {
+		if g.unassigned {
+			errs = append(errs, validationError(g, KindUnassigned))
+		}
+		if g.relative {
+			errs = append(errs, validationError(g, KindRelative))
+		}
+	}
+	gs = gs.visible()
+
+	var prev *groupedImport
+	for i, g := range gs {
 		if prev != nil {
 			emptyLines := g.startLine - prev.endLine - 1
 
+			// Imports on the same line are otherwise checked as if they
+			// were adjacent.
+			sameLine := emptyLines < 0
+			if sameLine {
+				errs = append(errs, validationError(g, KindSameLine))
+				emptyLines = 0
+			}
+
 			if g.group == prev.group {
 				if emptyLines > 0 {
-					return validationError(g, errstrStatementExtraLine)
-				} else if g.path < prev.path {
-					return validationError(g, errstrStatementOrder)
+					errs = append(errs, emptyLineError(g, prev, KindExtraBlankLine, 1))
+				} else if g.sortKey < prev.sortKey {
+					errs = append(errs, validationError(g, KindStatementOrder))
+				}
+			} else if g.afterIgnored {
+				if g.group < prev.group {
+					errs = append(errs, validationError(g, KindGroupOrder))
 				}
 			} else if emptyLines == 0 {
-				// This could also be a missing empty line.
-				return validationError(g, errstrStatementGroup)
+				if lenient && g.group > prev.group || g.separator != nil && g.group > prev.group {
+					// Adjacent groups are fine, or separated by a comment.
+				} else if gs.runOrdered(i) {
+					// Everything is in the right group, it just needs splitting up.
+					if !sameLine {
+						errs = append(errs, validationError(g, KindMissingGroupBlankLine))
+					}
+				} else {
+					errs = append(errs, groupError(g, prev, namer))
+				}
 			} else if g.group < prev.group {
-				return validationError(g, errstrGroupOrder)
+				errs = append(errs, validationError(g, KindGroupOrder))
 			} else if emptyLines > 1 {
-				return validationError(g, errstrGroupExtraLine)
+				errs = append(errs, emptyLineError(g, prev, KindExtraGroupBlankLine, 2))
 			}
 
 		}
 		prev = g
 	}
-	return nil
+	return errs
+}
+
+// Determine whether a file imports "C". Cgo needs its preamble right before a
+// declaration of its own, so the style of declarations is left alone.
+func (gs groupedImports) importsC() bool {
+	for _, g := range gs {
+		if g.path == "C" {
+			return true
+		}
+	}
+	return false
+}
+
+// Determine whether the imports of a file should be in a parenthesized
+// declaration, according to the block style, unless the rule it follows is
+// disabled.
+func (p *Processor) parenthesize(tree *ast.File, gs groupedImports) bool {
+	decls := importDecls(tree)
+	if !gs.importsC() {
+		switch {
+		case p.blockStyle == BlockParenthesized && p.fixes(KindUnparenthesized):
+			return true
+		case p.blockStyle == BlockPlain && len(gs) == 1 && p.fixes(KindSingleParenthesized):
+			return false
+		}
+	}
+	for _, gen := range decls {
+		if gen.Lparen.IsValid() {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate the style of the import declarations of a file.
+func (p *Processor) validateBlocks(tree *ast.File, gs groupedImports) []*ValidationError {
+	errs := []*ValidationError{}
+	if p.blockStyle == BlockAny || len(gs) == 0 || gs.importsC() {
+		return errs
+	}
+	bySpec := map[*ast.ImportSpec]*groupedImport{}
+	for _, g := range gs {
+		bySpec[g.spec] = g
+	}
+	for _, gen := range importDecls(tree) {
+		if len(gen.Specs) == 0 {
+			continue
+		}
+		g := bySpec[gen.Specs[0].(*ast.ImportSpec)]
+		if p.blockStyle == BlockParenthesized && !gen.Lparen.IsValid() {
+			errs = append(errs, validationError(g, KindUnparenthesized))
+		} else if p.blockStyle == BlockPlain && len(gs) == 1 && gen.Lparen.IsValid() {
+			errs = append(errs, validationError(g, KindSingleParenthesized))
+		}
+	}
+	return errs
+}
+
+// Validate that each group starts with its header, and that there are no
+// headers elsewhere.
+func (p *Processor) validateHeaders(gs groupedImports) []*ValidationError {
+	errs := []*ValidationError{}
+	if len(p.headers) == 0 {
+		return errs
+	}
+	var prev *groupedImport
+	for _, g := range gs.visible() {
+		want := ""
+		if prev == nil || g.group != prev.group {
+			want = p.headers[g.group]
+		}
+		if g.header == nil && want != "" {
+			errs = append(errs, validationError(g, KindMissingHeader))
+		} else if g.header != nil && g.header.Text != want {
+			errs = append(errs, validationError(g, KindWrongHeader))
+		}
+		prev = g
+	}
+	return errs
+}
+
+// Yield a violation for each comment to strip.
+func (gs groupedImports) validateStripped() []*ValidationError {
+	errs := []*ValidationError{}
+	for _, g := range gs {
+		for _, s := range g.stripped {
+			errs = append(errs, &ValidationError{
+				Kind:       KindStrippedComment,
+				Message:    KindStrippedComment.Message(),
+				Rule:       KindStrippedComment.Rule(),
+				ImportPath: g.path,
+				// Line numbers are one-based for humans.
+				Line: s.line + 1,
+				Pos:  g.file.PositionFor(s.comment.Pos(), false),
+				End:  g.file.PositionFor(s.comment.End(), false),
+			})
+		}
+	}
+	return errs
+}
+
+// Yield a violation for each import of a path imported earlier, mentioning
+// the lines of both.
+func (gs groupedImports) validateDuplicates() []*ValidationError {
+	errs := []*ValidationError{}
+	for _, g := range gs {
+		if g.duplicateOf != nil {
+			validErr := validationError(g, KindDuplicate)
+			validErr.Message = fmt.Sprintf("%s (lines %d and %d)", KindDuplicate.Message(),
+				g.duplicateOf.startLine+1, g.startLine+1)
+			errs = append(errs, validErr)
+		}
+	}
+	return errs
+}
+
+// Yield a violation for each import under the wrong name, of a denied
+// package, or forbidden by a layering rule.
+func (gs groupedImports) validateAliases() []*ValidationError {
+	errs := []*ValidationError{}
+	for _, g := range gs {
+		if g.wantAlias != "" {
+			validErr := validationError(g, KindWrongAlias)
+			validErr.Message = fmt.Sprintf("%s (expected %s)", KindWrongAlias.Message(), g.wantAlias)
+			errs = append(errs, validErr)
+		}
+		if g.denied != nil {
+			validErr := validationError(g, KindDenied)
+			if g.denied.Message != "" {
+				validErr.Message = fmt.Sprintf("%s (%s)", KindDenied.Message(), g.denied.Message)
+			}
+			errs = append(errs, validErr)
+		}
+		if g.layer != nil {
+			validErr := validationError(g, KindLayer)
+			validErr.Message = fmt.Sprintf("%s (%s)", KindLayer.Message(), g.layer.name())
+			errs = append(errs, validErr)
+		}
+		if g.forbiddenAlias {
+			validErr := validationError(g, KindForbiddenAlias)
+			validErr.Message = fmt.Sprintf("%s (%s)", KindForbiddenAlias.Message(), importName(g.spec))
+			errs = append(errs, validErr)
+		}
+	}
+	return errs
 }
 
 // Validate a file.
 func (p *Processor) validate(fileName string, r io.Reader) (validErr *ValidationError, err error) {
-	gs, err := p.readImports(fileName, r)
+	errs, err := p.validateAll(fileName, r)
+	if err != nil || len(errs) == 0 {
+		return nil, err
+	}
+	return errs[0], nil
+}
+
+// Validate source that's already been read, yielding every violation, and
+// whether the file is ignored. Ignored files are parsed only once, and have no
+// violations.
+func (p *Processor) validateSource(fileName string, src []byte) (bool, []*ValidationError, []*ValidationError,
+	error) {
+	fset, tree, gs, err := p.readSource(fileName, src)
+	if err != nil {
+		return false, nil, nil, err
+	}
+	if !p.ignoreDirectives && hasIgnoreDirective(tree) {
+		return true, nil, nil, nil
+	}
+	namer, _ := p.grouper.(GroupNamer)
+	defer p.timings.Start(PhaseCheck)()
+	errs, suppressed := p.check(fset, tree, gs, namer, true)
+	return false, errs, suppressed, nil
+}
+
+// Validate a file, yielding every violation.
+func (p *Processor) validateAll(fileName string, r io.Reader) ([]*ValidationError, error) {
+	fset, tree, gs, err := p.readImports(fileName, r)
 	if err != nil {
 		return nil, err
 	}
-	return gs.validate(), nil
+	namer, _ := p.grouper.(GroupNamer)
+	defer p.timings.Start(PhaseCheck)()
+	errs, _ := p.check(fset, tree, gs, namer, true)
+	return errs, nil
 }
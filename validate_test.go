@@ -1,7 +1,17 @@
 package gogroup
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -13,6 +23,14 @@ type vopts struct {
 	err     bool
 }
 
+// Clear the positions of violations, for tests that don't check them.
+func withoutPos(errs []*ValidationError) []*ValidationError {
+	for _, validErr := range errs {
+		validErr.Pos, validErr.End = token.Position{}, token.Position{}
+	}
+	return errs
+}
+
 func testValidate(t *testing.T, g Grouper, opts vopts, imports string) {
 	proc := NewProcessor(g)
 	text := "package main\n" + imports
@@ -214,8 +232,799 @@ func TestValidateEdgeCases(t *testing.T) {
 		. "golang.org/x/net/context"
 	)`
 	testValidate(t, grouperGoimports{}, vopts{}, imports)
+
+	// Imports sharing a line are not allowed, even if in order.
+	imports = `import ( "os"; "strings" )`
+	testValidate(t, grouperGoimports{}, vopts{verrstr: errstrStatementSameLine}, imports)
+	imports = `import "os"; import "strings"`
+	testValidate(t, grouperGoimports{}, vopts{verrstr: errstrStatementSameLine}, imports)
+
+	// Sharing a line with the parentheses is allowed.
+	imports = `import ("os"
+		"strings")`
+	testValidate(t, grouperGoimports{}, vopts{}, imports)
+
+	// Imports after shared lines are compared as if adjacent.
+	imports = `import (
+		"os"; "strings"
+		"fmt"
+	)`
+	proc := NewProcessor(grouperGoimports{})
+	errs, err := proc.ValidateAll("", strings.NewReader("package main\n"+imports))
+	assert.Nil(t, err)
+	if assert.Len(t, errs, 2) {
+		assert.Equal(t, "statement-same-line", errs[0].Rule)
+		assert.Equal(t, 3, errs[0].Line)
+		assert.Equal(t, "statement-order", errs[1].Rule)
+		assert.Equal(t, 4, errs[1].Line)
+	}
+
+	// Imports sharing a line are also checked for order and grouping.
+	imports = `import ( "strings"; "os" )`
+	errs, err = proc.ValidateAll("", strings.NewReader("package main\n"+imports))
+	assert.Nil(t, err)
+	if assert.Len(t, errs, 2) {
+		assert.Equal(t, "statement-same-line", errs[0].Rule)
+		assert.Equal(t, "statement-order", errs[1].Rule)
+		assert.Equal(t, "os", errs[1].ImportPath)
+	}
+	imports = `import (
+		"github.com/pkg/errors"; "os"
+	)`
+	errs, err = proc.ValidateAll("", strings.NewReader("package main\n"+imports))
+	assert.Nil(t, err)
+	if assert.Len(t, errs, 2) {
+		assert.Equal(t, "statement-same-line", errs[0].Rule)
+		assert.Equal(t, KindWrongGroup, errs[1].Kind)
+		assert.Equal(t, "os", errs[1].ImportPath)
+	}
+	imports = `import ( "os"; "github.com/pkg/errors" )`
+	errs, err = proc.ValidateAll("", strings.NewReader("package main\n"+imports))
+	assert.Nil(t, err)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "statement-same-line", errs[0].Rule)
+	}
 }
 
 func TestValidateErrors(t *testing.T) {
-	// TODO
+	t.Parallel()
+
+	// Correct groups, but no empty line between them.
+	imports := `import (
+		"os"
+		"strings"
+		"github.com/Sirupsen/logrus"
+	)`
+	testValidate(t, grouperGoimports{}, vopts{verrstr: errstrGroupMissingLine}, imports)
+
+	// An import that belongs in another group.
+	imports = `import (
+		"os"
+		"github.com/Sirupsen/logrus"
+		"strings"
+	)`
+	testValidate(t, grouperGoimports{}, vopts{verrstr: errstrStatementGroup}, imports)
+
+	imports = `import (
+		"github.com/Sirupsen/logrus"
+		"os"
+	)`
+	testValidate(t, grouperGoimports{}, vopts{verrstr: errstrStatementGroup}, imports)
+
+	// Only the adjacent run matters.
+	imports = `import (
+		"os"
+
+		"github.com/Sirupsen/logrus"
+		"local/foo"
+
+		"strings"
+	)`
+	testValidate(t, grouperGoimports{}, vopts{verrstr: errstrGroupMissingLine}, imports)
+}
+
+// A grouper that names some of its groups.
+type grouperNamed struct {
+	grouperGoimports
+}
+
+func (grouperNamed) GroupName(group int) string {
+	return map[int]string{0: "Standard", 1: "Third-party"}[group]
+}
+
+// Assigns no group to imports from example.com.
+type grouperStrict struct {
+	grouperGoimports
+}
+
+func (g grouperStrict) LookupGroup(pkg string) (int, bool) {
+	return g.Group(pkg), !strings.HasPrefix(pkg, "example.com/")
+}
+
+func TestValidateUnassigned(t *testing.T) {
+	t.Parallel()
+
+	proc := NewProcessor(grouperStrict{})
+	text := `package main
+
+import (
+	"os"
+	"example.com/new"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+`
+	errs, err := proc.ValidateAll("", strings.NewReader(text))
+	assert.Nil(t, err)
+	assert.Equal(t, []*ValidationError{{
+		Kind:       KindUnassigned,
+		Line:       5,
+		ImportPath: "example.com/new",
+		Message:    "Import not assigned to any group",
+		Rule:       "statement-unassigned",
+	}}, withoutPos(errs))
+
+	// Repairs can't assign a group, so they leave the import alone.
+	r, err := proc.Repair("", strings.NewReader(text))
+	assert.Nil(t, err)
+	assert.Equal(t, text, readAll(t, r))
+
+	// Other groupers always assign a group.
+	errs, err = NewProcessor(grouperGoimports{}).ValidateAll("", strings.NewReader(text))
+	assert.Nil(t, err)
+	for _, validErr := range errs {
+		assert.NotEqual(t, "statement-unassigned", validErr.Rule)
+	}
+}
+
+func TestValidateCommentSeparators(t *testing.T) {
+	t.Parallel()
+
+	proc := NewProcessor(grouperGoimports{}, CommentSeparators(true))
+	for _, text := range []string{
+		// Comment-separated groups.
+		"import (\n\t\"os\"\n\t// --- third party ---\n\t\"github.com/pkg/errors\"\n\t// --- local ---\n" +
+			"\t\"local/pkg\"\n)\n",
+		// Mixed empty lines and comments.
+		"import (\n\t\"os\"\n\n\t// --- third party ---\n\t\"github.com/pkg/errors\"\n\n\t\"local/pkg\"\n)\n",
+		// Comments within a group are just comments.
+		"import (\n\t\"fmt\"\n\t// Doc.\n\t\"os\"\n\t/* third party */\n\t\"github.com/pkg/errors\"\n)\n",
+	} {
+		errs, err := proc.ValidateAll("", strings.NewReader("package main\n\n"+text))
+		assert.Nil(t, err)
+		assert.Empty(t, errs, text)
+	}
+
+	for _, c := range []struct {
+		text, rule string
+	}{
+		// Comments don't excuse groups in the wrong order.
+		{"import (\n\t\"github.com/pkg/errors\"\n\t// --- std ---\n\t\"os\"\n)\n", "statement-group"},
+		// Nor extra empty lines.
+		{"import (\n\t\"os\"\n\n\n\t// --- third party ---\n\t\"github.com/pkg/errors\"\n)\n", "group-extra-line"},
+		// Groups still need separating somehow.
+		{"import (\n\t\"os\"\n\t\"github.com/pkg/errors\"\n)\n", "group-missing-line"},
+	} {
+		errs, err := proc.ValidateAll("", strings.NewReader("package main\n\n"+c.text))
+		assert.Nil(t, err)
+		if assert.Len(t, errs, 1, c.text) {
+			assert.Equal(t, c.rule, errs[0].Rule, c.text)
+		}
+	}
+
+	// Without the option, comments don't separate groups.
+	errs, err := NewProcessor(grouperGoimports{}).ValidateAll("", strings.NewReader(
+		"package main\n\nimport (\n\t\"os\"\n\t// --- third party ---\n\t\"github.com/pkg/errors\"\n)\n"))
+	assert.Nil(t, err)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "group-missing-line", errs[0].Rule)
+	}
+}
+
+func TestValidateLenient(t *testing.T) {
+	t.Parallel()
+
+	proc := NewProcessor(grouperGoimports{}, Lenient(true))
+	valid := `package main
+
+import (
+	"os"
+	"strings"
+	"github.com/pkg/errors"
+
+	"local/pkg"
+)
+`
+	errs, err := proc.ValidateAll("", strings.NewReader(valid))
+	assert.Nil(t, err)
+	assert.Empty(t, errs)
+
+	// Without lenience, the groups must be separated.
+	errs, err = NewProcessor(grouperGoimports{}).ValidateAll("", strings.NewReader(valid))
+	assert.Nil(t, err)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "group-missing-line", errs[0].Rule)
+	}
+
+	for _, c := range []struct {
+		text, rule string
+	}{
+		{`package main
+
+import (
+	"github.com/pkg/errors"
+	"os"
+)
+`, "statement-group"},
+		{`package main
+
+import (
+	"strings"
+	"os"
+	"github.com/pkg/errors"
+)
+`, "statement-order"},
+		{`package main
+
+import (
+	"os"
+
+
+	"github.com/pkg/errors"
+)
+`, "group-extra-line"},
+	} {
+		errs, err := proc.ValidateAll("", strings.NewReader(c.text))
+		assert.Nil(t, err)
+		if assert.Len(t, errs, 1, c.text) {
+			assert.Equal(t, c.rule, errs[0].Rule, c.text)
+		}
+	}
+
+	// Repairs still separate the groups.
+	r, err := proc.Repair("", strings.NewReader(`package main
+
+import (
+	"os"
+	"github.com/pkg/errors"
+	"fmt"
+)
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, `package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+)
+`, readAll(t, r))
+}
+
+func TestValidateHeaders(t *testing.T) {
+	t.Parallel()
+
+	proc := NewProcessor(grouperGoimports{}, GroupHeaders(map[int]string{
+		0: "// Standard library",
+		1: "// Third-party",
+	}))
+	for _, c := range []struct {
+		text  string
+		rules []string
+	}{
+		{"import (\n\t// Standard library\n\t\"os\"\n\n\t// Third-party\n\t// Doc.\n\t\"github.com/pkg/errors\"\n\n\t\"local/pkg\"\n)\n",
+			nil},
+		{"import (\n\t\"os\"\n\n\t// Third-party\n\t\"github.com/pkg/errors\"\n)\n",
+			[]string{"group-header-missing"}},
+		{"import (\n\t// Third-party\n\t\"os\"\n\n\t// Standard library\n\t\"github.com/pkg/errors\"\n)\n",
+			[]string{"group-header", "group-header"}},
+		{"import (\n\t// Standard library\n\t\"fmt\"\n\t// Standard library\n\t\"os\"\n)\n",
+			[]string{"group-header"}},
+		{"import (\n\t// Standard library\n\t\"os\"\n\n\t// Third-party\n\t\"local/pkg\"\n)\n",
+			[]string{"group-header"}},
+		// Headers of plain declarations are their doc comments.
+		{"// Standard library\nimport \"os\"\n", nil},
+	} {
+		errs, err := proc.ValidateAll("", strings.NewReader("package main\n\n"+c.text))
+		assert.Nil(t, err)
+		rules := []string{}
+		for _, validErr := range errs {
+			rules = append(rules, validErr.Rule)
+		}
+		assert.Equal(t, append([]string{}, c.rules...), rules, c.text)
+	}
+
+	// Without headers, header comments are just comments.
+	errs, err := NewProcessor(grouperGoimports{}).ValidateAll("", strings.NewReader(
+		"package main\n\nimport (\n\t// Third-party\n\t\"os\"\n)\n"))
+	assert.Nil(t, err)
+	assert.Empty(t, errs)
+}
+
+func TestValidateDuplicates(t *testing.T) {
+	t.Parallel()
+
+	proc := NewProcessor(grouperGoimports{}, Duplicates(true))
+	text := `package main
+
+import (
+	"os"
+	foo "local/pkg"
+	"os"
+	foopkg "local/pkg"
+)
+`
+	errs, err := proc.ValidateAll("", strings.NewReader(text))
+	assert.Nil(t, err)
+	dups := []*ValidationError{}
+	for _, validErr := range errs {
+		if validErr.Rule == "statement-duplicate" {
+			dups = append(dups, validErr)
+		}
+	}
+	assert.Equal(t, []*ValidationError{{
+		Kind:       KindDuplicate,
+		Line:       6,
+		ImportPath: "os",
+		Message:    "Import of a path imported more than once (lines 4 and 6)",
+		Rule:       "statement-duplicate",
+	}, {
+		Kind:       KindDuplicate,
+		Line:       7,
+		ImportPath: "local/pkg",
+		Message:    "Import of a path imported more than once (lines 5 and 7)",
+		Rule:       "statement-duplicate",
+	}}, withoutPos(dups))
+
+	// Repairs remove exact duplicates, but not those with other names.
+	r, err := proc.Repair("", strings.NewReader(text))
+	assert.Nil(t, err)
+	fixed := readAll(t, r)
+	assert.Equal(t, `package main
+
+import (
+	"os"
+
+	foo "local/pkg"
+	foopkg "local/pkg"
+)
+`, fixed)
+	errs, err = proc.ValidateAll("", strings.NewReader(fixed))
+	assert.Nil(t, err)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "statement-duplicate", errs[0].Rule)
+	}
+
+	// Duplicates with comments are left alone too.
+	r, err = proc.Repair("", strings.NewReader("package main\n\nimport (\n\t\"os\"\n\t\"os\" // Again.\n\t\"fmt\"\n)\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, "package main\n\nimport (\n\t\"fmt\"\n\t\"os\"\n\t\"os\" // Again.\n)\n", readAll(t, r))
+
+	// Duplicates are only detected on request.
+	errs, err = NewProcessor(grouperGoimports{}).ValidateAll("", strings.NewReader(fixed))
+	assert.Nil(t, err)
+	assert.Empty(t, errs)
+}
+
+func TestValidateRelative(t *testing.T) {
+	t.Parallel()
+
+	text := `package main
+
+import (
+	"os"
+	"./util"
+
+	"../shared"
+	"github.com/pkg/errors"
+)
+`
+	errs, err := NewProcessor(grouperGoimports{}).ValidateAll("", strings.NewReader(text))
+	assert.Nil(t, err)
+	assert.Equal(t, []*ValidationError{{
+		Kind:       KindRelative,
+		Line:       5,
+		ImportPath: "./util",
+		Message:    "Relative import path",
+		Rule:       "statement-relative",
+	}, {
+		Kind:       KindRelative,
+		Line:       7,
+		ImportPath: "../shared",
+		Message:    "Relative import path",
+		Rule:       "statement-relative",
+	}}, withoutPos(errs))
+
+	// Repairs keep them after the same imports, but can't fix them.
+	r, err := NewProcessor(grouperGoimports{}).Repair("", strings.NewReader(text))
+	assert.Nil(t, err)
+	fixed := readAll(t, r)
+	assert.Equal(t, `package main
+
+import (
+	"os"
+	"./util"
+	"../shared"
+
+	"github.com/pkg/errors"
+)
+`, fixed)
+	errs, err = NewProcessor(grouperGoimports{}).ValidateAll("", strings.NewReader(fixed))
+	assert.Nil(t, err)
+	assert.Len(t, errs, 2)
+
+	// Unless they're allowed, when they're grouped like any other import.
+	errs, err = NewProcessor(grouperGoimports{}, AllowRelativeImports(true)).ValidateAll("",
+		strings.NewReader(text))
+	assert.Nil(t, err)
+	for _, validErr := range errs {
+		assert.NotEqual(t, "statement-relative", validErr.Rule)
+	}
+}
+
+func TestValidatePositions(t *testing.T) {
+	t.Parallel()
+
+	// Positions are physical, despite the line directive.
+	text := "package main\n\n//line other.go:100\nimport (\n\t\"os\"\n\n\t\"fmt\"\n\n\n" +
+		"\t\"github.com/pkg/errors\"\n\t// --- strip ---\n)\n"
+	proc := NewProcessor(grouperGoimports{}, StripComments(regexp.MustCompile("---")))
+	errs, err := proc.ValidateAll("a.go", strings.NewReader(text))
+	assert.Nil(t, err)
+	positions := []token.Position{}
+	for _, validErr := range errs {
+		positions = append(positions, validErr.Pos)
+	}
+	assert.Equal(t, []token.Position{
+		// The empty line inside the group.
+		{Filename: "a.go", Offset: 49, Line: 6, Column: 1},
+		// The second empty line between groups.
+		{Filename: "a.go", Offset: 58, Line: 9, Column: 1},
+		// The comment to strip.
+		{Filename: "a.go", Offset: 85, Line: 11, Column: 2},
+	}, positions)
+	assert.Equal(t, 7, errs[0].Line)
+
+	// Other violations are at the import, and positions survive into reports.
+	dir, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "a.go")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("package main\n\nimport (\n\t\"os\"\n\tx \"fmt\"\n)\n"), 0644))
+	report, err := ProcessFiles(context.Background(), []string{path}, NewProcessor(grouperGoimports{}), RunOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, token.Position{Filename: path, Offset: 30, Line: 5, Column: 2},
+		report.Files[0].Violation.Pos)
+}
+
+func TestValidateGroupNames(t *testing.T) {
+	t.Parallel()
+
+	proc := NewProcessor(grouperNamed{})
+	validErr, err := proc.Validate("", strings.NewReader(`package main
+import (
+	"github.com/Sirupsen/logrus"
+	"os"
+)`))
+	assert.Nil(t, err)
+	assert.Equal(t, &ValidationError{
+		Kind:          KindWrongGroup,
+		Line:          4,
+		ImportPath:    "os",
+		Message:       "Import in incorrect group (expected group Standard, found in Third-party)",
+		Rule:          "statement-group",
+		ExpectedGroup: "Standard",
+		FoundGroup:    "Third-party",
+	}, withoutPos([]*ValidationError{validErr})[0])
+
+	// Unnamed groups aren't mentioned.
+	validErr, err = proc.Validate("", strings.NewReader(`package main
+import (
+	"local/foo"
+	"os"
+)`))
+	assert.Nil(t, err)
+	assert.Equal(t, errstrStatementGroup, validErr.Message)
+	assert.Equal(t, "", validErr.ExpectedGroup)
+}
+
+func TestIgnoreDirective(t *testing.T) {
+	t.Parallel()
+
+	invalid := `import (
+	"strings"
+	"os"
+)`
+	for _, text := range []string{
+		"//group-imports:ignore\npackage main\n" + invalid,
+		"package main\n//group-imports:ignore vendored file\n" + invalid,
+		"package main\n\n//group-imports:ignore\n\n" + invalid,
+	} {
+		proc := NewProcessor(grouperGoimports{})
+		ignored, err := proc.Ignored("", strings.NewReader(text))
+		assert.Nil(t, err)
+		assert.True(t, ignored)
+		validErr, err := proc.Validate("", strings.NewReader(text))
+		assert.Nil(t, err)
+		assert.Nil(t, validErr)
+		r, err := proc.Reformat("", strings.NewReader(text))
+		assert.Nil(t, err)
+		assert.Nil(t, r)
+
+		// Directives can be disabled.
+		proc = NewProcessor(grouperGoimports{}, IgnoreDirectives(true))
+		validErr, err = proc.Validate("", strings.NewReader(text))
+		assert.Nil(t, err)
+		assert.NotNil(t, validErr)
+	}
+
+	// Directives elsewhere don't count.
+	for _, text := range []string{
+		"package main\n" + invalid + "\n//group-imports:ignore\n",
+		"package main\n//group-imports:ignored\n" + invalid,
+		"package main\n// group-imports:ignore\n" + invalid,
+	} {
+		testValidate(t, grouperGoimports{}, vopts{verrstr: errstrStatementOrder}, text[len("package main\n"):])
+	}
+}
+
+func TestNolintDirective(t *testing.T) {
+	t.Parallel()
+
+	for _, c := range []struct {
+		text       string
+		suppressed bool
+	}{
+		{"\"strings\"\n\t\"os\" //nolint:gogroup", true},
+		{"\"strings\"\n\t//nolint:lll,gogroup // sorted by hand\n\t\"os\"", true},
+		{"\"strings\"\n\t// Operating system.\n\t//nolint:gogroup\n\t\"os\"", true},
+		{"\"strings\"\n\t//nolint:gogroup\n\t// Operating system.\n\t\"os\"", false},
+		{"\"strings\"\n\t\"os\" //nolint:lll", false},
+		{"\"strings\"\n\t\"os\" //nolint", false},
+		{"\"strings\"\n\t\"os\" // nolint:gogroup", false},
+		{"\"strings\"\n\t\"os\" //nolint:gogroupx", false},
+	} {
+		text := "package main\n\nimport (\n\t" + c.text + "\n)\n"
+		proc := NewProcessor(grouperGoimports{})
+		errs, err := proc.ValidateAll("", strings.NewReader(text))
+		assert.Nil(t, err, c.text)
+		assert.Equal(t, c.suppressed, len(errs) == 0, c.text)
+		_, errs, suppressed, err := proc.validateSource("", []byte(text))
+		assert.Nil(t, err, c.text)
+		if c.suppressed {
+			assert.Empty(t, errs, c.text)
+			if assert.Equal(t, 1, len(suppressed), c.text) {
+				assert.Equal(t, KindStatementOrder, suppressed[0].Kind, c.text)
+			}
+		} else {
+			assert.Empty(t, suppressed, c.text)
+		}
+
+		// Directives can be disabled.
+		proc = NewProcessor(grouperGoimports{}, IgnoreDirectives(true))
+		errs, err = proc.ValidateAll("", strings.NewReader(text))
+		assert.Nil(t, err, c.text)
+		assert.NotEmpty(t, errs, c.text)
+	}
+
+	// Repairs leave suppressed imports in place, and files with only
+	// suppressed violations alone.
+	proc := NewProcessor(grouperGoimports{})
+	r, err := proc.Repair("", strings.NewReader("package main\n\nimport (\n\t\"strings\"\n\t\"os\" //nolint:gogroup\n)\n"))
+	assert.Nil(t, err)
+	assert.Nil(t, r)
+	r, err = proc.Repair("", strings.NewReader(
+		"package main\n\nimport (\n\t\"strings\"\n\t\"os\" //nolint:gogroup\n\t\"fmt\"\n)\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, "package main\n\nimport (\n\t\"fmt\"\n\t\"os\" //nolint:gogroup\n\t\"strings\"\n)\n",
+		readAll(t, r))
+}
+
+func TestValidateAll(t *testing.T) {
+	t.Parallel()
+
+	proc := NewProcessor(grouperGoimports{})
+	text := `package main
+import (
+	"strings"
+	"os"
+
+	"github.com/Sirupsen/logrus"
+
+
+	"golang.org/x/tools/imports"
+)`
+	errs, err := proc.ValidateAll("", strings.NewReader(text))
+	assert.Nil(t, err)
+	assert.Len(t, errs, 2)
+	assert.Equal(t, "statement-order", errs[0].Rule)
+	assert.Equal(t, 4, errs[0].Line)
+	assert.Equal(t, "statement-extra-line", errs[1].Rule)
+	assert.Equal(t, 9, errs[1].Line)
+
+	// The first violation is the one Validate reports.
+	validErr, err := proc.Validate("", strings.NewReader(text))
+	assert.Nil(t, err)
+	assert.Equal(t, errs[0], validErr)
+
+	errs, err = proc.ValidateAll("", strings.NewReader("package main\nimport \"os\"\n"))
+	assert.Nil(t, err)
+	assert.Empty(t, errs)
+}
+
+func TestValidateWith(t *testing.T) {
+	t.Parallel()
+
+	g, err := ParseSpec("std,other")
+	assert.Nil(t, err)
+	proc := NewProcessor(g)
+	local, err := ParseSpec("std,prefix=example.com/,other")
+	assert.Nil(t, err)
+	text := "package main\n\nimport (\n\t\"os\"\n\n\t\"example.com/a\"\n\t\"github.com/b/c\"\n)\n"
+
+	// Calls with different groupers run concurrently, without affecting the
+	// processor's own.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var g Grouper
+			if i%2 == 0 {
+				g = local
+			}
+			validErr, err := proc.ValidateWith(g, "", strings.NewReader(text))
+			assert.Nil(t, err)
+			assert.Equal(t, g != nil, validErr != nil)
+		}(i)
+	}
+	wg.Wait()
+
+	errs, err := proc.ValidateAllWith(local, "", strings.NewReader(text))
+	assert.Nil(t, err)
+	assert.Len(t, errs, 1)
+	errs, err = proc.ValidateAllWith(nil, "", strings.NewReader(text))
+	assert.Nil(t, err)
+	assert.Empty(t, errs)
+
+	r, err := proc.RepairWith(local, "", strings.NewReader(text))
+	assert.Nil(t, err)
+	if assert.NotNil(t, r) {
+		fixed, err := ioutil.ReadAll(r)
+		assert.Nil(t, err)
+		assert.Equal(t, "package main\n\nimport (\n\t\"os\"\n\n\t\"example.com/a\"\n\n\t\"github.com/b/c\"\n)\n",
+			string(fixed))
+	}
+	r, err = proc.RepairWith(nil, "", strings.NewReader(text))
+	assert.Nil(t, err)
+	assert.Nil(t, r)
+
+	// Headers are for the processor's own groups, so another grouper doesn't
+	// require them.
+	proc = NewProcessor(g, GroupHeaders(map[int]string{1: "// Third party"}))
+	text = "package main\n\nimport (\n\t\"os\"\n\n\t\"example.com/a\"\n\n\t\"github.com/b/c\"\n)\n"
+	validErr, err := proc.ValidateWith(local, "", strings.NewReader(text))
+	assert.Nil(t, err)
+	assert.Nil(t, validErr)
+	r, err = proc.RepairWith(local, "", strings.NewReader(text))
+	assert.Nil(t, err)
+	assert.Nil(t, r)
+	validErr, err = proc.ValidateWith(nil, "", strings.NewReader(text))
+	assert.Nil(t, err)
+	assert.NotNil(t, validErr)
+}
+
+func TestValidateParseError(t *testing.T) {
+	t.Parallel()
+
+	proc := NewProcessor(grouperGoimports{})
+	_, err := proc.Validate("broken.go", strings.NewReader("package main\nimport (\n"))
+	assert.IsType(t, &ParseError{}, err)
+	assert.Equal(t, "broken.go", err.(*ParseError).FileName)
+	assert.Equal(t, 2, err.(*ParseError).Line)
+	assert.Equal(t, 10, err.(*ParseError).Column)
+	assert.Contains(t, err.Error(), "broken.go:2:10:")
+	assert.False(t, errors.Is(err, ErrNoPackageClause))
+
+	_, err = proc.Reformat("broken.go", strings.NewReader("package main\nfunc {\n"))
+	assert.IsType(t, &ParseError{}, err)
+}
+
+func TestValidateNoPackageClause(t *testing.T) {
+	t.Parallel()
+
+	proc := NewProcessor(grouperGoimports{})
+	for _, src := range []string{
+		"",
+		"// +build linux\n\n",
+		"/* just a comment */",
+		"This is not Go.\n",
+	} {
+		_, err := proc.Validate("empty.go", strings.NewReader(src))
+		assert.IsType(t, &ParseError{}, err, src)
+		assert.True(t, errors.Is(err, ErrNoPackageClause), src)
+		assert.Equal(t, 0, err.(*ParseError).Line, src)
+		assert.Equal(t, "empty.go: file contains no package clause", err.Error(), src)
+
+		_, err = proc.Reformat("empty.go", strings.NewReader(src))
+		assert.True(t, errors.Is(err, ErrNoPackageClause), src)
+	}
+}
+
+// Generate a file with n imports, correctly grouped into standard library and
+// third-party imports unless they're to be shuffled.
+func benchmarkSource(n int, shuffled bool) []byte {
+	var std, other []string
+	for i := 0; i < n; i++ {
+		if i%3 == 0 {
+			std = append(std, fmt.Sprintf("\t\"std%04d/pkg\"\n", i))
+		} else {
+			other = append(other, fmt.Sprintf("\tname%d \"github.com/corp/repo%04d/pkg\" // comment\n", i, i))
+		}
+	}
+	var b strings.Builder
+	b.WriteString("// Package bench is generated.\npackage bench\n\nimport (\n")
+	if shuffled {
+		for i := range other {
+			b.WriteString(other[len(other)-1-i])
+			if i < len(std) {
+				b.WriteString(std[i])
+			}
+		}
+	} else {
+		b.WriteString(strings.Join(std, ""))
+		b.WriteString("\n")
+		b.WriteString(strings.Join(other, ""))
+	}
+	b.WriteString(")\n\nfunc main() {}\n")
+	return []byte(b.String())
+}
+
+func BenchmarkValidateAll(b *testing.B) {
+	proc := NewProcessor(grouperGoimports{})
+	for _, n := range []int{5, 50, 500} {
+		for _, shuffled := range []bool{false, true} {
+			name := fmt.Sprintf("valid-%d", n)
+			if shuffled {
+				name = fmt.Sprintf("invalid-%d", n)
+			}
+			src := benchmarkSource(n, shuffled)
+			b.Run(name, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					errs, err := proc.ValidateAll("bench.go", bytes.NewReader(src))
+					if err != nil || len(errs) == 0 && shuffled {
+						b.Fatal(errs, err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkProcessFiles(b *testing.B) {
+	dir, err := ioutil.TempDir("", "gogroup")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	proc := NewProcessor(grouperGoimports{})
+	for _, n := range []int{5, 50, 500} {
+		path := filepath.Join(dir, fmt.Sprintf("valid%d.go", n))
+		if err = ioutil.WriteFile(path, benchmarkSource(n, false), 0644); err != nil {
+			b.Fatal(err)
+		}
+		b.Run(fmt.Sprintf("valid-%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			paths := []string{path}
+			for i := 0; i < b.N; i++ {
+				if _, err := ProcessFiles(context.Background(), paths, proc, RunOptions{Concurrency: 1}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
 }
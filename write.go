@@ -0,0 +1,76 @@
+package gogroup
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Write a file atomically, so that it's never left partially written. The
+// content is written to a temporary file in the same directory, which then
+// replaces the original, keeping its permissions.
+//
+// If the temporary file can't be created or renamed, such as on some network
+// file systems, the file is written in place instead, and a warning is
+// yielded. If the context is cancelled before the file is replaced, it's left
+// unchanged.
+func writeFileAtomic(ctx context.Context, path string, data []byte) (warning string, err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return writeFileInPlace(path, data, err)
+	}
+	renamed := false
+	defer func() {
+		if !renamed {
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), info.Mode().Perm())
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return writeFileInPlace(path, data, err)
+	}
+	renamed = true
+	return "", nil
+}
+
+// Write a file in place, as a fallback when writing atomically fails. Yields a
+// warning mentioning why the fallback was needed.
+func writeFileInPlace(path string, data []byte, reason error) (warning string, err error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err = f.Write(data); err != nil {
+		return "", err
+	}
+	if err = f.Close(); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s: rewrote in place, since it couldn't be replaced atomically: %s",
+		path, reason.Error()), nil
+}
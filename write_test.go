@@ -0,0 +1,60 @@
+package gogroup
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "gogroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "a.go")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("old"), 0600))
+
+	warning, err := writeFileAtomic(context.Background(), path, []byte("new"))
+	assert.Nil(t, err)
+	assert.Equal(t, "", warning)
+	data, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "new", string(data))
+
+	// The mode is kept, and no temporary files are left over.
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	entries, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Len(t, entries, 1)
+
+	// Cancelling leaves the file unchanged.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = writeFileAtomic(ctx, path, []byte("newer"))
+	assert.Equal(t, context.Canceled, err)
+	data, err = ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "new", string(data))
+	entries, err = ioutil.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Len(t, entries, 1)
+
+	_, err = writeFileAtomic(context.Background(), filepath.Join(dir, "missing.go"), nil)
+	assert.NotNil(t, err)
+
+	// The fallback writes in place, with a warning.
+	warning, err = writeFileInPlace(path, []byte("fallback"), errors.New("rename failed"))
+	assert.Nil(t, err)
+	assert.Contains(t, warning, "rename failed")
+	data, err = ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "fallback", string(data))
+}